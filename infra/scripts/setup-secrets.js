@@ -21,10 +21,23 @@ const question = (prompt) => {
 
 const PARAMETERS = [
   {
-    name: 'jwt-secret',
-    description: 'JWT Secret for token signing',
+    name: 'jwt-keyset',
+    description: 'JWT signing key set (JSON, rotate with `go run ./services/auth-svc/cmd/jwtkeys rotate`)',
     secure: true,
-    defaultValue: () => require('crypto').randomBytes(32).toString('hex')
+    defaultValue: () => JSON.stringify({
+      keys: [{
+        private_key: require('crypto').generateKeyPairSync('ed25519', {
+          privateKeyEncoding: { type: 'pkcs8', format: 'pem' },
+          publicKeyEncoding: { type: 'spki', format: 'pem' }
+        }).privateKey
+      }]
+    })
+  },
+  {
+    name: 'mfa-encryption-key',
+    description: 'Key encrypting TOTP secrets at rest (base64, 32 bytes)',
+    secure: true,
+    defaultValue: () => require('crypto').randomBytes(32).toString('base64')
   },
   {
     name: 'gemini-api-key',
@@ -0,0 +1,50 @@
+// Command fakeoidc serves the oauthtest provider for trying social login
+// locally:
+//
+//	go run ./services/auth-svc/cmd/fakeoidc -addr :9000
+//
+//	OAUTH_PROVIDERS=dev OAUTH_DEV_ISSUER=http://localhost:9000 \
+//	OAUTH_DEV_CLIENT_ID=dev-client OAUTH_DEV_CLIENT_SECRET=dev-secret \
+//	go run ./services/auth-svc/cmd -http :8080
+//
+// Anyone can sign in as anyone. Never point a deployed stage at it.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/oauth/oauthtest"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "listen address")
+	issuer := flag.String("issuer", "", "issuer URL (default http://localhost<addr>)")
+	clientID := flag.String("client-id", "dev-client", "the only client ID accepted")
+	clientSecret := flag.String("client-secret", "dev-secret", "its secret")
+	email := flag.String("email", "dev@example.com", "email of the user who signs in unless login_hint is given")
+	name := flag.String("name", "Dev User", "name of the user who signs in")
+	unverified := flag.Bool("unverified", false, "report the email as unverified")
+	flag.Parse()
+
+	if *issuer == "" {
+		*issuer = "http://localhost" + *addr
+	}
+
+	provider, err := oauthtest.NewProvider(oauthtest.Config{
+		Issuer:          *issuer,
+		ClientID:        *clientID,
+		ClientSecret:    *clientSecret,
+		Email:           *email,
+		Name:            *name,
+		EmailUnverified: *unverified,
+		Logf:            log.Printf,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("fake OIDC provider %s listening on %s (client %q)", provider.Issuer(), *addr, *clientID)
+	log.Fatal(http.ListenAndServe(*addr, provider))
+}
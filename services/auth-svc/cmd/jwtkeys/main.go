@@ -0,0 +1,192 @@
+// Command jwtkeys manages the JSON key set the auth service signs tokens with
+// (JWT_KEYSET). Rotation adds a new key that starts signing after a publish
+// delay and retires the current keys after an overlap, so outstanding tokens
+// stay valid and no deploy has to happen at a precise moment.
+//
+//	jwtkeys generate -alg EdDSA > keyset.json
+//	jwtkeys rotate -f keyset.json
+//	jwtkeys prune -f keyset.json
+//	jwtkeys list -f keyset.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/shared/jwtkeys"
+)
+
+const usage = `usage: jwtkeys <command> [flags]
+
+commands:
+  generate  create a key set with a single signing key
+  rotate    add a new signing key and retire the current ones
+  prune     remove keys whose validity window has closed
+  list      show the keys in a key set
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "generate":
+		err = generate(os.Args[2:])
+	case "rotate":
+		err = rotate(os.Args[2:])
+	case "prune":
+		err = prune(os.Args[2:])
+	case "list":
+		err = list(os.Args[2:])
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "jwtkeys %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func generate(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	alg := fs.String("alg", jwtkeys.AlgorithmEdDSA, "signing algorithm (RS256, ES256 or EdDSA)")
+	out := fs.String("o", "-", "output file, - for stdout")
+	fs.Parse(args)
+
+	key, err := jwtkeys.GenerateSigningKey(*alg)
+	if err != nil {
+		return err
+	}
+	return writeKeySet(*out, []*jwtkeys.Key{key})
+}
+
+func rotate(args []string) error {
+	fs := flag.NewFlagSet("rotate", flag.ExitOnError)
+	file := fs.String("f", "-", "key set file to rotate in place, - for stdin/stdout")
+	alg := fs.String("alg", jwtkeys.AlgorithmEdDSA, "signing algorithm for the new key")
+	publishDelay := fs.Duration("publish-delay", time.Hour, "how long the new key is published before it signs; at least the JWKS cache lifetime")
+	overlap := fs.Duration("overlap", models.DefaultRefreshDuration+time.Hour, "how long retired keys keep verifying after the new key activates; at least the refresh token lifetime")
+	fs.Parse(args)
+
+	keys, err := readKeySet(*file)
+	if err != nil {
+		return err
+	}
+
+	next, err := jwtkeys.GenerateSigningKey(*alg)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	keys = jwtkeys.Rotate(jwtkeys.Prune(keys, now), next, now, *publishDelay, *overlap)
+
+	fmt.Fprintf(os.Stderr, "added key %s, signing from %s\n", next.ID, next.NotBefore.Format(time.RFC3339))
+	return writeKeySet(*file, keys)
+}
+
+func prune(args []string) error {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	file := fs.String("f", "-", "key set file to prune in place, - for stdin/stdout")
+	fs.Parse(args)
+
+	keys, err := readKeySet(*file)
+	if err != nil {
+		return err
+	}
+
+	kept := jwtkeys.Prune(keys, time.Now())
+	fmt.Fprintf(os.Stderr, "removed %d expired key(s)\n", len(keys)-len(kept))
+	return writeKeySet(*file, kept)
+}
+
+func list(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	file := fs.String("f", "-", "key set file, - for stdin")
+	fs.Parse(args)
+
+	keys, err := readKeySet(*file)
+	if err != nil {
+		return err
+	}
+
+	ks, err := jwtkeys.NewKeySet(keys)
+	if err != nil {
+		return err
+	}
+	signing := ks.SigningKey()
+	now := time.Now()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KID\tALG\tNOT BEFORE\tNOT AFTER\tSTATUS")
+	for _, key := range keys {
+		status := "retired"
+		switch {
+		case key == signing:
+			status = "active"
+		case key.ExpiredAt(now):
+			status = "expired"
+		case !key.ValidAt(now):
+			status = "pending"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", key.ID, key.Algorithm, formatTime(key.NotBefore), formatTime(key.NotAfter), status)
+	}
+	return w.Flush()
+}
+
+func readKeySet(path string) ([]*jwtkeys.Key, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return jwtkeys.ParseKeySetJSON(data)
+}
+
+func writeKeySet(path string, keys []*jwtkeys.Key) error {
+	data, err := jwtkeys.MarshalKeySetJSON(keys)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	// Write next to the target and rename so a failed write can't leave a
+	// truncated key set behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
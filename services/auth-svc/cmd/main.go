@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"net/http"
 	"strings"
@@ -11,13 +13,28 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/multitask-platform/backend/services/auth-svc/internal/handlers"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/mail"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/oauth"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/repositories"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/secretbox"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/services"
 	"github.com/multitask-platform/backend/shared/config"
+	domainevents "github.com/multitask-platform/backend/shared/events"
+	"github.com/multitask-platform/backend/shared/jwtkeys"
 	"github.com/multitask-platform/backend/shared/logger"
 	"github.com/multitask-platform/backend/shared/middleware"
+	"github.com/multitask-platform/backend/shared/ratelimit"
+	"github.com/multitask-platform/backend/shared/revocation"
+	"github.com/multitask-platform/backend/shared/router"
+	"github.com/multitask-platform/backend/shared/server"
 	"go.uber.org/zap"
 )
 
 func main() {
+	httpAddr := flag.String("http", "", "serve over net/http on this address (e.g. :8080) instead of running as a Lambda")
+	flag.Parse()
+
 	// Initialize configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -41,86 +58,487 @@ func main() {
 		zap.String("region", cfg.Region),
 	)
 
-	// Initialize handlers
-	authHandlers := handlers.NewAuthHandlers()
+	// Initialize repositories
+	repos, err := newRepositories(context.Background(), cfg)
+	if err != nil {
+		logger.Fatal("Failed to initialize repositories", zap.Error(err))
+	}
+
+	// Initialize mailer
+	mailer, err := newMailer(context.Background(), cfg)
+	if err != nil {
+		logger.Fatal("Failed to initialize mailer", zap.Error(err))
+	}
+
+	// Initialize audit log
+	auditSink, err := newAuditSink(cfg, repos)
+	if err != nil {
+		logger.Fatal("Failed to initialize audit log", zap.Error(err))
+	}
+
+	// Initialize domain event publishing
+	eventPublisher, err := newEventPublisher(context.Background(), cfg)
+	if err != nil {
+		logger.Fatal("Failed to initialize event publishing", zap.Error(err))
+	}
+
+	// Initialize the relay from the outbox to the event bus. Deployed as its
+	// own scheduled Lambda, it does nothing else.
+	relay := services.NewOutboxRelay(repos.outbox, eventPublisher, services.OutboxRelayConfig{
+		BatchSize:   cfg.Outbox.BatchSize,
+		MaxAttempts: cfg.Outbox.MaxAttempts,
+	})
+	if cfg.Outbox.RelayOnly {
+		lambda.Start(newRelayHandler(relay))
+		return
+	}
+
+	// Initialize signing keys
+	keys, err := newKeySet(cfg)
+	if err != nil {
+		logger.Fatal("Failed to load JWT signing keys", zap.Error(err))
+	}
+
+	// Initialize access token revocation
+	revocations, err := newRevocationChecker(context.Background(), cfg)
+	if err != nil {
+		logger.Fatal("Failed to initialize token revocation", zap.Error(err))
+	}
+
+	// Initialize encryption for second factor secrets
+	secrets, err := newSecretBox(cfg)
+	if err != nil {
+		logger.Fatal("Failed to initialize MFA encryption", zap.Error(err))
+	}
+
+	// Initialize social login providers
+	oauthProviders, err := newOAuthProviders(cfg)
+	if err != nil {
+		logger.Fatal("Failed to initialize OAuth providers", zap.Error(err))
+	}
+
+	// Initialize service and handlers
+	authService, err := services.NewAuthService(cfg, repos.users, repos.sessions,
+		services.WithTokenIssuer(services.NewKeyTokenIssuer(keys, time.Now)),
+		services.WithMailer(mailer),
+		services.WithRevocations(revocations),
+		services.WithSecretBox(secrets),
+		services.WithCredentialRepository(repos.credentials),
+		services.WithIdentityRepository(repos.identities),
+		services.WithClientRepository(repos.clients),
+		services.WithAuditSink(auditSink),
+		services.WithOAuthProviders(oauthProviders...),
+	)
+	if err != nil {
+		logger.Fatal("Failed to initialize auth service", zap.Error(err))
+	}
+	authHandlers := handlers.NewAuthHandlers(authService)
+	jwksHandlers := handlers.NewJWKSHandlers(keys)
+	authOpts := []middleware.AuthenticatorOption{middleware.WithRevocationChecker(revocations)}
+	if cfg.Revocation.FailOpen {
+		authOpts = append(authOpts, middleware.WithRevocationFailOpen())
+	}
+	authenticator := middleware.NewAuthenticator(keys, authOpts...)
+
+	// Initialize rate limiting
+	rateLimiter, err := newRateLimiter(context.Background(), cfg)
+	if err != nil {
+		logger.Fatal("Failed to initialize rate limiter", zap.Error(err))
+	}
+
+	// Denied requests go to the audit log next to the admin actions
+	policy := middleware.DefaultPolicy().WithDenialHook(auditDenials(authService))
 
 	// Create router with middleware
-	router := createRouter(authHandlers)
+	router := createRouter(authHandlers, jwksHandlers, authenticator, policy, rateLimiter)
+
+	// Run as a standalone HTTP server when requested
+	if *httpAddr != "" {
+		relayCtx, stopRelay := context.WithCancel(context.Background())
+		go relay.Run(relayCtx, cfg.Outbox.PollInterval)
+
+		err := server.ListenAndServe(context.Background(), *httpAddr, router, cfg)
+		stopRelay()
+		if err != nil {
+			logger.Fatal("HTTP server failed", zap.Error(err))
+		}
+		return
+	}
 
 	// Start Lambda function
 	lambda.Start(router)
 }
 
+// authRepositories are the stores the auth service is built on
+type authRepositories struct {
+	users       repositories.UserRepository
+	sessions    repositories.SessionRepository
+	credentials repositories.CredentialRepository
+	identities  repositories.IdentityRepository
+	clients     repositories.ClientRepository
+	audit       repositories.AuditRepository
+	outbox      repositories.OutboxRepository
+}
+
+// newRepositories returns DynamoDB-backed repositories. Development stages
+// without the auth tables configured fall back to in-memory ones.
+func newRepositories(ctx context.Context, cfg *config.Config) (*authRepositories, error) {
+	if cfg.DynamoDB.AuthSessions == "" {
+		if !cfg.IsDevelopment() {
+			return nil, errors.New("DYNAMODB_TABLE_AUTH_SESSIONS not configured")
+		}
+		logger.Warn("DynamoDB auth tables not configured, using in-memory repositories")
+		outbox := repositories.NewMemoryOutboxRepository()
+		return &authRepositories{
+			users:       repositories.NewMemoryUserRepository(outbox),
+			sessions:    repositories.NewMemorySessionRepository(outbox),
+			credentials: repositories.NewMemoryCredentialRepository(),
+			identities:  repositories.NewMemoryIdentityRepository(),
+			clients:     repositories.NewMemoryClientRepository(),
+			audit:       repositories.NewMemoryAuditRepository(),
+			outbox:      outbox,
+		}, nil
+	}
+
+	client, err := repositories.NewDynamoDBClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &authRepositories{
+		users:       repositories.NewDynamoDBUserRepository(client, cfg.DynamoDB.AuthSessions),
+		sessions:    repositories.NewDynamoDBSessionRepository(client, cfg.DynamoDB.AuthSessions, cfg.DynamoDB.AuthAnonymous),
+		credentials: repositories.NewDynamoDBCredentialRepository(client, cfg.DynamoDB.AuthSessions),
+		identities:  repositories.NewDynamoDBIdentityRepository(client, cfg.DynamoDB.AuthSessions),
+		clients:     repositories.NewDynamoDBClientRepository(client, cfg.DynamoDB.AuthSessions),
+		audit:       repositories.NewDynamoDBAuditRepository(client, cfg.DynamoDB.AuthSessions),
+		outbox:      repositories.NewDynamoDBOutboxRepository(client, cfg.DynamoDB.AuthSessions),
+	}, nil
+}
+
+// newAuditSink keeps the audit log with the rest of the auth data unless a
+// local file is asked for
+func newAuditSink(cfg *config.Config, repos *authRepositories) (services.AuditSink, error) {
+	switch cfg.Audit.Driver {
+	case "repository", "":
+		return services.NewRepositoryAuditSink(repos.audit), nil
+	case "file":
+		return services.NewFileAuditSink(cfg.Audit.File)
+	default:
+		return nil, fmt.Errorf("unknown AUDIT_DRIVER %q", cfg.Audit.Driver)
+	}
+}
+
+// newEventPublisher publishes domain events to EventBridge when a bus is
+// configured. Otherwise they go to an in-memory bus nothing else can see.
+func newEventPublisher(ctx context.Context, cfg *config.Config) (services.EventPublisher, error) {
+	if cfg.EventBridge.BusName == "" {
+		logger.Warn("EVENTBRIDGE_BUS_NAME not configured, domain events stay in process")
+		return domainevents.NewMemoryBus(), nil
+	}
+
+	client, err := domainevents.NewEventBridgeClient(ctx, cfg.Region, cfg.EventBridge.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return domainevents.NewEventBridgePublisher(client, cfg.EventBridge.BusName), nil
+}
+
+// newRelayHandler drains the outbox each time the relay's schedule fires
+func newRelayHandler(relay *services.OutboxRelay) func(context.Context, events.CloudWatchEvent) error {
+	return func(ctx context.Context, _ events.CloudWatchEvent) error {
+		published, err := relay.Drain(ctx)
+		logger.InfoCtx(ctx, "Outbox drained", zap.Int("published", published))
+		return err
+	}
+}
+
+// newKeySet loads the JWT signing key. Development stages without a
+// configured key fall back to a throwaway Ed25519 key, which invalidates every
+// token on restart and can't be verified by other services.
+func newKeySet(cfg *config.Config) (*jwtkeys.KeySet, error) {
+	keys, err := jwtkeys.LoadSigningKeySet(cfg)
+	if err == nil || !errors.Is(err, jwtkeys.ErrNoSigningKey) || !cfg.IsDevelopment() {
+		return keys, err
+	}
+
+	logger.Warn("JWT_KEYSET and JWT_PRIVATE_KEY not configured, signing with an ephemeral key")
+	key, err := jwtkeys.GenerateSigningKey(jwtkeys.AlgorithmEdDSA)
+	if err != nil {
+		return nil, err
+	}
+	return jwtkeys.NewKeySet([]*jwtkeys.Key{key})
+}
+
+// newSecretBox loads the key TOTP secrets are encrypted with. Development
+// stages without a key fall back to a random one, which breaks every TOTP
+// enrollment on restart.
+func newSecretBox(cfg *config.Config) (*secretbox.Box, error) {
+	if cfg.MFA.EncryptionKey != "" {
+		return secretbox.NewFromBase64(cfg.MFA.EncryptionKey)
+	}
+	if !cfg.IsDevelopment() {
+		return nil, errors.New("MFA_ENCRYPTION_KEY not configured")
+	}
+
+	logger.Warn("MFA_ENCRYPTION_KEY not configured, encrypting TOTP secrets with an ephemeral key")
+	return secretbox.Generate()
+}
+
+// newOAuthProviders creates a social login provider for each entry in
+// OAUTH_PROVIDERS. OIDC providers are discovered on first use, so an
+// unreachable issuer doesn't stop the service from starting.
+func newOAuthProviders(cfg *config.Config) ([]oauth.Provider, error) {
+	client := &http.Client{Timeout: cfg.Timeouts.HTTPTimeout}
+
+	providers := make([]oauth.Provider, 0, len(cfg.OAuth.Providers))
+	for _, p := range cfg.OAuth.Providers {
+		provider, err := oauth.New(oauth.Config{
+			Name:         p.Name,
+			Type:         p.Type,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			Issuer:       p.Issuer,
+			Scopes:       p.Scopes,
+		}, client)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+// newMailer picks the delivery mechanism from MAIL_DRIVER: "smtp" for a relay
+// (MailHog on localhost:1025 by default), "file" for a local Maildir, "ses"
+// for Amazon SES, and "log" to only log that an email would be sent. Since
+// "log" delivers nothing, it is refused outside development.
+func newMailer(ctx context.Context, cfg *config.Config) (services.Mailer, error) {
+	var sender mail.Sender
+
+	switch cfg.Mail.Driver {
+	case "log", "":
+		if !cfg.IsDevelopment() {
+			return nil, errors.New("MAIL_DRIVER must be smtp, file or ses outside development")
+		}
+		return services.NewLogMailer(), nil
+	case "smtp":
+		sender = mail.NewSMTPSender(mail.SMTPConfig{
+			Host:     cfg.Mail.SMTPHost,
+			Port:     cfg.Mail.SMTPPort,
+			Username: cfg.Mail.SMTPUsername,
+			Password: cfg.Mail.SMTPPassword,
+		})
+	case "file":
+		fileSender, err := mail.NewFileSender(cfg.Mail.Dir)
+		if err != nil {
+			return nil, err
+		}
+		sender = fileSender
+	case "ses":
+		client, err := mail.NewSESClient(ctx, cfg.Mail.SESRegion)
+		if err != nil {
+			return nil, err
+		}
+		sender = mail.NewSESSender(client, cfg.Mail.SESConfigurationSet)
+	default:
+		return nil, fmt.Errorf("unknown MAIL_DRIVER %q", cfg.Mail.Driver)
+	}
+
+	return services.NewTemplateMailer(sender, services.MailerConfig{
+		From:        cfg.Mail.From,
+		BaseURL:     cfg.Mail.BaseURL,
+		ProductName: cfg.Mail.ProductName,
+	})
+}
+
+// newRevocationChecker keeps the access token denylist in DynamoDB when the
+// revocations table is configured, so other services see logouts too, and in
+// memory otherwise
+func newRevocationChecker(ctx context.Context, cfg *config.Config) (*revocation.Checker, error) {
+	checker, err := revocation.LoadChecker(ctx, cfg)
+	if err != nil || checker != nil {
+		return checker, err
+	}
+	return revocation.NewChecker(revocation.NewMemoryStore(), cfg.Revocation.CacheTTL), nil
+}
+
+// newRateLimiter applies the configured per-IP limit to every route and
+// stricter limits to endpoints that are attractive for brute force or spam.
+// Buckets live in DynamoDB when the rate limit table is configured so limits
+// hold across Lambda instances.
+func newRateLimiter(ctx context.Context, cfg *config.Config) (*middleware.RateLimiter, error) {
+	var store ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.DynamoDB.AuthRateLimits != "" {
+		client, err := repositories.NewDynamoDBClient(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		store = ratelimit.NewDynamoDBStore(client, cfg.DynamoDB.AuthRateLimits)
+	}
+
+	loginLimit := ratelimit.Limit{RequestsPerMinute: 5, Burst: 5}
+	emailLimit := ratelimit.Limit{RequestsPerMinute: 3, Burst: 3}
+
+	opts := []middleware.RateLimiterOption{
+		middleware.WithRouteLimit("/v1/auth/login", loginLimit),
+		middleware.WithRouteLimit("/v1/auth/login/mfa", loginLimit),
+		middleware.WithRouteLimit("/v1/auth/login/mfa/email", emailLimit),
+		middleware.WithRouteLimit("/v1/auth/login/magic-link", emailLimit),
+		middleware.WithRouteLimit("/v1/auth/login/magic-link/verify", loginLimit),
+		middleware.WithRouteLimit("/v1/auth/webauthn/login/finish", loginLimit),
+		middleware.WithRouteLimit("/v1/auth/forgot-password", emailLimit),
+		middleware.WithRouteLimit("/v1/auth/resend-verification", emailLimit),
+		middleware.WithRouteLimit("/v1/auth/reset-password", loginLimit),
+		middleware.WithRouteLimit("/v1/auth/verify-email", loginLimit),
+		middleware.WithRouteLimit("/v1/auth/oauth2/token", loginLimit),
+		middleware.WithRouteLimit("/v1/auth/oauth2/introspect", loginLimit),
+		middleware.WithRouteLimit("/v1/auth/oauth/{provider}/callback", loginLimit),
+	}
+
+	return middleware.NewRateLimiter(store,
+		ratelimit.Limit{
+			RequestsPerMinute: cfg.RateLimit.RequestsPerMinute,
+			Burst:             cfg.RateLimit.BurstSize,
+		},
+		opts...,
+	), nil
+}
+
+// auditDenials records the requests a Policy denies in the audit log
+func auditDenials(authService *services.AuthService) middleware.DenialHook {
+	return func(ctx context.Context, denial *middleware.Denial) {
+		details := map[string]string{
+			"method": denial.Method,
+			"path":   denial.Path,
+		}
+		if denial.ClientID != "" {
+			details["client_id"] = denial.ClientID
+		}
+		if len(denial.RequiredRoles) > 0 {
+			details["required_roles"] = strings.Join(denial.RequiredRoles, " ")
+		}
+		if len(denial.RequiredPermissions) > 0 {
+			details["required_permissions"] = strings.Join(denial.RequiredPermissions, " ")
+		}
+
+		authService.RecordAccessDenied(ctx, denial.UserID, denial.SessionID, denial.SourceIP, details)
+	}
+}
+
 // createRouter sets up the HTTP routing with middleware
-func createRouter(authHandlers *handlers.AuthHandlers) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+func createRouter(authHandlers *handlers.AuthHandlers, jwksHandlers *handlers.JWKSHandlers, authenticator *middleware.Authenticator, policy *middleware.Policy, rateLimiter *middleware.RateLimiter) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	r := router.New("/v1/auth")
+
+	// Rate limits run once the route is resolved, so per-route limits apply
+	// to the route a request reaches rather than to the path it spells out
+	r.Use(rateLimiter.Middleware)
+
+	// Authentication endpoints
+	r.POST("/login", authHandlers.Login)
+	r.POST("/login/mfa", authHandlers.LoginMFA)
+	r.POST("/login/mfa/email", authHandlers.SendLoginCode)
+	r.POST("/login/magic-link", authHandlers.RequestMagicLink)
+	r.POST("/login/magic-link/verify", authHandlers.VerifyMagicLink)
+	r.POST("/register", authHandlers.Register)
+	r.POST("/refresh", authHandlers.RefreshToken)
+
+	// Password management
+	r.POST("/forgot-password", authHandlers.ForgotPassword)
+	r.POST("/reset-password", authHandlers.ResetPassword)
+
+	// Email verification
+	r.POST("/verify-email", authHandlers.VerifyEmail)
+	r.POST("/resend-verification", authHandlers.ResendVerification)
+
+	// Passwordless login with a passkey
+	r.POST("/webauthn/login/begin", authHandlers.BeginPasskeyLogin)
+	r.POST("/webauthn/login/finish", authHandlers.FinishPasskeyLogin)
+
+	// Social login with an external OAuth/OIDC provider
+	r.POST("/oauth/{provider}/start", authHandlers.StartOAuthLogin)
+	r.POST("/oauth/{provider}/callback", authHandlers.OAuthCallback)
+
+	// OAuth2 authorization server for third-party applications. Clients
+	// authenticate themselves, so these sit outside the authed group.
+	r.GET("/oauth2/authorize", authHandlers.OAuth2Authorize)
+	r.POST("/oauth2/token", authHandlers.OAuth2Token)
+	r.POST("/oauth2/introspect", authHandlers.OAuth2Introspect)
+	r.POST("/oauth2/revoke", authHandlers.OAuth2Revoke)
+
+	// Anonymous session management
+	r.POST("/anonymous", authHandlers.CreateAnonymousSession)
+
+	// Health check
+	r.GET("/health", healthCheck)
+
+	// Public signing keys and discovery, served at the root as well as under
+	// /v1/auth
+	for _, wellKnown := range []*router.Group{r.Group("/.well-known"), r.Absolute("/.well-known")} {
+		wellKnown.GET("/jwks.json", jwksHandlers.GetJWKS)
+		wellKnown.GET("/openid-configuration", authHandlers.GetOpenIDConfiguration)
+	}
+
+	// Authenticated endpoints. The authenticator only takes first-party
+	// tokens, so tokens held by OAuth2 clients can't manage the account they
+	// were issued for.
+	authed := r.Group("", authenticator.Middleware)
+	authed.POST("/logout", authHandlers.Logout)
+	authed.POST("/change-password", authHandlers.ChangePassword)
+	authed.GET("/me", authHandlers.GetCurrentUser)
+
+	// Session management
+	sessions := authed.Group("/sessions")
+	sessions.GET("", authHandlers.GetUserSessions)
+	sessions.DELETE("/{id}", authHandlers.RevokeSession)
+
+	// Two-factor authentication
+	mfa := authed.Group("/mfa")
+	mfa.POST("/totp/setup", authHandlers.SetupTOTP)
+	mfa.POST("/totp/verify", authHandlers.VerifyTOTP)
+	mfa.POST("/recovery-codes", authHandlers.RegenerateRecoveryCodes)
+
+	// Passkey management
+	passkeys := authed.Group("/webauthn")
+	passkeys.POST("/register/begin", authHandlers.BeginPasskeyRegistration)
+	passkeys.POST("/register/finish", authHandlers.FinishPasskeyRegistration)
+	passkeys.GET("/credentials", authHandlers.GetPasskeys)
+	passkeys.DELETE("/credentials/{id}", authHandlers.DeletePasskey)
+
+	// OAuth2 consent and client registration
+	oauth2 := authed.Group("/oauth2")
+	oauth2.POST("/authorize", authHandlers.OAuth2AuthorizeDecision)
+	oauth2.POST("/clients", authHandlers.RegisterOAuth2Client)
+	oauth2.GET("/clients", authHandlers.GetOAuth2Clients)
+	oauth2.DELETE("/clients/{id}", authHandlers.DeleteOAuth2Client)
+
+	// Account administration, gated by the permissions of the caller's roles
+	admin := authed.Group("/admin/users")
+	adminRead := admin.Group("", policy.RequirePermission(models.PermissionUsersRead))
+	adminRead.GET("", authHandlers.AdminListUsers)
+	adminRead.GET("/{id}", authHandlers.AdminGetUser)
+	adminWrite := admin.Group("", policy.RequirePermission(models.PermissionUsersWrite))
+	adminWrite.POST("/{id}/roles", authHandlers.AdminAssignRole)
+	adminWrite.DELETE("/{id}/roles/{role}", authHandlers.AdminRemoveRole)
+	adminWrite.POST("/{id}/disable", authHandlers.AdminDisableUser)
+	adminWrite.POST("/{id}/enable", authHandlers.AdminEnableUser)
+	adminWrite.POST("/{id}/logout", authHandlers.AdminLogoutUser)
+	adminWrite.POST("/{id}/password-reset", authHandlers.AdminResetPassword)
+	admin.DELETE("/{id}", policy.RequirePermission(models.PermissionUsersDelete)(authHandlers.AdminDeleteUser))
+	authed.GET("/admin/audit", policy.RequirePermission(models.PermissionAuditRead)(authHandlers.AdminQueryAuditLog))
+
+	// OpenID Connect userinfo, for any user's token granted the openid scope
+	userinfo := r.Group("",
+		authenticator.Accepting(middleware.FirstPartyTokens|middleware.DelegatedTokens).Middleware,
+		middleware.RequireScope(models.ScopeOpenID),
+	)
+	userinfo.GET("/userinfo", authHandlers.UserInfo)
+	userinfo.POST("/userinfo", authHandlers.UserInfo)
+
 	return middleware.Chain(
 		middleware.CORSMiddleware,
 		middleware.RequestLoggingMiddleware,
-		middleware.RateLimitMiddleware,
-	)(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-		// Parse the path to determine the route
-		path := strings.TrimPrefix(request.Path, "/v1/auth")
-		method := request.HTTPMethod
-
-		logger.DebugCtx(ctx, "Routing request",
-			zap.String("method", method),
-			zap.String("path", path),
-		)
-
-		// Route to appropriate handler
-		switch {
-		// Authentication endpoints
-		case path == "/login" && method == "POST":
-			return authHandlers.Login(ctx, request)
-		case path == "/logout" && method == "POST":
-			return middleware.AuthMiddleware(authHandlers.Logout)(ctx, request)
-		case path == "/register" && method == "POST":
-			return authHandlers.Register(ctx, request)
-		case path == "/refresh" && method == "POST":
-			return authHandlers.RefreshToken(ctx, request)
-
-		// Password management
-		case path == "/forgot-password" && method == "POST":
-			return authHandlers.ForgotPassword(ctx, request)
-		case path == "/reset-password" && method == "POST":
-			return authHandlers.ResetPassword(ctx, request)
-		case path == "/change-password" && method == "POST":
-			return middleware.AuthMiddleware(authHandlers.ChangePassword)(ctx, request)
-
-		// Email verification
-		case path == "/verify-email" && method == "POST":
-			return authHandlers.VerifyEmail(ctx, request)
-		case path == "/resend-verification" && method == "POST":
-			return authHandlers.ResendVerification(ctx, request)
-
-		// User info
-		case path == "/me" && method == "GET":
-			return middleware.AuthMiddleware(authHandlers.GetCurrentUser)(ctx, request)
-
-		// Anonymous session management
-		case path == "/anonymous" && method == "POST":
-			return authHandlers.CreateAnonymousSession(ctx, request)
-
-		// Health check
-		case path == "/health" && method == "GET":
-			return healthCheck(ctx, request)
-
-		// Session management
-		case strings.HasPrefix(path, "/sessions") && method == "GET":
-			return middleware.AuthMiddleware(authHandlers.GetUserSessions)(ctx, request)
-		case strings.HasPrefix(path, "/sessions/") && method == "DELETE":
-			return middleware.AuthMiddleware(authHandlers.RevokeSession)(ctx, request)
-
-		default:
-			return events.APIGatewayProxyResponse{
-				StatusCode: http.StatusNotFound,
-				Headers: map[string]string{
-					"Content-Type": "application/json",
-				},
-				Body: `{"error":"endpoint not found"}`,
-			}, nil
-		}
-	})
+	)(r.ServeProxy)
 }
 
 // healthCheck returns service health status
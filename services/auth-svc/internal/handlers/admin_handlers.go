@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/services"
+	"github.com/multitask-platform/backend/shared/logger"
+	"github.com/multitask-platform/backend/shared/middleware"
+	"github.com/multitask-platform/backend/shared/router"
+)
+
+// AdminListUsers lists users a page at a time. Query parameters: q to
+// search by email or name, limit and cursor.
+func (h *AuthHandlers) AdminListUsers(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing admin list users request")
+
+	// Get user from context
+	userClaims := middleware.GetUserClaims(ctx)
+	if userClaims == nil {
+		return h.errorResponse(http.StatusUnauthorized, "unauthorized"), nil
+	}
+
+	query := request.QueryStringParameters
+	listReq := &models.ListUsersRequest{
+		Query:  query["q"],
+		Cursor: query["cursor"],
+	}
+	if limit := query["limit"]; limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return h.errorResponse(http.StatusBadRequest, "limit must be a positive integer"), nil
+		}
+		listReq.Limit = n
+	}
+
+	list, err := h.authService.ListUsers(ctx, userClaims.UserID, listReq)
+	if err != nil {
+		logger.ErrorCtx(ctx, "Failed to list users", zap.Error(err))
+
+		switch err {
+		case services.ErrInvalidCursor:
+			return h.errorResponse(http.StatusBadRequest, "invalid cursor"), nil
+		default:
+			return h.errorResponse(http.StatusInternalServerError, "failed to list users"), nil
+		}
+	}
+
+	return h.successResponse(http.StatusOK, list), nil
+}
+
+// AdminGetUser returns a user with their MFA status and active sessions
+func (h *AuthHandlers) AdminGetUser(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing admin get user request")
+
+	userClaims := middleware.GetUserClaims(ctx)
+	if userClaims == nil {
+		return h.errorResponse(http.StatusUnauthorized, "unauthorized"), nil
+	}
+
+	details, err := h.authService.GetUserDetails(ctx, userClaims.UserID, router.PathParam(ctx, "id"))
+	if err != nil {
+		logger.ErrorCtx(ctx, "Failed to get user details", zap.Error(err))
+		return h.adminErrorResponse(err, "failed to get user"), nil
+	}
+
+	return h.successResponse(http.StatusOK, details), nil
+}
+
+// AdminAssignRole gives a user a role
+func (h *AuthHandlers) AdminAssignRole(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing admin assign role request")
+
+	userClaims := middleware.GetUserClaims(ctx)
+	if userClaims == nil {
+		return h.errorResponse(http.StatusUnauthorized, "unauthorized"), nil
+	}
+
+	// Parse request body
+	var roleReq models.RoleRequest
+	if err := json.Unmarshal([]byte(request.Body), &roleReq); err != nil {
+		logger.WarnCtx(ctx, "Invalid assign role request body", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, "invalid request body"), nil
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&roleReq); err != nil {
+		logger.WarnCtx(ctx, "Assign role request validation failed", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, "validation failed: "+err.Error()), nil
+	}
+
+	user, err := h.authService.AssignRole(ctx, userClaims.UserID, router.PathParam(ctx, "id"), roleReq.Role)
+	if err != nil {
+		logger.WarnCtx(ctx, "Failed to assign role", zap.Error(err))
+		return h.adminErrorResponse(err, "failed to assign role"), nil
+	}
+
+	return h.successResponse(http.StatusOK, user), nil
+}
+
+// AdminRemoveRole takes a role away from a user
+func (h *AuthHandlers) AdminRemoveRole(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing admin remove role request")
+
+	userClaims := middleware.GetUserClaims(ctx)
+	if userClaims == nil {
+		return h.errorResponse(http.StatusUnauthorized, "unauthorized"), nil
+	}
+
+	user, err := h.authService.RemoveRole(ctx, userClaims.UserID, router.PathParam(ctx, "id"), router.PathParam(ctx, "role"))
+	if err != nil {
+		logger.WarnCtx(ctx, "Failed to remove role", zap.Error(err))
+		return h.adminErrorResponse(err, "failed to remove role"), nil
+	}
+
+	return h.successResponse(http.StatusOK, user), nil
+}
+
+// AdminDisableUser disables an account and logs the user out everywhere
+func (h *AuthHandlers) AdminDisableUser(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return h.setUserActive(ctx, false)
+}
+
+// AdminEnableUser re-enables a disabled account
+func (h *AuthHandlers) AdminEnableUser(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return h.setUserActive(ctx, true)
+}
+
+func (h *AuthHandlers) setUserActive(ctx context.Context, active bool) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing admin set user active request", zap.Bool("active", active))
+
+	userClaims := middleware.GetUserClaims(ctx)
+	if userClaims == nil {
+		return h.errorResponse(http.StatusUnauthorized, "unauthorized"), nil
+	}
+
+	user, err := h.authService.SetUserActive(ctx, userClaims.UserID, router.PathParam(ctx, "id"), active)
+	if err != nil {
+		logger.WarnCtx(ctx, "Failed to set user active", zap.Error(err))
+		return h.adminErrorResponse(err, "failed to update user"), nil
+	}
+
+	return h.successResponse(http.StatusOK, user), nil
+}
+
+// AdminLogoutUser ends all of a user's sessions
+func (h *AuthHandlers) AdminLogoutUser(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing admin logout user request")
+
+	userClaims := middleware.GetUserClaims(ctx)
+	if userClaims == nil {
+		return h.errorResponse(http.StatusUnauthorized, "unauthorized"), nil
+	}
+
+	err := h.authService.ForceLogout(ctx, userClaims.UserID, router.PathParam(ctx, "id"))
+	if err != nil {
+		logger.ErrorCtx(ctx, "Failed to log user out", zap.Error(err))
+		return h.adminErrorResponse(err, "failed to log user out"), nil
+	}
+
+	return h.successResponse(http.StatusOK, map[string]string{"message": "user logged out of all sessions"}), nil
+}
+
+// AdminResetPassword emails a user a password reset link
+func (h *AuthHandlers) AdminResetPassword(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing admin password reset request")
+
+	userClaims := middleware.GetUserClaims(ctx)
+	if userClaims == nil {
+		return h.errorResponse(http.StatusUnauthorized, "unauthorized"), nil
+	}
+
+	err := h.authService.SendPasswordReset(ctx, userClaims.UserID, router.PathParam(ctx, "id"))
+	if err != nil {
+		logger.ErrorCtx(ctx, "Failed to send password reset", zap.Error(err))
+		return h.adminErrorResponse(err, "failed to send password reset"), nil
+	}
+
+	return h.successResponse(http.StatusOK, map[string]string{"message": "password reset email sent"}), nil
+}
+
+// AdminDeleteUser deletes an account
+func (h *AuthHandlers) AdminDeleteUser(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing admin delete user request")
+
+	userClaims := middleware.GetUserClaims(ctx)
+	if userClaims == nil {
+		return h.errorResponse(http.StatusUnauthorized, "unauthorized"), nil
+	}
+
+	err := h.authService.DeleteUser(ctx, userClaims.UserID, router.PathParam(ctx, "id"))
+	if err != nil {
+		logger.ErrorCtx(ctx, "Failed to delete user", zap.Error(err))
+		return h.adminErrorResponse(err, "failed to delete user"), nil
+	}
+
+	return h.successResponse(http.StatusOK, map[string]string{"message": "user deleted successfully"}), nil
+}
+
+// AdminQueryAuditLog returns the newest audit log events. Query parameters:
+// user_id, action, from and to (RFC 3339) and limit.
+func (h *AuthHandlers) AdminQueryAuditLog(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing admin audit log request")
+
+	userClaims := middleware.GetUserClaims(ctx)
+	if userClaims == nil {
+		return h.errorResponse(http.StatusUnauthorized, "unauthorized"), nil
+	}
+
+	params := request.QueryStringParameters
+	query := &models.AuditQuery{
+		UserID: params["user_id"],
+		Action: params["action"],
+	}
+	for name, dst := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		if value := params[name]; value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return h.errorResponse(http.StatusBadRequest, name+" must be an RFC 3339 time"), nil
+			}
+			*dst = t
+		}
+	}
+	if limit := params["limit"]; limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return h.errorResponse(http.StatusBadRequest, "limit must be a positive integer"), nil
+		}
+		query.Limit = n
+	}
+
+	auditLog, err := h.authService.QueryAuditLog(ctx, userClaims.UserID, query)
+	if err != nil {
+		logger.ErrorCtx(ctx, "Failed to query audit log", zap.Error(err))
+		return h.errorResponse(http.StatusInternalServerError, "failed to query audit log"), nil
+	}
+
+	return h.successResponse(http.StatusOK, auditLog), nil
+}
+
+// adminErrorResponse maps the errors shared by the admin actions on a user
+func (h *AuthHandlers) adminErrorResponse(err error, message string) events.APIGatewayProxyResponse {
+	switch err {
+	case services.ErrUserNotFound:
+		return h.errorResponse(http.StatusNotFound, "user not found")
+	case services.ErrInvalidRole:
+		return h.errorResponse(http.StatusBadRequest, "invalid role")
+	case services.ErrAdminSelfAction:
+		return h.errorResponse(http.StatusConflict, "admins cannot disable, delete or demote themselves")
+	case services.ErrUserDisabled:
+		return h.errorResponse(http.StatusConflict, "account disabled")
+	default:
+		return h.errorResponse(http.StatusInternalServerError, message)
+	}
+}
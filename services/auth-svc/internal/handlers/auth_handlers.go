@@ -3,8 +3,8 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
-	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/go-playground/validator/v10"
@@ -14,6 +14,7 @@ import (
 	"github.com/multitask-platform/backend/services/auth-svc/internal/services"
 	"github.com/multitask-platform/backend/shared/logger"
 	"github.com/multitask-platform/backend/shared/middleware"
+	"github.com/multitask-platform/backend/shared/router"
 )
 
 // AuthHandlers contains all auth-related HTTP handlers
@@ -23,9 +24,9 @@ type AuthHandlers struct {
 }
 
 // NewAuthHandlers creates a new instance of AuthHandlers
-func NewAuthHandlers() *AuthHandlers {
+func NewAuthHandlers(authService *services.AuthService) *AuthHandlers {
 	return &AuthHandlers{
-		authService: services.NewAuthService(),
+		authService: authService,
 		validator:   validator.New(),
 	}
 }
@@ -47,14 +48,26 @@ func (h *AuthHandlers) Login(ctx context.Context, request events.APIGatewayProxy
 		return h.errorResponse(http.StatusBadRequest, "validation failed: "+err.Error()), nil
 	}
 
+	loginReq.IPAddress = request.RequestContext.Identity.SourceIP
+	loginReq.UserAgent = request.RequestContext.Identity.UserAgent
+
 	// Authenticate user
 	authResponse, err := h.authService.Login(ctx, &loginReq)
+	var mfaErr *services.MFARequiredError
+	if errors.As(err, &mfaErr) {
+		logger.InfoCtx(ctx, "Login requires second factor")
+		return h.successResponse(http.StatusOK, mfaErr.Challenge), nil
+	}
 	if err != nil {
 		logger.WarnCtx(ctx, "Login failed", zap.Error(err))
 		
 		switch err {
 		case services.ErrInvalidCredentials:
 			return h.errorResponse(http.StatusUnauthorized, "invalid credentials"), nil
+		case services.ErrAccountLocked:
+			return h.errorResponse(http.StatusLocked, "account temporarily locked"), nil
+		case services.ErrTooManyLoginAttempts:
+			return h.errorResponse(http.StatusTooManyRequests, "too many login attempts"), nil
 		case services.ErrUserNotVerified:
 			return h.errorResponse(http.StatusForbidden, "email not verified"), nil
 		case services.ErrUserDisabled:
@@ -167,6 +180,10 @@ func (h *AuthHandlers) RefreshToken(ctx context.Context, request events.APIGatew
 			return h.errorResponse(http.StatusUnauthorized, "invalid refresh token"), nil
 		case services.ErrTokenExpired:
 			return h.errorResponse(http.StatusUnauthorized, "refresh token expired"), nil
+		case services.ErrRefreshTokenReused:
+			return h.errorResponse(http.StatusUnauthorized, "refresh token already used, session revoked"), nil
+		case services.ErrUserDisabled:
+			return h.errorResponse(http.StatusForbidden, "account disabled"), nil
 		default:
 			return h.errorResponse(http.StatusInternalServerError, "token refresh failed"), nil
 		}
@@ -195,7 +212,14 @@ func (h *AuthHandlers) ForgotPassword(ctx context.Context, request events.APIGat
 	}
 
 	// Process forgot password
-	err := h.authService.ForgotPassword(ctx, forgotReq.Email)
+	var err error
+	message := "if the email exists, a password reset link has been sent"
+	if forgotReq.Method == models.EmailMethodCode {
+		err = h.authService.SendPasswordResetCode(ctx, forgotReq.Email)
+		message = "if the email exists, a password reset code has been sent"
+	} else {
+		err = h.authService.ForgotPassword(ctx, forgotReq.Email)
+	}
 	if err != nil {
 		logger.ErrorCtx(ctx, "Forgot password processing failed", zap.Error(err))
 		// Don't reveal if user exists or not
@@ -203,7 +227,7 @@ func (h *AuthHandlers) ForgotPassword(ctx context.Context, request events.APIGat
 
 	// Always return success to prevent email enumeration
 	response := map[string]string{
-		"message": "if the email exists, a password reset link has been sent",
+		"message": message,
 	}
 
 	return h.successResponse(http.StatusOK, response), nil
@@ -227,13 +251,20 @@ func (h *AuthHandlers) ResetPassword(ctx context.Context, request events.APIGate
 	}
 
 	// Reset password
-	err := h.authService.ResetPassword(ctx, resetReq.Token, resetReq.NewPassword)
+	var err error
+	if resetReq.Code != "" {
+		err = h.authService.ResetPasswordWithCode(ctx, resetReq.Email, resetReq.Code, resetReq.NewPassword)
+	} else {
+		err = h.authService.ResetPassword(ctx, resetReq.Token, resetReq.NewPassword)
+	}
 	if err != nil {
 		logger.WarnCtx(ctx, "Password reset failed", zap.Error(err))
 		
 		switch err {
 		case services.ErrInvalidToken:
 			return h.errorResponse(http.StatusBadRequest, "invalid or expired reset token"), nil
+		case services.ErrInvalidEmailCode:
+			return h.errorResponse(http.StatusBadRequest, "invalid or expired code"), nil
 		default:
 			return h.errorResponse(http.StatusInternalServerError, "password reset failed"), nil
 		}
@@ -311,13 +342,20 @@ func (h *AuthHandlers) VerifyEmail(ctx context.Context, request events.APIGatewa
 	}
 
 	// Verify email
-	err := h.authService.VerifyEmail(ctx, verifyReq.Token)
+	var err error
+	if verifyReq.Code != "" {
+		err = h.authService.VerifyEmailCode(ctx, verifyReq.Email, verifyReq.Code)
+	} else {
+		err = h.authService.VerifyEmail(ctx, verifyReq.Token)
+	}
 	if err != nil {
 		logger.WarnCtx(ctx, "Email verification failed", zap.Error(err))
 		
 		switch err {
 		case services.ErrInvalidToken:
 			return h.errorResponse(http.StatusBadRequest, "invalid or expired verification token"), nil
+		case services.ErrInvalidEmailCode:
+			return h.errorResponse(http.StatusBadRequest, "invalid or expired code"), nil
 		default:
 			return h.errorResponse(http.StatusInternalServerError, "email verification failed"), nil
 		}
@@ -350,7 +388,12 @@ func (h *AuthHandlers) ResendVerification(ctx context.Context, request events.AP
 	}
 
 	// Resend verification
-	err := h.authService.ResendVerification(ctx, resendReq.Email)
+	var err error
+	if resendReq.Method == models.EmailMethodCode {
+		err = h.authService.SendVerificationCode(ctx, resendReq.Email)
+	} else {
+		err = h.authService.ResendVerification(ctx, resendReq.Email)
+	}
 	if err != nil {
 		logger.ErrorCtx(ctx, "Resend verification failed", zap.Error(err))
 		// Don't reveal if user exists or not
@@ -431,11 +474,10 @@ func (h *AuthHandlers) RevokeSession(ctx context.Context, request events.APIGate
 	}
 
 	// Extract session ID from path
-	pathParts := strings.Split(strings.TrimPrefix(request.Path, "/v1/auth/sessions/"), "/")
-	if len(pathParts) == 0 || pathParts[0] == "" {
+	sessionID := router.PathParam(ctx, "id")
+	if sessionID == "" {
 		return h.errorResponse(http.StatusBadRequest, "session ID required"), nil
 	}
-	sessionID := pathParts[0]
 
 	// Revoke session
 	err := h.authService.RevokeSession(ctx, userClaims.UserID, sessionID)
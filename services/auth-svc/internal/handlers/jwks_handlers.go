@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/shared/jwtkeys"
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+// JWKSHandlers publishes the public keys other services verify tokens with
+type JWKSHandlers struct {
+	keys *jwtkeys.KeySet
+}
+
+// NewJWKSHandlers creates handlers serving keys
+func NewJWKSHandlers(keys *jwtkeys.KeySet) *JWKSHandlers {
+	return &JWKSHandlers{keys: keys}
+}
+
+// GetJWKS handles GET /.well-known/jwks.json
+func (h *JWKSHandlers) GetJWKS(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	set, err := h.keys.JWKS()
+	if err != nil {
+		logger.ErrorCtx(ctx, "Failed to build JWKS", zap.Error(err))
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Headers: map[string]string{
+				"Content-Type": "application/json",
+			},
+			Body: `{"error":"internal server error"}`,
+		}, nil
+	}
+
+	body, _ := json.Marshal(set)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":  "application/json",
+			"Cache-Control": "public, max-age=300",
+		},
+		Body: string(body),
+	}, nil
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/services"
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+// RequestMagicLink emails a passwordless login link
+func (h *AuthHandlers) RequestMagicLink(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing magic link request")
+
+	// Parse request body
+	var linkReq models.MagicLinkRequest
+	if err := json.Unmarshal([]byte(request.Body), &linkReq); err != nil {
+		logger.WarnCtx(ctx, "Invalid magic link request body", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, "invalid request body"), nil
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&linkReq); err != nil {
+		logger.WarnCtx(ctx, "Magic link request validation failed", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, "validation failed: "+err.Error()), nil
+	}
+
+	err := h.authService.RequestMagicLink(ctx, linkReq.Email)
+	if err != nil {
+		logger.ErrorCtx(ctx, "Magic link processing failed", zap.Error(err))
+		// Don't reveal if user exists or not
+	}
+
+	// Always return success to prevent email enumeration
+	response := map[string]string{
+		"message": "if the email exists, a login link has been sent",
+	}
+
+	return h.successResponse(http.StatusOK, response), nil
+}
+
+// VerifyMagicLink logs in with the token from a magic link
+func (h *AuthHandlers) VerifyMagicLink(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing magic link login request")
+
+	// Parse request body
+	var verifyReq models.MagicLinkVerifyRequest
+	if err := json.Unmarshal([]byte(request.Body), &verifyReq); err != nil {
+		logger.WarnCtx(ctx, "Invalid magic link login request body", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, "invalid request body"), nil
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&verifyReq); err != nil {
+		logger.WarnCtx(ctx, "Magic link login request validation failed", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, "validation failed: "+err.Error()), nil
+	}
+
+	verifyReq.IPAddress = request.RequestContext.Identity.SourceIP
+	verifyReq.UserAgent = request.RequestContext.Identity.UserAgent
+
+	authResponse, err := h.authService.VerifyMagicLink(ctx, &verifyReq)
+	var mfaErr *services.MFARequiredError
+	if errors.As(err, &mfaErr) {
+		logger.InfoCtx(ctx, "Magic link login requires second factor")
+		return h.successResponse(http.StatusOK, mfaErr.Challenge), nil
+	}
+	if err != nil {
+		logger.WarnCtx(ctx, "Magic link login failed", zap.Error(err))
+
+		switch err {
+		case services.ErrInvalidToken:
+			return h.errorResponse(http.StatusUnauthorized, "invalid or expired login link"), nil
+		case services.ErrAccountLocked:
+			return h.errorResponse(http.StatusLocked, "account temporarily locked"), nil
+		case services.ErrUserDisabled:
+			return h.errorResponse(http.StatusForbidden, "account disabled"), nil
+		default:
+			return h.errorResponse(http.StatusInternalServerError, "authentication failed"), nil
+		}
+	}
+
+	logger.InfoCtx(ctx, "Magic link login successful", zap.String("user_id", authResponse.User.ID))
+
+	return h.successResponse(http.StatusOK, authResponse), nil
+}
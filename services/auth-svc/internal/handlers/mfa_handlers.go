@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/services"
+	"github.com/multitask-platform/backend/shared/logger"
+	"github.com/multitask-platform/backend/shared/middleware"
+)
+
+// LoginMFA handles the second step of a login for accounts with a second factor
+func (h *AuthHandlers) LoginMFA(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing MFA login request")
+
+	// Parse request body
+	var mfaReq models.MFALoginRequest
+	if err := json.Unmarshal([]byte(request.Body), &mfaReq); err != nil {
+		logger.WarnCtx(ctx, "Invalid MFA login request body", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, "invalid request body"), nil
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&mfaReq); err != nil {
+		logger.WarnCtx(ctx, "MFA login request validation failed", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, "validation failed: "+err.Error()), nil
+	}
+
+	mfaReq.IPAddress = request.RequestContext.Identity.SourceIP
+	mfaReq.UserAgent = request.RequestContext.Identity.UserAgent
+
+	// Complete login
+	authResponse, err := h.authService.LoginMFA(ctx, &mfaReq)
+	if err != nil {
+		logger.WarnCtx(ctx, "MFA login failed", zap.Error(err))
+
+		switch err {
+		case services.ErrInvalidToken:
+			return h.errorResponse(http.StatusUnauthorized, "invalid or expired mfa token"), nil
+		case services.ErrInvalidMFACode:
+			return h.errorResponse(http.StatusUnauthorized, "invalid code"), nil
+		case services.ErrAccountLocked:
+			return h.errorResponse(http.StatusLocked, "account temporarily locked"), nil
+		case services.ErrTooManyLoginAttempts:
+			return h.errorResponse(http.StatusTooManyRequests, "too many login attempts"), nil
+		case services.ErrUserDisabled:
+			return h.errorResponse(http.StatusForbidden, "account disabled"), nil
+		default:
+			return h.errorResponse(http.StatusInternalServerError, "authentication failed"), nil
+		}
+	}
+
+	logger.InfoCtx(ctx, "MFA login successful", zap.String("user_id", authResponse.User.ID))
+
+	return h.successResponse(http.StatusOK, authResponse), nil
+}
+
+// SendLoginCode emails a code for the second step of a login
+func (h *AuthHandlers) SendLoginCode(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing MFA email code request")
+
+	// Parse request body
+	var codeReq models.MFAEmailCodeRequest
+	if err := json.Unmarshal([]byte(request.Body), &codeReq); err != nil {
+		logger.WarnCtx(ctx, "Invalid MFA email code request body", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, "invalid request body"), nil
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&codeReq); err != nil {
+		logger.WarnCtx(ctx, "MFA email code request validation failed", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, "validation failed: "+err.Error()), nil
+	}
+
+	err := h.authService.SendLoginCode(ctx, codeReq.MFAToken)
+	if err != nil {
+		logger.WarnCtx(ctx, "MFA email code failed", zap.Error(err))
+
+		switch err {
+		case services.ErrInvalidToken:
+			return h.errorResponse(http.StatusUnauthorized, "invalid or expired mfa token"), nil
+		case services.ErrUserDisabled:
+			return h.errorResponse(http.StatusForbidden, "account disabled"), nil
+		default:
+			return h.errorResponse(http.StatusInternalServerError, "failed to send code"), nil
+		}
+	}
+
+	response := map[string]interface{}{
+		"message":    "a login code has been sent to your email",
+		"expires_in": int64(models.EmailOTPDuration.Seconds()),
+	}
+
+	return h.successResponse(http.StatusOK, response), nil
+}
+
+// SetupTOTP starts TOTP enrollment for the current user
+func (h *AuthHandlers) SetupTOTP(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing TOTP setup request")
+
+	// Get user from context
+	userClaims := middleware.GetUserClaims(ctx)
+	if userClaims == nil {
+		return h.errorResponse(http.StatusUnauthorized, "unauthorized"), nil
+	}
+
+	setup, err := h.authService.SetupTOTP(ctx, userClaims.UserID)
+	if err != nil {
+		logger.WarnCtx(ctx, "TOTP setup failed", zap.Error(err))
+
+		switch err {
+		case services.ErrMFAAlreadyEnabled:
+			return h.errorResponse(http.StatusConflict, "two-factor authentication already enabled"), nil
+		case services.ErrUserNotFound:
+			return h.errorResponse(http.StatusNotFound, "user not found"), nil
+		default:
+			return h.errorResponse(http.StatusInternalServerError, "failed to set up two-factor authentication"), nil
+		}
+	}
+
+	return h.successResponse(http.StatusOK, setup), nil
+}
+
+// VerifyTOTP confirms the current user's TOTP enrollment with a first code
+func (h *AuthHandlers) VerifyTOTP(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing TOTP verification request")
+
+	// Get user from context
+	userClaims := middleware.GetUserClaims(ctx)
+	if userClaims == nil {
+		return h.errorResponse(http.StatusUnauthorized, "unauthorized"), nil
+	}
+
+	// Parse request body
+	var verifyReq models.TOTPVerifyRequest
+	if err := json.Unmarshal([]byte(request.Body), &verifyReq); err != nil {
+		logger.WarnCtx(ctx, "Invalid TOTP verification request body", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, "invalid request body"), nil
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&verifyReq); err != nil {
+		logger.WarnCtx(ctx, "TOTP verification request validation failed", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, "validation failed: "+err.Error()), nil
+	}
+
+	recovery, err := h.authService.VerifyTOTP(ctx, userClaims.UserID, verifyReq.Code)
+	if err != nil {
+		logger.WarnCtx(ctx, "TOTP verification failed", zap.Error(err))
+
+		switch err {
+		case services.ErrInvalidMFACode:
+			return h.errorResponse(http.StatusBadRequest, "invalid code"), nil
+		case services.ErrMFANotEnrolled:
+			return h.errorResponse(http.StatusBadRequest, "two-factor authentication setup not started"), nil
+		case services.ErrMFAAlreadyEnabled:
+			return h.errorResponse(http.StatusConflict, "two-factor authentication already enabled"), nil
+		default:
+			return h.errorResponse(http.StatusInternalServerError, "failed to verify code"), nil
+		}
+	}
+
+	logger.InfoCtx(ctx, "TOTP enabled", zap.String("user_id", userClaims.UserID))
+
+	response := map[string]interface{}{
+		"message":        "two-factor authentication enabled",
+		"recovery_codes": recovery.RecoveryCodes,
+	}
+
+	return h.successResponse(http.StatusOK, response), nil
+}
+
+// RegenerateRecoveryCodes replaces the current user's recovery codes
+func (h *AuthHandlers) RegenerateRecoveryCodes(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing recovery code regeneration request")
+
+	// Get user from context
+	userClaims := middleware.GetUserClaims(ctx)
+	if userClaims == nil {
+		return h.errorResponse(http.StatusUnauthorized, "unauthorized"), nil
+	}
+
+	recovery, err := h.authService.RegenerateRecoveryCodes(ctx, userClaims.UserID)
+	if err != nil {
+		logger.WarnCtx(ctx, "Recovery code regeneration failed", zap.Error(err))
+
+		switch err {
+		case services.ErrMFANotEnrolled:
+			return h.errorResponse(http.StatusBadRequest, "two-factor authentication not enabled"), nil
+		default:
+			return h.errorResponse(http.StatusInternalServerError, "failed to regenerate recovery codes"), nil
+		}
+	}
+
+	logger.InfoCtx(ctx, "Recovery codes regenerated", zap.String("user_id", userClaims.UserID))
+
+	return h.successResponse(http.StatusOK, recovery), nil
+}
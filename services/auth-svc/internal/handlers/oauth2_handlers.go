@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/services"
+	"github.com/multitask-platform/backend/shared/logger"
+	"github.com/multitask-platform/backend/shared/middleware"
+	"github.com/multitask-platform/backend/shared/router"
+)
+
+// OAuth2Authorize is the authorization endpoint (RFC 6749 section 3.1). It
+// checks the client's request and sends the browser on to the consent page,
+// or back to the client if the request is bad.
+func (h *AuthHandlers) OAuth2Authorize(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	query := request.QueryStringParameters
+	logger.InfoCtx(ctx, "Processing oauth2 authorization request", zap.String("client_id", query["client_id"]))
+
+	location, err := h.authService.AuthorizationRedirect(ctx, &models.AuthorizationRequest{
+		ResponseType:        query["response_type"],
+		ClientID:            query["client_id"],
+		RedirectURI:         query["redirect_uri"],
+		Scope:               query["scope"],
+		State:               query["state"],
+		CodeChallenge:       query["code_challenge"],
+		CodeChallengeMethod: query["code_challenge_method"],
+		Nonce:               query["nonce"],
+	})
+	if err != nil {
+		logger.WarnCtx(ctx, "Invalid oauth2 authorization request", zap.Error(err))
+
+		var oauthErr *services.OAuth2Error
+		if errors.As(err, &oauthErr) {
+			return h.errorResponse(http.StatusBadRequest, oauthErr.Description), nil
+		}
+		return h.errorResponse(http.StatusInternalServerError, "authorization failed"), nil
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusFound,
+		Headers: map[string]string{
+			"Location":      location,
+			"Cache-Control": "no-store",
+		},
+	}, nil
+}
+
+// OAuth2AuthorizeDecision takes the signed-in user's answer from the consent
+// page and returns where to send the browser
+func (h *AuthHandlers) OAuth2AuthorizeDecision(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing oauth2 authorization decision")
+
+	// Get user from context
+	userClaims := middleware.GetUserClaims(ctx)
+	if userClaims == nil {
+		return h.errorResponse(http.StatusUnauthorized, "unauthorized"), nil
+	}
+
+	// Parse request body
+	var authReq models.AuthorizationRequest
+	if err := json.Unmarshal([]byte(request.Body), &authReq); err != nil {
+		logger.WarnCtx(ctx, "Invalid authorization request body", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, "invalid request body"), nil
+	}
+
+	authResponse, err := h.authService.Authorize(ctx, userClaims.UserID, userClaims.SessionID, &authReq)
+	if err != nil {
+		logger.WarnCtx(ctx, "OAuth2 authorization failed", zap.Error(err))
+
+		var oauthErr *services.OAuth2Error
+		if errors.As(err, &oauthErr) {
+			return h.errorResponse(http.StatusBadRequest, oauthErr.Description), nil
+		}
+
+		switch err {
+		case services.ErrUserNotFound:
+			return h.errorResponse(http.StatusNotFound, "user not found"), nil
+		case services.ErrUserDisabled:
+			return h.errorResponse(http.StatusForbidden, "account disabled"), nil
+		case services.ErrSessionNotFound:
+			return h.errorResponse(http.StatusUnauthorized, "session not found"), nil
+		default:
+			return h.errorResponse(http.StatusInternalServerError, "authorization failed"), nil
+		}
+	}
+
+	return h.successResponse(http.StatusOK, authResponse), nil
+}
+
+// OAuth2Token is the token endpoint (RFC 6749 section 3.2)
+func (h *AuthHandlers) OAuth2Token(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	form, err := formBody(request)
+	if err != nil {
+		return h.oauth2ErrorResponse(ctx, &services.OAuth2Error{Code: services.OAuth2InvalidRequest, Description: "invalid form body"}, false), nil
+	}
+	logger.InfoCtx(ctx, "Processing oauth2 token request", zap.String("grant_type", form.Get("grant_type")))
+
+	clientID, clientSecret, basicAuth := clientCredentials(request, form)
+
+	tokenResponse, err := h.authService.Token(ctx, clientID, clientSecret, &models.TokenRequest{
+		GrantType:    form.Get("grant_type"),
+		Code:         form.Get("code"),
+		RedirectURI:  form.Get("redirect_uri"),
+		CodeVerifier: form.Get("code_verifier"),
+		RefreshToken: form.Get("refresh_token"),
+		Scope:        form.Get("scope"),
+		IPAddress:    request.RequestContext.Identity.SourceIP,
+		UserAgent:    request.RequestContext.Identity.UserAgent,
+	})
+	if err != nil {
+		return h.oauth2ErrorResponse(ctx, err, basicAuth), nil
+	}
+
+	return oauth2Response(http.StatusOK, tokenResponse), nil
+}
+
+// OAuth2Introspect is the token introspection endpoint (RFC 7662)
+func (h *AuthHandlers) OAuth2Introspect(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing oauth2 introspection request")
+
+	form, err := formBody(request)
+	if err != nil || form.Get("token") == "" {
+		return h.oauth2ErrorResponse(ctx, &services.OAuth2Error{Code: services.OAuth2InvalidRequest, Description: "token is required"}, false), nil
+	}
+
+	clientID, clientSecret, basicAuth := clientCredentials(request, form)
+
+	introspection, err := h.authService.IntrospectToken(ctx, clientID, clientSecret, form.Get("token"))
+	if err != nil {
+		return h.oauth2ErrorResponse(ctx, err, basicAuth), nil
+	}
+
+	return oauth2Response(http.StatusOK, introspection), nil
+}
+
+// OAuth2Revoke is the token revocation endpoint (RFC 7009). The
+// token_type_hint parameter is ignored, as both kinds of token say what they
+// are.
+func (h *AuthHandlers) OAuth2Revoke(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing oauth2 revocation request")
+
+	form, err := formBody(request)
+	if err != nil || form.Get("token") == "" {
+		return h.oauth2ErrorResponse(ctx, &services.OAuth2Error{Code: services.OAuth2InvalidRequest, Description: "token is required"}, false), nil
+	}
+
+	clientID, clientSecret, basicAuth := clientCredentials(request, form)
+
+	err = h.authService.RevokeToken(ctx, clientID, clientSecret, form.Get("token"))
+	if err != nil {
+		return h.oauth2ErrorResponse(ctx, err, basicAuth), nil
+	}
+
+	return oauth2Response(http.StatusOK, struct{}{}), nil
+}
+
+// RegisterOAuth2Client registers an OAuth2 client owned by the current user
+func (h *AuthHandlers) RegisterOAuth2Client(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing register oauth2 client request")
+
+	// Get user from context
+	userClaims := middleware.GetUserClaims(ctx)
+	if userClaims == nil {
+		return h.errorResponse(http.StatusUnauthorized, "unauthorized"), nil
+	}
+
+	// Parse request body
+	var registerReq models.RegisterClientRequest
+	if err := json.Unmarshal([]byte(request.Body), &registerReq); err != nil {
+		logger.WarnCtx(ctx, "Invalid register client request body", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, "invalid request body"), nil
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&registerReq); err != nil {
+		logger.WarnCtx(ctx, "Register client request validation failed", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, "validation failed: "+err.Error()), nil
+	}
+
+	client, err := h.authService.RegisterClient(ctx, userClaims.UserID, userClaims.HasRole(models.RoleAdmin), &registerReq)
+	if err != nil {
+		logger.WarnCtx(ctx, "Failed to register oauth2 client", zap.Error(err))
+
+		switch err {
+		case services.ErrFirstPartyClient:
+			return h.errorResponse(http.StatusForbidden, "only admins can register first-party clients"), nil
+		case services.ErrIntrospectingClient:
+			return h.errorResponse(http.StatusForbidden, "only admins can register clients that introspect first-party tokens"), nil
+		case services.ErrInvalidRedirectURI:
+			return h.errorResponse(http.StatusBadRequest, "redirect URIs must use https, http on a loopback address, or an app's private scheme, and have no fragment"), nil
+		case services.ErrInvalidGrantTypes:
+			return h.errorResponse(http.StatusBadRequest, "invalid grant types for client"), nil
+		case services.ErrUnsupportedScope:
+			return h.errorResponse(http.StatusBadRequest, "unsupported scope"), nil
+		default:
+			return h.errorResponse(http.StatusInternalServerError, "failed to register client"), nil
+		}
+	}
+
+	return h.successResponse(http.StatusCreated, client), nil
+}
+
+// GetOAuth2Clients lists the current user's OAuth2 clients
+func (h *AuthHandlers) GetOAuth2Clients(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing get oauth2 clients request")
+
+	// Get user from context
+	userClaims := middleware.GetUserClaims(ctx)
+	if userClaims == nil {
+		return h.errorResponse(http.StatusUnauthorized, "unauthorized"), nil
+	}
+
+	clients, err := h.authService.GetUserClients(ctx, userClaims.UserID)
+	if err != nil {
+		logger.ErrorCtx(ctx, "Failed to get oauth2 clients", zap.Error(err))
+		return h.errorResponse(http.StatusInternalServerError, "failed to get clients"), nil
+	}
+
+	return h.successResponse(http.StatusOK, map[string]interface{}{
+		"clients": clients,
+	}), nil
+}
+
+// DeleteOAuth2Client removes one of the current user's OAuth2 clients
+func (h *AuthHandlers) DeleteOAuth2Client(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing delete oauth2 client request")
+
+	// Get user from context
+	userClaims := middleware.GetUserClaims(ctx)
+	if userClaims == nil {
+		return h.errorResponse(http.StatusUnauthorized, "unauthorized"), nil
+	}
+
+	// Extract client ID from path
+	clientID := router.PathParam(ctx, "id")
+	if clientID == "" {
+		return h.errorResponse(http.StatusBadRequest, "client ID required"), nil
+	}
+
+	err := h.authService.DeleteClient(ctx, userClaims.UserID, clientID)
+	if err != nil {
+		logger.ErrorCtx(ctx, "Failed to delete oauth2 client", zap.Error(err))
+
+		switch err {
+		case services.ErrClientNotFound:
+			return h.errorResponse(http.StatusNotFound, "client not found"), nil
+		default:
+			return h.errorResponse(http.StatusInternalServerError, "failed to delete client"), nil
+		}
+	}
+
+	return h.successResponse(http.StatusOK, map[string]string{"message": "client deleted successfully"}), nil
+}
+
+// oauth2ErrorResponse reports an error the way RFC 6749 section 5.2 asks.
+// Clients that failed Basic authentication are challenged to retry it.
+func (h *AuthHandlers) oauth2ErrorResponse(ctx context.Context, err error, basicAuth bool) events.APIGatewayProxyResponse {
+	var oauthErr *services.OAuth2Error
+	if !errors.As(err, &oauthErr) {
+		logger.ErrorCtx(ctx, "OAuth2 request failed", zap.Error(err))
+		return oauth2Response(http.StatusInternalServerError, map[string]string{"error": "server_error"})
+	}
+
+	logger.WarnCtx(ctx, "OAuth2 request rejected", zap.Error(err))
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == services.OAuth2InvalidClient {
+		status = http.StatusUnauthorized
+	}
+
+	response := oauth2Response(status, map[string]string{
+		"error":             oauthErr.Code,
+		"error_description": oauthErr.Description,
+	})
+	if status == http.StatusUnauthorized && basicAuth {
+		response.Headers["WWW-Authenticate"] = `Basic realm="oauth2"`
+	}
+	return response
+}
+
+// oauth2Response is a JSON response that must not be cached, as it may carry
+// tokens
+func oauth2Response(statusCode int, data interface{}) events.APIGatewayProxyResponse {
+	body, _ := json.Marshal(data)
+	return events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Headers: map[string]string{
+			"Content-Type":  "application/json",
+			"Cache-Control": "no-store",
+			"Pragma":        "no-cache",
+		},
+		Body: string(body),
+	}
+}
+
+// formBody parses an application/x-www-form-urlencoded request body
+func formBody(request events.APIGatewayProxyRequest) (url.Values, error) {
+	body := request.Body
+	if request.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return nil, err
+		}
+		body = string(decoded)
+	}
+	return url.ParseQuery(body)
+}
+
+// clientCredentials returns the credentials a client authenticated with,
+// from the Authorization header (client_secret_basic) or the form
+// (client_secret_post), and whether the header was used
+func clientCredentials(request events.APIGatewayProxyRequest, form url.Values) (string, string, bool) {
+	authHeader := request.Headers["Authorization"]
+	if authHeader == "" {
+		authHeader = request.Headers["authorization"] // case insensitive
+	}
+
+	if encoded, ok := strings.CutPrefix(authHeader, "Basic "); ok {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", "", true
+		}
+		id, secret, _ := strings.Cut(string(decoded), ":")
+
+		// RFC 6749 section 2.3.1: both parts are form-encoded first
+		id, errID := url.QueryUnescape(id)
+		secret, errSecret := url.QueryUnescape(secret)
+		if errID != nil || errSecret != nil {
+			return "", "", true
+		}
+		return id, secret, true
+	}
+
+	return form.Get("client_id"), form.Get("client_secret"), false
+}
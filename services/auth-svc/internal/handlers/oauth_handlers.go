@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/services"
+	"github.com/multitask-platform/backend/shared/logger"
+	"github.com/multitask-platform/backend/shared/router"
+)
+
+// StartOAuthLogin returns the provider URL to send the user to for a social
+// login, and sets the cookie binding the login to this browser
+func (h *AuthHandlers) StartOAuthLogin(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	provider := router.PathParam(ctx, "provider")
+	logger.InfoCtx(ctx, "Processing oauth start request", zap.String("provider", provider))
+
+	startResponse, err := h.authService.StartOAuthLogin(ctx, provider)
+	if err != nil {
+		logger.ErrorCtx(ctx, "Failed to start oauth login", zap.Error(err))
+
+		switch err {
+		case services.ErrUnknownOAuthProvider:
+			return h.errorResponse(http.StatusNotFound, "unknown provider"), nil
+		default:
+			return h.errorResponse(http.StatusBadGateway, "failed to start login with provider"), nil
+		}
+	}
+
+	response := h.successResponse(http.StatusOK, startResponse)
+	response.MultiValueHeaders = map[string][]string{
+		"Set-Cookie": {oauthBindingCookie(request, startResponse.Binding, int(models.OAuthStateDuration.Seconds()))},
+	}
+	return response, nil
+}
+
+// OAuthCallback completes a social login with the code and state the provider
+// redirected back with
+func (h *AuthHandlers) OAuthCallback(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	provider := router.PathParam(ctx, "provider")
+	logger.InfoCtx(ctx, "Processing oauth callback request", zap.String("provider", provider))
+
+	// Parse request body
+	var callbackReq models.OAuthCallbackRequest
+	if err := json.Unmarshal([]byte(request.Body), &callbackReq); err != nil {
+		logger.WarnCtx(ctx, "Invalid oauth callback request body", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, "invalid request body"), nil
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&callbackReq); err != nil {
+		logger.WarnCtx(ctx, "OAuth callback request validation failed", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, "validation failed: "+err.Error()), nil
+	}
+
+	callbackReq.IPAddress = request.RequestContext.Identity.SourceIP
+	callbackReq.UserAgent = request.RequestContext.Identity.UserAgent
+	callbackReq.Binding = requestCookie(request, models.OAuthBindingCookie)
+
+	// The state is used up either way, so its cookie is too
+	clearBinding := map[string][]string{
+		"Set-Cookie": {oauthBindingCookie(request, "", -1)},
+	}
+
+	authResponse, err := h.authService.FinishOAuthLogin(ctx, provider, &callbackReq)
+	var mfaErr *services.MFARequiredError
+	if errors.As(err, &mfaErr) {
+		logger.InfoCtx(ctx, "OAuth login requires second factor")
+		response := h.successResponse(http.StatusOK, mfaErr.Challenge)
+		response.MultiValueHeaders = clearBinding
+		return response, nil
+	}
+	if err != nil {
+		logger.WarnCtx(ctx, "OAuth login failed", zap.Error(err))
+
+		switch err {
+		case services.ErrUnknownOAuthProvider:
+			return h.errorResponse(http.StatusNotFound, "unknown provider"), nil
+		case services.ErrInvalidToken:
+			return h.errorResponse(http.StatusBadRequest, "invalid or expired state"), nil
+		case services.ErrOAuthFailed:
+			return h.errorResponse(http.StatusUnauthorized, "login with provider failed"), nil
+		case services.ErrOAuthEmailUnverified:
+			return h.errorResponse(http.StatusForbidden, "provider did not supply a verified email"), nil
+		case services.ErrUserNotVerified:
+			return h.errorResponse(http.StatusForbidden, "email not verified"), nil
+		case services.ErrUserAlreadyExists:
+			return h.errorResponse(http.StatusConflict, "user already exists"), nil
+		case services.ErrAccountLocked:
+			return h.errorResponse(http.StatusLocked, "account temporarily locked"), nil
+		case services.ErrUserDisabled:
+			return h.errorResponse(http.StatusForbidden, "account disabled"), nil
+		default:
+			return h.errorResponse(http.StatusInternalServerError, "authentication failed"), nil
+		}
+	}
+
+	logger.InfoCtx(ctx, "OAuth login successful", zap.String("user_id", authResponse.User.ID))
+
+	response := h.successResponse(http.StatusOK, authResponse)
+	response.MultiValueHeaders = clearBinding
+	return response, nil
+}
+
+// oauthBindingCookie builds the Set-Cookie value for a login's browser
+// binding. It is scoped to the provider's routes, so the start request's
+// path minus "/start" covers the callback. A negative maxAge deletes it.
+func oauthBindingCookie(request events.APIGatewayProxyRequest, binding string, maxAge int) string {
+	cookie := &http.Cookie{
+		Name:     models.OAuthBindingCookie,
+		Value:    binding,
+		Path:     strings.TrimSuffix(strings.TrimSuffix(router.CleanPath(request.Path), "/start"), "/callback"),
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	return cookie.String()
+}
+
+// requestCookie returns the named cookie's value, or "" if it wasn't sent.
+// MultiValueHeaders keeps separate Cookie headers apart, so it is preferred.
+func requestCookie(request events.APIGatewayProxyRequest, name string) string {
+	header := http.Header{}
+	for key, values := range request.MultiValueHeaders {
+		if strings.EqualFold(key, "Cookie") {
+			header["Cookie"] = append(header["Cookie"], values...)
+		}
+	}
+	if len(header) == 0 {
+		for key, value := range request.Headers {
+			if strings.EqualFold(key, "Cookie") {
+				header.Add("Cookie", value)
+			}
+		}
+	}
+
+	cookie, err := (&http.Request{Header: header}).Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
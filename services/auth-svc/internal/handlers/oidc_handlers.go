@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/services"
+	"github.com/multitask-platform/backend/shared/logger"
+	"github.com/multitask-platform/backend/shared/middleware"
+)
+
+// GetOpenIDConfiguration handles GET /.well-known/openid-configuration
+func (h *AuthHandlers) GetOpenIDConfiguration(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	body, _ := json.Marshal(h.authService.OpenIDConfiguration())
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":  "application/json",
+			"Cache-Control": "public, max-age=300",
+		},
+		Body: string(body),
+	}, nil
+}
+
+// UserInfo is the OpenID Connect userinfo endpoint. It returns the claims
+// about the token's user that the token's scopes cover.
+func (h *AuthHandlers) UserInfo(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Get user from context
+	userClaims := middleware.GetUserClaims(ctx)
+	if userClaims == nil {
+		return h.errorResponse(http.StatusUnauthorized, "unauthorized"), nil
+	}
+
+	// First-party tokens have every scope
+	var scopes []string
+	for _, scope := range []string{models.ScopeProfile, models.ScopeEmail} {
+		if userClaims.HasScope(scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	info, err := h.authService.UserInfo(ctx, userClaims.UserID, scopes)
+	if err != nil {
+		logger.WarnCtx(ctx, "Failed to get user info", zap.Error(err))
+
+		switch err {
+		case services.ErrUserNotFound, services.ErrUserDisabled:
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusUnauthorized,
+				Headers: map[string]string{
+					"Content-Type":     "application/json",
+					"WWW-Authenticate": `Bearer error="invalid_token"`,
+				},
+				Body: `{"error":"invalid_token"}`,
+			}, nil
+		default:
+			return h.errorResponse(http.StatusInternalServerError, "failed to get user info"), nil
+		}
+	}
+
+	return oauth2Response(http.StatusOK, info), nil
+}
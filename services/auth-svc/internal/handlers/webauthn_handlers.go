@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/services"
+	"github.com/multitask-platform/backend/shared/logger"
+	"github.com/multitask-platform/backend/shared/middleware"
+	"github.com/multitask-platform/backend/shared/router"
+)
+
+// BeginPasskeyRegistration returns WebAuthn creation options for the current user
+func (h *AuthHandlers) BeginPasskeyRegistration(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing passkey registration begin request")
+
+	// Get user from context
+	userClaims := middleware.GetUserClaims(ctx)
+	if userClaims == nil {
+		return h.errorResponse(http.StatusUnauthorized, "unauthorized"), nil
+	}
+
+	options, err := h.authService.BeginPasskeyRegistration(ctx, userClaims.UserID)
+	if err != nil {
+		logger.ErrorCtx(ctx, "Failed to begin passkey registration", zap.Error(err))
+
+		switch err {
+		case services.ErrUserNotFound:
+			return h.errorResponse(http.StatusNotFound, "user not found"), nil
+		default:
+			return h.errorResponse(http.StatusInternalServerError, "failed to begin passkey registration"), nil
+		}
+	}
+
+	return h.successResponse(http.StatusOK, options), nil
+}
+
+// FinishPasskeyRegistration verifies and stores a new passkey for the current user
+func (h *AuthHandlers) FinishPasskeyRegistration(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing passkey registration finish request")
+
+	// Get user from context
+	userClaims := middleware.GetUserClaims(ctx)
+	if userClaims == nil {
+		return h.errorResponse(http.StatusUnauthorized, "unauthorized"), nil
+	}
+
+	// Parse request body
+	var finishReq models.WebAuthnRegisterFinishRequest
+	if err := json.Unmarshal([]byte(request.Body), &finishReq); err != nil {
+		logger.WarnCtx(ctx, "Invalid passkey registration request body", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, "invalid request body"), nil
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&finishReq); err != nil {
+		logger.WarnCtx(ctx, "Passkey registration request validation failed", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, "validation failed: "+err.Error()), nil
+	}
+
+	credential, err := h.authService.FinishPasskeyRegistration(ctx, userClaims.UserID, &finishReq)
+	if err != nil {
+		logger.WarnCtx(ctx, "Passkey registration failed", zap.Error(err))
+
+		switch err {
+		case services.ErrInvalidToken:
+			return h.errorResponse(http.StatusBadRequest, "invalid or expired challenge"), nil
+		case services.ErrInvalidPasskey:
+			return h.errorResponse(http.StatusBadRequest, "passkey verification failed"), nil
+		case services.ErrPasskeyExists:
+			return h.errorResponse(http.StatusConflict, "passkey already registered"), nil
+		default:
+			return h.errorResponse(http.StatusInternalServerError, "failed to register passkey"), nil
+		}
+	}
+
+	logger.InfoCtx(ctx, "Passkey registered", zap.String("user_id", userClaims.UserID))
+
+	return h.successResponse(http.StatusCreated, credential), nil
+}
+
+// GetPasskeys lists the current user's passkeys
+func (h *AuthHandlers) GetPasskeys(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing get passkeys request")
+
+	// Get user from context
+	userClaims := middleware.GetUserClaims(ctx)
+	if userClaims == nil {
+		return h.errorResponse(http.StatusUnauthorized, "unauthorized"), nil
+	}
+
+	credentials, err := h.authService.GetPasskeys(ctx, userClaims.UserID)
+	if err != nil {
+		logger.ErrorCtx(ctx, "Failed to get passkeys", zap.Error(err))
+		return h.errorResponse(http.StatusInternalServerError, "failed to get passkeys"), nil
+	}
+
+	return h.successResponse(http.StatusOK, map[string]interface{}{"passkeys": credentials}), nil
+}
+
+// DeletePasskey removes one of the current user's passkeys
+func (h *AuthHandlers) DeletePasskey(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing delete passkey request")
+
+	// Get user from context
+	userClaims := middleware.GetUserClaims(ctx)
+	if userClaims == nil {
+		return h.errorResponse(http.StatusUnauthorized, "unauthorized"), nil
+	}
+
+	// Extract credential ID from path
+	credentialID := router.PathParam(ctx, "id")
+	if credentialID == "" {
+		return h.errorResponse(http.StatusBadRequest, "passkey ID required"), nil
+	}
+
+	err := h.authService.DeletePasskey(ctx, userClaims.UserID, credentialID)
+	if err != nil {
+		logger.ErrorCtx(ctx, "Failed to delete passkey", zap.Error(err))
+
+		switch err {
+		case services.ErrPasskeyNotFound:
+			return h.errorResponse(http.StatusNotFound, "passkey not found"), nil
+		default:
+			return h.errorResponse(http.StatusInternalServerError, "failed to delete passkey"), nil
+		}
+	}
+
+	return h.successResponse(http.StatusOK, map[string]string{"message": "passkey deleted successfully"}), nil
+}
+
+// BeginPasskeyLogin returns WebAuthn request options for a passwordless login
+func (h *AuthHandlers) BeginPasskeyLogin(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing passkey login begin request")
+
+	options, err := h.authService.BeginPasskeyLogin(ctx)
+	if err != nil {
+		logger.ErrorCtx(ctx, "Failed to begin passkey login", zap.Error(err))
+		return h.errorResponse(http.StatusInternalServerError, "failed to begin passkey login"), nil
+	}
+
+	return h.successResponse(http.StatusOK, options), nil
+}
+
+// FinishPasskeyLogin verifies a passkey assertion and returns tokens
+func (h *AuthHandlers) FinishPasskeyLogin(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	logger.InfoCtx(ctx, "Processing passkey login finish request")
+
+	// Parse request body
+	var finishReq models.WebAuthnLoginFinishRequest
+	if err := json.Unmarshal([]byte(request.Body), &finishReq); err != nil {
+		logger.WarnCtx(ctx, "Invalid passkey login request body", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, "invalid request body"), nil
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&finishReq); err != nil {
+		logger.WarnCtx(ctx, "Passkey login request validation failed", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, "validation failed: "+err.Error()), nil
+	}
+
+	finishReq.IPAddress = request.RequestContext.Identity.SourceIP
+	finishReq.UserAgent = request.RequestContext.Identity.UserAgent
+
+	authResponse, err := h.authService.FinishPasskeyLogin(ctx, &finishReq)
+	if err != nil {
+		logger.WarnCtx(ctx, "Passkey login failed", zap.Error(err))
+
+		switch err {
+		case services.ErrInvalidToken:
+			return h.errorResponse(http.StatusUnauthorized, "invalid or expired challenge"), nil
+		case services.ErrInvalidPasskey:
+			return h.errorResponse(http.StatusUnauthorized, "invalid passkey"), nil
+		case services.ErrAccountLocked:
+			return h.errorResponse(http.StatusLocked, "account temporarily locked"), nil
+		case services.ErrTooManyLoginAttempts:
+			return h.errorResponse(http.StatusTooManyRequests, "too many login attempts"), nil
+		case services.ErrUserDisabled:
+			return h.errorResponse(http.StatusForbidden, "account disabled"), nil
+		default:
+			return h.errorResponse(http.StatusInternalServerError, "authentication failed"), nil
+		}
+	}
+
+	logger.InfoCtx(ctx, "Passkey login successful", zap.String("user_id", authResponse.User.ID))
+
+	return h.successResponse(http.StatusOK, authResponse), nil
+}
@@ -0,0 +1,68 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+// FileSender writes each message into a Maildir for local development. Any
+// Maildir-aware client (mutt -f, Thunderbird) can read the directory, or the
+// .eml files under new/ can be opened directly.
+type FileSender struct {
+	dir string
+	now func() time.Time
+}
+
+// NewFileSender creates a sender that delivers into the Maildir at dir,
+// creating it if needed
+func NewFileSender(dir string) (*FileSender, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create maildir: %w", err)
+		}
+	}
+	return &FileSender{dir: dir, now: time.Now}, nil
+}
+
+// Send writes the message to tmp/ and renames it into new/ so readers never
+// see a partial file
+func (s *FileSender) Send(ctx context.Context, msg *Message) error {
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("failed to name message file: %w", err)
+	}
+	name := fmt.Sprintf("%d.%s.eml", s.now().UnixNano(), hex.EncodeToString(suffix))
+
+	tmpPath := filepath.Join(s.dir, "tmp", name)
+	if err := os.WriteFile(tmpPath, body, 0o644); err != nil {
+		return fmt.Errorf("failed to write message file: %w", err)
+	}
+
+	newPath := filepath.Join(s.dir, "new", name)
+	if err := os.Rename(tmpPath, newPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to deliver message file: %w", err)
+	}
+
+	logger.InfoCtx(ctx, "Email written to maildir",
+		zap.Strings("to", msg.To),
+		zap.String("subject", msg.Subject),
+		zap.String("path", newPath),
+	)
+
+	return nil
+}
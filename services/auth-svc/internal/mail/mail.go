@@ -0,0 +1,109 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message is a rendered email with plain text and HTML alternatives
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+	Date    time.Time
+}
+
+// Sender delivers rendered messages
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Bytes encodes the message as RFC 5322 with a multipart/alternative body
+func (m *Message) Bytes() ([]byte, error) {
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	if err := writePart(writer, "text/plain; charset=utf-8", m.Text); err != nil {
+		return nil, err
+	}
+	if m.HTML != "" {
+		if err := writePart(writer, "text/html; charset=utf-8", m.HTML); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close message: %w", err)
+	}
+
+	header := []string{
+		"From: " + m.From,
+		"To: " + strings.Join(m.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", m.Subject),
+		"Date: " + date.Format(time.RFC1123Z),
+		"Message-ID: " + messageID(m.From),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + writer.Boundary(),
+	}
+
+	var out bytes.Buffer
+	out.WriteString(strings.Join(header, "\r\n"))
+	out.WriteString("\r\n\r\n")
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
+
+func writePart(writer *multipart.Writer, contentType, body string) error {
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create %s part: %w", contentType, err)
+	}
+
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return fmt.Errorf("failed to write %s part: %w", contentType, err)
+	}
+	return qp.Close()
+}
+
+// messageID returns a unique Message-ID in the sender's domain
+func messageID(from string) string {
+	domain := "localhost"
+	address := envelopeAddress(from)
+	if at := strings.LastIndex(address, "@"); at >= 0 {
+		domain = address[at+1:]
+	}
+
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
+
+// envelopeAddress strips any display name, returning the bare address used in
+// the SMTP envelope
+func envelopeAddress(address string) string {
+	parsed, err := netmail.ParseAddress(address)
+	if err != nil {
+		return address
+	}
+	return parsed.Address
+}
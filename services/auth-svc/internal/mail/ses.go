@@ -0,0 +1,80 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+// SESAPI is the subset of the SES v2 client used by SESSender
+type SESAPI interface {
+	SendEmail(ctx context.Context, params *sesv2.SendEmailInput, optFns ...func(*sesv2.Options)) (*sesv2.SendEmailOutput, error)
+}
+
+// NewSESClient creates an SES v2 client for region
+func NewSESClient(ctx context.Context, region string) (*sesv2.Client, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	return sesv2.NewFromConfig(awsCfg), nil
+}
+
+// SESSender delivers messages through Amazon SES as raw MIME so the plain
+// text and HTML alternatives arrive exactly as rendered
+type SESSender struct {
+	client           SESAPI
+	configurationSet string
+}
+
+// NewSESSender creates a sender using client. configurationSet is optional.
+func NewSESSender(client SESAPI, configurationSet string) *SESSender {
+	return &SESSender{
+		client:           client,
+		configurationSet: configurationSet,
+	}
+}
+
+func (s *SESSender) Send(ctx context.Context, msg *Message) error {
+	start := time.Now()
+
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	input := &sesv2.SendEmailInput{
+		FromEmailAddress: &msg.From,
+		Destination:      &types.Destination{ToAddresses: msg.To},
+		Content: &types.EmailContent{
+			Raw: &types.RawMessage{Data: body},
+		},
+	}
+	if s.configurationSet != "" {
+		input.ConfigurationSetName = &s.configurationSet
+	}
+
+	out, err := s.client.SendEmail(ctx, input)
+	logger.DebugCtx(ctx, "SES send",
+		zap.Strings("to", msg.To),
+		zap.Duration("duration", time.Since(start)),
+		zap.Error(err),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to send email via SES: %w", err)
+	}
+
+	if out.MessageId != nil {
+		logger.DebugCtx(ctx, "SES accepted message", zap.String("message_id", *out.MessageId))
+	}
+
+	return nil
+}
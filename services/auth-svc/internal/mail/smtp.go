@@ -0,0 +1,122 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+// SMTPConfig describes an SMTP relay. Username may be empty for relays that
+// accept unauthenticated mail, such as MailHog or Mailpit during development.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Timeout  time.Duration // defaults to 10s
+}
+
+// SMTPSender delivers messages through an SMTP relay, upgrading to TLS with
+// STARTTLS whenever the server offers it
+type SMTPSender struct {
+	cfg SMTPConfig
+}
+
+// NewSMTPSender creates a sender for the given relay
+func NewSMTPSender(cfg SMTPConfig) *SMTPSender {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &SMTPSender{cfg: cfg}
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg *Message) error {
+	start := time.Now()
+
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	err = s.send(ctx, msg, body)
+	logger.DebugCtx(ctx, "SMTP send",
+		zap.String("host", s.cfg.Host),
+		zap.Strings("to", msg.To),
+		zap.Duration("duration", time.Since(start)),
+		zap.Error(err),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to send email via SMTP: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SMTPSender) send(ctx context.Context, msg *Message, body []byte) error {
+	addr := net.JoinHostPort(s.cfg.Host, fmt.Sprint(s.cfg.Port))
+
+	dialer := &net.Dialer{Timeout: s.cfg.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(s.cfg.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.cfg.Host}); err != nil {
+			return err
+		}
+	}
+
+	if s.cfg.Username != "" {
+		auth := smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(envelopeAddress(msg.From)); err != nil {
+		return err
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(envelopeAddress(to)); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
@@ -0,0 +1,86 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*.txt templates/*.html
+var templateFS embed.FS
+
+// Template names, one per transactional email. Each has a "<name>.subject"
+// and "<name>.txt" template in templates/<name>.txt and a "<name>.html"
+// template in templates/<name>.html.
+const (
+	TemplateVerification    = "verification"
+	TemplatePasswordReset   = "password_reset"
+	TemplatePasswordChanged = "password_changed"
+	TemplateNewLogin        = "new_login"
+	TemplateAccountLocked   = "account_locked"
+	TemplateMagicLink       = "magic_link"
+	TemplateEmailCode       = "email_code"
+)
+
+// TemplateData is the data passed to every template
+type TemplateData struct {
+	Product   string
+	Subject   string // filled in by Render before the bodies are executed
+	Name      string
+	Link      string
+	Code      string // One-time code to type in instead of following a link
+	Action    string // What the code is for, e.g. "reset your password"
+	ExpiresIn string
+	Time      string
+	IPAddress string
+	UserAgent string
+}
+
+// Templates renders the embedded email templates
+type Templates struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// LoadTemplates parses the embedded templates
+func LoadTemplates() (*Templates, error) {
+	text, err := texttemplate.ParseFS(templateFS, "templates/*.txt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse text templates: %w", err)
+	}
+
+	html, err := htmltemplate.ParseFS(templateFS, "templates/*.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse html templates: %w", err)
+	}
+
+	return &Templates{text: text, html: html}, nil
+}
+
+// Render executes the named template into msg's subject and bodies
+func (t *Templates) Render(name string, data TemplateData, msg *Message) error {
+	var buf bytes.Buffer
+
+	if err := t.text.ExecuteTemplate(&buf, name+".subject", data); err != nil {
+		return fmt.Errorf("failed to render %s subject: %w", name, err)
+	}
+	data.Subject = strings.TrimSpace(buf.String())
+	msg.Subject = data.Subject
+
+	buf.Reset()
+	if err := t.text.ExecuteTemplate(&buf, name+".txt", data); err != nil {
+		return fmt.Errorf("failed to render %s text body: %w", name, err)
+	}
+	msg.Text = buf.String()
+
+	buf.Reset()
+	if err := t.html.ExecuteTemplate(&buf, name+".html", data); err != nil {
+		return fmt.Errorf("failed to render %s html body: %w", name, err)
+	}
+	msg.HTML = buf.String()
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package models
+
+// Permissions the admin API checks, granted through the roles in
+// middleware.DefaultRoles
+const (
+	PermissionUsersRead   = "users:read"
+	PermissionUsersWrite  = "users:write"
+	PermissionUsersDelete = "users:delete"
+)
+
+// User list paging
+const (
+	DefaultUserPageSize = 50
+	MaxUserPageSize     = 100
+)
+
+// ListUsersRequest filters and pages the admin user list
+type ListUsersRequest struct {
+	Query  string // Part of the email or name; empty lists everyone
+	Limit  int
+	Cursor string // NextCursor from the previous page
+}
+
+// UserList is one page of users. NextCursor is empty on the last page.
+type UserList struct {
+	Users      []*User `json:"users"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// AdminUserDetails is what an admin sees about one account
+type AdminUserDetails struct {
+	User     *User      `json:"user"`
+	Sessions []*Session `json:"sessions"`
+}
+
+// RoleRequest names a role to assign
+type RoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
@@ -0,0 +1,79 @@
+package models
+
+import "time"
+
+// Audit actions
+const (
+	AuditActionLoginSucceeded    = "login.succeeded"
+	AuditActionLoginFailed       = "login.failed"
+	AuditActionPasswordChanged   = "password.changed"
+	AuditActionPasswordReset     = "password.reset"
+	AuditActionSessionRevoked    = "session.revoked"
+	AuditActionEmailVerified     = "email.verified"
+	AuditActionRoleAssigned      = "role.assigned"
+	AuditActionRoleRemoved       = "role.removed"
+	AuditActionUserDisabled      = "user.disabled"
+	AuditActionUserEnabled       = "user.enabled"
+	AuditActionUserLoggedOut     = "user.logged_out" // All sessions ended by an admin
+	AuditActionUserDeleted       = "user.deleted"
+	AuditActionPasswordResetSent = "password.reset_sent"
+	AuditActionUsersListed       = "admin.users_listed"
+	AuditActionUserViewed        = "admin.user_viewed"
+	AuditActionAuditQueried      = "admin.audit_queried"
+	AuditActionAccessDenied      = "access.denied" // A request the caller's roles didn't allow
+)
+
+// PermissionAuditRead lets a role query the audit log
+const PermissionAuditRead = "audit:read"
+
+// Audit log query paging
+const (
+	DefaultAuditPageSize = 100
+	MaxAuditPageSize     = 1000
+)
+
+// AuditEvent is one entry in the append-only audit log. Entries are numbered
+// from 1 and chained: Hash covers the entry and PrevHash, so changing,
+// removing or reordering entries breaks every hash after them.
+type AuditEvent struct {
+	Sequence  int64             `json:"sequence" dynamodb:"sequence"`
+	ID        string            `json:"id" dynamodb:"id"`
+	Time      time.Time         `json:"time" dynamodb:"time"`
+	Action    string            `json:"action" dynamodb:"action"`
+	ActorID   string            `json:"actor_id,omitempty" dynamodb:"actor_id"` // Who acted, when known: the user or an admin
+	UserID    string            `json:"user_id,omitempty" dynamodb:"user_id"`   // The account acted on
+	SessionID string            `json:"session_id,omitempty" dynamodb:"session_id"`
+	IPAddress string            `json:"ip_address,omitempty" dynamodb:"ip_address"`
+	Details   map[string]string `json:"details,omitempty" dynamodb:"details"`
+	PrevHash  string            `json:"prev_hash" dynamodb:"prev_hash"`
+	Hash      string            `json:"hash" dynamodb:"hash"`
+}
+
+// AuditQuery filters the audit log. Zero fields match everything.
+type AuditQuery struct {
+	UserID string
+	Action string
+	From   time.Time // Inclusive
+	To     time.Time // Exclusive
+	Limit  int
+}
+
+// Matches reports whether the event passes the query's filters
+func (q *AuditQuery) Matches(event *AuditEvent) bool {
+	switch {
+	case q.UserID != "" && event.UserID != q.UserID:
+		return false
+	case q.Action != "" && event.Action != q.Action:
+		return false
+	case !q.From.IsZero() && event.Time.Before(q.From):
+		return false
+	case !q.To.IsZero() && !event.Time.Before(q.To):
+		return false
+	}
+	return true
+}
+
+// AuditLog is the newest events matching a query, newest first
+type AuditLog struct {
+	Events []*AuditEvent `json:"events"`
+}
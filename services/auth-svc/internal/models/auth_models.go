@@ -13,19 +13,33 @@ type User struct {
 	CreatedAt   time.Time `json:"created_at" dynamodb:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" dynamodb:"updated_at"`
 	LastLoginAt *time.Time `json:"last_login_at,omitempty" dynamodb:"last_login_at,omitempty"`
+
+	MFA *MFAStatus `json:"mfa,omitempty" dynamodb:"-"` // Only filled in for the user's own profile and for admins
 }
 
-// Session represents a user session
+// Session represents a user session. Every refresh token rotated from the
+// same login belongs to the session; only the one whose jti matches
+// RefreshTokenID is current.
 type Session struct {
-	ID        string    `json:"id" dynamodb:"session_id"`
-	UserID    string    `json:"user_id" dynamodb:"user_id"`
-	Token     string    `json:"-" dynamodb:"token"` // Don't expose token in JSON
-	DeviceID  string    `json:"device_id" dynamodb:"device_id"`
-	UserAgent string    `json:"user_agent" dynamodb:"user_agent"`
-	IPAddress string    `json:"ip_address" dynamodb:"ip_address"`
-	CreatedAt time.Time `json:"created_at" dynamodb:"created_at"`
-	ExpiresAt time.Time `json:"expires_at" dynamodb:"expires_at"`
-	IsActive  bool      `json:"is_active" dynamodb:"is_active"`
+	ID             string    `json:"id" dynamodb:"session_id"`
+	UserID         string    `json:"user_id" dynamodb:"user_id"`
+	Token          string    `json:"-" dynamodb:"token"` // Don't expose token in JSON
+	RefreshTokenID string    `json:"-" dynamodb:"refresh_token_id"`
+	DeviceID       string    `json:"device_id" dynamodb:"device_id"`
+	UserAgent      string    `json:"user_agent" dynamodb:"user_agent"`
+	IPAddress      string    `json:"ip_address" dynamodb:"ip_address"`
+	CreatedAt      time.Time `json:"created_at" dynamodb:"created_at"`
+	ExpiresAt      time.Time `json:"expires_at" dynamodb:"expires_at"`
+	IsActive       bool      `json:"is_active" dynamodb:"is_active"`
+
+	// How and when the user signed in. Sessions an OAuth2 client holds
+	// inherit them from the login that approved the client.
+	AuthTime    time.Time `json:"auth_time" dynamodb:"auth_time"`
+	AuthMethods []string  `json:"amr,omitempty" dynamodb:"amr"`
+
+	// Set for sessions an OAuth2 client holds on the user's behalf
+	ClientID string   `json:"client_id,omitempty" dynamodb:"client_id"`
+	Scopes   []string `json:"scopes,omitempty" dynamodb:"scopes"`
 }
 
 // AnonymousSession represents an anonymous user session
@@ -43,6 +57,10 @@ type LoginRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=8"`
 	DeviceID string `json:"device_id,omitempty"`
+
+	// Populated by the handler from the request, never from the body
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
 }
 
 // RegisterRequest represents a registration request payload
@@ -64,29 +82,54 @@ type RefreshTokenRequest struct {
 
 // ForgotPasswordRequest represents a forgot password request payload
 type ForgotPasswordRequest struct {
-	Email string `json:"email" validate:"required,email"`
+	Email  string `json:"email" validate:"required,email"`
+	Method string `json:"method,omitempty" validate:"omitempty,oneof=link code"` // Defaults to link
 }
 
-// ResetPasswordRequest represents a password reset request payload
+// ResetPasswordRequest represents a password reset request payload. Either
+// the token from the reset link or the email address and emailed code are
+// required.
 type ResetPasswordRequest struct {
-	Token       string `json:"token" validate:"required"`
+	Token       string `json:"token,omitempty" validate:"required_without=Code"`
+	Email       string `json:"email,omitempty" validate:"required_with=Code,omitempty,email"`
+	Code        string `json:"code,omitempty" validate:"omitempty,len=6,numeric"`
 	NewPassword string `json:"new_password" validate:"required,min=8"`
 }
 
+// MagicLinkRequest asks for a login link to be emailed
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// MagicLinkVerifyRequest exchanges the token from a login link for a session
+type MagicLinkVerifyRequest struct {
+	Token    string `json:"token" validate:"required"`
+	DeviceID string `json:"device_id,omitempty"`
+
+	// Populated by the handler from the request, never from the body
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
 // ChangePasswordRequest represents a password change request payload
 type ChangePasswordRequest struct {
 	CurrentPassword string `json:"current_password" validate:"required"`
 	NewPassword     string `json:"new_password" validate:"required,min=8"`
 }
 
-// VerifyEmailRequest represents an email verification request payload
+// VerifyEmailRequest represents an email verification request payload.
+// Either the token from the verification link or the email address and
+// emailed code are required.
 type VerifyEmailRequest struct {
-	Token string `json:"token" validate:"required"`
+	Token string `json:"token,omitempty" validate:"required_without=Code"`
+	Email string `json:"email,omitempty" validate:"required_with=Code,omitempty,email"`
+	Code  string `json:"code,omitempty" validate:"omitempty,len=6,numeric"`
 }
 
 // ResendVerificationRequest represents a resend verification request payload
 type ResendVerificationRequest struct {
-	Email string `json:"email" validate:"required,email"`
+	Email  string `json:"email" validate:"required,email"`
+	Method string `json:"method,omitempty" validate:"omitempty,oneof=link code"` // Defaults to link
 }
 
 // AuthResponse represents a successful authentication response
@@ -104,8 +147,13 @@ type TokenClaims struct {
 	Name      string   `json:"name"`
 	Roles     []string `json:"roles"`
 	SessionID string   `json:"session_id"`
+	TokenID   string   `json:"jti,omitempty"`
 	IssuedAt  int64    `json:"iat"`
 	ExpiresAt int64    `json:"exp"`
+
+	Type     string   `json:"type,omitempty"`
+	ClientID string   `json:"client_id,omitempty"` // OAuth2 client the token was issued to
+	Scopes   []string `json:"scope,omitempty"`
 }
 
 // PasswordResetToken represents a password reset token
@@ -117,6 +165,14 @@ type PasswordResetToken struct {
 	CreatedAt time.Time `json:"created_at" dynamodb:"created_at"`
 }
 
+// MagicLinkToken is a single-use token emailed for passwordless login
+type MagicLinkToken struct {
+	UserID    string    `json:"user_id" dynamodb:"user_id"`
+	Token     string    `json:"token" dynamodb:"token"`
+	ExpiresAt time.Time `json:"expires_at" dynamodb:"expires_at"`
+	CreatedAt time.Time `json:"created_at" dynamodb:"created_at"`
+}
+
 // EmailVerificationToken represents an email verification token
 type EmailVerificationToken struct {
 	UserID    string    `json:"user_id" dynamodb:"user_id"`
@@ -127,6 +183,53 @@ type EmailVerificationToken struct {
 	CreatedAt time.Time `json:"created_at" dynamodb:"created_at"`
 }
 
+// EmailOTP is a numeric code emailed as an alternative to a link, for typing
+// in on devices where following the link is awkward. A user has at most one
+// per purpose; sending another replaces it.
+type EmailOTP struct {
+	UserID    string    `json:"user_id" dynamodb:"user_id"`
+	Purpose   string    `json:"purpose" dynamodb:"purpose"`
+	CodeHash  string    `json:"-" dynamodb:"code_hash"` // SHA-256 of the code
+	Attempts  int       `json:"attempts" dynamodb:"attempts"`
+	ExpiresAt time.Time `json:"expires_at" dynamodb:"expires_at"`
+	CreatedAt time.Time `json:"created_at" dynamodb:"created_at"`
+}
+
+// How password reset and verification emails let the user prove they got
+// them
+const (
+	EmailMethodLink = "link"
+	EmailMethodCode = "code"
+)
+
+// Email OTP purposes
+const (
+	EmailOTPPurposeVerify = "verify_email"
+	EmailOTPPurposeReset  = "reset_password"
+	EmailOTPPurposeLogin  = "login" // Second step of a login
+)
+
+// Email OTP limits. Codes are short enough to guess, so each one only gets a
+// few tries before it's thrown away.
+const (
+	EmailOTPLength      = 6
+	EmailOTPDuration    = 10 * time.Minute
+	EmailOTPMaxAttempts = 5
+)
+
+// LoginAttempts tracks recent failed logins for a user or source IP
+type LoginAttempts struct {
+	Key          string    `json:"key" dynamodb:"key"` // "user:<id>" or "ip:<addr>"
+	FailedCount  int       `json:"failed_count" dynamodb:"failed_count"`
+	LastFailedAt time.Time `json:"last_failed_at" dynamodb:"last_failed_at"`
+	LockedUntil  time.Time `json:"locked_until" dynamodb:"locked_until"`
+}
+
+// IsLocked checks if logins for this key are blocked at the given time
+func (a *LoginAttempts) IsLocked(now time.Time) bool {
+	return now.Before(a.LockedUntil)
+}
+
 // UserCreateRequest represents internal user creation request
 type UserCreateRequest struct {
 	Email        string   `json:"email"`
@@ -141,6 +244,13 @@ type SessionCreateRequest struct {
 	DeviceID  string `json:"device_id"`
 	UserAgent string `json:"user_agent"`
 	IPAddress string `json:"ip_address"`
+
+	SessionID string   `json:"session_id,omitempty"` // Generated when empty
+	ClientID  string   `json:"client_id,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
+
+	AuthTime    time.Time `json:"auth_time,omitempty"` // Defaults to now
+	AuthMethods []string  `json:"amr,omitempty"`
 }
 
 // Constants for token types
@@ -149,6 +259,7 @@ const (
 	TokenTypeRefresh          = "refresh"
 	TokenTypePasswordReset    = "password_reset"
 	TokenTypeEmailVerification = "email_verification"
+	TokenTypeMagicLink        = "magic_link"
 )
 
 // Constants for user roles
@@ -164,9 +275,20 @@ const (
 	DefaultRefreshDuration     = 7 * 24 * time.Hour  // Refresh token duration
 	DefaultResetTokenDuration  = 1 * time.Hour       // Password reset token duration
 	DefaultVerifyTokenDuration = 24 * time.Hour      // Email verification token duration
+	DefaultMagicLinkDuration   = 15 * time.Minute    // Magic link login token duration
 	DefaultAnonymousDuration   = 24 * time.Hour      // Anonymous session duration
 )
 
+// Brute-force protection
+const (
+	LoginAttemptWindow          = 15 * time.Minute // Failures older than this are forgotten
+	LoginBackoffThreshold       = 3                // Failures per user before backoff starts
+	MaxFailedLoginAttempts      = 5                // Failures per user before lockout
+	LoginBackoffThresholdPerIP  = 10               // Failures per IP before backoff starts
+	MaxFailedLoginAttemptsPerIP = 20               // Failures per IP before lockout
+	AccountLockoutDuration      = 15 * time.Minute // How long a lockout lasts
+)
+
 // Validation helper methods
 
 // IsValidRole checks if a role is valid
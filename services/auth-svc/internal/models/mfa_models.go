@@ -0,0 +1,98 @@
+package models
+
+import (
+	"time"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/webauthn"
+)
+
+// TOTPEnrollment is a user's TOTP authenticator. Secret is encrypted before it
+// reaches the repository; the enrollment only counts once confirmed with a
+// first code.
+type TOTPEnrollment struct {
+	UserID       string     `json:"user_id" dynamodb:"user_id"`
+	Secret       string     `json:"-" dynamodb:"secret"`
+	CreatedAt    time.Time  `json:"created_at" dynamodb:"created_at"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty" dynamodb:"confirmed_at,omitempty"`
+	LastUsedStep int64      `json:"-" dynamodb:"last_used_step"` // Codes at or before this step are rejected as replays
+}
+
+// IsConfirmed checks if the enrollment has been confirmed and is in use
+func (e *TOTPEnrollment) IsConfirmed() bool {
+	return e.ConfirmedAt != nil
+}
+
+// MFAChallenge is returned by Login instead of tokens when the account has a
+// second factor; the client completes it at /login/mfa
+type MFAChallenge struct {
+	MFARequired bool     `json:"mfa_required"`
+	MFAToken    string   `json:"mfa_token"`
+	Methods     []string `json:"methods"`
+	ExpiresIn   int64    `json:"expires_in"` // seconds
+
+	// Options for navigator.credentials.get() when the user has passkeys
+	WebAuthn *webauthn.RequestOptions `json:"webauthn,omitempty"`
+}
+
+// MFALoginRequest represents the second step of a login. One of Code from
+// the authenticator app, a passkey assertion, a recovery code or a code sent
+// by email is required.
+type MFALoginRequest struct {
+	MFAToken     string                      `json:"mfa_token" validate:"required"`
+	Code         string                      `json:"code,omitempty" validate:"required_without_all=RecoveryCode WebAuthn EmailCode"`
+	WebAuthn     *webauthn.AssertionResponse `json:"webauthn,omitempty"`
+	RecoveryCode string                      `json:"recovery_code,omitempty"`
+	EmailCode    string                      `json:"email_code,omitempty"` // Sent by /login/mfa/email
+	DeviceID     string                      `json:"device_id,omitempty"`
+
+	// Populated by the handler from the request, never from the body
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+// MFAEmailCodeRequest asks for a login code to be emailed during the second
+// step of a login
+type MFAEmailCodeRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+}
+
+// TOTPSetupResponse carries a new TOTP secret for the user's authenticator app
+type TOTPSetupResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"` // otpauth:// URI, usually shown as a QR code
+}
+
+// TOTPVerifyRequest confirms a TOTP enrollment with a first code
+type TOTPVerifyRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// RecoveryCodesResponse carries newly generated recovery codes. They are
+// only ever shown once; the server keeps hashes.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAStatus summarises a user's second factors for GET /me
+type MFAStatus struct {
+	Enabled                bool     `json:"enabled"`
+	Methods                []string `json:"methods"`
+	RecoveryCodesRemaining int      `json:"recovery_codes_remaining"`
+}
+
+// Second factor methods
+const (
+	MFAMethodTOTP         = "totp"
+	MFAMethodWebAuthn     = "webauthn"
+	MFAMethodRecoveryCode = "recovery_code"
+	MFAMethodEmail        = "email" // Offered in login challenges only, unless the first step was email already
+)
+
+// RecoveryCodeCount is how many recovery codes are issued at a time
+const RecoveryCodeCount = 10
+
+// Token type for the short-lived token linking the two login steps
+const TokenTypeMFAChallenge = "mfa_challenge"
+
+// MFAChallengeDuration is how long a client has to complete the second step
+const MFAChallengeDuration = 5 * time.Minute
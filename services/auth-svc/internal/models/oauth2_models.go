@@ -0,0 +1,168 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// OAuth2 client types (RFC 6749 section 2.1). Confidential clients can keep
+// a secret; public ones, such as SPAs and mobile apps, can't.
+const (
+	ClientTypeConfidential = "confidential"
+	ClientTypePublic       = "public"
+)
+
+// OAuth2 grant types the authorization server supports
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeRefreshToken      = "refresh_token"
+)
+
+// TokenTypeClientAccess is the type of the access tokens clients get for
+// themselves with the client credentials grant. They have no subject, so
+// services only take them on routes that accept client tokens.
+const TokenTypeClientAccess = "client_access"
+
+// Scopes that control which user details a delegated access token carries.
+// openid asks for an ID token as well (OpenID Connect).
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// OAuthClient is an application registered to get delegated access through
+// the authorization server
+type OAuthClient struct {
+	ID           string    `json:"client_id" dynamodb:"client_id"`
+	SecretHash   string    `json:"-" dynamodb:"secret_hash"` // SHA-256 of the secret; confidential clients only
+	Name         string    `json:"name" dynamodb:"name"`
+	Type         string    `json:"client_type" dynamodb:"client_type"`
+	RedirectURIs []string  `json:"redirect_uris" dynamodb:"redirect_uris"`
+	GrantTypes   []string  `json:"grant_types" dynamodb:"grant_types"`
+	Scopes       []string  `json:"scopes" dynamodb:"scopes"`           // The most the client may ask for
+	FirstParty   bool      `json:"first_party" dynamodb:"first_party"` // Platform apps skip the consent screen
+	Introspect   bool      `json:"introspect" dynamodb:"introspect"`   // May introspect tokens from the platform's own logins
+	OwnerID      string    `json:"-" dynamodb:"user_id"`
+	CreatedAt    time.Time `json:"created_at" dynamodb:"created_at"`
+}
+
+// IsConfidential reports whether the client authenticates with a secret
+func (c *OAuthClient) IsConfidential() bool {
+	return c.Type == ClientTypeConfidential
+}
+
+// AllowsGrant reports whether the client registered for grantType
+func (c *OAuthClient) AllowsGrant(grantType string) bool {
+	return slices.Contains(c.GrantTypes, grantType)
+}
+
+// AuthorizationCode is issued when a user approves a client and redeemed once
+// at the token endpoint. Used codes are kept until they expire so a replay
+// can revoke the tokens the first redemption got.
+type AuthorizationCode struct {
+	Code          string    `json:"-" dynamodb:"code"`
+	ClientID      string    `json:"-" dynamodb:"client_id"`
+	UserID        string    `json:"-" dynamodb:"owner_id"`
+	RedirectURI   string    `json:"-" dynamodb:"redirect_uri"` // Only if the authorization request named one
+	Scopes        []string  `json:"-" dynamodb:"scopes"`
+	CodeChallenge string    `json:"-" dynamodb:"code_challenge"`  // S256
+	SessionID     string    `json:"-" dynamodb:"auth_session_id"` // Given to the session the code is redeemed for
+	Nonce         string    `json:"-" dynamodb:"nonce"`
+	AuthTime      time.Time `json:"-" dynamodb:"auth_time"` // Of the login that approved the client
+	AuthMethods   []string  `json:"-" dynamodb:"amr"`
+	Used          bool      `json:"-" dynamodb:"used"`
+	ExpiresAt     time.Time `json:"-" dynamodb:"expires_at"`
+}
+
+// RegisterClientRequest registers an OAuth2 client
+type RegisterClientRequest struct {
+	Name         string   `json:"name" validate:"required,min=2,max=100"`
+	Type         string   `json:"client_type" validate:"required,oneof=confidential public"`
+	RedirectURIs []string `json:"redirect_uris" validate:"max=10,dive,required,max=2000"`
+	GrantTypes   []string `json:"grant_types,omitempty" validate:"dive,oneof=authorization_code client_credentials refresh_token"` // Defaults to authorization_code and refresh_token
+	Scopes       []string `json:"scopes,omitempty"`                                                                                // Defaults to every supported scope
+	FirstParty   bool     `json:"first_party,omitempty"`                                                                           // Admins only
+	Introspect   bool     `json:"introspect,omitempty"`                                                                            // Admins only
+}
+
+// RegisterClientResponse returns a new client. The secret is only ever shown
+// here.
+type RegisterClientResponse struct {
+	*OAuthClient
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// AuthorizationRequest carries the parameters of an authorization request
+// (RFC 6749 section 4.1.1, RFC 7636 section 4.3). The consent page posts them
+// back with the user's decision.
+type AuthorizationRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri,omitempty"`
+	Scope               string `json:"scope,omitempty"`
+	State               string `json:"state,omitempty"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+	Nonce               string `json:"nonce,omitempty"` // OpenID Connect; echoed in the ID token
+
+	Decision string `json:"decision,omitempty"` // "approve" or "deny"; empty asks what to show
+}
+
+// Authorization decisions
+const (
+	DecisionApprove = "approve"
+	DecisionDeny    = "deny"
+)
+
+// AuthorizationResponse either asks for the user's consent or says where to
+// send the browser next
+type AuthorizationResponse struct {
+	ConsentRequired bool         `json:"consent_required"`
+	Client          *OAuthClient `json:"client,omitempty"`
+	Scopes          []string     `json:"scopes,omitempty"`
+	RedirectTo      string       `json:"redirect_to,omitempty"`
+}
+
+// TokenRequest is a token endpoint request (RFC 6749 sections 4.1.3, 4.4.2
+// and 6)
+type TokenRequest struct {
+	GrantType    string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+
+	// Populated by the handler from the request
+	IPAddress string
+	UserAgent string
+}
+
+// TokenResponse is a successful token endpoint response (RFC 6749 section
+// 5.1)
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"` // seconds
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"` // With the openid scope
+}
+
+// IntrospectionResponse describes a token to a resource server (RFC 7662
+// section 2.2). Inactive tokens report nothing else.
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"` // "access_token" or "refresh_token"
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	TokenID   string `json:"jti,omitempty"`
+}
+
+// AuthorizationCodeDuration is how long a client has to redeem a code
+const AuthorizationCodeDuration = 5 * time.Minute
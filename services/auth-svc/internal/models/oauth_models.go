@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// LinkedIdentity ties an account at an external OAuth/OIDC provider to a
+// user, so signing in there signs in here
+type LinkedIdentity struct {
+	Provider string    `json:"provider" dynamodb:"provider"`
+	Subject  string    `json:"-" dynamodb:"subject"` // Account ID at the provider
+	UserID   string    `json:"-" dynamodb:"user_id"`
+	Email    string    `json:"email" dynamodb:"email"` // As reported by the provider when linked
+	LinkedAt time.Time `json:"linked_at" dynamodb:"linked_at"`
+}
+
+// OAuthState is a social login in progress, kept from the redirect to the
+// provider until the user comes back with a code. It is used once.
+type OAuthState struct {
+	State        string    `json:"-" dynamodb:"state"`
+	Provider     string    `json:"-" dynamodb:"provider"`
+	Nonce        string    `json:"-" dynamodb:"nonce"`
+	CodeVerifier string    `json:"-" dynamodb:"code_verifier"`
+	RedirectURI  string    `json:"-" dynamodb:"redirect_uri"`
+	BindingHash  string    `json:"-" dynamodb:"binding_hash"` // SHA-256 of the browser binding
+	ExpiresAt    time.Time `json:"-" dynamodb:"expires_at"`
+}
+
+// OAuthStartResponse sends the user to the provider. The client should keep
+// State and check that the provider's redirect carries the same value.
+type OAuthStartResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"state"`
+	ExpiresIn        int64  `json:"expires_in"` // seconds
+
+	// Binding ties the login to the browser that started it. The handler
+	// sets it as a cookie; it never appears in the body.
+	Binding string `json:"-"`
+}
+
+// OAuthCallbackRequest carries the provider's redirect parameters back
+type OAuthCallbackRequest struct {
+	Code     string `json:"code" validate:"required"`
+	State    string `json:"state" validate:"required"`
+	DeviceID string `json:"device_id,omitempty"`
+
+	// Populated by the handler from the request, never from the body
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
+	Binding   string `json:"-"` // from the cookie set when the login started
+}
+
+// OAuthBindingCookie names the cookie holding a social login's browser
+// binding between the start and callback requests
+const OAuthBindingCookie = "oauth_binding"
+
+// OAuthStateDuration is how long the user has to sign in at the provider
+const OAuthStateDuration = 10 * time.Minute
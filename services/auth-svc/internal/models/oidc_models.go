@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// TokenTypeID marks ID tokens so they can't be used as access tokens
+const TokenTypeID = "id"
+
+// IDTokenDuration is how long an ID token is valid. Clients only check it
+// right after a token response, so it matches the access token.
+const IDTokenDuration = DefaultSessionDuration
+
+// Authentication methods recorded on sessions and reported in the amr claim
+// (RFC 8176)
+const (
+	AuthMethodPassword    = "pwd"
+	AuthMethodOTP         = "otp" // TOTP or recovery code
+	AuthMethodHardwareKey = "hwk" // Passkey
+	AuthMethodMFA         = "mfa"
+	AuthMethodFederated   = "fed"   // Social login; not registered in RFC 8176 but widely used
+	AuthMethodEmail       = "email" // Magic link or emailed code; not registered in RFC 8176
+)
+
+// Authentication context classes reported in the acr claim, after the NIST
+// SP 800-63B assurance levels
+const (
+	ACRSingleFactor = "aal1"
+	ACRMultiFactor  = "aal2"
+)
+
+// UserInfo holds the OpenID Connect standard claims about a user, filtered
+// by the scopes the client was granted
+type UserInfo struct {
+	Subject       string `json:"sub"`
+	Name          string `json:"name,omitempty"`
+	UpdatedAt     int64  `json:"updated_at,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+}
+
+// IDTokenClaims is what an ID token asserts about a login (OpenID Connect
+// Core section 2)
+type IDTokenClaims struct {
+	*UserInfo
+	Issuer           string
+	Audience         string // The client
+	AuthTime         time.Time
+	Nonce            string
+	AuthMethods      []string
+	AuthContextClass string
+}
+
+// OpenIDConfiguration is the provider metadata published for discovery
+// (OpenID Connect Discovery section 3)
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	ResponseModesSupported            []string `json:"response_modes_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	ACRValuesSupported                []string `json:"acr_values_supported"`
+}
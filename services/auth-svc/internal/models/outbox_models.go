@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/multitask-platform/backend/shared/events"
+)
+
+// Outbox message states. Published messages are deleted.
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusDead    = "dead" // Gave up after too many attempts
+)
+
+// OutboxMessage is a domain event waiting to be published. It is keyed by
+// the event ID, which doubles as the idempotency key: storing the same event
+// twice leaves one message, and consumers drop deliveries of an eventId
+// they've seen, since a relay that crashes after publishing sends it again.
+type OutboxMessage struct {
+	ID            string        `json:"id" dynamodb:"id"`
+	Event         *events.Event `json:"event" dynamodb:"event"`
+	Status        string        `json:"status" dynamodb:"outbox_status"`
+	Attempts      int           `json:"attempts" dynamodb:"attempts"`
+	NextAttemptAt time.Time     `json:"next_attempt_at" dynamodb:"next_attempt_at"`
+	LastError     string        `json:"last_error,omitempty" dynamodb:"last_error"`
+	CreatedAt     time.Time     `json:"created_at" dynamodb:"created_at"`
+}
+
+// NewOutboxMessage wraps an event for the outbox, due at once
+func NewOutboxMessage(event *events.Event) *OutboxMessage {
+	return &OutboxMessage{
+		ID:            event.ID,
+		Event:         event,
+		Status:        OutboxStatusPending,
+		NextAttemptAt: event.Time,
+		CreatedAt:     event.Time,
+	}
+}
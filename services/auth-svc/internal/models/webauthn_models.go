@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/webauthn"
+)
+
+// WebAuthnCredential is a passkey registered to a user. ID is the
+// base64url-encoded credential ID the authenticator chose.
+type WebAuthnCredential struct {
+	ID             string     `json:"id" dynamodb:"credential_id"`
+	UserID         string     `json:"-" dynamodb:"user_id"`
+	Name           string     `json:"name" dynamodb:"name"`
+	PublicKey      []byte     `json:"-" dynamodb:"public_key"` // CBOR-encoded COSE_Key
+	SignCount      uint32     `json:"-" dynamodb:"sign_count"`
+	Transports     []string   `json:"transports,omitempty" dynamodb:"transports"`
+	BackupEligible bool       `json:"synced" dynamodb:"backup_eligible"`
+	CreatedAt      time.Time  `json:"created_at" dynamodb:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty" dynamodb:"last_used_at,omitempty"`
+}
+
+// WebAuthnChallenge is a ceremony in progress, kept from the begin request
+// until the authenticator's response comes back. It is used once.
+type WebAuthnChallenge struct {
+	Challenge string    `json:"-" dynamodb:"challenge"`         // base64url
+	Ceremony  string    `json:"-" dynamodb:"ceremony"`          // TokenTypeWebAuthnRegister, TokenTypeWebAuthnLogin or TokenTypeMFAChallenge
+	UserID    string    `json:"-" dynamodb:"challenge_user_id"` // empty for passwordless logins
+	ExpiresAt time.Time `json:"-" dynamodb:"expires_at"`
+}
+
+// ChallengeClaims is what a signed challenge token carries between the two
+// halves of a ceremony
+type ChallengeClaims struct {
+	UserID      string
+	Challenge   []byte   // WebAuthn challenge, if any
+	AuthMethods []string // MFA challenges only: how the first step was passed
+}
+
+// WebAuthnRegisterBeginResponse starts registering a passkey. PublicKey goes
+// to navigator.credentials.create(); ChallengeToken comes back with the result.
+type WebAuthnRegisterBeginResponse struct {
+	ChallengeToken string                    `json:"challenge_token"`
+	PublicKey      *webauthn.CreationOptions `json:"publicKey"`
+}
+
+// WebAuthnRegisterFinishRequest completes registering a passkey
+type WebAuthnRegisterFinishRequest struct {
+	ChallengeToken string                       `json:"challenge_token" validate:"required"`
+	Name           string                       `json:"name,omitempty" validate:"max=64"`
+	Credential     webauthn.AttestationResponse `json:"credential"`
+}
+
+// WebAuthnLoginBeginResponse starts a passwordless login. PublicKey goes to
+// navigator.credentials.get(); ChallengeToken comes back with the result.
+type WebAuthnLoginBeginResponse struct {
+	ChallengeToken string                   `json:"challenge_token"`
+	PublicKey      *webauthn.RequestOptions `json:"publicKey"`
+}
+
+// WebAuthnLoginFinishRequest completes a passwordless login
+type WebAuthnLoginFinishRequest struct {
+	ChallengeToken string                     `json:"challenge_token" validate:"required"`
+	Credential     webauthn.AssertionResponse `json:"credential"`
+	DeviceID       string                     `json:"device_id,omitempty"`
+
+	// Populated by the handler from the request, never from the body
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+// Token types for the short-lived tokens carrying WebAuthn challenges
+const (
+	TokenTypeWebAuthnRegister = "webauthn_register"
+	TokenTypeWebAuthnLogin    = "webauthn_login"
+)
+
+// DefaultPasskeyName is used when the user doesn't name a passkey
+const DefaultPasskeyName = "Passkey"
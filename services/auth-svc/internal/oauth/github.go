@@ -0,0 +1,104 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// GitHub endpoints
+const (
+	gitHubAuthorizeURL = "https://github.com/login/oauth/authorize"
+	gitHubTokenURL     = "https://github.com/login/oauth/access_token"
+	gitHubAPIURL       = "https://api.github.com"
+)
+
+// GitHubProvider signs users in with GitHub. GitHub has no ID tokens, so the
+// account and its emails are read from the REST API with the access token.
+type GitHubProvider struct {
+	cfg    Config
+	client *http.Client
+
+	authorizeURL string
+	tokenURL     string
+	apiURL       string
+}
+
+// gitHubUser is the part of GET /user we use
+type gitHubUser struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
+
+// gitHubEmail is an entry of GET /user/emails
+type gitHubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// NewGitHubProvider creates a provider for github.com
+func NewGitHubProvider(cfg Config, client *http.Client) *GitHubProvider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"read:user", "user:email"}
+	}
+
+	return &GitHubProvider{
+		cfg:          cfg,
+		client:       client,
+		authorizeURL: gitHubAuthorizeURL,
+		tokenURL:     gitHubTokenURL,
+		apiURL:       gitHubAPIURL,
+	}
+}
+
+// Name implements Provider
+func (p *GitHubProvider) Name() string {
+	return p.cfg.Name
+}
+
+// AuthCodeURL implements Provider
+func (p *GitHubProvider) AuthCodeURL(ctx context.Context, req *AuthRequest) (string, error) {
+	return authCodeURL(p.authorizeURL, &p.cfg, req, nil)
+}
+
+// Exchange implements Provider. The email is the account's primary address,
+// and only counts as verified if GitHub has verified that address.
+func (p *GitHubProvider) Exchange(ctx context.Context, code string, req *AuthRequest) (*Identity, error) {
+	token, err := exchangeCode(ctx, p.client, p.tokenURL, &p.cfg, code, req, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var user gitHubUser
+	if err := getJSON(ctx, p.client, p.apiURL+"/user", token.AccessToken, &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, authenticationError("github returned no user ID")
+	}
+
+	var emails []gitHubEmail
+	if err := getJSON(ctx, p.client, p.apiURL+"/user/emails", token.AccessToken, &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Provider: p.cfg.Name,
+		Subject:  strconv.FormatInt(user.ID, 10),
+		Name:     user.Name,
+	}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email = email.Email
+			identity.EmailVerified = email.Verified
+			break
+		}
+	}
+
+	return identity, nil
+}
@@ -0,0 +1,275 @@
+// Package oauth signs users in with external OAuth 2.0 authorization servers
+// using the authorization code flow with PKCE (RFC 7636). OpenID Connect
+// providers are discovered from their issuer and identify the user with a
+// validated ID token; GitHub, which doesn't speak OIDC, is asked through its
+// REST API instead.
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Provider types
+const (
+	TypeOIDC   = "oidc"
+	TypeGitHub = "github"
+)
+
+// maxResponseSize caps what is read from a provider
+const maxResponseSize = 1 << 20
+
+// validName keeps provider names safe to use in paths and storage keys
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// ErrAuthentication wraps every reason a provider's answer is rejected: a bad
+// or reused code, an invalid ID token, a missing subject. Other errors mean
+// the provider couldn't be reached or misbehaved.
+var ErrAuthentication = errors.New("oauth authentication failed")
+
+func authenticationError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrAuthentication, fmt.Sprintf(format, args...))
+}
+
+// Config describes a provider registered with us as a client
+type Config struct {
+	Name         string // Used in routes, e.g. "google"
+	Type         string // TypeOIDC or TypeGitHub
+	ClientID     string
+	ClientSecret string   // Empty for public clients
+	Issuer       string   // OIDC only
+	Scopes       []string // Defaults to what's needed for the user's email
+}
+
+// Identity is the provider account that signed in
+type Identity struct {
+	Provider      string
+	Subject       string // Stable account ID at the provider
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// AuthRequest is one login in progress. The same values must be passed to
+// AuthCodeURL and to Exchange.
+type AuthRequest struct {
+	State        string
+	Nonce        string // Bound into the ID token by OIDC providers
+	CodeVerifier string
+	RedirectURI  string
+}
+
+// Provider is an authorization server users can sign in with
+type Provider interface {
+	// Name is the key the provider is configured and routed under
+	Name() string
+
+	// AuthCodeURL is where to send the user to sign in
+	AuthCodeURL(ctx context.Context, req *AuthRequest) (string, error)
+
+	// Exchange redeems the code the provider redirected back with and
+	// returns the identity it belongs to
+	Exchange(ctx context.Context, code string, req *AuthRequest) (*Identity, error)
+}
+
+// New creates a provider from its configuration. A nil client uses one with
+// a short timeout.
+func New(cfg Config, client *http.Client) (Provider, error) {
+	if !validName.MatchString(cfg.Name) {
+		return nil, fmt.Errorf("invalid oauth provider name %q", cfg.Name)
+	}
+	if cfg.ClientID == "" {
+		return nil, fmt.Errorf("oauth provider %q: client ID is required", cfg.Name)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	switch cfg.Type {
+	case TypeOIDC, "":
+		if cfg.Issuer == "" {
+			return nil, fmt.Errorf("oauth provider %q: issuer is required", cfg.Name)
+		}
+		return NewOIDCProvider(cfg, client), nil
+	case TypeGitHub:
+		return NewGitHubProvider(cfg, client), nil
+	default:
+		return nil, fmt.Errorf("oauth provider %q: unknown type %q", cfg.Name, cfg.Type)
+	}
+}
+
+// NewAuthRequest generates the random state, nonce and PKCE verifier for a
+// login that will return to redirectURI
+func NewAuthRequest(redirectURI string) (*AuthRequest, error) {
+	state, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthRequest{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		RedirectURI:  redirectURI,
+	}, nil
+}
+
+// CodeChallenge is the S256 PKCE challenge for verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// randomString returns 32 random bytes as base64url, which also makes a
+// valid 43 character PKCE verifier
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random value: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// authCodeURL adds the authorization request parameters to endpoint
+func authCodeURL(endpoint string, cfg *Config, req *AuthRequest, extra url.Values) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint: %w", err)
+	}
+
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", cfg.ClientID)
+	q.Set("redirect_uri", req.RedirectURI)
+	q.Set("scope", strings.Join(cfg.Scopes, " "))
+	q.Set("state", req.State)
+	q.Set("code_challenge", CodeChallenge(req.CodeVerifier))
+	q.Set("code_challenge_method", "S256")
+	for key, values := range extra {
+		q[key] = values
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// tokenResponse is the token endpoint's answer (RFC 6749 section 5)
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// exchangeCode redeems code at the token endpoint. With basicAuth the client
+// authenticates with HTTP Basic (client_secret_basic), otherwise in the body
+// (client_secret_post).
+func exchangeCode(ctx context.Context, client *http.Client, endpoint string, cfg *Config, code string, req *AuthRequest, basicAuth bool) (*tokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {req.RedirectURI},
+		"code_verifier": {req.CodeVerifier},
+	}
+	if cfg.ClientSecret == "" || !basicAuth {
+		form.Set("client_id", cfg.ClientID)
+	}
+	if cfg.ClientSecret != "" && !basicAuth {
+		form.Set("client_secret", cfg.ClientSecret)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build token request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Accept", "application/json")
+	if cfg.ClientSecret != "" && basicAuth {
+		// RFC 6749 section 2.3.1: form-encode before Basic encoding
+		httpReq.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("failed to decode token response (status %d): %w", resp.StatusCode, err)
+	}
+
+	// GitHub reports errors with a 200
+	if token.Error != "" {
+		return nil, authenticationError("token endpoint: %s %s", token.Error, token.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned status %d", resp.StatusCode)
+	}
+	if token.AccessToken == "" {
+		return nil, authenticationError("token endpoint returned no access token")
+	}
+
+	return &token, nil
+}
+
+// getJSON fetches endpoint with the access token and decodes the response
+// into out
+func getJSON(ctx context.Context, client *http.Client, endpoint, accessToken string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return authenticationError("%s rejected the access token", endpoint)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch %s: status %d", endpoint, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", endpoint, err)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", endpoint, err)
+	}
+	return nil
+}
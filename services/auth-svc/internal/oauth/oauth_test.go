@@ -0,0 +1,106 @@
+package oauth
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestCodeChallenge(t *testing.T) {
+	tests := []struct {
+		name     string
+		verifier string
+		want     string
+	}{
+		// RFC 7636 appendix B
+		{name: "RFC 7636 example", verifier: "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk", want: "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"},
+		{name: "empty", verifier: "", want: "47DEQpj8HBSa-_TImW-5JCeuQeRkm5NMpJWZG3hSuFU"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CodeChallenge(tt.verifier); got != tt.want {
+				t.Fatalf("CodeChallenge(%q) = %q, want %q", tt.verifier, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAuthRequest(t *testing.T) {
+	first, err := NewAuthRequest("https://app.example.com/oauth/dev/callback")
+	if err != nil {
+		t.Fatalf("NewAuthRequest: %v", err)
+	}
+	second, err := NewAuthRequest("https://app.example.com/oauth/dev/callback")
+	if err != nil {
+		t.Fatalf("NewAuthRequest: %v", err)
+	}
+
+	// RFC 7636 section 4.1: 43 to 128 characters
+	if n := len(first.CodeVerifier); n < 43 || n > 128 {
+		t.Fatalf("code verifier length = %d, want 43 to 128", n)
+	}
+	if first.State == second.State || first.Nonce == second.Nonce || first.CodeVerifier == second.CodeVerifier {
+		t.Fatal("NewAuthRequest() repeated a random value")
+	}
+	if first.State == first.Nonce || first.State == first.CodeVerifier {
+		t.Fatal("NewAuthRequest() reused one random value for another")
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	cfg := &Config{Name: "dev", ClientID: "dev-client", Scopes: []string{"openid", "email"}}
+	req := &AuthRequest{
+		State:        "state-1",
+		Nonce:        "nonce-1",
+		CodeVerifier: "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk",
+		RedirectURI:  "https://app.example.com/oauth/dev/callback",
+	}
+
+	raw, err := authCodeURL("https://idp.example.com/authorize?prompt=login", cfg, req, url.Values{"nonce": {req.Nonce}})
+	if err != nil {
+		t.Fatalf("authCodeURL: %v", err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	want := map[string]string{
+		"prompt":                "login",
+		"response_type":         "code",
+		"client_id":             "dev-client",
+		"redirect_uri":          req.RedirectURI,
+		"scope":                 "openid email",
+		"state":                 "state-1",
+		"nonce":                 "nonce-1",
+		"code_challenge":        "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+		"code_challenge_method": "S256",
+	}
+	q := u.Query()
+	for param, value := range want {
+		if got := q.Get(param); got != value {
+			t.Errorf("%s = %q, want %q", param, got, value)
+		}
+	}
+	if q.Has("code_verifier") {
+		t.Error("authorization URL leaks the code verifier")
+	}
+}
+
+func TestUsesBasicAuth(t *testing.T) {
+	tests := []struct {
+		methods []string
+		want    bool
+	}{
+		{methods: nil, want: true},
+		{methods: []string{"client_secret_basic", "client_secret_post"}, want: true},
+		{methods: []string{"client_secret_post"}, want: false},
+		{methods: []string{"private_key_jwt"}, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := usesBasicAuth(tt.methods); got != tt.want {
+			t.Errorf("usesBasicAuth(%q) = %v, want %v", tt.methods, got, tt.want)
+		}
+	}
+}
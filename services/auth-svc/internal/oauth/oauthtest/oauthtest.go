@@ -0,0 +1,322 @@
+// Package oauthtest provides a minimal OpenID Connect provider for tests and
+// local development. Every authorization request is approved on the spot as
+// the configured user, or the address in the login_hint parameter, so the
+// whole flow can be driven without a browser.
+//
+// Clients, redirect URIs and PKCE verifiers are checked like a real provider
+// would, but anyone can sign in as anyone. Never point a deployed stage at it.
+package oauthtest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/oauth"
+	"github.com/multitask-platform/backend/shared/jwtkeys"
+)
+
+const (
+	codeLifetime  = time.Minute
+	tokenLifetime = 5 * time.Minute
+)
+
+// Config describes the one client the provider accepts and the user who
+// signs in
+type Config struct {
+	Issuer          string // Base URL the provider is served at
+	ClientID        string
+	ClientSecret    string
+	Email           string // Signs in unless the request has a login_hint
+	Name            string
+	EmailUnverified bool // Report the email as unverified
+
+	// Logf, if set, is told about every approved login
+	Logf func(format string, args ...interface{})
+}
+
+// grant is what a code, and then an access token, was issued for
+type grant struct {
+	redirectURI   string
+	codeChallenge string
+	nonce         string
+	subject       string
+	email         string
+	name          string
+	expiresAt     time.Time
+}
+
+// Provider is the fake provider's HTTP handler
+type Provider struct {
+	cfg  Config
+	keys *jwtkeys.KeySet
+	mux  *http.ServeMux
+
+	mu     sync.Mutex
+	codes  map[string]*grant
+	tokens map[string]*grant
+}
+
+// NewProvider creates a provider with a fresh signing key
+func NewProvider(cfg Config) (*Provider, error) {
+	if cfg.Issuer == "" || cfg.ClientID == "" {
+		return nil, errors.New("oauthtest: issuer and client ID are required")
+	}
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+
+	key, err := jwtkeys.GenerateSigningKey(jwtkeys.AlgorithmES256)
+	if err != nil {
+		return nil, fmt.Errorf("oauthtest: %w", err)
+	}
+	keys, err := jwtkeys.NewKeySet([]*jwtkeys.Key{key})
+	if err != nil {
+		return nil, fmt.Errorf("oauthtest: %w", err)
+	}
+
+	p := &Provider{
+		cfg:    cfg,
+		keys:   keys,
+		mux:    http.NewServeMux(),
+		codes:  make(map[string]*grant),
+		tokens: make(map[string]*grant),
+	}
+	p.mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	p.mux.HandleFunc("/authorize", p.authorize)
+	p.mux.HandleFunc("/token", p.token)
+	p.mux.HandleFunc("/userinfo", p.userinfo)
+	p.mux.HandleFunc("/jwks", p.jwks)
+
+	return p, nil
+}
+
+// NewServer starts a provider on a local test server, with the server's URL
+// as issuer. Callers must Close it.
+func NewServer(cfg Config) (*httptest.Server, error) {
+	srv := httptest.NewUnstartedServer(nil)
+	cfg.Issuer = "http://" + srv.Listener.Addr().String()
+
+	p, err := NewProvider(cfg)
+	if err != nil {
+		srv.Close()
+		return nil, err
+	}
+	srv.Config.Handler = p
+	srv.Start()
+
+	return srv, nil
+}
+
+// Issuer is the provider's issuer URL
+func (p *Provider) Issuer() string {
+	return p.cfg.Issuer
+}
+
+// ServeHTTP implements http.Handler
+func (p *Provider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mux.ServeHTTP(w, r)
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := p.cfg.Issuer
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/authorize",
+		"token_endpoint":                        issuer + "/token",
+		"userinfo_endpoint":                     issuer + "/userinfo",
+		"jwks_uri":                              issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{jwtkeys.AlgorithmES256},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+		"scopes_supported":                      []string{"openid", "email", "profile"},
+	})
+}
+
+// authorize approves the request and redirects straight back with a code.
+// Errors are shown rather than redirected, as the redirect URI isn't trusted.
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	switch {
+	case q.Get("client_id") != p.cfg.ClientID:
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	case err != nil || !redirectURI.IsAbs():
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	case q.Get("response_type") != "code":
+		http.Error(w, "unsupported response_type", http.StatusBadRequest)
+		return
+	case q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256":
+		http.Error(w, "PKCE with S256 is required", http.StatusBadRequest)
+		return
+	}
+
+	email := p.cfg.Email
+	if hint := q.Get("login_hint"); hint != "" {
+		email = hint
+	}
+	subject := sha256.Sum256([]byte(strings.ToLower(email)))
+
+	code := randomToken()
+	p.mu.Lock()
+	p.codes[code] = &grant{
+		redirectURI:   redirectURI.String(),
+		codeChallenge: q.Get("code_challenge"),
+		nonce:         q.Get("nonce"),
+		subject:       hex.EncodeToString(subject[:8]),
+		email:         email,
+		name:          p.cfg.Name,
+		expiresAt:     time.Now().Add(codeLifetime),
+	}
+	p.mu.Unlock()
+
+	params := redirectURI.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirectURI.RawQuery = params.Encode()
+
+	if p.cfg.Logf != nil {
+		p.cfg.Logf("authorized %s, redirecting to %s", email, redirectURI.Host+redirectURI.Path)
+	}
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != p.cfg.ClientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(p.cfg.ClientSecret)) != 1 {
+		tokenError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	// Codes are single use even when the request fails
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	g, ok := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+
+	switch {
+	case !ok || time.Now().After(g.expiresAt):
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	case r.PostForm.Get("redirect_uri") != g.redirectURI:
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	case oauth.CodeChallenge(r.PostForm.Get("code_verifier")) != g.codeChallenge:
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	idToken, err := p.keys.Sign(jwt.MapClaims{
+		"iss":            p.cfg.Issuer,
+		"sub":            g.subject,
+		"aud":            p.cfg.ClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(tokenLifetime).Unix(),
+		"nonce":          g.nonce,
+		"email":          g.email,
+		"email_verified": !p.cfg.EmailUnverified,
+		"name":           g.name,
+	})
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+
+	accessToken := randomToken()
+	g.expiresAt = now.Add(tokenLifetime)
+	p.mu.Lock()
+	p.tokens[accessToken] = g
+	p.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(tokenLifetime.Seconds()),
+		"id_token":     idToken,
+	})
+}
+
+func (p *Provider) userinfo(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	p.mu.Lock()
+	g, ok := p.tokens[token]
+	p.mu.Unlock()
+	if !ok || time.Now().After(g.expiresAt) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"sub":            g.subject,
+		"email":          g.email,
+		"email_verified": !p.cfg.EmailUnverified,
+		"name":           g.name,
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	set, err := p.keys.JWKS()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, set)
+}
+
+func tokenError(w http.ResponseWriter, status int, code string) {
+	writeJSON(w, status, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
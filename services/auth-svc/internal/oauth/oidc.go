@@ -0,0 +1,220 @@
+package oauth
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/multitask-platform/backend/shared/jwtkeys"
+)
+
+// idTokenLeeway allows for clock skew between us and the provider
+const idTokenLeeway = time.Minute
+
+// idTokenAlgorithms are the signatures accepted on ID tokens
+var idTokenAlgorithms = []string{jwtkeys.AlgorithmRS256, jwtkeys.AlgorithmES256, jwtkeys.AlgorithmEdDSA}
+
+// OIDCProvider signs users in with an OpenID Connect provider. Its endpoints
+// and keys are discovered from the issuer on first use.
+type OIDCProvider struct {
+	cfg    Config
+	client *http.Client
+	now    func() time.Time
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	keys      *jwtkeys.RemoteKeySet
+}
+
+// discoveryDocument is the part of the provider metadata we use (OpenID
+// Connect Discovery 1.0 section 3)
+type discoveryDocument struct {
+	Issuer                   string   `json:"issuer"`
+	AuthorizationEndpoint    string   `json:"authorization_endpoint"`
+	TokenEndpoint            string   `json:"token_endpoint"`
+	UserInfoEndpoint         string   `json:"userinfo_endpoint"`
+	JWKSURI                  string   `json:"jwks_uri"`
+	TokenEndpointAuthMethods []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// idTokenClaims are the ID token claims we check or use
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce           string      `json:"nonce"`
+	AuthorizedParty string      `json:"azp"`
+	Email           string      `json:"email"`
+	EmailVerified   interface{} `json:"email_verified"` // Some providers send "true"
+	Name            string      `json:"name"`
+}
+
+// userInfo is the UserInfo endpoint's answer
+type userInfo struct {
+	Subject       string      `json:"sub"`
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"`
+	Name          string      `json:"name"`
+}
+
+// NewOIDCProvider creates a provider for cfg.Issuer
+func NewOIDCProvider(cfg Config, client *http.Client) *OIDCProvider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	} else if !slices.Contains(cfg.Scopes, "openid") {
+		cfg.Scopes = append([]string{"openid"}, cfg.Scopes...)
+	}
+
+	return &OIDCProvider{
+		cfg:    cfg,
+		client: client,
+		now:    time.Now,
+	}
+}
+
+// Name implements Provider
+func (p *OIDCProvider) Name() string {
+	return p.cfg.Name
+}
+
+// AuthCodeURL implements Provider
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, req *AuthRequest) (string, error) {
+	doc, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return authCodeURL(doc.AuthorizationEndpoint, &p.cfg, req, url.Values{"nonce": {req.Nonce}})
+}
+
+// Exchange implements Provider. The identity comes from the ID token; the
+// UserInfo endpoint is only asked when the token carries no email.
+func (p *OIDCProvider) Exchange(ctx context.Context, code string, req *AuthRequest) (*Identity, error) {
+	doc, keys, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := exchangeCode(ctx, p.client, doc.TokenEndpoint, &p.cfg, code, req, usesBasicAuth(doc.TokenEndpointAuthMethods))
+	if err != nil {
+		return nil, err
+	}
+	if token.IDToken == "" {
+		return nil, authenticationError("token endpoint returned no ID token")
+	}
+
+	claims, err := p.verifyIDToken(token.IDToken, keys, req.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Provider:      p.cfg.Name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: isTrue(claims.EmailVerified),
+		Name:          claims.Name,
+	}
+
+	if identity.Email == "" && doc.UserInfoEndpoint != "" {
+		var info userInfo
+		if err := getJSON(ctx, p.client, doc.UserInfoEndpoint, token.AccessToken, &info); err != nil {
+			return nil, err
+		}
+		// The UserInfo response must be about the ID token's subject
+		if info.Subject != claims.Subject {
+			return nil, authenticationError("userinfo subject does not match ID token")
+		}
+		identity.Email = info.Email
+		identity.EmailVerified = isTrue(info.EmailVerified)
+		if identity.Name == "" {
+			identity.Name = info.Name
+		}
+	}
+
+	return identity, nil
+}
+
+// verifyIDToken checks the ID token's signature, issuer, audience, lifetime
+// and nonce (OpenID Connect Core 1.0 section 3.1.3.7)
+func (p *OIDCProvider) verifyIDToken(raw string, keys *jwtkeys.RemoteKeySet, nonce string) (*idTokenClaims, error) {
+	claims := &idTokenClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, keys.Keyfunc,
+		jwt.WithValidMethods(idTokenAlgorithms),
+		jwt.WithIssuer(p.cfg.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(idTokenLeeway),
+		jwt.WithTimeFunc(p.now),
+	)
+	if err != nil {
+		return nil, authenticationError("invalid ID token: %v", err)
+	}
+
+	if claims.Subject == "" {
+		return nil, authenticationError("ID token has no subject")
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.cfg.ClientID {
+		return nil, authenticationError("ID token was issued to another client")
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, authenticationError("ID token nonce mismatch")
+	}
+
+	return claims, nil
+}
+
+// discover fetches the provider metadata once and keeps it; a failed fetch
+// is retried on the next login
+func (p *OIDCProvider) discover(ctx context.Context) (*discoveryDocument, *jwtkeys.RemoteKeySet, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, p.keys, nil
+	}
+
+	endpoint := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	var doc discoveryDocument
+	if err := getJSON(ctx, p.client, endpoint, "", &doc); err != nil {
+		return nil, nil, fmt.Errorf("oidc discovery for %q: %w", p.cfg.Name, err)
+	}
+
+	if doc.Issuer != p.cfg.Issuer {
+		return nil, nil, fmt.Errorf("oidc discovery for %q: issuer %q does not match %q", p.cfg.Name, doc.Issuer, p.cfg.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, nil, fmt.Errorf("oidc discovery for %q: missing endpoints", p.cfg.Name)
+	}
+
+	p.discovery = &doc
+	p.keys = jwtkeys.NewRemoteKeySet(doc.JWKSURI, p.client)
+	return p.discovery, p.keys, nil
+}
+
+// usesBasicAuth picks client_secret_basic, the default, unless the provider
+// only lists client_secret_post
+func usesBasicAuth(methods []string) bool {
+	if len(methods) == 0 || slices.Contains(methods, "client_secret_basic") {
+		return true
+	}
+	return !slices.Contains(methods, "client_secret_post")
+}
+
+// isTrue reads a boolean claim that may be sent as a string
+func isTrue(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		return b == "true"
+	default:
+		return false
+	}
+}
@@ -0,0 +1,2773 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/shared/config"
+	"github.com/multitask-platform/backend/shared/events"
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+// Single-table key design for the auth sessions table.
+//
+// Every item lives under the table's "session_id" partition key with an
+// entity prefix. Sessions and tokens carry "user_id" so the user-id-index GSI
+// can list them per user; user items carry "email_key" for the email-index GSI.
+// Outbox messages carry "outbox_status" and "next_attempt_at", the keys of the
+// outbox-index GSI, so the relay can query the pending ones that are due.
+//
+//	USER#<user_id>     user profile + password hash
+//	EMAIL#<email>      uniqueness guard for a normalized email address
+//	SESSION#<id>       refresh session (TTL on expires_at)
+//	VERIFY#<token>     email verification token (TTL on expires_at)
+//	RESET#<token>      password reset token (TTL on expires_at)
+//	MAGICLINK#<token>  magic link login token (TTL on expires_at)
+//	EMAILOTP#<purpose>#<user_id>  emailed one-time code and its attempt count (TTL on expires_at)
+//	ATTEMPTS#<key>     failed login counter / lockout for a user or IP (TTL on expires_at)
+//	TOTP#<user_id>     encrypted TOTP secret and last accepted time step
+//	RECOVERY#<user_id> hashes of unused MFA recovery codes
+//	CREDENTIAL#<id>    WebAuthn credential, listed per user via user-id-index
+//	IDENTITY#<provider>#<subject>  external OAuth/OIDC account linked to a user
+//	OAUTHSTATE#<state> social login in progress (TTL on expires_at)
+//	OAUTHCLIENT#<id>   OAuth2 client, listed per owner via user-id-index
+//	OAUTHCODE#<code>   OAuth2 authorization code (TTL on expires_at)
+//	AUDIT#<sequence>   audit log event, zero-padded so keys sort in order; listed per user via user-id-index
+//	AUDITHEAD          sequence number and hash of the newest audit event
+//	OUTBOX#<event_id>  domain event waiting to be published, or dead-lettered
+//
+// Anonymous sessions live in their own table keyed by "anonymous_id".
+const (
+	attrPK       = "session_id"
+	attrEntity   = "entity"
+	attrUserID   = "user_id"
+	attrEmailKey = "email_key"
+	attrNameKey  = "name_key" // Lowercased name, for ListUsers' search
+	attrTTL      = "expires_at"
+
+	entityUser        = "user"
+	entityEmail       = "email"
+	entitySession     = "session"
+	entityVerifyToken = "verify_token"
+	entityResetToken  = "reset_token"
+	entityMagicLink   = "magic_link_token"
+	entityEmailOTP    = "email_otp"
+	entityAttempts    = "login_attempts"
+	entityTOTP        = "totp"
+	entityRecovery    = "recovery_codes"
+	entityCredential  = "webauthn_credential"
+	entityChallenge   = "webauthn_challenge"
+	entityIdentity    = "linked_identity"
+	entityOAuthState  = "oauth_state"
+	entityOAuthClient = "oauth_client"
+	entityOAuthCode   = "oauth_code"
+	entityAuditEvent  = "audit_event"
+	entityAuditHead   = "audit_head"
+	entityOutbox      = "outbox_message"
+
+	userIDIndex = "user-id-index"
+	emailIndex  = "email-index"
+	outboxIndex = "outbox-index"
+
+	prefixUser       = "USER#"
+	prefixEmail      = "EMAIL#"
+	prefixSession    = "SESSION#"
+	prefixVerify     = "VERIFY#"
+	prefixReset      = "RESET#"
+	prefixMagicLink  = "MAGICLINK#"
+	prefixEmailOTP   = "EMAILOTP#"
+	prefixAttempts   = "ATTEMPTS#"
+	prefixTOTP       = "TOTP#"
+	prefixRecovery   = "RECOVERY#"
+	prefixCredential = "CREDENTIAL#"
+	prefixChallenge  = "WEBAUTHNCHALLENGE#"
+	prefixIdentity   = "IDENTITY#"
+	prefixOAuthState = "OAUTHSTATE#"
+	prefixClient     = "OAUTHCLIENT#"
+	prefixCode       = "OAUTHCODE#"
+	prefixAudit      = "AUDIT#"
+	auditHeadPK      = "AUDITHEAD"
+	prefixOutbox     = "OUTBOX#"
+)
+
+// DynamoDBAPI is the subset of the DynamoDB client used by the repositories
+type DynamoDBAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+// NewDynamoDBClient creates a DynamoDB client for the configured region.
+// When DYNAMODB_ENDPOINT is set (e.g. http://localhost:8000 for DynamoDB Local)
+// requests are sent there instead of the regional AWS endpoint.
+func NewDynamoDBClient(ctx context.Context, cfg *config.Config) (*dynamodb.Client, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(cfg.Region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	return dynamodb.NewFromConfig(awsCfg, func(o *dynamodb.Options) {
+		if cfg.DynamoDB.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.DynamoDB.Endpoint)
+		}
+	}), nil
+}
+
+// DynamoDBUserRepository implements UserRepository on the auth sessions table
+type DynamoDBUserRepository struct {
+	client    DynamoDBAPI
+	tableName string
+}
+
+// NewDynamoDBUserRepository creates a user repository backed by tableName
+func NewDynamoDBUserRepository(client DynamoDBAPI, tableName string) *DynamoDBUserRepository {
+	return &DynamoDBUserRepository{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+var (
+	_ UserRepository       = (*DynamoDBUserRepository)(nil)
+	_ SessionRepository    = (*DynamoDBSessionRepository)(nil)
+	_ CredentialRepository = (*DynamoDBCredentialRepository)(nil)
+	_ IdentityRepository   = (*DynamoDBIdentityRepository)(nil)
+	_ ClientRepository     = (*DynamoDBClientRepository)(nil)
+	_ AuditRepository      = (*DynamoDBAuditRepository)(nil)
+	_ OutboxRepository     = (*DynamoDBOutboxRepository)(nil)
+)
+
+// CreateUser writes the user and its email guard in one transaction so two
+// concurrent registrations for the same address cannot both succeed
+func (r *DynamoDBUserRepository) CreateUser(ctx context.Context, user *models.User, passwordHash string, outbox ...*events.Event) error {
+	puts, err := outboxPuts(r.tableName, outbox)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+
+	item := marshalUser(user)
+	item["password_hash"] = &types.AttributeValueMemberS{Value: passwordHash}
+
+	_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: append([]types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName:           aws.String(r.tableName),
+					Item:                item,
+					ConditionExpression: aws.String("attribute_not_exists(#pk)"),
+					ExpressionAttributeNames: map[string]string{
+						"#pk": attrPK,
+					},
+				},
+			},
+			{
+				Put: &types.Put{
+					TableName:           aws.String(r.tableName),
+					Item:                emailGuardItem(user.Email, user.ID),
+					ConditionExpression: aws.String("attribute_not_exists(#pk)"),
+					ExpressionAttributeNames: map[string]string{
+						"#pk": attrPK,
+					},
+				},
+			},
+		}, puts...),
+	})
+	logger.LogDatabaseOperation(ctx, "CreateUser", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrUserExists
+		}
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DynamoDBUserRepository) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	item, err := r.getUserItem(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalUser(item), nil
+}
+
+func (r *DynamoDBUserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	start := time.Now()
+
+	out, err := r.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(emailIndex),
+		KeyConditionExpression: aws.String("#email = :email"),
+		ExpressionAttributeNames: map[string]string{
+			"#email": attrEmailKey,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":email": &types.AttributeValueMemberS{Value: normalizeEmail(email)},
+		},
+		Limit: aws.Int32(1),
+	})
+	logger.LogDatabaseOperation(ctx, "GetUserByEmail", r.tableName, time.Since(start), err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user by email: %w", err)
+	}
+
+	if len(out.Items) == 0 {
+		return nil, ErrUserNotFound
+	}
+	return unmarshalUser(out.Items[0]), nil
+}
+
+// UpdateUser updates the profile attributes of an existing user. The password
+// hash is left untouched; an email change also moves the email guard item.
+func (r *DynamoDBUserRepository) UpdateUser(ctx context.Context, user *models.User) error {
+	existing, err := r.GetUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	update := &types.Update{
+		TableName:           aws.String(r.tableName),
+		Key:                 userKey(user.ID),
+		UpdateExpression:    aws.String("SET #email = :email, #email_key = :email_key, #name = :name, #name_key = :name_key, is_verified = :verified, is_active = :active, #roles = :roles, updated_at = :updated"),
+		ConditionExpression: aws.String("attribute_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk":        attrPK,
+			"#email":     "email",
+			"#email_key": attrEmailKey,
+			"#name":      "name",
+			"#name_key":  attrNameKey,
+			"#roles":     "roles",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":email":     &types.AttributeValueMemberS{Value: user.Email},
+			":email_key": &types.AttributeValueMemberS{Value: normalizeEmail(user.Email)},
+			":name":      &types.AttributeValueMemberS{Value: user.Name},
+			":name_key":  &types.AttributeValueMemberS{Value: strings.ToLower(user.Name)},
+			":verified":  &types.AttributeValueMemberBOOL{Value: user.IsVerified},
+			":active":    &types.AttributeValueMemberBOOL{Value: user.IsActive},
+			":roles":     stringList(user.Roles),
+			":updated":   timeValue(user.UpdatedAt),
+		},
+	}
+
+	items := []types.TransactWriteItem{{Update: update}}
+	if normalizeEmail(existing.Email) != normalizeEmail(user.Email) {
+		items = append(items,
+			types.TransactWriteItem{
+				Put: &types.Put{
+					TableName:           aws.String(r.tableName),
+					Item:                emailGuardItem(user.Email, user.ID),
+					ConditionExpression: aws.String("attribute_not_exists(#pk)"),
+					ExpressionAttributeNames: map[string]string{
+						"#pk": attrPK,
+					},
+				},
+			},
+			types.TransactWriteItem{
+				Delete: &types.Delete{
+					TableName: aws.String(r.tableName),
+					Key:       emailGuardKey(existing.Email),
+				},
+			},
+		)
+	}
+
+	start := time.Now()
+	_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	logger.LogDatabaseOperation(ctx, "UpdateUser", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			if len(items) > 1 {
+				return ErrUserExists
+			}
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteUser removes the user's tokens and email codes first, so a failure
+// leaves the user in place to retry the deletion
+func (r *DynamoDBUserRepository) DeleteUser(ctx context.Context, userID string, outbox ...*events.Event) error {
+	user, err := r.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = deleteUserItems(ctx, r.client, r.tableName, "DeleteUserTokens", userID,
+		entityVerifyToken, entityResetToken, entityMagicLink, entityEmailOTP)
+	if err != nil {
+		return err
+	}
+
+	puts, err := outboxPuts(r.tableName, outbox)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: append([]types.TransactWriteItem{
+			{Delete: &types.Delete{TableName: aws.String(r.tableName), Key: userKey(userID)}},
+			{Delete: &types.Delete{TableName: aws.String(r.tableName), Key: emailGuardKey(user.Email)}},
+			{Delete: &types.Delete{TableName: aws.String(r.tableName), Key: totpKey(userID)}},
+			{Delete: &types.Delete{TableName: aws.String(r.tableName), Key: recoveryKey(userID)}},
+		}, puts...),
+	})
+	logger.LogDatabaseOperation(ctx, "DeleteUser", r.tableName, time.Since(start), err)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	return nil
+}
+
+// ListUsers scans the table for user items. A scan applies its limit before
+// the filter, so pages are read until there are enough matches; the cursor
+// is the key of the last item read, whatever its entity.
+func (r *DynamoDBUserRepository) ListUsers(ctx context.Context, query string, limit int, cursor string) ([]*models.User, string, error) {
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(r.tableName),
+		FilterExpression: aws.String("#entity = :entity"),
+		ExpressionAttributeNames: map[string]string{
+			"#entity": attrEntity,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":entity": &types.AttributeValueMemberS{Value: entityUser},
+		},
+	}
+	if query != "" {
+		input.FilterExpression = aws.String("#entity = :entity AND (contains(#email_key, :query) OR contains(#name_key, :query))")
+		input.ExpressionAttributeNames["#email_key"] = attrEmailKey
+		input.ExpressionAttributeNames["#name_key"] = attrNameKey
+		input.ExpressionAttributeValues[":query"] = &types.AttributeValueMemberS{Value: strings.ToLower(query)}
+	}
+	if after != "" {
+		input.ExclusiveStartKey = pkKey(after)
+	}
+
+	users := make([]*models.User, 0, limit)
+	for {
+		// Never read more items than the page has room for, so no user is
+		// skipped between the last one returned and the cursor
+		input.Limit = aws.Int32(int32(limit - len(users)))
+
+		start := time.Now()
+		out, err := r.client.Scan(ctx, input)
+		logger.LogDatabaseOperation(ctx, "ListUsers", r.tableName, time.Since(start), err)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan users: %w", err)
+		}
+
+		for _, item := range out.Items {
+			users = append(users, unmarshalUser(item))
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return users, "", nil
+		}
+		if len(users) == limit {
+			return users, encodeCursor(stringAttr(out.LastEvaluatedKey, attrPK)), nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+func (r *DynamoDBUserRepository) GetPasswordHash(ctx context.Context, userID string) (string, error) {
+	item, err := r.getUserItem(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return stringAttr(item, "password_hash"), nil
+}
+
+func (r *DynamoDBUserRepository) UpdatePassword(ctx context.Context, userID, passwordHash string, outbox ...*events.Event) error {
+	return r.updateUserAttrs(ctx, "UpdatePassword", userID, "SET password_hash = :hash, updated_at = :updated", map[string]types.AttributeValue{
+		":hash":    &types.AttributeValueMemberS{Value: passwordHash},
+		":updated": timeValue(time.Now().UTC()),
+	}, outbox...)
+}
+
+func (r *DynamoDBUserRepository) UpdateLastLogin(ctx context.Context, userID string, loginTime time.Time) error {
+	return r.updateUserAttrs(ctx, "UpdateLastLogin", userID, "SET last_login_at = :login", map[string]types.AttributeValue{
+		":login": timeValue(loginTime),
+	})
+}
+
+func (r *DynamoDBUserRepository) CreateEmailVerificationToken(ctx context.Context, userID, email, token string, duration time.Duration) error {
+	now := time.Now().UTC()
+	item := map[string]types.AttributeValue{
+		attrPK:       &types.AttributeValueMemberS{Value: prefixVerify + token},
+		attrEntity:   &types.AttributeValueMemberS{Value: entityVerifyToken},
+		attrUserID:   &types.AttributeValueMemberS{Value: userID},
+		"email":      &types.AttributeValueMemberS{Value: email},
+		"used":       &types.AttributeValueMemberBOOL{Value: false},
+		"created_at": timeValue(now),
+		attrTTL:      unixValue(now.Add(duration)),
+	}
+	return r.putItem(ctx, "CreateEmailVerificationToken", item)
+}
+
+func (r *DynamoDBUserRepository) VerifyEmailToken(ctx context.Context, token string) (string, error) {
+	return r.verifyToken(ctx, "VerifyEmailToken", prefixVerify+token)
+}
+
+func (r *DynamoDBUserRepository) MarkEmailTokenUsed(ctx context.Context, token string) error {
+	return r.markTokenUsed(ctx, "MarkEmailTokenUsed", prefixVerify+token)
+}
+
+func (r *DynamoDBUserRepository) MarkUserVerified(ctx context.Context, userID string, outbox ...*events.Event) error {
+	return r.updateUserAttrs(ctx, "MarkUserVerified", userID, "SET is_verified = :verified, updated_at = :updated", map[string]types.AttributeValue{
+		":verified": &types.AttributeValueMemberBOOL{Value: true},
+		":updated":  timeValue(time.Now().UTC()),
+	}, outbox...)
+}
+
+func (r *DynamoDBUserRepository) CreatePasswordResetToken(ctx context.Context, userID, token string, duration time.Duration) error {
+	now := time.Now().UTC()
+	item := map[string]types.AttributeValue{
+		attrPK:       &types.AttributeValueMemberS{Value: prefixReset + token},
+		attrEntity:   &types.AttributeValueMemberS{Value: entityResetToken},
+		attrUserID:   &types.AttributeValueMemberS{Value: userID},
+		"used":       &types.AttributeValueMemberBOOL{Value: false},
+		"created_at": timeValue(now),
+		attrTTL:      unixValue(now.Add(duration)),
+	}
+	return r.putItem(ctx, "CreatePasswordResetToken", item)
+}
+
+func (r *DynamoDBUserRepository) VerifyPasswordResetToken(ctx context.Context, token string) (string, error) {
+	return r.verifyToken(ctx, "VerifyPasswordResetToken", prefixReset+token)
+}
+
+func (r *DynamoDBUserRepository) MarkPasswordResetTokenUsed(ctx context.Context, token string) error {
+	return r.markTokenUsed(ctx, "MarkPasswordResetTokenUsed", prefixReset+token)
+}
+
+func (r *DynamoDBUserRepository) CreateMagicLinkToken(ctx context.Context, userID, token string, duration time.Duration) error {
+	now := time.Now().UTC()
+	item := map[string]types.AttributeValue{
+		attrPK:       &types.AttributeValueMemberS{Value: prefixMagicLink + token},
+		attrEntity:   &types.AttributeValueMemberS{Value: entityMagicLink},
+		attrUserID:   &types.AttributeValueMemberS{Value: userID},
+		"created_at": timeValue(now),
+		attrTTL:      unixValue(now.Add(duration)),
+	}
+	return r.putItem(ctx, "CreateMagicLinkToken", item)
+}
+
+// ConsumeMagicLinkToken deletes the token and returns its user, so two
+// requests racing with the same link can't both log in
+func (r *DynamoDBUserRepository) ConsumeMagicLinkToken(ctx context.Context, token string) (string, error) {
+	start := time.Now()
+
+	out, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 pkKey(prefixMagicLink + token),
+		ConditionExpression: aws.String("attribute_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": attrPK,
+		},
+		ReturnValues: types.ReturnValueAllOld,
+	})
+	logger.LogDatabaseOperation(ctx, "ConsumeMagicLinkToken", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return "", ErrTokenNotFound
+		}
+		return "", fmt.Errorf("failed to consume magic link token: %w", err)
+	}
+
+	if time.Now().UTC().After(unixAttr(out.Attributes, attrTTL)) {
+		return "", ErrTokenExpired
+	}
+	return stringAttr(out.Attributes, attrUserID), nil
+}
+
+func (r *DynamoDBUserRepository) SaveEmailOTP(ctx context.Context, otp *models.EmailOTP) error {
+	return r.putItem(ctx, "SaveEmailOTP", marshalEmailOTP(otp))
+}
+
+func (r *DynamoDBUserRepository) GetEmailOTP(ctx context.Context, userID, purpose string) (*models.EmailOTP, error) {
+	start := time.Now()
+
+	out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            emailOTPKey(userID, purpose),
+		ConsistentRead: aws.Bool(true),
+	})
+	logger.LogDatabaseOperation(ctx, "GetEmailOTP", r.tableName, time.Since(start), err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get email code: %w", err)
+	}
+
+	if len(out.Item) == 0 {
+		return nil, ErrTokenNotFound
+	}
+	return unmarshalEmailOTP(out.Item), nil
+}
+
+// RecordEmailOTPAttempt counts a guess before it is checked, so concurrent
+// guesses can't get past the limit
+func (r *DynamoDBUserRepository) RecordEmailOTPAttempt(ctx context.Context, userID, purpose string) (int, error) {
+	start := time.Now()
+
+	out, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 emailOTPKey(userID, purpose),
+		UpdateExpression:    aws.String("ADD attempts :one"),
+		ConditionExpression: aws.String("attribute_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": attrPK,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one": &types.AttributeValueMemberN{Value: "1"},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	})
+	logger.LogDatabaseOperation(ctx, "RecordEmailOTPAttempt", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return 0, ErrTokenNotFound
+		}
+		return 0, fmt.Errorf("failed to record email code attempt: %w", err)
+	}
+
+	return intAttr(out.Attributes, "attempts"), nil
+}
+
+// DeleteEmailOTP removes a code. The condition lets only one of two requests
+// racing with the right code succeed.
+func (r *DynamoDBUserRepository) DeleteEmailOTP(ctx context.Context, userID, purpose string) error {
+	start := time.Now()
+
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 emailOTPKey(userID, purpose),
+		ConditionExpression: aws.String("attribute_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": attrPK,
+		},
+	})
+	logger.LogDatabaseOperation(ctx, "DeleteEmailOTP", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrTokenNotFound
+		}
+		return fmt.Errorf("failed to delete email code: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DynamoDBUserRepository) GetLoginAttempts(ctx context.Context, key string) (*models.LoginAttempts, error) {
+	start := time.Now()
+
+	out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            pkKey(prefixAttempts + key),
+		ConsistentRead: aws.Bool(true),
+	})
+	logger.LogDatabaseOperation(ctx, "GetLoginAttempts", r.tableName, time.Since(start), err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login attempts: %w", err)
+	}
+
+	if len(out.Item) == 0 {
+		return &models.LoginAttempts{Key: key}, nil
+	}
+	return unmarshalLoginAttempts(key, out.Item), nil
+}
+
+// RecordFailedLogin atomically increments the failure counter. If the last
+// failure fell outside window the counter restarts at one instead.
+func (r *DynamoDBUserRepository) RecordFailedLogin(ctx context.Context, key string, attemptTime time.Time, window time.Duration) (*models.LoginAttempts, error) {
+	start := time.Now()
+
+	out, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 pkKey(prefixAttempts + key),
+		UpdateExpression:    aws.String("SET #entity = :entity, failed_count = if_not_exists(failed_count, :zero) + :one, last_failed_at = :now, #ttl = :ttl"),
+		ConditionExpression: aws.String("attribute_not_exists(#pk) OR last_failed_at >= :window_start"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk":     attrPK,
+			"#entity": attrEntity,
+			"#ttl":    attrTTL,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":entity":       &types.AttributeValueMemberS{Value: entityAttempts},
+			":zero":         &types.AttributeValueMemberN{Value: "0"},
+			":one":          &types.AttributeValueMemberN{Value: "1"},
+			":now":          unixValue(attemptTime),
+			":window_start": unixValue(attemptTime.Add(-window)),
+			":ttl":          unixValue(attemptTime.Add(window + models.AccountLockoutDuration)),
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	})
+	if err != nil && isConditionFailure(err) {
+		// Previous failures are stale: start a new window
+		out, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:        aws.String(r.tableName),
+			Key:              pkKey(prefixAttempts + key),
+			UpdateExpression: aws.String("SET #entity = :entity, failed_count = :one, last_failed_at = :now, #ttl = :ttl"),
+			ExpressionAttributeNames: map[string]string{
+				"#entity": attrEntity,
+				"#ttl":    attrTTL,
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":entity": &types.AttributeValueMemberS{Value: entityAttempts},
+				":one":    &types.AttributeValueMemberN{Value: "1"},
+				":now":    unixValue(attemptTime),
+				":ttl":    unixValue(attemptTime.Add(window + models.AccountLockoutDuration)),
+			},
+			ReturnValues: types.ReturnValueAllNew,
+		})
+	}
+	logger.LogDatabaseOperation(ctx, "RecordFailedLogin", r.tableName, time.Since(start), err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record failed login: %w", err)
+	}
+
+	return unmarshalLoginAttempts(key, out.Attributes), nil
+}
+
+func (r *DynamoDBUserRepository) LockLogin(ctx context.Context, key string, until time.Time) error {
+	start := time.Now()
+
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(r.tableName),
+		Key:              pkKey(prefixAttempts + key),
+		UpdateExpression: aws.String("SET #entity = :entity, locked_until = :until, #ttl = :ttl"),
+		ExpressionAttributeNames: map[string]string{
+			"#entity": attrEntity,
+			"#ttl":    attrTTL,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":entity": &types.AttributeValueMemberS{Value: entityAttempts},
+			":until":  unixValue(until),
+			":ttl":    unixValue(until.Add(models.LoginAttemptWindow)),
+		},
+	})
+	logger.LogDatabaseOperation(ctx, "LockLogin", r.tableName, time.Since(start), err)
+	if err != nil {
+		return fmt.Errorf("failed to lock login: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DynamoDBUserRepository) ClearLoginAttempts(ctx context.Context, key string) error {
+	start := time.Now()
+
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.tableName),
+		Key:       pkKey(prefixAttempts + key),
+	})
+	logger.LogDatabaseOperation(ctx, "ClearLoginAttempts", r.tableName, time.Since(start), err)
+	if err != nil {
+		return fmt.Errorf("failed to clear login attempts: %w", err)
+	}
+
+	return nil
+}
+
+// SaveTOTPEnrollment stores a pending enrollment, replacing any earlier
+// unconfirmed one but never a confirmed one
+func (r *DynamoDBUserRepository) SaveTOTPEnrollment(ctx context.Context, enrollment *models.TOTPEnrollment) error {
+	start := time.Now()
+
+	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(r.tableName),
+		Item:                marshalTOTPEnrollment(enrollment),
+		ConditionExpression: aws.String("attribute_not_exists(confirmed_at)"),
+	})
+	logger.LogDatabaseOperation(ctx, "SaveTOTPEnrollment", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrMFAEnabled
+		}
+		return fmt.Errorf("failed to save TOTP enrollment: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DynamoDBUserRepository) GetTOTPEnrollment(ctx context.Context, userID string) (*models.TOTPEnrollment, error) {
+	start := time.Now()
+
+	out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            totpKey(userID),
+		ConsistentRead: aws.Bool(true),
+	})
+	logger.LogDatabaseOperation(ctx, "GetTOTPEnrollment", r.tableName, time.Since(start), err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TOTP enrollment: %w", err)
+	}
+
+	if len(out.Item) == 0 {
+		return nil, ErrMFANotFound
+	}
+	return unmarshalTOTPEnrollment(out.Item), nil
+}
+
+func (r *DynamoDBUserRepository) ConfirmTOTPEnrollment(ctx context.Context, userID string, confirmedAt time.Time, step int64) error {
+	start := time.Now()
+
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 totpKey(userID),
+		UpdateExpression:    aws.String("SET confirmed_at = :confirmed, last_used_step = :step"),
+		ConditionExpression: aws.String("attribute_exists(#pk) AND attribute_not_exists(confirmed_at)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": attrPK,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":confirmed": timeValue(confirmedAt),
+			":step":      &types.AttributeValueMemberN{Value: strconv.FormatInt(step, 10)},
+		},
+	})
+	logger.LogDatabaseOperation(ctx, "ConfirmTOTPEnrollment", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrMFAEnabled
+		}
+		return fmt.Errorf("failed to confirm TOTP enrollment: %w", err)
+	}
+
+	return nil
+}
+
+// UseTOTPStep records the time step of an accepted code. The condition makes
+// it atomic, so the same code can't be accepted twice even concurrently.
+func (r *DynamoDBUserRepository) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	start := time.Now()
+
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 totpKey(userID),
+		UpdateExpression:    aws.String("SET last_used_step = :step"),
+		ConditionExpression: aws.String("attribute_exists(#pk) AND last_used_step < :step"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": attrPK,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":step": &types.AttributeValueMemberN{Value: strconv.FormatInt(step, 10)},
+		},
+	})
+	logger.LogDatabaseOperation(ctx, "UseTOTPStep", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrCodeReused
+		}
+		return fmt.Errorf("failed to record TOTP code use: %w", err)
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes overwrites the user's recovery codes. DynamoDB sets
+// can't be empty, so replacing with none deletes the item.
+func (r *DynamoDBUserRepository) ReplaceRecoveryCodes(ctx context.Context, userID string, hashes []string) error {
+	start := time.Now()
+
+	var err error
+	if len(hashes) == 0 {
+		_, err = r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName: aws.String(r.tableName),
+			Key:       recoveryKey(userID),
+		})
+	} else {
+		item := recoveryKey(userID)
+		item[attrEntity] = &types.AttributeValueMemberS{Value: entityRecovery}
+		item[attrUserID] = &types.AttributeValueMemberS{Value: userID}
+		item["code_hashes"] = &types.AttributeValueMemberSS{Value: hashes}
+
+		_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(r.tableName),
+			Item:      item,
+		})
+	}
+	logger.LogDatabaseOperation(ctx, "ReplaceRecoveryCodes", r.tableName, time.Since(start), err)
+	if err != nil {
+		return fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DynamoDBUserRepository) GetRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	start := time.Now()
+
+	out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            recoveryKey(userID),
+		ConsistentRead: aws.Bool(true),
+	})
+	logger.LogDatabaseOperation(ctx, "GetRecoveryCodes", r.tableName, time.Since(start), err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recovery codes: %w", err)
+	}
+
+	if v, ok := out.Item["code_hashes"].(*types.AttributeValueMemberSS); ok {
+		return v.Value, nil
+	}
+	return nil, nil
+}
+
+// UseRecoveryCode removes hash from the set. The condition makes it atomic,
+// so a code can't be redeemed twice even concurrently.
+func (r *DynamoDBUserRepository) UseRecoveryCode(ctx context.Context, userID, hash string) error {
+	start := time.Now()
+
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 recoveryKey(userID),
+		UpdateExpression:    aws.String("DELETE code_hashes :hashes"),
+		ConditionExpression: aws.String("contains(code_hashes, :hash)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":hashes": &types.AttributeValueMemberSS{Value: []string{hash}},
+			":hash":   &types.AttributeValueMemberS{Value: hash},
+		},
+	})
+	logger.LogDatabaseOperation(ctx, "UseRecoveryCode", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrCodeReused
+		}
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DynamoDBUserRepository) getUserItem(ctx context.Context, userID string) (map[string]types.AttributeValue, error) {
+	start := time.Now()
+
+	out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            userKey(userID),
+		ConsistentRead: aws.Bool(true),
+	})
+	logger.LogDatabaseOperation(ctx, "GetUser", r.tableName, time.Since(start), err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if len(out.Item) == 0 {
+		return nil, ErrUserNotFound
+	}
+	return out.Item, nil
+}
+
+func (r *DynamoDBUserRepository) updateUserAttrs(ctx context.Context, operation, userID, expression string, values map[string]types.AttributeValue, outbox ...*events.Event) error {
+	err := updateWithOutbox(ctx, r.client, operation, &types.Update{
+		TableName:           aws.String(r.tableName),
+		Key:                 userKey(userID),
+		UpdateExpression:    aws.String(expression),
+		ConditionExpression: aws.String("attribute_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": attrPK,
+		},
+		ExpressionAttributeValues: values,
+	}, outbox)
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DynamoDBUserRepository) putItem(ctx context.Context, operation string, item map[string]types.AttributeValue) error {
+	start := time.Now()
+
+	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	})
+	logger.LogDatabaseOperation(ctx, operation, r.tableName, time.Since(start), err)
+	if err != nil {
+		return fmt.Errorf("failed to put item: %w", err)
+	}
+
+	return nil
+}
+
+// verifyToken returns the owning user ID of an unused token. TTL deletion is
+// asynchronous, so the expiry is checked here rather than trusted to DynamoDB.
+func (r *DynamoDBUserRepository) verifyToken(ctx context.Context, operation, pk string) (string, error) {
+	start := time.Now()
+
+	out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            pkKey(pk),
+		ConsistentRead: aws.Bool(true),
+	})
+	logger.LogDatabaseOperation(ctx, operation, r.tableName, time.Since(start), err)
+	if err != nil {
+		return "", fmt.Errorf("failed to get token: %w", err)
+	}
+
+	if len(out.Item) == 0 || boolAttr(out.Item, "used") {
+		return "", ErrTokenNotFound
+	}
+	if time.Now().UTC().After(unixAttr(out.Item, attrTTL)) {
+		return "", ErrTokenExpired
+	}
+
+	return stringAttr(out.Item, attrUserID), nil
+}
+
+func (r *DynamoDBUserRepository) markTokenUsed(ctx context.Context, operation, pk string) error {
+	start := time.Now()
+
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 pkKey(pk),
+		UpdateExpression:    aws.String("SET used = :used"),
+		ConditionExpression: aws.String("attribute_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": attrPK,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":used": &types.AttributeValueMemberBOOL{Value: true},
+		},
+	})
+	logger.LogDatabaseOperation(ctx, operation, r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrTokenNotFound
+		}
+		return fmt.Errorf("failed to mark token used: %w", err)
+	}
+
+	return nil
+}
+
+// DynamoDBSessionRepository implements SessionRepository. Sessions share the
+// auth sessions table with users; anonymous sessions use their own table.
+type DynamoDBSessionRepository struct {
+	client         DynamoDBAPI
+	tableName      string
+	anonymousTable string
+}
+
+// NewDynamoDBSessionRepository creates a session repository backed by the
+// given sessions and anonymous sessions tables
+func NewDynamoDBSessionRepository(client DynamoDBAPI, tableName, anonymousTable string) *DynamoDBSessionRepository {
+	return &DynamoDBSessionRepository{
+		client:         client,
+		tableName:      tableName,
+		anonymousTable: anonymousTable,
+	}
+}
+
+func (r *DynamoDBSessionRepository) CreateSession(ctx context.Context, session *models.Session) error {
+	start := time.Now()
+
+	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      marshalSession(session),
+	})
+	logger.LogDatabaseOperation(ctx, "CreateSession", r.tableName, time.Since(start), err)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DynamoDBSessionRepository) GetSession(ctx context.Context, sessionID string) (*models.Session, error) {
+	start := time.Now()
+
+	out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            sessionKey(sessionID),
+		ConsistentRead: aws.Bool(true),
+	})
+	logger.LogDatabaseOperation(ctx, "GetSession", r.tableName, time.Since(start), err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	if len(out.Item) == 0 {
+		return nil, ErrSessionNotFound
+	}
+	return unmarshalSession(out.Item), nil
+}
+
+// GetUserSessions returns the user's active, unexpired sessions via the user-id-index GSI
+func (r *DynamoDBSessionRepository) GetUserSessions(ctx context.Context, userID string) ([]*models.Session, error) {
+	items, err := r.queryUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*models.Session, 0, len(items))
+	for _, item := range items {
+		session := unmarshalSession(item)
+		if session.IsActive && !session.IsExpired() {
+			sessions = append(sessions, session)
+		}
+	}
+
+	return sessions, nil
+}
+
+func (r *DynamoDBSessionRepository) UpdateSession(ctx context.Context, session *models.Session) error {
+	start := time.Now()
+
+	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(r.tableName),
+		Item:                marshalSession(session),
+		ConditionExpression: aws.String("attribute_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": attrPK,
+		},
+	})
+	logger.LogDatabaseOperation(ctx, "UpdateSession", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrSessionNotFound
+		}
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DynamoDBSessionRepository) DeleteSession(ctx context.Context, sessionID string) error {
+	start := time.Now()
+
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 sessionKey(sessionID),
+		ConditionExpression: aws.String("attribute_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": attrPK,
+		},
+	})
+	logger.LogDatabaseOperation(ctx, "DeleteSession", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrSessionNotFound
+		}
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DynamoDBSessionRepository) DeactivateSession(ctx context.Context, sessionID string, outbox ...*events.Event) error {
+	err := updateWithOutbox(ctx, r.client, "DeactivateSession", &types.Update{
+		TableName:           aws.String(r.tableName),
+		Key:                 sessionKey(sessionID),
+		UpdateExpression:    aws.String("SET is_active = :inactive"),
+		ConditionExpression: aws.String("attribute_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": attrPK,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":inactive": &types.AttributeValueMemberBOOL{Value: false},
+		},
+	}, outbox)
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrSessionNotFound
+		}
+		return fmt.Errorf("failed to deactivate session: %w", err)
+	}
+
+	return nil
+}
+
+// DeactivateUserSessions writes the events with the last session it
+// deactivates, or on their own if there is none, so they are stored once
+// every session is deactivated
+func (r *DynamoDBSessionRepository) DeactivateUserSessions(ctx context.Context, userID string, outbox ...*events.Event) error {
+	items, err := r.queryUserSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	var active []string
+	for _, item := range items {
+		if boolAttr(item, "is_active") {
+			active = append(active, stringAttr(item, "id"))
+		}
+	}
+
+	for i, sessionID := range active {
+		if i == len(active)-1 {
+			err := r.DeactivateSession(ctx, sessionID, outbox...)
+			if err != ErrSessionNotFound {
+				return err
+			}
+			break // Deleted since the query
+		}
+		if err := r.DeactivateSession(ctx, sessionID); err != nil && err != ErrSessionNotFound {
+			return err
+		}
+	}
+
+	return putOutbox(ctx, r.client, r.tableName, "DeactivateUserSessions", outbox)
+}
+
+// RotateRefreshToken swaps the refresh token id with a conditional update, so
+// of two concurrent refreshes with the same token only one can win. Sessions
+// created before rotation have no refresh_token_id and match an empty currentID.
+func (r *DynamoDBSessionRepository) RotateRefreshToken(ctx context.Context, sessionID, currentID, nextID string) error {
+	start := time.Now()
+
+	condition := "attribute_exists(#pk) AND is_active = :active AND refresh_token_id = :current"
+	if currentID == "" {
+		condition = "attribute_exists(#pk) AND is_active = :active AND (attribute_not_exists(refresh_token_id) OR refresh_token_id = :current)"
+	}
+
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 sessionKey(sessionID),
+		UpdateExpression:    aws.String("SET refresh_token_id = :next"),
+		ConditionExpression: aws.String(condition),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": attrPK,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":active":  &types.AttributeValueMemberBOOL{Value: true},
+			":current": &types.AttributeValueMemberS{Value: currentID},
+			":next":    &types.AttributeValueMemberS{Value: nextID},
+		},
+	})
+	logger.LogDatabaseOperation(ctx, "RotateRefreshToken", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrTokenReused
+		}
+		return fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// CleanupExpiredSessions removes sessions whose TTL has passed but which
+// DynamoDB has not yet swept
+func (r *DynamoDBSessionRepository) CleanupExpiredSessions(ctx context.Context) error {
+	return cleanupExpired(ctx, r.client, r.tableName, "CleanupExpiredSessions", &types.AttributeValueMemberS{Value: entitySession})
+}
+
+func (r *DynamoDBSessionRepository) CreateAnonymousSession(ctx context.Context, session *models.AnonymousSession) error {
+	start := time.Now()
+
+	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.anonymousTable),
+		Item: map[string]types.AttributeValue{
+			"anonymous_id": &types.AttributeValueMemberS{Value: session.ID},
+			"token":        &types.AttributeValueMemberS{Value: session.Token},
+			"created_at":   timeValue(session.CreatedAt),
+			attrTTL:        unixValue(session.ExpiresAt),
+		},
+	})
+	logger.LogDatabaseOperation(ctx, "CreateAnonymousSession", r.anonymousTable, time.Since(start), err)
+	if err != nil {
+		return fmt.Errorf("failed to create anonymous session: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DynamoDBSessionRepository) GetAnonymousSession(ctx context.Context, sessionID string) (*models.AnonymousSession, error) {
+	start := time.Now()
+
+	out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.anonymousTable),
+		Key:       anonymousKey(sessionID),
+	})
+	logger.LogDatabaseOperation(ctx, "GetAnonymousSession", r.anonymousTable, time.Since(start), err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get anonymous session: %w", err)
+	}
+
+	if len(out.Item) == 0 {
+		return nil, ErrSessionNotFound
+	}
+
+	session := &models.AnonymousSession{
+		ID:        stringAttr(out.Item, "anonymous_id"),
+		Token:     stringAttr(out.Item, "token"),
+		CreatedAt: timeAttr(out.Item, "created_at"),
+		ExpiresAt: unixAttr(out.Item, attrTTL),
+	}
+	if session.IsExpired() {
+		return nil, ErrSessionNotFound
+	}
+
+	return session, nil
+}
+
+func (r *DynamoDBSessionRepository) DeleteAnonymousSession(ctx context.Context, sessionID string) error {
+	start := time.Now()
+
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(r.anonymousTable),
+		Key:                 anonymousKey(sessionID),
+		ConditionExpression: aws.String("attribute_exists(anonymous_id)"),
+	})
+	logger.LogDatabaseOperation(ctx, "DeleteAnonymousSession", r.anonymousTable, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrSessionNotFound
+		}
+		return fmt.Errorf("failed to delete anonymous session: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DynamoDBSessionRepository) CleanupExpiredAnonymousSessions(ctx context.Context) error {
+	return cleanupExpired(ctx, r.client, r.anonymousTable, "CleanupExpiredAnonymousSessions", nil)
+}
+
+func (r *DynamoDBSessionRepository) queryUserSessions(ctx context.Context, userID string) ([]map[string]types.AttributeValue, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(userIDIndex),
+		KeyConditionExpression: aws.String("#uid = :uid"),
+		FilterExpression:       aws.String("#entity = :entity"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid":    attrUserID,
+			"#entity": attrEntity,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid":    &types.AttributeValueMemberS{Value: userID},
+			":entity": &types.AttributeValueMemberS{Value: entitySession},
+		},
+	}
+
+	var items []map[string]types.AttributeValue
+	for {
+		start := time.Now()
+		out, err := r.client.Query(ctx, input)
+		logger.LogDatabaseOperation(ctx, "GetUserSessions", r.tableName, time.Since(start), err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query user sessions: %w", err)
+		}
+
+		items = append(items, out.Items...)
+		if len(out.LastEvaluatedKey) == 0 {
+			return items, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// DynamoDBCredentialRepository implements CredentialRepository on the auth
+// sessions table
+type DynamoDBCredentialRepository struct {
+	client    DynamoDBAPI
+	tableName string
+}
+
+// NewDynamoDBCredentialRepository creates a credential repository backed by
+// tableName
+func NewDynamoDBCredentialRepository(client DynamoDBAPI, tableName string) *DynamoDBCredentialRepository {
+	return &DynamoDBCredentialRepository{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+func (r *DynamoDBCredentialRepository) CreateCredential(ctx context.Context, credential *models.WebAuthnCredential) error {
+	start := time.Now()
+
+	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(r.tableName),
+		Item:                marshalCredential(credential),
+		ConditionExpression: aws.String("attribute_not_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": attrPK,
+		},
+	})
+	logger.LogDatabaseOperation(ctx, "CreateCredential", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrCredentialExists
+		}
+		return fmt.Errorf("failed to create credential: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DynamoDBCredentialRepository) GetCredential(ctx context.Context, credentialID string) (*models.WebAuthnCredential, error) {
+	start := time.Now()
+
+	out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            credentialKey(credentialID),
+		ConsistentRead: aws.Bool(true),
+	})
+	logger.LogDatabaseOperation(ctx, "GetCredential", r.tableName, time.Since(start), err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credential: %w", err)
+	}
+
+	if len(out.Item) == 0 {
+		return nil, ErrCredentialNotFound
+	}
+	return unmarshalCredential(out.Item), nil
+}
+
+func (r *DynamoDBCredentialRepository) GetUserCredentials(ctx context.Context, userID string) ([]*models.WebAuthnCredential, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(userIDIndex),
+		KeyConditionExpression: aws.String("#uid = :uid"),
+		FilterExpression:       aws.String("#entity = :entity"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid":    attrUserID,
+			"#entity": attrEntity,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid":    &types.AttributeValueMemberS{Value: userID},
+			":entity": &types.AttributeValueMemberS{Value: entityCredential},
+		},
+	}
+
+	var credentials []*models.WebAuthnCredential
+	for {
+		start := time.Now()
+		out, err := r.client.Query(ctx, input)
+		logger.LogDatabaseOperation(ctx, "GetUserCredentials", r.tableName, time.Since(start), err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query user credentials: %w", err)
+		}
+
+		for _, item := range out.Items {
+			credentials = append(credentials, unmarshalCredential(item))
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+
+	sort.Slice(credentials, func(i, j int) bool {
+		return credentials[i].CreatedAt.Before(credentials[j].CreatedAt)
+	})
+	return credentials, nil
+}
+
+func (r *DynamoDBCredentialRepository) UpdateCredentialUsage(ctx context.Context, credentialID string, signCount uint32, usedAt time.Time) error {
+	start := time.Now()
+
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 credentialKey(credentialID),
+		UpdateExpression:    aws.String("SET sign_count = :count, last_used_at = :used"),
+		ConditionExpression: aws.String("attribute_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": attrPK,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":count": &types.AttributeValueMemberN{Value: strconv.FormatUint(uint64(signCount), 10)},
+			":used":  timeValue(usedAt),
+		},
+	})
+	logger.LogDatabaseOperation(ctx, "UpdateCredentialUsage", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrCredentialNotFound
+		}
+		return fmt.Errorf("failed to update credential: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DynamoDBCredentialRepository) DeleteCredential(ctx context.Context, userID, credentialID string) error {
+	start := time.Now()
+
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 credentialKey(credentialID),
+		ConditionExpression: aws.String("#uid = :uid"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid": attrUserID,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid": &types.AttributeValueMemberS{Value: userID},
+		},
+	})
+	logger.LogDatabaseOperation(ctx, "DeleteCredential", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrCredentialNotFound
+		}
+		return fmt.Errorf("failed to delete credential: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DynamoDBCredentialRepository) SaveChallenge(ctx context.Context, challenge *models.WebAuthnChallenge) error {
+	start := time.Now()
+
+	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      marshalChallenge(challenge),
+	})
+	logger.LogDatabaseOperation(ctx, "SaveChallenge", r.tableName, time.Since(start), err)
+	if err != nil {
+		return fmt.Errorf("failed to save webauthn challenge: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeChallenge deletes the challenge and returns what it held, so an
+// assertion can't be replayed against it
+func (r *DynamoDBCredentialRepository) ConsumeChallenge(ctx context.Context, challenge string) (*models.WebAuthnChallenge, error) {
+	start := time.Now()
+
+	out, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 pkKey(prefixChallenge + challenge),
+		ConditionExpression: aws.String("attribute_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": attrPK,
+		},
+		ReturnValues: types.ReturnValueAllOld,
+	})
+	logger.LogDatabaseOperation(ctx, "ConsumeChallenge", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to consume webauthn challenge: %w", err)
+	}
+
+	stored := unmarshalChallenge(out.Attributes)
+	if time.Now().UTC().After(stored.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+	return stored, nil
+}
+
+// DynamoDBIdentityRepository implements IdentityRepository on the auth
+// sessions table
+type DynamoDBIdentityRepository struct {
+	client    DynamoDBAPI
+	tableName string
+}
+
+// NewDynamoDBIdentityRepository creates an identity repository backed by
+// tableName
+func NewDynamoDBIdentityRepository(client DynamoDBAPI, tableName string) *DynamoDBIdentityRepository {
+	return &DynamoDBIdentityRepository{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+func (r *DynamoDBIdentityRepository) LinkIdentity(ctx context.Context, identity *models.LinkedIdentity) error {
+	start := time.Now()
+
+	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(r.tableName),
+		Item:                marshalIdentity(identity),
+		ConditionExpression: aws.String("attribute_not_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": attrPK,
+		},
+	})
+	logger.LogDatabaseOperation(ctx, "LinkIdentity", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrIdentityLinked
+		}
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DynamoDBIdentityRepository) GetIdentity(ctx context.Context, provider, subject string) (*models.LinkedIdentity, error) {
+	start := time.Now()
+
+	out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            identityKey(provider, subject),
+		ConsistentRead: aws.Bool(true),
+	})
+	logger.LogDatabaseOperation(ctx, "GetIdentity", r.tableName, time.Since(start), err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	if len(out.Item) == 0 {
+		return nil, ErrIdentityNotFound
+	}
+	return unmarshalIdentity(out.Item), nil
+}
+
+func (r *DynamoDBIdentityRepository) DeleteUserIdentities(ctx context.Context, userID string) error {
+	return deleteUserItems(ctx, r.client, r.tableName, "DeleteUserIdentities", userID, entityIdentity)
+}
+
+func (r *DynamoDBIdentityRepository) SaveOAuthState(ctx context.Context, state *models.OAuthState) error {
+	start := time.Now()
+
+	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      marshalOAuthState(state),
+	})
+	logger.LogDatabaseOperation(ctx, "SaveOAuthState", r.tableName, time.Since(start), err)
+	if err != nil {
+		return fmt.Errorf("failed to save oauth state: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeOAuthState deletes the state and returns what it held, so two
+// callbacks racing with the same state can't both succeed
+func (r *DynamoDBIdentityRepository) ConsumeOAuthState(ctx context.Context, state string) (*models.OAuthState, error) {
+	start := time.Now()
+
+	out, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 pkKey(prefixOAuthState + state),
+		ConditionExpression: aws.String("attribute_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": attrPK,
+		},
+		ReturnValues: types.ReturnValueAllOld,
+	})
+	logger.LogDatabaseOperation(ctx, "ConsumeOAuthState", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to consume oauth state: %w", err)
+	}
+
+	stored := unmarshalOAuthState(out.Attributes)
+	if time.Now().UTC().After(stored.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+	return stored, nil
+}
+
+// DynamoDBClientRepository implements ClientRepository on the auth sessions
+// table
+type DynamoDBClientRepository struct {
+	client    DynamoDBAPI
+	tableName string
+}
+
+// NewDynamoDBClientRepository creates a client repository backed by tableName
+func NewDynamoDBClientRepository(client DynamoDBAPI, tableName string) *DynamoDBClientRepository {
+	return &DynamoDBClientRepository{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+func (r *DynamoDBClientRepository) CreateClient(ctx context.Context, client *models.OAuthClient) error {
+	start := time.Now()
+
+	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      marshalClient(client),
+	})
+	logger.LogDatabaseOperation(ctx, "CreateClient", r.tableName, time.Since(start), err)
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DynamoDBClientRepository) GetClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	start := time.Now()
+
+	out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key:       clientKey(clientID),
+	})
+	logger.LogDatabaseOperation(ctx, "GetClient", r.tableName, time.Since(start), err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client: %w", err)
+	}
+
+	if len(out.Item) == 0 {
+		return nil, ErrClientNotFound
+	}
+	return unmarshalClient(out.Item), nil
+}
+
+func (r *DynamoDBClientRepository) GetUserClients(ctx context.Context, ownerID string) ([]*models.OAuthClient, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(userIDIndex),
+		KeyConditionExpression: aws.String("#uid = :uid"),
+		FilterExpression:       aws.String("#entity = :entity"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid":    attrUserID,
+			"#entity": attrEntity,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid":    &types.AttributeValueMemberS{Value: ownerID},
+			":entity": &types.AttributeValueMemberS{Value: entityOAuthClient},
+		},
+	}
+
+	var clients []*models.OAuthClient
+	for {
+		start := time.Now()
+		out, err := r.client.Query(ctx, input)
+		logger.LogDatabaseOperation(ctx, "GetUserClients", r.tableName, time.Since(start), err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query user clients: %w", err)
+		}
+
+		for _, item := range out.Items {
+			clients = append(clients, unmarshalClient(item))
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].CreatedAt.Before(clients[j].CreatedAt)
+	})
+	return clients, nil
+}
+
+func (r *DynamoDBClientRepository) DeleteClient(ctx context.Context, ownerID, clientID string) error {
+	start := time.Now()
+
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 clientKey(clientID),
+		ConditionExpression: aws.String("#uid = :uid"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid": attrUserID,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid": &types.AttributeValueMemberS{Value: ownerID},
+		},
+	})
+	logger.LogDatabaseOperation(ctx, "DeleteClient", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrClientNotFound
+		}
+		return fmt.Errorf("failed to delete client: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DynamoDBClientRepository) SaveAuthorizationCode(ctx context.Context, code *models.AuthorizationCode) error {
+	start := time.Now()
+
+	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      marshalAuthorizationCode(code),
+	})
+	logger.LogDatabaseOperation(ctx, "SaveAuthorizationCode", r.tableName, time.Since(start), err)
+	if err != nil {
+		return fmt.Errorf("failed to save authorization code: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeAuthorizationCode flags the code used with a conditional update, so
+// of two concurrent redemptions only one can win. The loser reads the code
+// back to tell a replay from a code that never existed.
+func (r *DynamoDBClientRepository) ConsumeAuthorizationCode(ctx context.Context, code string) (*models.AuthorizationCode, error) {
+	start := time.Now()
+
+	out, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 pkKey(prefixCode + code),
+		UpdateExpression:    aws.String("SET used = :used"),
+		ConditionExpression: aws.String("attribute_exists(#pk) AND used = :unused"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": attrPK,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":used":   &types.AttributeValueMemberBOOL{Value: true},
+			":unused": &types.AttributeValueMemberBOOL{Value: false},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	})
+	logger.LogDatabaseOperation(ctx, "ConsumeAuthorizationCode", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return r.usedAuthorizationCode(ctx, code)
+		}
+		return nil, fmt.Errorf("failed to consume authorization code: %w", err)
+	}
+
+	stored := unmarshalAuthorizationCode(out.Attributes)
+	if time.Now().UTC().After(stored.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+	return stored, nil
+}
+
+// usedAuthorizationCode returns a code that failed to be consumed along with
+// ErrCodeReused, or ErrTokenNotFound if there is no such code
+func (r *DynamoDBClientRepository) usedAuthorizationCode(ctx context.Context, code string) (*models.AuthorizationCode, error) {
+	start := time.Now()
+
+	out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            pkKey(prefixCode + code),
+		ConsistentRead: aws.Bool(true),
+	})
+	logger.LogDatabaseOperation(ctx, "GetAuthorizationCode", r.tableName, time.Since(start), err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get authorization code: %w", err)
+	}
+
+	if len(out.Item) == 0 {
+		return nil, ErrTokenNotFound
+	}
+	return unmarshalAuthorizationCode(out.Item), ErrCodeReused
+}
+
+// DynamoDBAuditRepository implements AuditRepository on the auth sessions
+// table
+type DynamoDBAuditRepository struct {
+	client    DynamoDBAPI
+	tableName string
+}
+
+// NewDynamoDBAuditRepository creates an audit repository backed by tableName
+func NewDynamoDBAuditRepository(client DynamoDBAPI, tableName string) *DynamoDBAuditRepository {
+	return &DynamoDBAuditRepository{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+func (r *DynamoDBAuditRepository) GetAuditHead(ctx context.Context) (int64, string, error) {
+	start := time.Now()
+
+	out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            pkKey(auditHeadPK),
+		ConsistentRead: aws.Bool(true),
+	})
+	logger.LogDatabaseOperation(ctx, "GetAuditHead", r.tableName, time.Since(start), err)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to get audit head: %w", err)
+	}
+
+	if len(out.Item) == 0 {
+		return 0, "", nil
+	}
+	return int64Attr(out.Item, "sequence"), stringAttr(out.Item, "hash"), nil
+}
+
+// AppendAuditEvent writes the event and moves the head in one transaction.
+// The head update is conditional on the previous sequence number, so of two
+// writers chaining to the same head only one succeeds.
+func (r *DynamoDBAuditRepository) AppendAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	start := time.Now()
+
+	head := &types.Update{
+		TableName:           aws.String(r.tableName),
+		Key:                 pkKey(auditHeadPK),
+		UpdateExpression:    aws.String("SET #entity = :entity, #seq = :seq, #hash = :hash"),
+		ConditionExpression: aws.String("#seq = :prev"),
+		ExpressionAttributeNames: map[string]string{
+			"#entity": attrEntity,
+			"#seq":    "sequence",
+			"#hash":   "hash",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":entity": &types.AttributeValueMemberS{Value: entityAuditHead},
+			":seq":    int64Value(event.Sequence),
+			":hash":   &types.AttributeValueMemberS{Value: event.Hash},
+			":prev":   int64Value(event.Sequence - 1),
+		},
+	}
+	if event.Sequence == 1 {
+		head.ConditionExpression = aws.String("attribute_not_exists(#pk)")
+		head.ExpressionAttributeNames["#pk"] = attrPK
+		delete(head.ExpressionAttributeValues, ":prev")
+	}
+
+	_, err := r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName:           aws.String(r.tableName),
+					Item:                marshalAuditEvent(event),
+					ConditionExpression: aws.String("attribute_not_exists(#pk)"),
+					ExpressionAttributeNames: map[string]string{
+						"#pk": attrPK,
+					},
+				},
+			},
+			{Update: head},
+		},
+	})
+	logger.LogDatabaseOperation(ctx, "AppendAuditEvent", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrAuditConflict
+		}
+		return fmt.Errorf("failed to append audit event: %w", err)
+	}
+
+	return nil
+}
+
+// QueryAuditEvents reads a user's events through the user-id-index, or scans
+// the whole table when no user is given. The time range is applied after
+// reading because stored times don't compare correctly as strings.
+func (r *DynamoDBAuditRepository) QueryAuditEvents(ctx context.Context, query *models.AuditQuery) ([]*models.AuditEvent, error) {
+	filter := "#entity = :entity"
+	names := map[string]string{
+		"#entity": attrEntity,
+	}
+	values := map[string]types.AttributeValue{
+		":entity": &types.AttributeValueMemberS{Value: entityAuditEvent},
+	}
+	if query.Action != "" {
+		filter += " AND #action = :action"
+		names["#action"] = "action"
+		values[":action"] = &types.AttributeValueMemberS{Value: query.Action}
+	}
+
+	var events []*models.AuditEvent
+	collect := func(items []map[string]types.AttributeValue) {
+		for _, item := range items {
+			if event := unmarshalAuditEvent(item); query.Matches(event) {
+				events = append(events, event)
+			}
+		}
+	}
+
+	if query.UserID != "" {
+		names["#uid"] = attrUserID
+		values[":uid"] = &types.AttributeValueMemberS{Value: query.UserID}
+		input := &dynamodb.QueryInput{
+			TableName:                 aws.String(r.tableName),
+			IndexName:                 aws.String(userIDIndex),
+			KeyConditionExpression:    aws.String("#uid = :uid"),
+			FilterExpression:          aws.String(filter),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+		}
+		for {
+			start := time.Now()
+			out, err := r.client.Query(ctx, input)
+			logger.LogDatabaseOperation(ctx, "QueryAuditEvents", r.tableName, time.Since(start), err)
+			if err != nil {
+				return nil, fmt.Errorf("failed to query audit events: %w", err)
+			}
+
+			collect(out.Items)
+			if len(out.LastEvaluatedKey) == 0 {
+				break
+			}
+			input.ExclusiveStartKey = out.LastEvaluatedKey
+		}
+	} else {
+		input := &dynamodb.ScanInput{
+			TableName:                 aws.String(r.tableName),
+			FilterExpression:          aws.String(filter),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+		}
+		for {
+			start := time.Now()
+			out, err := r.client.Scan(ctx, input)
+			logger.LogDatabaseOperation(ctx, "QueryAuditEvents", r.tableName, time.Since(start), err)
+			if err != nil {
+				return nil, fmt.Errorf("failed to scan audit events: %w", err)
+			}
+
+			collect(out.Items)
+			if len(out.LastEvaluatedKey) == 0 {
+				break
+			}
+			input.ExclusiveStartKey = out.LastEvaluatedKey
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Sequence > events[j].Sequence
+	})
+	if len(events) > query.Limit {
+		events = events[:query.Limit]
+	}
+	return events, nil
+}
+
+// DynamoDBOutboxRepository implements OutboxRepository on the auth sessions
+// table, where the user and session repositories write the messages
+type DynamoDBOutboxRepository struct {
+	client    DynamoDBAPI
+	tableName string
+}
+
+// NewDynamoDBOutboxRepository creates an outbox repository backed by tableName
+func NewDynamoDBOutboxRepository(client DynamoDBAPI, tableName string) *DynamoDBOutboxRepository {
+	return &DynamoDBOutboxRepository{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+func (r *DynamoDBOutboxRepository) GetDueOutboxMessages(ctx context.Context, now time.Time, limit int) ([]*models.OutboxMessage, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(outboxIndex),
+		KeyConditionExpression: aws.String("#status = :pending AND #next <= :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "outbox_status",
+			"#next":   "next_attempt_at",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pending": &types.AttributeValueMemberS{Value: models.OutboxStatusPending},
+			":now":     unixValue(now),
+		},
+	}
+
+	var messages []*models.OutboxMessage
+	for len(messages) < limit {
+		input.Limit = aws.Int32(int32(limit - len(messages)))
+
+		start := time.Now()
+		out, err := r.client.Query(ctx, input)
+		logger.LogDatabaseOperation(ctx, "GetDueOutboxMessages", r.tableName, time.Since(start), err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query outbox: %w", err)
+		}
+
+		for _, item := range out.Items {
+			message, err := unmarshalOutboxMessage(item)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, message)
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+
+	// The index orders by the second; restore the order events were written in
+	sortOutboxMessages(messages)
+	return messages, nil
+}
+
+func (r *DynamoDBOutboxRepository) UpdateOutboxMessage(ctx context.Context, message *models.OutboxMessage) error {
+	item, err := marshalOutboxMessage(message)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(r.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": attrPK,
+		},
+	})
+	logger.LogDatabaseOperation(ctx, "UpdateOutboxMessage", r.tableName, time.Since(start), err)
+	if err != nil {
+		if isConditionFailure(err) {
+			return ErrOutboxMessageNotFound
+		}
+		return fmt.Errorf("failed to update outbox message: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DynamoDBOutboxRepository) DeleteOutboxMessage(ctx context.Context, id string) error {
+	start := time.Now()
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.tableName),
+		Key:       pkKey(prefixOutbox + id),
+	})
+	logger.LogDatabaseOperation(ctx, "DeleteOutboxMessage", r.tableName, time.Since(start), err)
+	if err != nil {
+		return fmt.Errorf("failed to delete outbox message: %w", err)
+	}
+
+	return nil
+}
+
+// outboxPuts returns the writes adding events to the outbox, for the
+// transaction making the change they describe. Writing an event again
+// replaces its message rather than adding another.
+func outboxPuts(tableName string, outbox []*events.Event) ([]types.TransactWriteItem, error) {
+	puts := make([]types.TransactWriteItem, 0, len(outbox))
+	for _, event := range outbox {
+		item, err := marshalOutboxMessage(models.NewOutboxMessage(event))
+		if err != nil {
+			return nil, err
+		}
+		puts = append(puts, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName: aws.String(tableName),
+				Item:      item,
+			},
+		})
+	}
+	return puts, nil
+}
+
+// putOutbox writes events to the outbox in a transaction of their own
+func putOutbox(ctx context.Context, client DynamoDBAPI, tableName, operation string, outbox []*events.Event) error {
+	if len(outbox) == 0 {
+		return nil
+	}
+
+	puts, err := outboxPuts(tableName, outbox)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	_, err = client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: puts,
+	})
+	logger.LogDatabaseOperation(ctx, operation, tableName, time.Since(start), err)
+	if err != nil {
+		return fmt.Errorf("failed to write outbox: %w", err)
+	}
+
+	return nil
+}
+
+// updateWithOutbox applies update, in one transaction with the outbox
+// writes when there are events. Errors are returned as is so callers can
+// check for condition failures.
+func updateWithOutbox(ctx context.Context, client DynamoDBAPI, operation string, update *types.Update, outbox []*events.Event) error {
+	tableName := aws.ToString(update.TableName)
+
+	puts, err := outboxPuts(tableName, outbox)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	if len(puts) == 0 {
+		_, err = client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 update.TableName,
+			Key:                       update.Key,
+			UpdateExpression:          update.UpdateExpression,
+			ConditionExpression:       update.ConditionExpression,
+			ExpressionAttributeNames:  update.ExpressionAttributeNames,
+			ExpressionAttributeValues: update.ExpressionAttributeValues,
+		})
+	} else {
+		_, err = client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: append([]types.TransactWriteItem{{Update: update}}, puts...),
+		})
+	}
+	logger.LogDatabaseOperation(ctx, operation, tableName, time.Since(start), err)
+	return err
+}
+
+// cleanupExpired scans tableName for items past their TTL and deletes them.
+// A nil entity matches every item in the table.
+func cleanupExpired(ctx context.Context, client DynamoDBAPI, tableName, operation string, entity types.AttributeValue) error {
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(tableName),
+		FilterExpression: aws.String("#ttl < :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#ttl": attrTTL,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": unixValue(time.Now().UTC()),
+		},
+	}
+	keyAttr := "anonymous_id"
+	if entity != nil {
+		input.FilterExpression = aws.String("#ttl < :now AND #entity = :entity")
+		input.ExpressionAttributeNames["#entity"] = attrEntity
+		input.ExpressionAttributeValues[":entity"] = entity
+		keyAttr = attrPK
+	}
+
+	for {
+		start := time.Now()
+		out, err := client.Scan(ctx, input)
+		logger.LogDatabaseOperation(ctx, operation, tableName, time.Since(start), err)
+		if err != nil {
+			return fmt.Errorf("failed to scan expired items: %w", err)
+		}
+
+		for _, item := range out.Items {
+			_, err := client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+				TableName: aws.String(tableName),
+				Key:       map[string]types.AttributeValue{keyAttr: item[keyAttr]},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to delete expired item: %w", err)
+			}
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// deleteUserItems deletes the user's items of the given entities, found
+// through the user-id-index
+func deleteUserItems(ctx context.Context, client DynamoDBAPI, tableName, operation, userID string, entities ...string) error {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		IndexName:              aws.String(userIDIndex),
+		KeyConditionExpression: aws.String("#uid = :uid"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid":    attrUserID,
+			"#entity": attrEntity,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid": &types.AttributeValueMemberS{Value: userID},
+		},
+	}
+	placeholders := make([]string, len(entities))
+	for i, entity := range entities {
+		placeholders[i] = fmt.Sprintf(":entity%d", i)
+		input.ExpressionAttributeValues[placeholders[i]] = &types.AttributeValueMemberS{Value: entity}
+	}
+	input.FilterExpression = aws.String("#entity IN (" + strings.Join(placeholders, ", ") + ")")
+
+	for {
+		start := time.Now()
+		out, err := client.Query(ctx, input)
+		logger.LogDatabaseOperation(ctx, operation, tableName, time.Since(start), err)
+		if err != nil {
+			return fmt.Errorf("failed to query user items: %w", err)
+		}
+
+		for _, item := range out.Items {
+			_, err := client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+				TableName: aws.String(tableName),
+				Key:       map[string]types.AttributeValue{attrPK: item[attrPK]},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to delete user item: %w", err)
+			}
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// Item mapping
+
+func marshalUser(user *models.User) map[string]types.AttributeValue {
+	item := map[string]types.AttributeValue{
+		attrPK:        &types.AttributeValueMemberS{Value: prefixUser + user.ID},
+		attrEntity:    &types.AttributeValueMemberS{Value: entityUser},
+		attrEmailKey:  &types.AttributeValueMemberS{Value: normalizeEmail(user.Email)},
+		attrNameKey:   &types.AttributeValueMemberS{Value: strings.ToLower(user.Name)},
+		"id":          &types.AttributeValueMemberS{Value: user.ID},
+		"email":       &types.AttributeValueMemberS{Value: user.Email},
+		"name":        &types.AttributeValueMemberS{Value: user.Name},
+		"is_verified": &types.AttributeValueMemberBOOL{Value: user.IsVerified},
+		"is_active":   &types.AttributeValueMemberBOOL{Value: user.IsActive},
+		"roles":       stringList(user.Roles),
+		"created_at":  timeValue(user.CreatedAt),
+		"updated_at":  timeValue(user.UpdatedAt),
+	}
+	if user.LastLoginAt != nil {
+		item["last_login_at"] = timeValue(*user.LastLoginAt)
+	}
+	return item
+}
+
+func unmarshalUser(item map[string]types.AttributeValue) *models.User {
+	user := &models.User{
+		ID:         stringAttr(item, "id"),
+		Email:      stringAttr(item, "email"),
+		Name:       stringAttr(item, "name"),
+		IsVerified: boolAttr(item, "is_verified"),
+		IsActive:   boolAttr(item, "is_active"),
+		Roles:      stringListAttr(item, "roles"),
+		CreatedAt:  timeAttr(item, "created_at"),
+		UpdatedAt:  timeAttr(item, "updated_at"),
+	}
+	if _, ok := item["last_login_at"]; ok {
+		lastLogin := timeAttr(item, "last_login_at")
+		user.LastLoginAt = &lastLogin
+	}
+	return user
+}
+
+func marshalSession(session *models.Session) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		attrPK:             &types.AttributeValueMemberS{Value: prefixSession + session.ID},
+		attrEntity:         &types.AttributeValueMemberS{Value: entitySession},
+		attrUserID:         &types.AttributeValueMemberS{Value: session.UserID},
+		"id":               &types.AttributeValueMemberS{Value: session.ID},
+		"token":            &types.AttributeValueMemberS{Value: session.Token},
+		"refresh_token_id": &types.AttributeValueMemberS{Value: session.RefreshTokenID},
+		"device_id":        &types.AttributeValueMemberS{Value: session.DeviceID},
+		"user_agent":       &types.AttributeValueMemberS{Value: session.UserAgent},
+		"ip_address":       &types.AttributeValueMemberS{Value: session.IPAddress},
+		"created_at":       timeValue(session.CreatedAt),
+		"is_active":        &types.AttributeValueMemberBOOL{Value: session.IsActive},
+		"auth_time":        timeValue(session.AuthTime),
+		"amr":              stringList(session.AuthMethods),
+		"client_id":        &types.AttributeValueMemberS{Value: session.ClientID},
+		"scopes":           stringList(session.Scopes),
+		attrTTL:            unixValue(session.ExpiresAt),
+	}
+}
+
+func unmarshalSession(item map[string]types.AttributeValue) *models.Session {
+	return &models.Session{
+		ID:             stringAttr(item, "id"),
+		UserID:         stringAttr(item, attrUserID),
+		Token:          stringAttr(item, "token"),
+		RefreshTokenID: stringAttr(item, "refresh_token_id"),
+		DeviceID:       stringAttr(item, "device_id"),
+		UserAgent:      stringAttr(item, "user_agent"),
+		IPAddress:      stringAttr(item, "ip_address"),
+		CreatedAt:      timeAttr(item, "created_at"),
+		ExpiresAt:      unixAttr(item, attrTTL),
+		IsActive:       boolAttr(item, "is_active"),
+		AuthTime:       timeAttr(item, "auth_time"),
+		AuthMethods:    stringListAttr(item, "amr"),
+		ClientID:       stringAttr(item, "client_id"),
+		Scopes:         stringListAttr(item, "scopes"),
+	}
+}
+
+func unmarshalLoginAttempts(key string, item map[string]types.AttributeValue) *models.LoginAttempts {
+	attempts := &models.LoginAttempts{
+		Key:          key,
+		FailedCount:  intAttr(item, "failed_count"),
+		LastFailedAt: unixAttr(item, "last_failed_at"),
+	}
+	if _, ok := item["locked_until"]; ok {
+		attempts.LockedUntil = unixAttr(item, "locked_until")
+	}
+	return attempts
+}
+
+func marshalEmailOTP(otp *models.EmailOTP) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		attrPK:       &types.AttributeValueMemberS{Value: prefixEmailOTP + otp.Purpose + "#" + otp.UserID},
+		attrEntity:   &types.AttributeValueMemberS{Value: entityEmailOTP},
+		attrUserID:   &types.AttributeValueMemberS{Value: otp.UserID},
+		"purpose":    &types.AttributeValueMemberS{Value: otp.Purpose},
+		"code_hash":  &types.AttributeValueMemberS{Value: otp.CodeHash},
+		"attempts":   &types.AttributeValueMemberN{Value: strconv.Itoa(otp.Attempts)},
+		"created_at": timeValue(otp.CreatedAt),
+		attrTTL:      unixValue(otp.ExpiresAt),
+	}
+}
+
+func unmarshalEmailOTP(item map[string]types.AttributeValue) *models.EmailOTP {
+	return &models.EmailOTP{
+		UserID:    stringAttr(item, attrUserID),
+		Purpose:   stringAttr(item, "purpose"),
+		CodeHash:  stringAttr(item, "code_hash"),
+		Attempts:  intAttr(item, "attempts"),
+		ExpiresAt: unixAttr(item, attrTTL),
+		CreatedAt: timeAttr(item, "created_at"),
+	}
+}
+
+func marshalTOTPEnrollment(enrollment *models.TOTPEnrollment) map[string]types.AttributeValue {
+	item := map[string]types.AttributeValue{
+		attrPK:           &types.AttributeValueMemberS{Value: prefixTOTP + enrollment.UserID},
+		attrEntity:       &types.AttributeValueMemberS{Value: entityTOTP},
+		attrUserID:       &types.AttributeValueMemberS{Value: enrollment.UserID},
+		"secret":         &types.AttributeValueMemberS{Value: enrollment.Secret},
+		"created_at":     timeValue(enrollment.CreatedAt),
+		"last_used_step": &types.AttributeValueMemberN{Value: strconv.FormatInt(enrollment.LastUsedStep, 10)},
+	}
+	if enrollment.ConfirmedAt != nil {
+		item["confirmed_at"] = timeValue(*enrollment.ConfirmedAt)
+	}
+	return item
+}
+
+func unmarshalTOTPEnrollment(item map[string]types.AttributeValue) *models.TOTPEnrollment {
+	enrollment := &models.TOTPEnrollment{
+		UserID:       stringAttr(item, attrUserID),
+		Secret:       stringAttr(item, "secret"),
+		CreatedAt:    timeAttr(item, "created_at"),
+		LastUsedStep: int64(intAttr(item, "last_used_step")),
+	}
+	if _, ok := item["confirmed_at"]; ok {
+		confirmedAt := timeAttr(item, "confirmed_at")
+		enrollment.ConfirmedAt = &confirmedAt
+	}
+	return enrollment
+}
+
+func marshalCredential(credential *models.WebAuthnCredential) map[string]types.AttributeValue {
+	item := credentialKey(credential.ID)
+	item[attrEntity] = &types.AttributeValueMemberS{Value: entityCredential}
+	item["credential_id"] = &types.AttributeValueMemberS{Value: credential.ID}
+	item[attrUserID] = &types.AttributeValueMemberS{Value: credential.UserID}
+	item["name"] = &types.AttributeValueMemberS{Value: credential.Name}
+	item["public_key"] = &types.AttributeValueMemberB{Value: credential.PublicKey}
+	item["sign_count"] = &types.AttributeValueMemberN{Value: strconv.FormatUint(uint64(credential.SignCount), 10)}
+	item["transports"] = stringList(credential.Transports)
+	item["backup_eligible"] = &types.AttributeValueMemberBOOL{Value: credential.BackupEligible}
+	item["created_at"] = timeValue(credential.CreatedAt)
+	if credential.LastUsedAt != nil {
+		item["last_used_at"] = timeValue(*credential.LastUsedAt)
+	}
+	return item
+}
+
+func unmarshalCredential(item map[string]types.AttributeValue) *models.WebAuthnCredential {
+	credential := &models.WebAuthnCredential{
+		ID:             stringAttr(item, "credential_id"),
+		UserID:         stringAttr(item, attrUserID),
+		Name:           stringAttr(item, "name"),
+		SignCount:      uint32(intAttr(item, "sign_count")),
+		Transports:     stringListAttr(item, "transports"),
+		BackupEligible: boolAttr(item, "backup_eligible"),
+		CreatedAt:      timeAttr(item, "created_at"),
+	}
+	if v, ok := item["public_key"].(*types.AttributeValueMemberB); ok {
+		credential.PublicKey = v.Value
+	}
+	if _, ok := item["last_used_at"]; ok {
+		lastUsed := timeAttr(item, "last_used_at")
+		credential.LastUsedAt = &lastUsed
+	}
+	return credential
+}
+
+func marshalIdentity(identity *models.LinkedIdentity) map[string]types.AttributeValue {
+	item := identityKey(identity.Provider, identity.Subject)
+	item[attrEntity] = &types.AttributeValueMemberS{Value: entityIdentity}
+	item["provider"] = &types.AttributeValueMemberS{Value: identity.Provider}
+	item["subject"] = &types.AttributeValueMemberS{Value: identity.Subject}
+	item[attrUserID] = &types.AttributeValueMemberS{Value: identity.UserID}
+	item["email"] = &types.AttributeValueMemberS{Value: identity.Email}
+	item["linked_at"] = timeValue(identity.LinkedAt)
+	return item
+}
+
+func unmarshalIdentity(item map[string]types.AttributeValue) *models.LinkedIdentity {
+	return &models.LinkedIdentity{
+		Provider: stringAttr(item, "provider"),
+		Subject:  stringAttr(item, "subject"),
+		UserID:   stringAttr(item, attrUserID),
+		Email:    stringAttr(item, "email"),
+		LinkedAt: timeAttr(item, "linked_at"),
+	}
+}
+
+// Challenges keep their user under their own attribute name so they stay out
+// of the user ID index
+func marshalChallenge(challenge *models.WebAuthnChallenge) map[string]types.AttributeValue {
+	item := pkKey(prefixChallenge + challenge.Challenge)
+	item[attrEntity] = &types.AttributeValueMemberS{Value: entityChallenge}
+	item["challenge"] = &types.AttributeValueMemberS{Value: challenge.Challenge}
+	item["ceremony"] = &types.AttributeValueMemberS{Value: challenge.Ceremony}
+	item["challenge_user_id"] = &types.AttributeValueMemberS{Value: challenge.UserID}
+	item[attrTTL] = unixValue(challenge.ExpiresAt)
+	return item
+}
+
+func unmarshalChallenge(item map[string]types.AttributeValue) *models.WebAuthnChallenge {
+	return &models.WebAuthnChallenge{
+		Challenge: stringAttr(item, "challenge"),
+		Ceremony:  stringAttr(item, "ceremony"),
+		UserID:    stringAttr(item, "challenge_user_id"),
+		ExpiresAt: unixAttr(item, attrTTL),
+	}
+}
+
+func marshalOAuthState(state *models.OAuthState) map[string]types.AttributeValue {
+	item := pkKey(prefixOAuthState + state.State)
+	item[attrEntity] = &types.AttributeValueMemberS{Value: entityOAuthState}
+	item["state"] = &types.AttributeValueMemberS{Value: state.State}
+	item["provider"] = &types.AttributeValueMemberS{Value: state.Provider}
+	item["nonce"] = &types.AttributeValueMemberS{Value: state.Nonce}
+	item["code_verifier"] = &types.AttributeValueMemberS{Value: state.CodeVerifier}
+	item["redirect_uri"] = &types.AttributeValueMemberS{Value: state.RedirectURI}
+	item["binding_hash"] = &types.AttributeValueMemberS{Value: state.BindingHash}
+	item[attrTTL] = unixValue(state.ExpiresAt)
+	return item
+}
+
+func unmarshalOAuthState(item map[string]types.AttributeValue) *models.OAuthState {
+	return &models.OAuthState{
+		State:        stringAttr(item, "state"),
+		Provider:     stringAttr(item, "provider"),
+		Nonce:        stringAttr(item, "nonce"),
+		CodeVerifier: stringAttr(item, "code_verifier"),
+		RedirectURI:  stringAttr(item, "redirect_uri"),
+		BindingHash:  stringAttr(item, "binding_hash"),
+		ExpiresAt:    unixAttr(item, attrTTL),
+	}
+}
+
+func marshalClient(client *models.OAuthClient) map[string]types.AttributeValue {
+	item := clientKey(client.ID)
+	item[attrEntity] = &types.AttributeValueMemberS{Value: entityOAuthClient}
+	item["client_id"] = &types.AttributeValueMemberS{Value: client.ID}
+	item["secret_hash"] = &types.AttributeValueMemberS{Value: client.SecretHash}
+	item["name"] = &types.AttributeValueMemberS{Value: client.Name}
+	item["client_type"] = &types.AttributeValueMemberS{Value: client.Type}
+	item["redirect_uris"] = stringList(client.RedirectURIs)
+	item["grant_types"] = stringList(client.GrantTypes)
+	item["scopes"] = stringList(client.Scopes)
+	item["first_party"] = &types.AttributeValueMemberBOOL{Value: client.FirstParty}
+	item["introspect"] = &types.AttributeValueMemberBOOL{Value: client.Introspect}
+	item[attrUserID] = &types.AttributeValueMemberS{Value: client.OwnerID}
+	item["created_at"] = timeValue(client.CreatedAt)
+	return item
+}
+
+func unmarshalClient(item map[string]types.AttributeValue) *models.OAuthClient {
+	return &models.OAuthClient{
+		ID:           stringAttr(item, "client_id"),
+		SecretHash:   stringAttr(item, "secret_hash"),
+		Name:         stringAttr(item, "name"),
+		Type:         stringAttr(item, "client_type"),
+		RedirectURIs: stringListAttr(item, "redirect_uris"),
+		GrantTypes:   stringListAttr(item, "grant_types"),
+		Scopes:       stringListAttr(item, "scopes"),
+		FirstParty:   boolAttr(item, "first_party"),
+		Introspect:   boolAttr(item, "introspect"),
+		OwnerID:      stringAttr(item, attrUserID),
+		CreatedAt:    timeAttr(item, "created_at"),
+	}
+}
+
+// marshalAuthorizationCode leaves out user_id so codes stay out of the
+// user-id-index
+func marshalAuthorizationCode(code *models.AuthorizationCode) map[string]types.AttributeValue {
+	item := pkKey(prefixCode + code.Code)
+	item[attrEntity] = &types.AttributeValueMemberS{Value: entityOAuthCode}
+	item["code"] = &types.AttributeValueMemberS{Value: code.Code}
+	item["client_id"] = &types.AttributeValueMemberS{Value: code.ClientID}
+	item["owner_id"] = &types.AttributeValueMemberS{Value: code.UserID}
+	item["redirect_uri"] = &types.AttributeValueMemberS{Value: code.RedirectURI}
+	item["scopes"] = stringList(code.Scopes)
+	item["code_challenge"] = &types.AttributeValueMemberS{Value: code.CodeChallenge}
+	item["auth_session_id"] = &types.AttributeValueMemberS{Value: code.SessionID}
+	item["nonce"] = &types.AttributeValueMemberS{Value: code.Nonce}
+	item["auth_time"] = timeValue(code.AuthTime)
+	item["amr"] = stringList(code.AuthMethods)
+	item["used"] = &types.AttributeValueMemberBOOL{Value: code.Used}
+	item[attrTTL] = unixValue(code.ExpiresAt)
+	return item
+}
+
+func unmarshalAuthorizationCode(item map[string]types.AttributeValue) *models.AuthorizationCode {
+	return &models.AuthorizationCode{
+		Code:          stringAttr(item, "code"),
+		ClientID:      stringAttr(item, "client_id"),
+		UserID:        stringAttr(item, "owner_id"),
+		RedirectURI:   stringAttr(item, "redirect_uri"),
+		Scopes:        stringListAttr(item, "scopes"),
+		CodeChallenge: stringAttr(item, "code_challenge"),
+		SessionID:     stringAttr(item, "auth_session_id"),
+		Nonce:         stringAttr(item, "nonce"),
+		AuthTime:      timeAttr(item, "auth_time"),
+		AuthMethods:   stringListAttr(item, "amr"),
+		Used:          boolAttr(item, "used"),
+		ExpiresAt:     unixAttr(item, attrTTL),
+	}
+}
+
+func emailGuardItem(email, userID string) map[string]types.AttributeValue {
+	item := emailGuardKey(email)
+	item[attrEntity] = &types.AttributeValueMemberS{Value: entityEmail}
+	item["owner_id"] = &types.AttributeValueMemberS{Value: userID}
+	return item
+}
+
+// marshalAuditEvent leaves out user_id for events without a user, since
+// index keys can't be empty
+func marshalAuditEvent(event *models.AuditEvent) map[string]types.AttributeValue {
+	item := pkKey(fmt.Sprintf("%s%020d", prefixAudit, event.Sequence))
+	item[attrEntity] = &types.AttributeValueMemberS{Value: entityAuditEvent}
+	item["sequence"] = int64Value(event.Sequence)
+	item["id"] = &types.AttributeValueMemberS{Value: event.ID}
+	item["time"] = timeValue(event.Time)
+	item["action"] = &types.AttributeValueMemberS{Value: event.Action}
+	item["actor_id"] = &types.AttributeValueMemberS{Value: event.ActorID}
+	if event.UserID != "" {
+		item[attrUserID] = &types.AttributeValueMemberS{Value: event.UserID}
+	}
+	item["session_id"] = &types.AttributeValueMemberS{Value: event.SessionID}
+	item["ip_address"] = &types.AttributeValueMemberS{Value: event.IPAddress}
+	if len(event.Details) > 0 {
+		details := make(map[string]types.AttributeValue, len(event.Details))
+		for k, v := range event.Details {
+			details[k] = &types.AttributeValueMemberS{Value: v}
+		}
+		item["details"] = &types.AttributeValueMemberM{Value: details}
+	}
+	item["prev_hash"] = &types.AttributeValueMemberS{Value: event.PrevHash}
+	item["hash"] = &types.AttributeValueMemberS{Value: event.Hash}
+	return item
+}
+
+func unmarshalAuditEvent(item map[string]types.AttributeValue) *models.AuditEvent {
+	event := &models.AuditEvent{
+		Sequence:  int64Attr(item, "sequence"),
+		ID:        stringAttr(item, "id"),
+		Time:      timeAttr(item, "time"),
+		Action:    stringAttr(item, "action"),
+		ActorID:   stringAttr(item, "actor_id"),
+		UserID:    stringAttr(item, attrUserID),
+		SessionID: stringAttr(item, "session_id"),
+		IPAddress: stringAttr(item, "ip_address"),
+		PrevHash:  stringAttr(item, "prev_hash"),
+		Hash:      stringAttr(item, "hash"),
+	}
+	if details, ok := item["details"].(*types.AttributeValueMemberM); ok {
+		event.Details = make(map[string]string, len(details.Value))
+		for k, v := range details.Value {
+			if s, ok := v.(*types.AttributeValueMemberS); ok {
+				event.Details[k] = s.Value
+			}
+		}
+	}
+	return event
+}
+
+func marshalOutboxMessage(message *models.OutboxMessage) (map[string]types.AttributeValue, error) {
+	event, err := json.Marshal(message.Event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode outbox event %s: %w", message.ID, err)
+	}
+
+	item := pkKey(prefixOutbox + message.ID)
+	item[attrEntity] = &types.AttributeValueMemberS{Value: entityOutbox}
+	item["id"] = &types.AttributeValueMemberS{Value: message.ID}
+	item["event"] = &types.AttributeValueMemberS{Value: string(event)}
+	item["outbox_status"] = &types.AttributeValueMemberS{Value: message.Status}
+	item["attempts"] = int64Value(int64(message.Attempts))
+	item["next_attempt_at"] = unixValue(message.NextAttemptAt)
+	item["last_error"] = &types.AttributeValueMemberS{Value: message.LastError}
+	item["created_at"] = timeValue(message.CreatedAt)
+	return item, nil
+}
+
+func unmarshalOutboxMessage(item map[string]types.AttributeValue) (*models.OutboxMessage, error) {
+	message := &models.OutboxMessage{
+		ID:            stringAttr(item, "id"),
+		Status:        stringAttr(item, "outbox_status"),
+		Attempts:      intAttr(item, "attempts"),
+		NextAttemptAt: unixAttr(item, "next_attempt_at"),
+		LastError:     stringAttr(item, "last_error"),
+		CreatedAt:     timeAttr(item, "created_at"),
+	}
+	if err := json.Unmarshal([]byte(stringAttr(item, "event")), &message.Event); err != nil {
+		return nil, fmt.Errorf("failed to decode outbox event %s: %w", message.ID, err)
+	}
+	return message, nil
+}
+
+// Keys
+
+func pkKey(pk string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		attrPK: &types.AttributeValueMemberS{Value: pk},
+	}
+}
+
+func userKey(userID string) map[string]types.AttributeValue {
+	return pkKey(prefixUser + userID)
+}
+
+func emailGuardKey(email string) map[string]types.AttributeValue {
+	return pkKey(prefixEmail + normalizeEmail(email))
+}
+
+func sessionKey(sessionID string) map[string]types.AttributeValue {
+	return pkKey(prefixSession + sessionID)
+}
+
+func totpKey(userID string) map[string]types.AttributeValue {
+	return pkKey(prefixTOTP + userID)
+}
+
+func recoveryKey(userID string) map[string]types.AttributeValue {
+	return pkKey(prefixRecovery + userID)
+}
+
+func emailOTPKey(userID, purpose string) map[string]types.AttributeValue {
+	return pkKey(prefixEmailOTP + purpose + "#" + userID)
+}
+
+func credentialKey(credentialID string) map[string]types.AttributeValue {
+	return pkKey(prefixCredential + credentialID)
+}
+
+func identityKey(provider, subject string) map[string]types.AttributeValue {
+	return pkKey(prefixIdentity + identityKeyString(provider, subject))
+}
+
+func clientKey(clientID string) map[string]types.AttributeValue {
+	return pkKey(prefixClient + clientID)
+}
+
+func anonymousKey(sessionID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"anonymous_id": &types.AttributeValueMemberS{Value: sessionID},
+	}
+}
+
+// Attribute helpers
+
+func timeValue(t time.Time) types.AttributeValue {
+	return &types.AttributeValueMemberS{Value: t.UTC().Format(time.RFC3339Nano)}
+}
+
+// unixValue encodes t as epoch seconds, the format DynamoDB TTL requires
+func unixValue(t time.Time) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(t.Unix(), 10)}
+}
+
+func int64Value(n int64) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(n, 10)}
+}
+
+func stringList(values []string) types.AttributeValue {
+	list := make([]types.AttributeValue, 0, len(values))
+	for _, v := range values {
+		list = append(list, &types.AttributeValueMemberS{Value: v})
+	}
+	return &types.AttributeValueMemberL{Value: list}
+}
+
+func stringAttr(item map[string]types.AttributeValue, key string) string {
+	if v, ok := item[key].(*types.AttributeValueMemberS); ok {
+		return v.Value
+	}
+	return ""
+}
+
+func boolAttr(item map[string]types.AttributeValue, key string) bool {
+	if v, ok := item[key].(*types.AttributeValueMemberBOOL); ok {
+		return v.Value
+	}
+	return false
+}
+
+func timeAttr(item map[string]types.AttributeValue, key string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, stringAttr(item, key))
+	return t
+}
+
+func unixAttr(item map[string]types.AttributeValue, key string) time.Time {
+	v, ok := item[key].(*types.AttributeValueMemberN)
+	if !ok {
+		return time.Time{}
+	}
+	seconds, _ := strconv.ParseInt(v.Value, 10, 64)
+	return time.Unix(seconds, 0).UTC()
+}
+
+func intAttr(item map[string]types.AttributeValue, key string) int {
+	v, ok := item[key].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.Atoi(v.Value)
+	return n
+}
+
+func int64Attr(item map[string]types.AttributeValue, key string) int64 {
+	v, ok := item[key].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.ParseInt(v.Value, 10, 64)
+	return n
+}
+
+func stringListAttr(item map[string]types.AttributeValue, key string) []string {
+	v, ok := item[key].(*types.AttributeValueMemberL)
+	if !ok {
+		return nil
+	}
+	values := make([]string, 0, len(v.Value))
+	for _, elem := range v.Value {
+		if s, ok := elem.(*types.AttributeValueMemberS); ok {
+			values = append(values, s.Value)
+		}
+	}
+	return values
+}
+
+// isConditionFailure reports whether err is a failed condition expression,
+// either on a single write or inside a cancelled transaction
+func isConditionFailure(err error) bool {
+	var condErr *types.ConditionalCheckFailedException
+	if errors.As(err, &condErr) {
+		return true
+	}
+
+	var txErr *types.TransactionCanceledException
+	if errors.As(err, &txErr) {
+		for _, reason := range txErr.CancellationReasons {
+			if aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
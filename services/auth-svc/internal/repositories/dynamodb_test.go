@@ -0,0 +1,256 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/shared/config"
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+func TestMain(m *testing.M) {
+	if err := logger.Initialize(zap.NewAtomicLevelAt(zap.FatalLevel), false); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// newDynamoDBTestTable creates a throwaway auth sessions table with the
+// schema from infra/serverless.yml on the DynamoDB at DYNAMODB_ENDPOINT
+// (e.g. DynamoDB Local) and deletes it when the test ends. Tests using it
+// are skipped when DYNAMODB_ENDPOINT is not set.
+func newDynamoDBTestTable(t *testing.T) (*dynamodb.Client, string) {
+	t.Helper()
+
+	endpoint := os.Getenv("DYNAMODB_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_ENDPOINT not set, skipping DynamoDB integration test")
+	}
+	// DynamoDB Local accepts any credentials but still wants some
+	if os.Getenv("AWS_ACCESS_KEY_ID") == "" {
+		t.Setenv("AWS_ACCESS_KEY_ID", "local")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "local")
+	}
+
+	ctx := context.Background()
+	cfg := &config.Config{Region: "us-east-1"}
+	cfg.DynamoDB.Endpoint = endpoint
+	client, err := NewDynamoDBClient(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewDynamoDBClient: %v", err)
+	}
+
+	index := func(name string, keys ...types.KeySchemaElement) types.GlobalSecondaryIndex {
+		return types.GlobalSecondaryIndex{
+			IndexName:  aws.String(name),
+			KeySchema:  keys,
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}
+	}
+	hash := func(name string) types.KeySchemaElement {
+		return types.KeySchemaElement{AttributeName: aws.String(name), KeyType: types.KeyTypeHash}
+	}
+
+	table := "auth-sessions-test-" + uuid.NewString()
+	_, err = client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:   aws.String(table),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String(attrPK), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String(attrUserID), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String(attrEmailKey), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("outbox_status"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("next_attempt_at"), AttributeType: types.ScalarAttributeTypeN},
+		},
+		KeySchema: []types.KeySchemaElement{hash(attrPK)},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+			index(userIDIndex, hash(attrUserID)),
+			index(emailIndex, hash(attrEmailKey)),
+			index(outboxIndex, hash("outbox_status"), types.KeySchemaElement{AttributeName: aws.String("next_attempt_at"), KeyType: types.KeyTypeRange}),
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := client.DeleteTable(context.Background(), &dynamodb.DeleteTableInput{TableName: aws.String(table)}); err != nil {
+			t.Errorf("DeleteTable: %v", err)
+		}
+	})
+
+	waiter := dynamodb.NewTableExistsWaiter(client)
+	if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)}, time.Minute); err != nil {
+		t.Fatalf("waiting for table %s: %v", table, err)
+	}
+
+	return client, table
+}
+
+// ttlOf reads the raw expires_at attribute of an item
+func ttlOf(t *testing.T, client *dynamodb.Client, table string, key map[string]types.AttributeValue) int64 {
+	t.Helper()
+
+	out, err := client.GetItem(context.Background(), &dynamodb.GetItemInput{TableName: aws.String(table), Key: key, ConsistentRead: aws.Bool(true)})
+	if err != nil {
+		t.Fatalf("GetItem: %v", err)
+	}
+	attr, ok := out.Item[attrTTL].(*types.AttributeValueMemberN)
+	if !ok {
+		t.Fatalf("%s = %#v, want a number", attrTTL, out.Item[attrTTL])
+	}
+	seconds, err := strconv.ParseInt(attr.Value, 10, 64)
+	if err != nil {
+		t.Fatalf("%s = %q: %v", attrTTL, attr.Value, err)
+	}
+	return seconds
+}
+
+func TestDynamoDBUserRepository(t *testing.T) {
+	client, table := newDynamoDBTestTable(t)
+	ctx := context.Background()
+	repo := NewDynamoDBUserRepository(client, table)
+
+	jane := &models.User{ID: "user-1", Email: "Jane@Example.com", Name: "Jane", Roles: []string{models.RoleUser}, IsActive: true}
+	if err := repo.CreateUser(ctx, jane, "hash"); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	t.Run("duplicate email", func(t *testing.T) {
+		err := repo.CreateUser(ctx, &models.User{ID: "user-2", Email: "jane@example.COM", IsActive: true}, "hash")
+		if !errors.Is(err, ErrUserExists) {
+			t.Fatalf("CreateUser() error = %v, want %v", err, ErrUserExists)
+		}
+		// The user item and the email guard are written together or not at all
+		if _, err := repo.GetUser(ctx, "user-2"); err != ErrUserNotFound {
+			t.Fatalf("GetUser(user-2) error = %v, want %v", err, ErrUserNotFound)
+		}
+	})
+
+	t.Run("duplicate ID", func(t *testing.T) {
+		err := repo.CreateUser(ctx, &models.User{ID: "user-1", Email: "other@example.com", IsActive: true}, "hash")
+		if !errors.Is(err, ErrUserExists) {
+			t.Fatalf("CreateUser() error = %v, want %v", err, ErrUserExists)
+		}
+		// Nor is the guard for the new address left behind
+		if err := repo.CreateUser(ctx, &models.User{ID: "user-3", Email: "other@example.com", IsActive: true}, "hash"); err != nil {
+			t.Fatalf("CreateUser() with the address freed = %v", err)
+		}
+	})
+
+	t.Run("email index", func(t *testing.T) {
+		user, err := repo.GetUserByEmail(ctx, "JANE@example.com")
+		if err != nil {
+			t.Fatalf("GetUserByEmail: %v", err)
+		}
+		if user.ID != jane.ID || user.Email != jane.Email || user.Name != jane.Name || !slices.Equal(user.Roles, jane.Roles) {
+			t.Fatalf("GetUserByEmail() = %+v, want %+v", user, jane)
+		}
+		if _, err := repo.GetUserByEmail(ctx, "nobody@example.com"); err != ErrUserNotFound {
+			t.Fatalf("GetUserByEmail(unknown) error = %v, want %v", err, ErrUserNotFound)
+		}
+	})
+
+	t.Run("token TTL", func(t *testing.T) {
+		before := time.Now().Add(time.Hour).Unix()
+		if err := repo.CreatePasswordResetToken(ctx, jane.ID, "reset-1", time.Hour); err != nil {
+			t.Fatalf("CreatePasswordResetToken: %v", err)
+		}
+		after := time.Now().Add(time.Hour).Unix()
+
+		if got := ttlOf(t, client, table, pkKey(prefixReset+"reset-1")); got < before || got > after {
+			t.Fatalf("%s = %d, want between %d and %d", attrTTL, got, before, after)
+		}
+	})
+}
+
+func TestDynamoDBSessionRepository(t *testing.T) {
+	client, table := newDynamoDBTestTable(t)
+	ctx := context.Background()
+	repo := NewDynamoDBSessionRepository(client, table, "")
+
+	now := time.Now().UTC().Truncate(time.Second)
+	newSession := func(id, userID string, expiresAt time.Time) *models.Session {
+		t.Helper()
+
+		session := &models.Session{ID: id, UserID: userID, Token: "token-" + id, CreatedAt: now, ExpiresAt: expiresAt, IsActive: true}
+		if err := repo.CreateSession(ctx, session); err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		return session
+	}
+
+	newSession("session-1", "user-1", now.Add(time.Hour))
+	newSession("session-2", "user-1", now.Add(2*time.Hour))
+	newSession("session-3", "user-1", now.Add(-time.Minute))
+	newSession("session-4", "user-1", now.Add(time.Hour))
+	newSession("session-5", "user-2", now.Add(time.Hour))
+	if err := repo.DeactivateSession(ctx, "session-4"); err != nil {
+		t.Fatalf("DeactivateSession: %v", err)
+	}
+
+	t.Run("user index", func(t *testing.T) {
+		sessions, err := repo.GetUserSessions(ctx, "user-1")
+		if err != nil {
+			t.Fatalf("GetUserSessions: %v", err)
+		}
+		var ids []string
+		for _, session := range sessions {
+			ids = append(ids, session.ID)
+		}
+		slices.Sort(ids)
+		// Expired and deactivated sessions are left out
+		if want := []string{"session-1", "session-2"}; !slices.Equal(ids, want) {
+			t.Fatalf("GetUserSessions() = %v, want %v", ids, want)
+		}
+	})
+
+	t.Run("session TTL", func(t *testing.T) {
+		if got, want := ttlOf(t, client, table, sessionKey("session-2")), now.Add(2*time.Hour).Unix(); got != want {
+			t.Fatalf("%s = %d, want %d", attrTTL, got, want)
+		}
+	})
+
+	t.Run("refresh token rotation", func(t *testing.T) {
+		steps := []struct {
+			session string
+			current string
+			next    string
+			wantErr error
+		}{
+			{session: "session-1", current: "", next: "refresh-1"},
+			{session: "session-1", current: "refresh-1", next: "refresh-2"},
+			{session: "session-1", current: "refresh-1", next: "refresh-3", wantErr: ErrTokenReused},
+			{session: "session-1", current: "", next: "refresh-3", wantErr: ErrTokenReused},
+			{session: "session-1", current: "refresh-2", next: "refresh-3"},
+			{session: "session-4", current: "", next: "refresh-1", wantErr: ErrTokenReused},
+			{session: "missing", current: "", next: "refresh-1", wantErr: ErrTokenReused},
+		}
+
+		for _, step := range steps {
+			err := repo.RotateRefreshToken(ctx, step.session, step.current, step.next)
+			if err != step.wantErr {
+				t.Fatalf("RotateRefreshToken(%s, %q, %q) error = %v, want %v", step.session, step.current, step.next, err, step.wantErr)
+			}
+		}
+
+		session, err := repo.GetSession(ctx, "session-1")
+		if err != nil {
+			t.Fatalf("GetSession: %v", err)
+		}
+		if session.RefreshTokenID != "refresh-3" {
+			t.Fatalf("RefreshTokenID = %q, want refresh-3", session.RefreshTokenID)
+		}
+	})
+}
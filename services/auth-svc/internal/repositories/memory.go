@@ -0,0 +1,1226 @@
+package repositories
+
+import (
+	"context"
+	"encoding/base64"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/shared/events"
+)
+
+var (
+	_ UserRepository       = (*MemoryUserRepository)(nil)
+	_ SessionRepository    = (*MemorySessionRepository)(nil)
+	_ CredentialRepository = (*MemoryCredentialRepository)(nil)
+	_ IdentityRepository   = (*MemoryIdentityRepository)(nil)
+	_ ClientRepository     = (*MemoryClientRepository)(nil)
+	_ AuditRepository      = (*MemoryAuditRepository)(nil)
+	_ OutboxRepository     = (*MemoryOutboxRepository)(nil)
+)
+
+// MemoryUserRepository is a concurrency-safe, in-process UserRepository.
+// It backs local development and service-level tests; nothing is persisted.
+type MemoryUserRepository struct {
+	mu             sync.RWMutex
+	users          map[string]*models.User // keyed by user ID
+	emailIndex     map[string]string       // normalized email -> user ID
+	passwordHashes map[string]string       // user ID -> bcrypt hash
+	verifyTokens   map[string]*models.EmailVerificationToken
+	resetTokens    map[string]*models.PasswordResetToken
+	magicLinks     map[string]*models.MagicLinkToken
+	emailOTPs      map[string]*models.EmailOTP // keyed by purpose and user ID
+	loginAttempts  map[string]*models.LoginAttempts
+	totp           map[string]*models.TOTPEnrollment // keyed by user ID
+	recoveryCodes  map[string][]string               // user ID -> unused code hashes
+	outbox         *MemoryOutboxRepository
+}
+
+// NewMemoryUserRepository creates an empty in-memory user repository that
+// adds events to outbox, or to an outbox of its own if outbox is nil
+func NewMemoryUserRepository(outbox *MemoryOutboxRepository) *MemoryUserRepository {
+	if outbox == nil {
+		outbox = NewMemoryOutboxRepository()
+	}
+
+	return &MemoryUserRepository{
+		users:          make(map[string]*models.User),
+		emailIndex:     make(map[string]string),
+		passwordHashes: make(map[string]string),
+		verifyTokens:   make(map[string]*models.EmailVerificationToken),
+		resetTokens:    make(map[string]*models.PasswordResetToken),
+		magicLinks:     make(map[string]*models.MagicLinkToken),
+		emailOTPs:      make(map[string]*models.EmailOTP),
+		loginAttempts:  make(map[string]*models.LoginAttempts),
+		totp:           make(map[string]*models.TOTPEnrollment),
+		recoveryCodes:  make(map[string][]string),
+		outbox:         outbox,
+	}
+}
+
+func (r *MemoryUserRepository) CreateUser(ctx context.Context, user *models.User, passwordHash string, outbox ...*events.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	email := normalizeEmail(user.Email)
+	if _, exists := r.emailIndex[email]; exists {
+		return ErrUserExists
+	}
+	if _, exists := r.users[user.ID]; exists {
+		return ErrUserExists
+	}
+
+	r.users[user.ID] = copyUser(user)
+	r.emailIndex[email] = user.ID
+	r.passwordHashes[user.ID] = passwordHash
+	r.outbox.add(outbox)
+
+	return nil
+}
+
+func (r *MemoryUserRepository) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return copyUser(user), nil
+}
+
+func (r *MemoryUserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	userID, ok := r.emailIndex[normalizeEmail(email)]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return copyUser(r.users[userID]), nil
+}
+
+func (r *MemoryUserRepository) UpdateUser(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.users[user.ID]
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	// Keep the email index in step when the address changes
+	oldEmail := normalizeEmail(existing.Email)
+	newEmail := normalizeEmail(user.Email)
+	if oldEmail != newEmail {
+		if ownerID, taken := r.emailIndex[newEmail]; taken && ownerID != user.ID {
+			return ErrUserExists
+		}
+		delete(r.emailIndex, oldEmail)
+		r.emailIndex[newEmail] = user.ID
+	}
+
+	r.users[user.ID] = copyUser(user)
+	return nil
+}
+
+func (r *MemoryUserRepository) DeleteUser(ctx context.Context, userID string, outbox ...*events.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	delete(r.emailIndex, normalizeEmail(user.Email))
+	delete(r.passwordHashes, userID)
+	delete(r.users, userID)
+	delete(r.totp, userID)
+	delete(r.recoveryCodes, userID)
+
+	for token, t := range r.verifyTokens {
+		if t.UserID == userID {
+			delete(r.verifyTokens, token)
+		}
+	}
+	for token, t := range r.resetTokens {
+		if t.UserID == userID {
+			delete(r.resetTokens, token)
+		}
+	}
+	for token, t := range r.magicLinks {
+		if t.UserID == userID {
+			delete(r.magicLinks, token)
+		}
+	}
+	for key, otp := range r.emailOTPs {
+		if otp.UserID == userID {
+			delete(r.emailOTPs, key)
+		}
+	}
+
+	r.outbox.add(outbox)
+	return nil
+}
+
+// ListUsers pages through users in ID order
+func (r *MemoryUserRepository) ListUsers(ctx context.Context, query string, limit int, cursor string) ([]*models.User, string, error) {
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]string, 0, len(r.users))
+	for id := range r.users {
+		if id > after && userMatches(r.users[id], query) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	next := ""
+	if len(ids) > limit {
+		ids = ids[:limit]
+		next = encodeCursor(ids[limit-1])
+	}
+
+	users := make([]*models.User, 0, len(ids))
+	for _, id := range ids {
+		users = append(users, copyUser(r.users[id]))
+	}
+	return users, next, nil
+}
+
+func (r *MemoryUserRepository) GetPasswordHash(ctx context.Context, userID string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hash, ok := r.passwordHashes[userID]
+	if !ok {
+		return "", ErrUserNotFound
+	}
+	return hash, nil
+}
+
+func (r *MemoryUserRepository) UpdatePassword(ctx context.Context, userID, passwordHash string, outbox ...*events.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	r.passwordHashes[userID] = passwordHash
+	user.UpdatedAt = time.Now().UTC()
+	r.outbox.add(outbox)
+	return nil
+}
+
+func (r *MemoryUserRepository) UpdateLastLogin(ctx context.Context, userID string, loginTime time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	lastLogin := loginTime
+	user.LastLoginAt = &lastLogin
+	return nil
+}
+
+func (r *MemoryUserRepository) CreateEmailVerificationToken(ctx context.Context, userID, email, token string, duration time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[userID]; !ok {
+		return ErrUserNotFound
+	}
+
+	now := time.Now().UTC()
+	r.verifyTokens[token] = &models.EmailVerificationToken{
+		UserID:    userID,
+		Token:     token,
+		Email:     email,
+		ExpiresAt: now.Add(duration),
+		CreatedAt: now,
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) VerifyEmailToken(ctx context.Context, token string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.verifyTokens[token]
+	if !ok || t.Used {
+		return "", ErrTokenNotFound
+	}
+	if time.Now().UTC().After(t.ExpiresAt) {
+		return "", ErrTokenExpired
+	}
+	return t.UserID, nil
+}
+
+func (r *MemoryUserRepository) MarkEmailTokenUsed(ctx context.Context, token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.verifyTokens[token]
+	if !ok {
+		return ErrTokenNotFound
+	}
+	t.Used = true
+	return nil
+}
+
+func (r *MemoryUserRepository) MarkUserVerified(ctx context.Context, userID string, outbox ...*events.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.IsVerified = true
+	user.UpdatedAt = time.Now().UTC()
+	r.outbox.add(outbox)
+	return nil
+}
+
+func (r *MemoryUserRepository) CreatePasswordResetToken(ctx context.Context, userID, token string, duration time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[userID]; !ok {
+		return ErrUserNotFound
+	}
+
+	now := time.Now().UTC()
+	r.resetTokens[token] = &models.PasswordResetToken{
+		UserID:    userID,
+		Token:     token,
+		ExpiresAt: now.Add(duration),
+		CreatedAt: now,
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) VerifyPasswordResetToken(ctx context.Context, token string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.resetTokens[token]
+	if !ok || t.Used {
+		return "", ErrTokenNotFound
+	}
+	if time.Now().UTC().After(t.ExpiresAt) {
+		return "", ErrTokenExpired
+	}
+	return t.UserID, nil
+}
+
+func (r *MemoryUserRepository) MarkPasswordResetTokenUsed(ctx context.Context, token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.resetTokens[token]
+	if !ok {
+		return ErrTokenNotFound
+	}
+	t.Used = true
+	return nil
+}
+
+func (r *MemoryUserRepository) CreateMagicLinkToken(ctx context.Context, userID, token string, duration time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[userID]; !ok {
+		return ErrUserNotFound
+	}
+
+	now := time.Now().UTC()
+	r.magicLinks[token] = &models.MagicLinkToken{
+		UserID:    userID,
+		Token:     token,
+		ExpiresAt: now.Add(duration),
+		CreatedAt: now,
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) ConsumeMagicLinkToken(ctx context.Context, token string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.magicLinks[token]
+	if !ok {
+		return "", ErrTokenNotFound
+	}
+	delete(r.magicLinks, token)
+
+	if time.Now().UTC().After(t.ExpiresAt) {
+		return "", ErrTokenExpired
+	}
+	return t.UserID, nil
+}
+
+func (r *MemoryUserRepository) SaveEmailOTP(ctx context.Context, otp *models.EmailOTP) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[otp.UserID]; !ok {
+		return ErrUserNotFound
+	}
+
+	stored := *otp
+	r.emailOTPs[otp.Purpose+"#"+otp.UserID] = &stored
+	return nil
+}
+
+func (r *MemoryUserRepository) GetEmailOTP(ctx context.Context, userID, purpose string) (*models.EmailOTP, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	otp, ok := r.emailOTPs[purpose+"#"+userID]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	stored := *otp
+	return &stored, nil
+}
+
+func (r *MemoryUserRepository) RecordEmailOTPAttempt(ctx context.Context, userID, purpose string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	otp, ok := r.emailOTPs[purpose+"#"+userID]
+	if !ok {
+		return 0, ErrTokenNotFound
+	}
+	otp.Attempts++
+	return otp.Attempts, nil
+}
+
+func (r *MemoryUserRepository) DeleteEmailOTP(ctx context.Context, userID, purpose string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := purpose + "#" + userID
+	if _, ok := r.emailOTPs[key]; !ok {
+		return ErrTokenNotFound
+	}
+	delete(r.emailOTPs, key)
+	return nil
+}
+
+func (r *MemoryUserRepository) GetLoginAttempts(ctx context.Context, key string) (*models.LoginAttempts, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	attempts, ok := r.loginAttempts[key]
+	if !ok {
+		return &models.LoginAttempts{Key: key}, nil
+	}
+	copied := *attempts
+	return &copied, nil
+}
+
+func (r *MemoryUserRepository) RecordFailedLogin(ctx context.Context, key string, attemptTime time.Time, window time.Duration) (*models.LoginAttempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempts, ok := r.loginAttempts[key]
+	if !ok || attemptTime.Sub(attempts.LastFailedAt) > window {
+		attempts = &models.LoginAttempts{Key: key}
+		r.loginAttempts[key] = attempts
+	}
+
+	attempts.FailedCount++
+	attempts.LastFailedAt = attemptTime
+
+	copied := *attempts
+	return &copied, nil
+}
+
+func (r *MemoryUserRepository) LockLogin(ctx context.Context, key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempts, ok := r.loginAttempts[key]
+	if !ok {
+		attempts = &models.LoginAttempts{Key: key}
+		r.loginAttempts[key] = attempts
+	}
+	attempts.LockedUntil = until
+	return nil
+}
+
+func (r *MemoryUserRepository) ClearLoginAttempts(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.loginAttempts, key)
+	return nil
+}
+
+func (r *MemoryUserRepository) SaveTOTPEnrollment(ctx context.Context, enrollment *models.TOTPEnrollment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.totp[enrollment.UserID]; ok && existing.IsConfirmed() {
+		return ErrMFAEnabled
+	}
+	r.totp[enrollment.UserID] = copyTOTPEnrollment(enrollment)
+	return nil
+}
+
+func (r *MemoryUserRepository) GetTOTPEnrollment(ctx context.Context, userID string) (*models.TOTPEnrollment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	enrollment, ok := r.totp[userID]
+	if !ok {
+		return nil, ErrMFANotFound
+	}
+	return copyTOTPEnrollment(enrollment), nil
+}
+
+func (r *MemoryUserRepository) ConfirmTOTPEnrollment(ctx context.Context, userID string, confirmedAt time.Time, step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	enrollment, ok := r.totp[userID]
+	if !ok {
+		return ErrMFANotFound
+	}
+	if enrollment.IsConfirmed() {
+		return ErrMFAEnabled
+	}
+	enrollment.ConfirmedAt = &confirmedAt
+	enrollment.LastUsedStep = step
+	return nil
+}
+
+func (r *MemoryUserRepository) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	enrollment, ok := r.totp[userID]
+	if !ok {
+		return ErrMFANotFound
+	}
+	if step <= enrollment.LastUsedStep {
+		return ErrCodeReused
+	}
+	enrollment.LastUsedStep = step
+	return nil
+}
+
+func (r *MemoryUserRepository) ReplaceRecoveryCodes(ctx context.Context, userID string, hashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(hashes) == 0 {
+		delete(r.recoveryCodes, userID)
+		return nil
+	}
+	r.recoveryCodes[userID] = append([]string(nil), hashes...)
+	return nil
+}
+
+func (r *MemoryUserRepository) GetRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]string(nil), r.recoveryCodes[userID]...), nil
+}
+
+func (r *MemoryUserRepository) UseRecoveryCode(ctx context.Context, userID, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hashes := r.recoveryCodes[userID]
+	for i, h := range hashes {
+		if h == hash {
+			r.recoveryCodes[userID] = append(hashes[:i:i], hashes[i+1:]...)
+			return nil
+		}
+	}
+	return ErrCodeReused
+}
+
+// MemorySessionRepository is a concurrency-safe, in-process SessionRepository
+type MemorySessionRepository struct {
+	mu                sync.RWMutex
+	sessions          map[string]*models.Session
+	anonymousSessions map[string]*models.AnonymousSession
+	outbox            *MemoryOutboxRepository
+}
+
+// NewMemorySessionRepository creates an empty in-memory session repository
+// that adds events to outbox, or to an outbox of its own if outbox is nil
+func NewMemorySessionRepository(outbox *MemoryOutboxRepository) *MemorySessionRepository {
+	if outbox == nil {
+		outbox = NewMemoryOutboxRepository()
+	}
+
+	return &MemorySessionRepository{
+		sessions:          make(map[string]*models.Session),
+		anonymousSessions: make(map[string]*models.AnonymousSession),
+		outbox:            outbox,
+	}
+}
+
+func (r *MemorySessionRepository) CreateSession(ctx context.Context, session *models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copied := *session
+	r.sessions[session.ID] = &copied
+	return nil
+}
+
+func (r *MemorySessionRepository) GetSession(ctx context.Context, sessionID string) (*models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, ok := r.sessions[sessionID]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	copied := *session
+	return &copied, nil
+}
+
+// GetUserSessions returns the user's active, unexpired sessions, newest first
+func (r *MemorySessionRepository) GetUserSessions(ctx context.Context, userID string) ([]*models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sessions := make([]*models.Session, 0)
+	for _, session := range r.sessions {
+		if session.UserID != userID || !session.IsActive || session.IsExpired() {
+			continue
+		}
+		copied := *session
+		sessions = append(sessions, &copied)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+
+	return sessions, nil
+}
+
+func (r *MemorySessionRepository) UpdateSession(ctx context.Context, session *models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sessions[session.ID]; !ok {
+		return ErrSessionNotFound
+	}
+	copied := *session
+	r.sessions[session.ID] = &copied
+	return nil
+}
+
+func (r *MemorySessionRepository) DeleteSession(ctx context.Context, sessionID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sessions[sessionID]; !ok {
+		return ErrSessionNotFound
+	}
+	delete(r.sessions, sessionID)
+	return nil
+}
+
+func (r *MemorySessionRepository) DeactivateSession(ctx context.Context, sessionID string, outbox ...*events.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[sessionID]
+	if !ok {
+		return ErrSessionNotFound
+	}
+	session.IsActive = false
+	r.outbox.add(outbox)
+	return nil
+}
+
+func (r *MemorySessionRepository) DeactivateUserSessions(ctx context.Context, userID string, outbox ...*events.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, session := range r.sessions {
+		if session.UserID == userID {
+			session.IsActive = false
+		}
+	}
+	r.outbox.add(outbox)
+	return nil
+}
+
+func (r *MemorySessionRepository) RotateRefreshToken(ctx context.Context, sessionID, currentID, nextID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[sessionID]
+	if !ok {
+		return ErrSessionNotFound
+	}
+	if !session.IsActive || session.RefreshTokenID != currentID {
+		return ErrTokenReused
+	}
+	session.RefreshTokenID = nextID
+	return nil
+}
+
+func (r *MemorySessionRepository) CleanupExpiredSessions(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, session := range r.sessions {
+		if session.IsExpired() {
+			delete(r.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (r *MemorySessionRepository) CreateAnonymousSession(ctx context.Context, session *models.AnonymousSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copied := *session
+	r.anonymousSessions[session.ID] = &copied
+	return nil
+}
+
+func (r *MemorySessionRepository) GetAnonymousSession(ctx context.Context, sessionID string) (*models.AnonymousSession, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, ok := r.anonymousSessions[sessionID]
+	if !ok || session.IsExpired() {
+		return nil, ErrSessionNotFound
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (r *MemorySessionRepository) DeleteAnonymousSession(ctx context.Context, sessionID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.anonymousSessions[sessionID]; !ok {
+		return ErrSessionNotFound
+	}
+	delete(r.anonymousSessions, sessionID)
+	return nil
+}
+
+func (r *MemorySessionRepository) CleanupExpiredAnonymousSessions(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, session := range r.anonymousSessions {
+		if session.IsExpired() {
+			delete(r.anonymousSessions, id)
+		}
+	}
+	return nil
+}
+
+// MemoryCredentialRepository is a concurrency-safe, in-process
+// CredentialRepository
+type MemoryCredentialRepository struct {
+	mu          sync.RWMutex
+	credentials map[string]*models.WebAuthnCredential // keyed by credential ID
+	challenges  map[string]*models.WebAuthnChallenge  // keyed by challenge
+}
+
+// NewMemoryCredentialRepository creates an empty in-memory credential repository
+func NewMemoryCredentialRepository() *MemoryCredentialRepository {
+	return &MemoryCredentialRepository{
+		credentials: make(map[string]*models.WebAuthnCredential),
+		challenges:  make(map[string]*models.WebAuthnChallenge),
+	}
+}
+
+func (r *MemoryCredentialRepository) CreateCredential(ctx context.Context, credential *models.WebAuthnCredential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.credentials[credential.ID]; exists {
+		return ErrCredentialExists
+	}
+	r.credentials[credential.ID] = copyCredential(credential)
+	return nil
+}
+
+func (r *MemoryCredentialRepository) GetCredential(ctx context.Context, credentialID string) (*models.WebAuthnCredential, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	credential, ok := r.credentials[credentialID]
+	if !ok {
+		return nil, ErrCredentialNotFound
+	}
+	return copyCredential(credential), nil
+}
+
+func (r *MemoryCredentialRepository) GetUserCredentials(ctx context.Context, userID string) ([]*models.WebAuthnCredential, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var credentials []*models.WebAuthnCredential
+	for _, credential := range r.credentials {
+		if credential.UserID == userID {
+			credentials = append(credentials, copyCredential(credential))
+		}
+	}
+	sort.Slice(credentials, func(i, j int) bool {
+		return credentials[i].CreatedAt.Before(credentials[j].CreatedAt)
+	})
+	return credentials, nil
+}
+
+func (r *MemoryCredentialRepository) UpdateCredentialUsage(ctx context.Context, credentialID string, signCount uint32, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	credential, ok := r.credentials[credentialID]
+	if !ok {
+		return ErrCredentialNotFound
+	}
+	credential.SignCount = signCount
+	credential.LastUsedAt = &usedAt
+	return nil
+}
+
+func (r *MemoryCredentialRepository) DeleteCredential(ctx context.Context, userID, credentialID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	credential, ok := r.credentials[credentialID]
+	if !ok || credential.UserID != userID {
+		return ErrCredentialNotFound
+	}
+	delete(r.credentials, credentialID)
+	return nil
+}
+
+func (r *MemoryCredentialRepository) SaveChallenge(ctx context.Context, challenge *models.WebAuthnChallenge) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copied := *challenge
+	r.challenges[challenge.Challenge] = &copied
+	return nil
+}
+
+func (r *MemoryCredentialRepository) ConsumeChallenge(ctx context.Context, challenge string) (*models.WebAuthnChallenge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.challenges[challenge]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	delete(r.challenges, challenge)
+
+	if time.Now().UTC().After(stored.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+	return stored, nil
+}
+
+// MemoryIdentityRepository is a concurrency-safe, in-process
+// IdentityRepository
+type MemoryIdentityRepository struct {
+	mu         sync.Mutex
+	identities map[string]*models.LinkedIdentity // keyed by provider and subject
+	states     map[string]*models.OAuthState     // keyed by state
+}
+
+// NewMemoryIdentityRepository creates an empty in-memory identity repository
+func NewMemoryIdentityRepository() *MemoryIdentityRepository {
+	return &MemoryIdentityRepository{
+		identities: make(map[string]*models.LinkedIdentity),
+		states:     make(map[string]*models.OAuthState),
+	}
+}
+
+func (r *MemoryIdentityRepository) LinkIdentity(ctx context.Context, identity *models.LinkedIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := identityKeyString(identity.Provider, identity.Subject)
+	if _, exists := r.identities[key]; exists {
+		return ErrIdentityLinked
+	}
+	copied := *identity
+	r.identities[key] = &copied
+	return nil
+}
+
+func (r *MemoryIdentityRepository) GetIdentity(ctx context.Context, provider, subject string) (*models.LinkedIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	identity, ok := r.identities[identityKeyString(provider, subject)]
+	if !ok {
+		return nil, ErrIdentityNotFound
+	}
+	copied := *identity
+	return &copied, nil
+}
+
+func (r *MemoryIdentityRepository) DeleteUserIdentities(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, identity := range r.identities {
+		if identity.UserID == userID {
+			delete(r.identities, key)
+		}
+	}
+	return nil
+}
+
+func (r *MemoryIdentityRepository) SaveOAuthState(ctx context.Context, state *models.OAuthState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copied := *state
+	r.states[state.State] = &copied
+	return nil
+}
+
+func (r *MemoryIdentityRepository) ConsumeOAuthState(ctx context.Context, state string) (*models.OAuthState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.states[state]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	delete(r.states, state)
+
+	if time.Now().UTC().After(stored.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+	return stored, nil
+}
+
+// MemoryClientRepository is a concurrency-safe, in-process ClientRepository
+type MemoryClientRepository struct {
+	mu      sync.Mutex
+	clients map[string]*models.OAuthClient       // keyed by client ID
+	codes   map[string]*models.AuthorizationCode // keyed by code
+}
+
+// NewMemoryClientRepository creates an empty in-memory client repository
+func NewMemoryClientRepository() *MemoryClientRepository {
+	return &MemoryClientRepository{
+		clients: make(map[string]*models.OAuthClient),
+		codes:   make(map[string]*models.AuthorizationCode),
+	}
+}
+
+func (r *MemoryClientRepository) CreateClient(ctx context.Context, client *models.OAuthClient) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copied := *client
+	r.clients[client.ID] = &copied
+	return nil
+}
+
+func (r *MemoryClientRepository) GetClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	client, ok := r.clients[clientID]
+	if !ok {
+		return nil, ErrClientNotFound
+	}
+	copied := *client
+	return &copied, nil
+}
+
+func (r *MemoryClientRepository) GetUserClients(ctx context.Context, ownerID string) ([]*models.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var clients []*models.OAuthClient
+	for _, client := range r.clients {
+		if client.OwnerID == ownerID {
+			copied := *client
+			clients = append(clients, &copied)
+		}
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].CreatedAt.Before(clients[j].CreatedAt)
+	})
+	return clients, nil
+}
+
+func (r *MemoryClientRepository) DeleteClient(ctx context.Context, ownerID, clientID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	client, ok := r.clients[clientID]
+	if !ok || client.OwnerID != ownerID {
+		return ErrClientNotFound
+	}
+	delete(r.clients, clientID)
+	return nil
+}
+
+func (r *MemoryClientRepository) SaveAuthorizationCode(ctx context.Context, code *models.AuthorizationCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copied := *code
+	r.codes[code.Code] = &copied
+	return nil
+}
+
+func (r *MemoryClientRepository) ConsumeAuthorizationCode(ctx context.Context, code string) (*models.AuthorizationCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.codes[code]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	copied := *stored
+	if stored.Used {
+		return &copied, ErrCodeReused
+	}
+	if time.Now().UTC().After(stored.ExpiresAt) {
+		delete(r.codes, code)
+		return nil, ErrTokenExpired
+	}
+	stored.Used = true
+	copied.Used = true
+	return &copied, nil
+}
+
+// MemoryAuditRepository is a concurrency-safe, in-process AuditRepository
+type MemoryAuditRepository struct {
+	mu     sync.Mutex
+	events []*models.AuditEvent // in sequence order
+}
+
+// NewMemoryAuditRepository creates an empty in-memory audit repository
+func NewMemoryAuditRepository() *MemoryAuditRepository {
+	return &MemoryAuditRepository{}
+}
+
+func (r *MemoryAuditRepository) GetAuditHead(ctx context.Context) (int64, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.events) == 0 {
+		return 0, "", nil
+	}
+	head := r.events[len(r.events)-1]
+	return head.Sequence, head.Hash, nil
+}
+
+func (r *MemoryAuditRepository) AppendAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if event.Sequence != int64(len(r.events))+1 {
+		return ErrAuditConflict
+	}
+	r.events = append(r.events, copyAuditEvent(event))
+	return nil
+}
+
+func (r *MemoryAuditRepository) QueryAuditEvents(ctx context.Context, query *models.AuditQuery) ([]*models.AuditEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var events []*models.AuditEvent
+	for i := len(r.events) - 1; i >= 0 && len(events) < query.Limit; i-- {
+		if query.Matches(r.events[i]) {
+			events = append(events, copyAuditEvent(r.events[i]))
+		}
+	}
+	return events, nil
+}
+
+// MemoryOutboxRepository is a concurrency-safe, in-process OutboxRepository.
+// The memory user and session repositories given it add their events to it
+// under their own lock, so the change and its events appear together.
+type MemoryOutboxRepository struct {
+	mu       sync.Mutex
+	messages map[string]*models.OutboxMessage // keyed by event ID
+}
+
+// NewMemoryOutboxRepository creates an empty in-memory outbox
+func NewMemoryOutboxRepository() *MemoryOutboxRepository {
+	return &MemoryOutboxRepository{
+		messages: make(map[string]*models.OutboxMessage),
+	}
+}
+
+// add stores events as pending messages, skipping any already stored
+func (r *MemoryOutboxRepository) add(outbox []*events.Event) {
+	if len(outbox) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, event := range outbox {
+		if _, exists := r.messages[event.ID]; !exists {
+			copied := *event
+			r.messages[event.ID] = models.NewOutboxMessage(&copied)
+		}
+	}
+}
+
+func (r *MemoryOutboxRepository) GetDueOutboxMessages(ctx context.Context, now time.Time, limit int) ([]*models.OutboxMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var messages []*models.OutboxMessage
+	for _, message := range r.messages {
+		if message.Status == models.OutboxStatusPending && !message.NextAttemptAt.After(now) {
+			copied := *message
+			messages = append(messages, &copied)
+		}
+	}
+
+	sortOutboxMessages(messages)
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+func (r *MemoryOutboxRepository) UpdateOutboxMessage(ctx context.Context, message *models.OutboxMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.messages[message.ID]; !ok {
+		return ErrOutboxMessageNotFound
+	}
+	copied := *message
+	r.messages[message.ID] = &copied
+	return nil
+}
+
+func (r *MemoryOutboxRepository) DeleteOutboxMessage(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.messages, id)
+	return nil
+}
+
+// Helper functions
+
+// sortOutboxMessages orders messages oldest first; IDs break ties
+func sortOutboxMessages(messages []*models.OutboxMessage) {
+	sort.Slice(messages, func(i, j int) bool {
+		if !messages[i].CreatedAt.Equal(messages[j].CreatedAt) {
+			return messages[i].CreatedAt.Before(messages[j].CreatedAt)
+		}
+		return messages[i].ID < messages[j].ID
+	})
+}
+
+// identityKeyString joins a provider and subject; provider names can't
+// contain "#"
+func identityKeyString(provider, subject string) string {
+	return provider + "#" + subject
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// userMatches applies ListUsers' search the way the DynamoDB filter does
+func userMatches(user *models.User, query string) bool {
+	query = strings.ToLower(query)
+	return strings.Contains(normalizeEmail(user.Email), query) ||
+		strings.Contains(strings.ToLower(user.Name), query)
+}
+
+// Page cursors wrap the key to resume after: a user ID in memory, the
+// partition key of the last item scanned in DynamoDB
+func encodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(key) == 0 {
+		return "", ErrInvalidCursor
+	}
+	return string(key), nil
+}
+
+func copyUser(user *models.User) *models.User {
+	copied := *user
+	if user.Roles != nil {
+		copied.Roles = append([]string(nil), user.Roles...)
+	}
+	if user.LastLoginAt != nil {
+		lastLogin := *user.LastLoginAt
+		copied.LastLoginAt = &lastLogin
+	}
+	return &copied
+}
+
+func copyTOTPEnrollment(enrollment *models.TOTPEnrollment) *models.TOTPEnrollment {
+	copied := *enrollment
+	if enrollment.ConfirmedAt != nil {
+		confirmedAt := *enrollment.ConfirmedAt
+		copied.ConfirmedAt = &confirmedAt
+	}
+	return &copied
+}
+
+func copyCredential(credential *models.WebAuthnCredential) *models.WebAuthnCredential {
+	copied := *credential
+	copied.PublicKey = append([]byte(nil), credential.PublicKey...)
+	if credential.Transports != nil {
+		copied.Transports = append([]string(nil), credential.Transports...)
+	}
+	if credential.LastUsedAt != nil {
+		lastUsed := *credential.LastUsedAt
+		copied.LastUsedAt = &lastUsed
+	}
+	return &copied
+}
+
+func copyAuditEvent(event *models.AuditEvent) *models.AuditEvent {
+	copied := *event
+	copied.Details = maps.Clone(event.Details)
+	return &copied
+}
@@ -0,0 +1,439 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+)
+
+func newTestUser(t *testing.T, repo *MemoryUserRepository, id, email string) *models.User {
+	t.Helper()
+
+	user := &models.User{ID: id, Email: email, IsActive: true}
+	if err := repo.CreateUser(context.Background(), user, "hash"); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return user
+}
+
+func TestMemoryUserRepositoryCreateUser(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryUserRepository(nil)
+	newTestUser(t, repo, "user-1", "Jane@Example.com")
+
+	tests := []struct {
+		name    string
+		user    *models.User
+		wantErr error
+	}{
+		{name: "new user", user: &models.User{ID: "user-2", Email: "john@example.com"}},
+		{name: "email taken ignoring case", user: &models.User{ID: "user-3", Email: "jane@example.COM"}, wantErr: ErrUserExists},
+		{name: "ID taken", user: &models.User{ID: "user-1", Email: "other@example.com"}, wantErr: ErrUserExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repo.CreateUser(ctx, tt.user, "hash")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateUser() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := repo.GetUser(ctx, "missing"); err != ErrUserNotFound {
+		t.Errorf("GetUser(missing) error = %v, want %v", err, ErrUserNotFound)
+	}
+	if user, err := repo.GetUserByEmail(ctx, "JANE@example.com"); err != nil || user.ID != "user-1" {
+		t.Errorf("GetUserByEmail() = %v, %v, want user-1", user, err)
+	}
+}
+
+// TestMemoryUserRepositoryEmailTokens covers the verification and reset
+// tokens, which share their rules: unknown and used tokens are not found,
+// expired ones are reported as such
+func TestMemoryUserRepositoryEmailTokens(t *testing.T) {
+	type tokenStore struct {
+		create func(repo *MemoryUserRepository, userID, token string, duration time.Duration) error
+		verify func(repo *MemoryUserRepository, token string) (string, error)
+		use    func(repo *MemoryUserRepository, token string) error
+	}
+
+	ctx := context.Background()
+	stores := map[string]tokenStore{
+		"email verification": {
+			create: func(repo *MemoryUserRepository, userID, token string, duration time.Duration) error {
+				return repo.CreateEmailVerificationToken(ctx, userID, "jane@example.com", token, duration)
+			},
+			verify: func(repo *MemoryUserRepository, token string) (string, error) {
+				return repo.VerifyEmailToken(ctx, token)
+			},
+			use: func(repo *MemoryUserRepository, token string) error {
+				return repo.MarkEmailTokenUsed(ctx, token)
+			},
+		},
+		"password reset": {
+			create: func(repo *MemoryUserRepository, userID, token string, duration time.Duration) error {
+				return repo.CreatePasswordResetToken(ctx, userID, token, duration)
+			},
+			verify: func(repo *MemoryUserRepository, token string) (string, error) {
+				return repo.VerifyPasswordResetToken(ctx, token)
+			},
+			use: func(repo *MemoryUserRepository, token string) error {
+				return repo.MarkPasswordResetTokenUsed(ctx, token)
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		duration time.Duration
+		used     bool
+		token    string // defaults to the created token
+		wantUser string
+		wantErr  error
+	}{
+		{name: "valid", duration: time.Hour, wantUser: "user-1"},
+		{name: "used", duration: time.Hour, used: true, wantErr: ErrTokenNotFound},
+		{name: "expired", duration: -time.Minute, wantErr: ErrTokenExpired},
+		{name: "unknown", duration: time.Hour, token: "other", wantErr: ErrTokenNotFound},
+	}
+
+	for storeName, store := range stores {
+		for _, tt := range tests {
+			t.Run(storeName+"/"+tt.name, func(t *testing.T) {
+				repo := NewMemoryUserRepository(nil)
+				newTestUser(t, repo, "user-1", "jane@example.com")
+
+				if err := store.create(repo, "user-1", "token", tt.duration); err != nil {
+					t.Fatalf("create: %v", err)
+				}
+				if tt.used {
+					if err := store.use(repo, "token"); err != nil {
+						t.Fatalf("use: %v", err)
+					}
+				}
+
+				token := tt.token
+				if token == "" {
+					token = "token"
+				}
+				userID, err := store.verify(repo, token)
+				if err != tt.wantErr || userID != tt.wantUser {
+					t.Fatalf("verify() = %q, %v, want %q, %v", userID, err, tt.wantUser, tt.wantErr)
+				}
+			})
+		}
+
+		t.Run(storeName+"/unknown user", func(t *testing.T) {
+			repo := NewMemoryUserRepository(nil)
+			if err := store.create(repo, "missing", "token", time.Hour); err != ErrUserNotFound {
+				t.Fatalf("create() error = %v, want %v", err, ErrUserNotFound)
+			}
+		})
+	}
+}
+
+func TestMemoryUserRepositoryConsumeMagicLinkToken(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		duration time.Duration
+		wantUser string
+		wantErr  error
+	}{
+		{name: "valid", duration: time.Hour, wantUser: "user-1"},
+		{name: "expired", duration: -time.Minute, wantErr: ErrTokenExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewMemoryUserRepository(nil)
+			newTestUser(t, repo, "user-1", "jane@example.com")
+			if err := repo.CreateMagicLinkToken(ctx, "user-1", "link", tt.duration); err != nil {
+				t.Fatalf("CreateMagicLinkToken: %v", err)
+			}
+
+			userID, err := repo.ConsumeMagicLinkToken(ctx, "link")
+			if err != tt.wantErr || userID != tt.wantUser {
+				t.Fatalf("ConsumeMagicLinkToken() = %q, %v, want %q, %v", userID, err, tt.wantUser, tt.wantErr)
+			}
+
+			// A link works once, whether or not the first use succeeded
+			if _, err := repo.ConsumeMagicLinkToken(ctx, "link"); err != ErrTokenNotFound {
+				t.Fatalf("second ConsumeMagicLinkToken() error = %v, want %v", err, ErrTokenNotFound)
+			}
+		})
+	}
+}
+
+func TestMemoryUserRepositoryOneTimeCodes(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		setup   func(repo *MemoryUserRepository) error
+		use     func(repo *MemoryUserRepository) error
+		wantErr error
+	}{
+		{
+			name: "newer TOTP step",
+			setup: func(repo *MemoryUserRepository) error {
+				return repo.SaveTOTPEnrollment(ctx, &models.TOTPEnrollment{UserID: "user-1", LastUsedStep: 10})
+			},
+			use: func(repo *MemoryUserRepository) error { return repo.UseTOTPStep(ctx, "user-1", 11) },
+		},
+		{
+			name: "replayed TOTP step",
+			setup: func(repo *MemoryUserRepository) error {
+				return repo.SaveTOTPEnrollment(ctx, &models.TOTPEnrollment{UserID: "user-1", LastUsedStep: 10})
+			},
+			use:     func(repo *MemoryUserRepository) error { return repo.UseTOTPStep(ctx, "user-1", 10) },
+			wantErr: ErrCodeReused,
+		},
+		{
+			name:    "TOTP not enrolled",
+			setup:   func(repo *MemoryUserRepository) error { return nil },
+			use:     func(repo *MemoryUserRepository) error { return repo.UseTOTPStep(ctx, "user-1", 1) },
+			wantErr: ErrMFANotFound,
+		},
+		{
+			name: "unused recovery code",
+			setup: func(repo *MemoryUserRepository) error {
+				return repo.ReplaceRecoveryCodes(ctx, "user-1", []string{"a", "b"})
+			},
+			use: func(repo *MemoryUserRepository) error { return repo.UseRecoveryCode(ctx, "user-1", "b") },
+		},
+		{
+			name: "used recovery code",
+			setup: func(repo *MemoryUserRepository) error {
+				if err := repo.ReplaceRecoveryCodes(ctx, "user-1", []string{"a", "b"}); err != nil {
+					return err
+				}
+				return repo.UseRecoveryCode(ctx, "user-1", "a")
+			},
+			use:     func(repo *MemoryUserRepository) error { return repo.UseRecoveryCode(ctx, "user-1", "a") },
+			wantErr: ErrCodeReused,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewMemoryUserRepository(nil)
+			newTestUser(t, repo, "user-1", "jane@example.com")
+			if err := tt.setup(repo); err != nil {
+				t.Fatalf("setup: %v", err)
+			}
+
+			if err := tt.use(repo); err != tt.wantErr {
+				t.Fatalf("use error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMemoryUserRepositoryDeleteUser(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryUserRepository(nil)
+	newTestUser(t, repo, "user-1", "jane@example.com")
+	newTestUser(t, repo, "user-2", "john@example.com")
+
+	for _, userID := range []string{"user-1", "user-2"} {
+		if err := repo.CreatePasswordResetToken(ctx, userID, "reset-"+userID, time.Hour); err != nil {
+			t.Fatalf("CreatePasswordResetToken: %v", err)
+		}
+		if err := repo.CreateMagicLinkToken(ctx, userID, "link-"+userID, time.Hour); err != nil {
+			t.Fatalf("CreateMagicLinkToken: %v", err)
+		}
+		err := repo.SaveEmailOTP(ctx, &models.EmailOTP{UserID: userID, Purpose: "login", ExpiresAt: time.Now().Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("SaveEmailOTP: %v", err)
+		}
+	}
+
+	if err := repo.DeleteUser(ctx, "user-1"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if err := repo.DeleteUser(ctx, "user-1"); err != ErrUserNotFound {
+		t.Fatalf("second DeleteUser() error = %v, want %v", err, ErrUserNotFound)
+	}
+
+	tests := []struct {
+		name    string
+		userID  string
+		wantErr error
+	}{
+		{name: "deleted user", userID: "user-1", wantErr: ErrTokenNotFound},
+		{name: "other user", userID: "user-2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := repo.VerifyPasswordResetToken(ctx, "reset-"+tt.userID); err != tt.wantErr {
+				t.Errorf("VerifyPasswordResetToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if _, err := repo.ConsumeMagicLinkToken(ctx, "link-"+tt.userID); err != tt.wantErr {
+				t.Errorf("ConsumeMagicLinkToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if _, err := repo.GetEmailOTP(ctx, tt.userID, "login"); err != tt.wantErr {
+				t.Errorf("GetEmailOTP() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// The address is free again
+	newTestUser(t, repo, "user-3", "jane@example.com")
+}
+
+func TestMemorySessionRepositoryRotateRefreshToken(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		sessionID string
+		inactive  bool
+		currentID string
+		wantErr   error
+	}{
+		{name: "current token", sessionID: "session-1", currentID: "token-1"},
+		{name: "rotated token", sessionID: "session-1", currentID: "token-0", wantErr: ErrTokenReused},
+		{name: "inactive session", sessionID: "session-1", inactive: true, currentID: "token-1", wantErr: ErrTokenReused},
+		{name: "unknown session", sessionID: "missing", currentID: "token-1", wantErr: ErrSessionNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewMemorySessionRepository(nil)
+			err := repo.CreateSession(ctx, &models.Session{
+				ID:             "session-1",
+				UserID:         "user-1",
+				RefreshTokenID: "token-1",
+				IsActive:       !tt.inactive,
+				ExpiresAt:      time.Now().Add(time.Hour),
+			})
+			if err != nil {
+				t.Fatalf("CreateSession: %v", err)
+			}
+
+			err = repo.RotateRefreshToken(ctx, tt.sessionID, tt.currentID, "token-2")
+			if err != tt.wantErr {
+				t.Fatalf("RotateRefreshToken() error = %v, want %v", err, tt.wantErr)
+			}
+
+			session, err := repo.GetSession(ctx, "session-1")
+			if err != nil {
+				t.Fatalf("GetSession: %v", err)
+			}
+			wantID := "token-1"
+			if tt.wantErr == nil {
+				wantID = "token-2"
+			}
+			if session.RefreshTokenID != wantID {
+				t.Errorf("RefreshTokenID = %q, want %q", session.RefreshTokenID, wantID)
+			}
+		})
+	}
+}
+
+func TestMemoryClientRepositoryConsumeAuthorizationCode(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		expires  time.Duration
+		consumes int
+		code     string
+		wantUsed bool
+		wantErr  error
+	}{
+		{name: "first use", expires: time.Minute, consumes: 1, code: "code", wantUsed: true},
+		{name: "replay", expires: time.Minute, consumes: 2, code: "code", wantUsed: true, wantErr: ErrCodeReused},
+		{name: "expired", expires: -time.Minute, consumes: 1, code: "code", wantErr: ErrTokenExpired},
+		{name: "unknown", expires: time.Minute, consumes: 1, code: "other", wantErr: ErrTokenNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewMemoryClientRepository()
+			err := repo.SaveAuthorizationCode(ctx, &models.AuthorizationCode{
+				Code:      "code",
+				ClientID:  "client-1",
+				UserID:    "user-1",
+				ExpiresAt: time.Now().Add(tt.expires),
+			})
+			if err != nil {
+				t.Fatalf("SaveAuthorizationCode: %v", err)
+			}
+
+			var code *models.AuthorizationCode
+			for i := 0; i < tt.consumes; i++ {
+				code, err = repo.ConsumeAuthorizationCode(ctx, tt.code)
+			}
+			if err != tt.wantErr {
+				t.Fatalf("ConsumeAuthorizationCode() error = %v, want %v", err, tt.wantErr)
+			}
+			// A replayed code comes back so the caller can revoke what it got
+			if tt.wantUsed && (code == nil || !code.Used || code.UserID != "user-1") {
+				t.Fatalf("ConsumeAuthorizationCode() = %+v, want the used code", code)
+			}
+		})
+	}
+}
+
+// TestMemorySingleUseState covers the WebAuthn challenges and social login
+// states, which are deleted on first use, expired or not
+func TestMemorySingleUseState(t *testing.T) {
+	ctx := context.Background()
+
+	stores := map[string]func(expiresAt time.Time) (consume func() error){
+		"webauthn challenge": func(expiresAt time.Time) func() error {
+			repo := NewMemoryCredentialRepository()
+			err := repo.SaveChallenge(ctx, &models.WebAuthnChallenge{Challenge: "challenge", ExpiresAt: expiresAt})
+			if err != nil {
+				t.Fatalf("SaveChallenge: %v", err)
+			}
+			return func() error {
+				_, err := repo.ConsumeChallenge(ctx, "challenge")
+				return err
+			}
+		},
+		"oauth state": func(expiresAt time.Time) func() error {
+			repo := NewMemoryIdentityRepository()
+			err := repo.SaveOAuthState(ctx, &models.OAuthState{State: "state", ExpiresAt: expiresAt})
+			if err != nil {
+				t.Fatalf("SaveOAuthState: %v", err)
+			}
+			return func() error {
+				_, err := repo.ConsumeOAuthState(ctx, "state")
+				return err
+			}
+		},
+	}
+
+	tests := []struct {
+		name    string
+		expires time.Duration
+		wantErr error
+	}{
+		{name: "valid", expires: time.Minute},
+		{name: "expired", expires: -time.Minute, wantErr: ErrTokenExpired},
+	}
+
+	for storeName, save := range stores {
+		for _, tt := range tests {
+			t.Run(storeName+"/"+tt.name, func(t *testing.T) {
+				consume := save(time.Now().Add(tt.expires))
+
+				if err := consume(); err != tt.wantErr {
+					t.Fatalf("first consume error = %v, want %v", err, tt.wantErr)
+				}
+				if err := consume(); err != ErrTokenNotFound {
+					t.Fatalf("second consume error = %v, want %v", err, ErrTokenNotFound)
+				}
+			})
+		}
+	}
+}
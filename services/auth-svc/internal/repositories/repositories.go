@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/shared/events"
 )
 
 // Common repository errors
@@ -14,32 +15,87 @@ var (
 	ErrSessionNotFound = errors.New("session not found")
 	ErrTokenNotFound   = errors.New("token not found")
 	ErrTokenExpired    = errors.New("token expired")
+	ErrUserExists      = errors.New("user already exists")
+	ErrTokenReused     = errors.New("refresh token already rotated")
+	ErrMFANotFound     = errors.New("mfa not enrolled")
+	ErrMFAEnabled      = errors.New("mfa already enabled")
+	ErrCodeReused      = errors.New("one-time code already used")
+
+	ErrCredentialNotFound = errors.New("credential not found")
+	ErrCredentialExists   = errors.New("credential already registered")
+
+	ErrIdentityNotFound = errors.New("identity not linked")
+	ErrIdentityLinked   = errors.New("identity already linked")
+
+	ErrClientNotFound = errors.New("oauth client not found")
+
+	ErrInvalidCursor = errors.New("invalid page cursor")
+
+	ErrAuditConflict = errors.New("audit log head moved")
+
+	ErrOutboxMessageNotFound = errors.New("outbox message not found")
 )
 
+// Methods taking outbox events add them to the outbox in the same
+// transaction as the change they describe, so the events are stored if and
+// only if the change is. See OutboxRepository.
+
 // UserRepository defines the interface for user data operations
 type UserRepository interface {
 	// User CRUD operations
-	CreateUser(ctx context.Context, user *models.User, passwordHash string) error
+	CreateUser(ctx context.Context, user *models.User, passwordHash string, outbox ...*events.Event) error
 	GetUser(ctx context.Context, userID string) (*models.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
 	UpdateUser(ctx context.Context, user *models.User) error
-	DeleteUser(ctx context.Context, userID string) error
+	DeleteUser(ctx context.Context, userID string, outbox ...*events.Event) error // Also removes the user's pending tokens, email codes and second factors
+
+	// ListUsers pages through the users whose email or name contains query,
+	// ignoring case. Pass "" as cursor for the first page; the returned
+	// cursor is "" after the last one.
+	ListUsers(ctx context.Context, query string, limit int, cursor string) ([]*models.User, string, error) // ErrInvalidCursor for a cursor it didn't issue
 
 	// Password operations
 	GetPasswordHash(ctx context.Context, userID string) (string, error)
-	UpdatePassword(ctx context.Context, userID, passwordHash string) error
+	UpdatePassword(ctx context.Context, userID, passwordHash string, outbox ...*events.Event) error
 	UpdateLastLogin(ctx context.Context, userID string, loginTime time.Time) error
 
 	// Email verification
 	CreateEmailVerificationToken(ctx context.Context, userID, email, token string, duration time.Duration) error
 	VerifyEmailToken(ctx context.Context, token string) (string, error) // returns userID
 	MarkEmailTokenUsed(ctx context.Context, token string) error
-	MarkUserVerified(ctx context.Context, userID string) error
+	MarkUserVerified(ctx context.Context, userID string, outbox ...*events.Event) error
 
 	// Password reset
 	CreatePasswordResetToken(ctx context.Context, userID, token string, duration time.Duration) error
 	VerifyPasswordResetToken(ctx context.Context, token string) (string, error) // returns userID
 	MarkPasswordResetTokenUsed(ctx context.Context, token string) error
+
+	// Magic link login
+	CreateMagicLinkToken(ctx context.Context, userID, token string, duration time.Duration) error
+	ConsumeMagicLinkToken(ctx context.Context, token string) (string, error) // returns userID; a token works once
+
+	// Email one-time codes, one per user and purpose
+	SaveEmailOTP(ctx context.Context, otp *models.EmailOTP) error // replaces any earlier code
+	GetEmailOTP(ctx context.Context, userID, purpose string) (*models.EmailOTP, error)
+	RecordEmailOTPAttempt(ctx context.Context, userID, purpose string) (int, error) // returns attempts so far, this one included
+	DeleteEmailOTP(ctx context.Context, userID, purpose string) error               // ErrTokenNotFound if already gone
+
+	// Login attempt tracking, keyed by "user:<id>" or "ip:<addr>"
+	GetLoginAttempts(ctx context.Context, key string) (*models.LoginAttempts, error) // zero value if none
+	RecordFailedLogin(ctx context.Context, key string, attemptTime time.Time, window time.Duration) (*models.LoginAttempts, error)
+	LockLogin(ctx context.Context, key string, until time.Time) error
+	ClearLoginAttempts(ctx context.Context, key string) error
+
+	// TOTP second factor. Secrets arrive encrypted and are stored as given.
+	SaveTOTPEnrollment(ctx context.Context, enrollment *models.TOTPEnrollment) error // ErrMFAEnabled if a confirmed one exists
+	GetTOTPEnrollment(ctx context.Context, userID string) (*models.TOTPEnrollment, error)
+	ConfirmTOTPEnrollment(ctx context.Context, userID string, confirmedAt time.Time, step int64) error
+	UseTOTPStep(ctx context.Context, userID string, step int64) error // ErrCodeReused unless step is newer than the last one used
+
+	// MFA recovery codes, stored as bcrypt hashes
+	ReplaceRecoveryCodes(ctx context.Context, userID string, hashes []string) error
+	GetRecoveryCodes(ctx context.Context, userID string) ([]string, error) // empty if none
+	UseRecoveryCode(ctx context.Context, userID, hash string) error        // ErrCodeReused if hash is no longer unused
 }
 
 // SessionRepository defines the interface for session data operations
@@ -52,8 +108,12 @@ type SessionRepository interface {
 	DeleteSession(ctx context.Context, sessionID string) error
 
 	// Session management
-	DeactivateSession(ctx context.Context, sessionID string) error
-	DeactivateUserSessions(ctx context.Context, userID string) error
+	DeactivateSession(ctx context.Context, sessionID string, outbox ...*events.Event) error
+	DeactivateUserSessions(ctx context.Context, userID string, outbox ...*events.Event) error
+	// RotateRefreshToken replaces the session's current refresh token id with
+	// nextID if it is still currentID and the session is active, returning
+	// ErrTokenReused otherwise
+	RotateRefreshToken(ctx context.Context, sessionID, currentID, nextID string) error
 	CleanupExpiredSessions(ctx context.Context) error
 
 	// Anonymous sessions
@@ -63,151 +123,64 @@ type SessionRepository interface {
 	CleanupExpiredAnonymousSessions(ctx context.Context) error
 }
 
-// Mock implementations for now (will be replaced with DynamoDB implementations)
-
-type MockUserRepository struct{}
-
-func NewDynamoDBUserRepository() UserRepository {
-	return &MockUserRepository{}
-}
-
-func (r *MockUserRepository) CreateUser(ctx context.Context, user *models.User, passwordHash string) error {
-	// TODO: Implement DynamoDB operations
-	return nil
-}
-
-func (r *MockUserRepository) GetUser(ctx context.Context, userID string) (*models.User, error) {
-	// TODO: Implement DynamoDB operations
-	return nil, ErrUserNotFound
-}
-
-func (r *MockUserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
-	// TODO: Implement DynamoDB operations
-	return nil, ErrUserNotFound
-}
-
-func (r *MockUserRepository) UpdateUser(ctx context.Context, user *models.User) error {
-	// TODO: Implement DynamoDB operations
-	return nil
-}
-
-func (r *MockUserRepository) DeleteUser(ctx context.Context, userID string) error {
-	// TODO: Implement DynamoDB operations
-	return nil
-}
-
-func (r *MockUserRepository) GetPasswordHash(ctx context.Context, userID string) (string, error) {
-	// TODO: Implement DynamoDB operations
-	return "", ErrUserNotFound
+// CredentialRepository stores users' WebAuthn credentials (passkeys)
+type CredentialRepository interface {
+	CreateCredential(ctx context.Context, credential *models.WebAuthnCredential) error // ErrCredentialExists if the ID is taken
+	GetCredential(ctx context.Context, credentialID string) (*models.WebAuthnCredential, error)
+	GetUserCredentials(ctx context.Context, userID string) ([]*models.WebAuthnCredential, error)
+	UpdateCredentialUsage(ctx context.Context, credentialID string, signCount uint32, usedAt time.Time) error
+	DeleteCredential(ctx context.Context, userID, credentialID string) error // ErrCredentialNotFound unless it belongs to userID
+
+	SaveChallenge(ctx context.Context, challenge *models.WebAuthnChallenge) error
+	ConsumeChallenge(ctx context.Context, challenge string) (*models.WebAuthnChallenge, error) // ErrTokenNotFound if unknown or used, ErrTokenExpired
+}
+
+// IdentityRepository stores the external OAuth/OIDC accounts linked to users
+// and the social logins in progress
+type IdentityRepository interface {
+	LinkIdentity(ctx context.Context, identity *models.LinkedIdentity) error // ErrIdentityLinked if the provider account is linked already
+	GetIdentity(ctx context.Context, provider, subject string) (*models.LinkedIdentity, error)
+	DeleteUserIdentities(ctx context.Context, userID string) error
+
+	SaveOAuthState(ctx context.Context, state *models.OAuthState) error
+	ConsumeOAuthState(ctx context.Context, state string) (*models.OAuthState, error) // ErrTokenNotFound if unknown or used, ErrTokenExpired
+}
+
+// ClientRepository stores the OAuth2 clients registered with the
+// authorization server and the authorization codes issued to them
+type ClientRepository interface {
+	CreateClient(ctx context.Context, client *models.OAuthClient) error
+	GetClient(ctx context.Context, clientID string) (*models.OAuthClient, error)
+	GetUserClients(ctx context.Context, ownerID string) ([]*models.OAuthClient, error)
+	DeleteClient(ctx context.Context, ownerID, clientID string) error // ErrClientNotFound unless it belongs to ownerID
+
+	SaveAuthorizationCode(ctx context.Context, code *models.AuthorizationCode) error
+	// ConsumeAuthorizationCode marks the code used and returns it. A code that
+	// was used before is returned with ErrCodeReused; unknown ones give
+	// ErrTokenNotFound and expired ones ErrTokenExpired.
+	ConsumeAuthorizationCode(ctx context.Context, code string) (*models.AuthorizationCode, error)
+}
+
+// AuditRepository stores the hash-chained audit log. The head is the
+// sequence number and hash of the newest event, which the next one chains to.
+type AuditRepository interface {
+	GetAuditHead(ctx context.Context) (int64, string, error) // 0 and "" while the log is empty
+	// AppendAuditEvent stores an event chained to the head and makes it the
+	// new head. If the head is no longer event.Sequence-1 nothing is written
+	// and ErrAuditConflict is returned.
+	AppendAuditEvent(ctx context.Context, event *models.AuditEvent) error
+	QueryAuditEvents(ctx context.Context, query *models.AuditQuery) ([]*models.AuditEvent, error) // Newest first, at most query.Limit
+}
+
+// OutboxRepository holds domain events between the transaction that stored
+// them and their publication. Messages are delivered at least once: the relay
+// may publish one again if it fails to record that it was published.
+type OutboxRepository interface {
+	// GetDueOutboxMessages returns up to limit pending messages whose next
+	// attempt is due at now, oldest first
+	GetDueOutboxMessages(ctx context.Context, now time.Time, limit int) ([]*models.OutboxMessage, error)
+	// UpdateOutboxMessage saves a message's attempts, next attempt and status
+	UpdateOutboxMessage(ctx context.Context, message *models.OutboxMessage) error // ErrOutboxMessageNotFound if it was deleted
+	// DeleteOutboxMessage removes a message once it is published
+	DeleteOutboxMessage(ctx context.Context, id string) error
 }
-
-func (r *MockUserRepository) UpdatePassword(ctx context.Context, userID, passwordHash string) error {
-	// TODO: Implement DynamoDB operations
-	return nil
-}
-
-func (r *MockUserRepository) UpdateLastLogin(ctx context.Context, userID string, loginTime time.Time) error {
-	// TODO: Implement DynamoDB operations
-	return nil
-}
-
-func (r *MockUserRepository) CreateEmailVerificationToken(ctx context.Context, userID, email, token string, duration time.Duration) error {
-	// TODO: Implement DynamoDB operations
-	return nil
-}
-
-func (r *MockUserRepository) VerifyEmailToken(ctx context.Context, token string) (string, error) {
-	// TODO: Implement DynamoDB operations
-	return "", ErrTokenNotFound
-}
-
-func (r *MockUserRepository) MarkEmailTokenUsed(ctx context.Context, token string) error {
-	// TODO: Implement DynamoDB operations
-	return nil
-}
-
-func (r *MockUserRepository) MarkUserVerified(ctx context.Context, userID string) error {
-	// TODO: Implement DynamoDB operations
-	return nil
-}
-
-func (r *MockUserRepository) CreatePasswordResetToken(ctx context.Context, userID, token string, duration time.Duration) error {
-	// TODO: Implement DynamoDB operations
-	return nil
-}
-
-func (r *MockUserRepository) VerifyPasswordResetToken(ctx context.Context, token string) (string, error) {
-	// TODO: Implement DynamoDB operations
-	return "", ErrTokenNotFound
-}
-
-func (r *MockUserRepository) MarkPasswordResetTokenUsed(ctx context.Context, token string) error {
-	// TODO: Implement DynamoDB operations
-	return nil
-}
-
-type MockSessionRepository struct{}
-
-func NewDynamoDBSessionRepository() SessionRepository {
-	return &MockSessionRepository{}
-}
-
-func (r *MockSessionRepository) CreateSession(ctx context.Context, session *models.Session) error {
-	// TODO: Implement DynamoDB operations
-	return nil
-}
-
-func (r *MockSessionRepository) GetSession(ctx context.Context, sessionID string) (*models.Session, error) {
-	// TODO: Implement DynamoDB operations
-	return nil, ErrSessionNotFound
-}
-
-func (r *MockSessionRepository) GetUserSessions(ctx context.Context, userID string) ([]*models.Session, error) {
-	// TODO: Implement DynamoDB operations
-	return nil, nil
-}
-
-func (r *MockSessionRepository) UpdateSession(ctx context.Context, session *models.Session) error {
-	// TODO: Implement DynamoDB operations
-	return nil
-}
-
-func (r *MockSessionRepository) DeleteSession(ctx context.Context, sessionID string) error {
-	// TODO: Implement DynamoDB operations
-	return nil
-}
-
-func (r *MockSessionRepository) DeactivateSession(ctx context.Context, sessionID string) error {
-	// TODO: Implement DynamoDB operations
-	return nil
-}
-
-func (r *MockSessionRepository) DeactivateUserSessions(ctx context.Context, userID string) error {
-	// TODO: Implement DynamoDB operations
-	return nil
-}
-
-func (r *MockSessionRepository) CleanupExpiredSessions(ctx context.Context) error {
-	// TODO: Implement DynamoDB operations
-	return nil
-}
-
-func (r *MockSessionRepository) CreateAnonymousSession(ctx context.Context, session *models.AnonymousSession) error {
-	// TODO: Implement DynamoDB operations
-	return nil
-}
-
-func (r *MockSessionRepository) GetAnonymousSession(ctx context.Context, sessionID string) (*models.AnonymousSession, error) {
-	// TODO: Implement DynamoDB operations
-	return nil, ErrSessionNotFound
-}
-
-func (r *MockSessionRepository) DeleteAnonymousSession(ctx context.Context, sessionID string) error {
-	// TODO: Implement DynamoDB operations
-	return nil
-}
-
-func (r *MockSessionRepository) CleanupExpiredAnonymousSessions(ctx context.Context) error {
-	// TODO: Implement DynamoDB operations
-	return nil
-}
\ No newline at end of file
@@ -0,0 +1,95 @@
+// Package secretbox encrypts small secrets, such as TOTP keys, before they are
+// written to storage, so a leaked table dump doesn't leak second factors
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// KeySize is the required key length (AES-256)
+const KeySize = 32
+
+// version prefixes every ciphertext so the format can change later
+const version = "v1:"
+
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+// Box seals and opens secrets with AES-256-GCM
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New creates a Box from a 32 byte key
+func New(key []byte) (*Box, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Box{aead: aead}, nil
+}
+
+// NewFromBase64 creates a Box from a base64-encoded key, as stored in
+// configuration
+func NewFromBase64(encoded string) (*Box, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key is not valid base64: %w", err)
+	}
+	return New(key)
+}
+
+// Generate creates a Box with a random key, for development and tests.
+// Anything it seals can't be opened after a restart.
+func Generate() (*Box, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return New(key)
+}
+
+// Seal encrypts plaintext. additionalData (e.g. the owning user ID) is
+// authenticated but not encrypted, so a ciphertext copied to another record
+// fails to open.
+func (b *Box) Seal(plaintext, additionalData string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), []byte(additionalData))
+	return version + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value produced by Seal with the same additionalData
+func (b *Box) Open(ciphertext, additionalData string) (string, error) {
+	if len(ciphertext) < len(version) || ciphertext[:len(version)] != version {
+		return "", ErrInvalidCiphertext
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(ciphertext[len(version):])
+	if err != nil || len(sealed) < b.aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	nonce, body := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, body, []byte(additionalData))
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	return string(plaintext), nil
+}
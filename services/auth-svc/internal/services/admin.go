@@ -0,0 +1,330 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/repositories"
+	"github.com/multitask-platform/backend/shared/events"
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+// Account administration. Each method takes the ID of the admin acting, and
+// every call is recorded in the audit log with the admin as the actor. Admins can't
+// disable, delete or demote themselves, so there is always someone left to
+// undo a mistake.
+
+// ListUsers returns a page of users, optionally filtered by email or name
+func (s *AuthService) ListUsers(ctx context.Context, adminID string, req *models.ListUsersRequest) (*models.UserList, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = models.DefaultUserPageSize
+	}
+	if limit > models.MaxUserPageSize {
+		limit = models.MaxUserPageSize
+	}
+
+	users, next, err := s.userRepo.ListUsers(ctx, req.Query, limit, req.Cursor)
+	if err != nil {
+		if err == repositories.ErrInvalidCursor {
+			return nil, ErrInvalidCursor
+		}
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	s.auditAdminAction(ctx, models.AuditActionUsersListed, adminID, "", map[string]string{"query": req.Query})
+
+	list := &models.UserList{
+		Users:      make([]*models.User, 0, len(users)),
+		NextCursor: next,
+	}
+	for _, user := range users {
+		list.Users = append(list.Users, user.SanitizeUser())
+	}
+	return list, nil
+}
+
+// GetUserDetails returns a user with their MFA status and active sessions
+func (s *AuthService) GetUserDetails(ctx context.Context, adminID, userID string) (*models.AdminUserDetails, error) {
+	user, err := s.getUserForAdmin(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	mfa, err := s.mfaStatus(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.sessionRepo.GetUserSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user sessions: %w", err)
+	}
+	if sessions == nil {
+		sessions = []*models.Session{}
+	}
+
+	s.auditAdminAction(ctx, models.AuditActionUserViewed, adminID, userID, nil)
+
+	sanitized := user.SanitizeUser()
+	sanitized.MFA = mfa
+	return &models.AdminUserDetails{
+		User:     sanitized,
+		Sessions: sessions,
+	}, nil
+}
+
+// AssignRole gives a user a role. Their current access tokens keep the old
+// roles until they refresh.
+func (s *AuthService) AssignRole(ctx context.Context, adminID, userID, role string) (*models.User, error) {
+	if !models.IsValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+
+	user, err := s.getUserForAdmin(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.HasRole(role) {
+		user.Roles = append(user.Roles, role)
+		if err := s.updateUserForAdmin(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	s.auditAdminAction(ctx, models.AuditActionRoleAssigned, adminID, userID, map[string]string{"role": role})
+
+	return user.SanitizeUser(), nil
+}
+
+// RemoveRole takes a role away from a user. Their access tokens are revoked
+// so the role stops working at once; refreshing gets tokens without it.
+func (s *AuthService) RemoveRole(ctx context.Context, adminID, userID, role string) (*models.User, error) {
+	if !models.IsValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	if userID == adminID && role == models.RoleAdmin {
+		return nil, ErrAdminSelfAction
+	}
+
+	user, err := s.getUserForAdmin(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.HasRole(role) {
+		user.Roles = slices.DeleteFunc(user.Roles, func(r string) bool { return r == role })
+		if err := s.updateUserForAdmin(ctx, user); err != nil {
+			return nil, err
+		}
+		s.revokeUserTokens(ctx, userID)
+	}
+
+	s.auditAdminAction(ctx, models.AuditActionRoleRemoved, adminID, userID, map[string]string{"role": role})
+
+	return user.SanitizeUser(), nil
+}
+
+// SetUserActive disables or re-enables an account. Disabling also ends all
+// of the user's sessions and revokes their access tokens.
+func (s *AuthService) SetUserActive(ctx context.Context, adminID, userID string, active bool) (*models.User, error) {
+	if userID == adminID && !active {
+		return nil, ErrAdminSelfAction
+	}
+
+	user, err := s.getUserForAdmin(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.IsActive != active {
+		user.IsActive = active
+		if err := s.updateUserForAdmin(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	action := models.AuditActionUserEnabled
+	if !active {
+		action = models.AuditActionUserDisabled
+		s.endUserSessions(ctx, userID, s.outboxEvents(ctx, events.SessionRevoked{
+			UserID: userID,
+			Reason: events.RevokeReasonUserDisabled,
+		})...)
+	}
+
+	s.auditAdminAction(ctx, action, adminID, userID, nil)
+
+	return user.SanitizeUser(), nil
+}
+
+// ForceLogout ends all of a user's sessions and revokes their access tokens
+func (s *AuthService) ForceLogout(ctx context.Context, adminID, userID string) error {
+	if _, err := s.getUserForAdmin(ctx, userID); err != nil {
+		return err
+	}
+
+	err := s.sessionRepo.DeactivateUserSessions(ctx, userID, s.outboxEvents(ctx, events.SessionRevoked{
+		UserID: userID,
+		Reason: events.RevokeReasonAdmin,
+	})...)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate user sessions: %w", err)
+	}
+	s.revokeUserTokens(ctx, userID)
+
+	s.auditAdminAction(ctx, models.AuditActionUserLoggedOut, adminID, userID, nil)
+
+	return nil
+}
+
+// SendPasswordReset emails a user a password reset link. Their current
+// password keeps working until they use it.
+func (s *AuthService) SendPasswordReset(ctx context.Context, adminID, userID string) error {
+	user, err := s.getUserForAdmin(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.IsActive {
+		return ErrUserDisabled
+	}
+
+	if err := s.startPasswordReset(ctx, user); err != nil {
+		return err
+	}
+
+	s.auditAdminAction(ctx, models.AuditActionPasswordResetSent, adminID, userID, nil)
+
+	return nil
+}
+
+// DeleteUser removes an account along with its passkeys, OAuth2 clients,
+// linked social logins and lockout state, after ending its sessions. The
+// user repository drops the second factors and pending tokens with the user.
+func (s *AuthService) DeleteUser(ctx context.Context, adminID, userID string) error {
+	if userID == adminID {
+		return ErrAdminSelfAction
+	}
+
+	if _, err := s.getUserForAdmin(ctx, userID); err != nil {
+		return err
+	}
+
+	s.endUserSessions(ctx, userID)
+
+	credentials, err := s.credentials.GetUserCredentials(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get passkeys: %w", err)
+	}
+	for _, credential := range credentials {
+		err := s.credentials.DeleteCredential(ctx, userID, credential.ID)
+		if err != nil && err != repositories.ErrCredentialNotFound {
+			return fmt.Errorf("failed to delete passkey: %w", err)
+		}
+	}
+
+	clients, err := s.clients.GetUserClients(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get clients: %w", err)
+	}
+	for _, client := range clients {
+		err := s.clients.DeleteClient(ctx, userID, client.ID)
+		if err != nil && err != repositories.ErrClientNotFound {
+			return fmt.Errorf("failed to delete client: %w", err)
+		}
+	}
+
+	if err := s.identities.DeleteUserIdentities(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete linked identities: %w", err)
+	}
+
+	if err := s.userRepo.ClearLoginAttempts(ctx, userAttemptKey(userID)); err != nil {
+		return fmt.Errorf("failed to clear login attempts: %w", err)
+	}
+
+	err = s.userRepo.DeleteUser(ctx, userID, s.outboxEvents(ctx, events.UserDeleted{UserID: userID})...)
+	if err != nil {
+		if err == repositories.ErrUserNotFound {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	s.auditAdminAction(ctx, models.AuditActionUserDeleted, adminID, userID, nil)
+
+	return nil
+}
+
+// QueryAuditLog returns the newest audit events matching the query
+func (s *AuthService) QueryAuditLog(ctx context.Context, adminID string, query *models.AuditQuery) (*models.AuditLog, error) {
+	if query.Limit <= 0 {
+		query.Limit = models.DefaultAuditPageSize
+	}
+	if query.Limit > models.MaxAuditPageSize {
+		query.Limit = models.MaxAuditPageSize
+	}
+
+	events, err := s.auditLog.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	if events == nil {
+		events = []*models.AuditEvent{}
+	}
+
+	s.auditAdminAction(ctx, models.AuditActionAuditQueried, adminID, "", nil)
+
+	return &models.AuditLog{Events: events}, nil
+}
+
+// getUserForAdmin loads the user an admin action targets
+func (s *AuthService) getUserForAdmin(ctx context.Context, userID string) (*models.User, error) {
+	user, err := s.userRepo.GetUser(ctx, userID)
+	if err != nil {
+		if err == repositories.ErrUserNotFound {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// updateUserForAdmin saves an admin's change to a user
+func (s *AuthService) updateUserForAdmin(ctx context.Context, user *models.User) error {
+	user.UpdatedAt = s.now().UTC()
+
+	err := s.userRepo.UpdateUser(ctx, user)
+	if err != nil {
+		if err == repositories.ErrUserNotFound {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	return nil
+}
+
+// endUserSessions logs a user out everywhere, adding any events to the
+// outbox with the sessions. The account change that prompted it has already
+// been saved, so failures are only logged.
+func (s *AuthService) endUserSessions(ctx context.Context, userID string, outbox ...*events.Event) {
+	err := s.sessionRepo.DeactivateUserSessions(ctx, userID, outbox...)
+	if err != nil {
+		logger.WarnCtx(ctx, "Failed to deactivate user sessions", zap.Error(err))
+	}
+	s.revokeUserTokens(ctx, userID)
+}
+
+// auditAdminAction records what an admin did, and to whom
+func (s *AuthService) auditAdminAction(ctx context.Context, action, adminID, userID string, details map[string]string) {
+	s.recordAudit(ctx, &models.AuditEvent{
+		Action:  action,
+		ActorID: adminID,
+		UserID:  userID,
+		Details: details,
+	})
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/shared/revocation"
+)
+
+// adminFixture is an admin and a signed-in user they manage
+type adminFixture struct {
+	s       *AuthService
+	checker *revocation.Checker
+	adminID string
+	user    *models.User
+	login   *models.AuthResponse
+}
+
+func newAdminFixture(t *testing.T) *adminFixture {
+	t.Helper()
+
+	checker := revocation.NewChecker(revocation.NewMemoryStore(), 0)
+	s, mailer := newTestService(t, newTestConfig(), WithRevocations(checker))
+	admin := registerVerifiedUser(t, s, mailer, "admin@example.com", "correct horse")
+	user := registerVerifiedUser(t, s, mailer, "jane@example.com", "correct horse")
+
+	login, err := s.Login(context.Background(), &models.LoginRequest{Email: "jane@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	return &adminFixture{s: s, checker: checker, adminID: admin.ID, user: user, login: login}
+}
+
+// revoked reports whether the user's access token from the fixture's login
+// has been revoked
+func (f *adminFixture) revoked(t *testing.T) bool {
+	t.Helper()
+
+	claims, err := f.s.tokens.ParseToken(f.login.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseToken: %v", err)
+	}
+	revoked, err := f.checker.IsRevoked(context.Background(), revocation.Token{
+		UserID:    claims.UserID,
+		SessionID: claims.SessionID,
+		TokenID:   claims.TokenID,
+		IssuedAt:  time.Unix(claims.IssuedAt, 0),
+	})
+	if err != nil {
+		t.Fatalf("IsRevoked: %v", err)
+	}
+	return revoked
+}
+
+// lastAudit returns the newest audit event about the user
+func (f *adminFixture) lastAudit(t *testing.T) *models.AuditEvent {
+	t.Helper()
+
+	events, err := f.s.auditLog.Query(context.Background(), &models.AuditQuery{UserID: f.user.ID, Limit: 1})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	return events[0]
+}
+
+func TestAuthServiceAdminActions(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name         string
+		act          func(f *adminFixture) error
+		wantErr      error
+		wantAction   string            // audit action recorded with the admin as actor
+		wantDetails  map[string]string // details of that audit event
+		wantRoles    []string
+		wantActive   bool
+		wantSessions int
+		wantRevoked  bool // the user's access token stops working
+		wantLoginErr error
+	}{
+		{
+			name:       "view user",
+			act:        func(f *adminFixture) error { _, err := f.s.GetUserDetails(ctx, f.adminID, f.user.ID); return err },
+			wantAction: models.AuditActionUserViewed,
+			wantRoles:  []string{models.RoleUser}, wantActive: true, wantSessions: 1,
+		},
+		{
+			name: "assign role",
+			act: func(f *adminFixture) error {
+				_, err := f.s.AssignRole(ctx, f.adminID, f.user.ID, models.RoleMod)
+				return err
+			},
+			wantAction:  models.AuditActionRoleAssigned,
+			wantDetails: map[string]string{"role": models.RoleMod},
+			wantRoles:   []string{models.RoleUser, models.RoleMod}, wantActive: true, wantSessions: 1,
+		},
+		{
+			name: "assign unknown role",
+			act: func(f *adminFixture) error {
+				_, err := f.s.AssignRole(ctx, f.adminID, f.user.ID, "superuser")
+				return err
+			},
+			wantErr: ErrInvalidRole,
+		},
+		{
+			name: "remove role",
+			act: func(f *adminFixture) error {
+				if _, err := f.s.AssignRole(ctx, f.adminID, f.user.ID, models.RoleMod); err != nil {
+					return err
+				}
+				_, err := f.s.RemoveRole(ctx, f.adminID, f.user.ID, models.RoleMod)
+				return err
+			},
+			wantAction:  models.AuditActionRoleRemoved,
+			wantDetails: map[string]string{"role": models.RoleMod},
+			wantRoles:   []string{models.RoleUser}, wantActive: true, wantSessions: 1, wantRevoked: true,
+		},
+		{
+			name: "remove own admin role",
+			act: func(f *adminFixture) error {
+				_, err := f.s.RemoveRole(ctx, f.adminID, f.adminID, models.RoleAdmin)
+				return err
+			},
+			wantErr: ErrAdminSelfAction,
+		},
+		{
+			name: "disable",
+			act: func(f *adminFixture) error {
+				_, err := f.s.SetUserActive(ctx, f.adminID, f.user.ID, false)
+				return err
+			},
+			wantAction: models.AuditActionUserDisabled,
+			wantRoles:  []string{models.RoleUser}, wantActive: false, wantSessions: 0, wantRevoked: true,
+			wantLoginErr: ErrUserDisabled,
+		},
+		{
+			name: "disable self",
+			act: func(f *adminFixture) error {
+				_, err := f.s.SetUserActive(ctx, f.adminID, f.adminID, false)
+				return err
+			},
+			wantErr: ErrAdminSelfAction,
+		},
+		{
+			name: "enable",
+			act: func(f *adminFixture) error {
+				if _, err := f.s.SetUserActive(ctx, f.adminID, f.user.ID, false); err != nil {
+					return err
+				}
+				_, err := f.s.SetUserActive(ctx, f.adminID, f.user.ID, true)
+				return err
+			},
+			wantAction: models.AuditActionUserEnabled,
+			wantRoles:  []string{models.RoleUser}, wantActive: true, wantSessions: 0, wantRevoked: true,
+		},
+		{
+			name:       "force logout",
+			act:        func(f *adminFixture) error { return f.s.ForceLogout(ctx, f.adminID, f.user.ID) },
+			wantAction: models.AuditActionUserLoggedOut,
+			wantRoles:  []string{models.RoleUser}, wantActive: true, wantSessions: 0, wantRevoked: true,
+		},
+		{
+			name:    "force logout of an unknown user",
+			act:     func(f *adminFixture) error { return f.s.ForceLogout(ctx, f.adminID, "missing") },
+			wantErr: ErrUserNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAdminFixture(t)
+
+			err := tt.act(f)
+			if err != tt.wantErr {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if event := f.lastAudit(t); event != nil && event.ActorID == f.adminID {
+					t.Fatalf("failed action was audited as %s", event.Action)
+				}
+				return
+			}
+
+			event := f.lastAudit(t)
+			if event == nil || event.Action != tt.wantAction || event.ActorID != f.adminID {
+				t.Fatalf("last audit event = %+v, want %s by the admin", event, tt.wantAction)
+			}
+			for key, value := range tt.wantDetails {
+				if event.Details[key] != value {
+					t.Errorf("audit detail %s = %q, want %q", key, event.Details[key], value)
+				}
+			}
+
+			user, err := f.s.GetUser(ctx, f.user.ID)
+			if err != nil {
+				t.Fatalf("GetUser: %v", err)
+			}
+			if !slices.Equal(user.Roles, tt.wantRoles) || user.IsActive != tt.wantActive {
+				t.Fatalf("user roles %v, active %v; want %v, %v", user.Roles, user.IsActive, tt.wantRoles, tt.wantActive)
+			}
+
+			sessions, err := f.s.GetUserSessions(ctx, f.user.ID)
+			if err != nil {
+				t.Fatalf("GetUserSessions: %v", err)
+			}
+			if len(sessions) != tt.wantSessions {
+				t.Fatalf("GetUserSessions() = %d sessions, want %d", len(sessions), tt.wantSessions)
+			}
+			if got := f.revoked(t); got != tt.wantRevoked {
+				t.Fatalf("access token revoked = %v, want %v", got, tt.wantRevoked)
+			}
+
+			if _, err := f.s.Login(ctx, &models.LoginRequest{Email: "jane@example.com", Password: "correct horse"}); err != tt.wantLoginErr {
+				t.Fatalf("Login() error = %v, want %v", err, tt.wantLoginErr)
+			}
+		})
+	}
+}
+
+func TestAuthServiceListUsers(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService(t, newTestConfig())
+
+	for _, req := range []models.RegisterRequest{
+		{Email: "alice@example.com", Name: "Alice Smith"},
+		{Email: "bob@example.com", Name: "Bob Jones"},
+		{Email: "carol@example.org", Name: "Carol Smithers"},
+		{Email: "dave@example.org", Name: "Dave"},
+		{Email: "Erin@Example.com", Name: "Erin"},
+	} {
+		req.Password = "correct horse"
+		if _, err := s.Register(ctx, &req); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+	}
+
+	// Sorted by byte, so the upper case address comes first
+	everyone := []string{"Erin@Example.com", "alice@example.com", "bob@example.com", "carol@example.org", "dave@example.org"}
+
+	tests := []struct {
+		name       string
+		query      string
+		limit      int
+		wantEmails []string
+	}{
+		{name: "everyone", limit: 100, wantEmails: everyone},
+		{name: "several pages", limit: 2, wantEmails: everyone},
+		{name: "default page size", wantEmails: everyone},
+		{name: "name in another case", query: "SMITH", limit: 1, wantEmails: []string{"alice@example.com", "carol@example.org"}},
+		{name: "email in another case", query: "erin@EXAMPLE", limit: 10, wantEmails: []string{"Erin@Example.com"}},
+		{name: "email domain", query: "example.org", limit: 10, wantEmails: []string{"carol@example.org", "dave@example.org"}},
+		{name: "no match", query: "mallory", limit: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var emails []string
+			cursor := ""
+			for pages := 0; ; pages++ {
+				if pages > 10 {
+					t.Fatal("ListUsers() kept returning cursors")
+				}
+
+				list, err := s.ListUsers(ctx, "admin-1", &models.ListUsersRequest{Query: tt.query, Limit: tt.limit, Cursor: cursor})
+				if err != nil {
+					t.Fatalf("ListUsers: %v", err)
+				}
+				if tt.limit > 0 && len(list.Users) > tt.limit {
+					t.Fatalf("ListUsers() = %d users, want at most %d", len(list.Users), tt.limit)
+				}
+				for _, user := range list.Users {
+					emails = append(emails, user.Email)
+				}
+
+				if list.NextCursor == "" {
+					break
+				}
+				cursor = list.NextCursor
+			}
+
+			sort.Strings(emails)
+			if !slices.Equal(emails, tt.wantEmails) {
+				t.Fatalf("listed %v, want %v", emails, tt.wantEmails)
+			}
+		})
+	}
+
+	if _, err := s.ListUsers(ctx, "admin-1", &models.ListUsersRequest{Cursor: "not a cursor"}); err != ErrInvalidCursor {
+		t.Fatalf("ListUsers() with a bad cursor error = %v, want %v", err, ErrInvalidCursor)
+	}
+
+	events, err := s.auditLog.Query(ctx, &models.AuditQuery{Action: models.AuditActionUsersListed, Limit: 1})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(events) != 1 || events[0].ActorID != "admin-1" {
+		t.Fatalf("audit events = %+v, want the listing by admin-1", events)
+	}
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/repositories"
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+// AuditSink is where AuthService records security-relevant actions. Sinks
+// number and chain the events they append; see models.AuditEvent.
+type AuditSink interface {
+	// Append fills in the event's Sequence, PrevHash and Hash and stores it
+	Append(ctx context.Context, event *models.AuditEvent) error
+	// Query returns at most query.Limit matching events, newest first
+	Query(ctx context.Context, query *models.AuditQuery) ([]*models.AuditEvent, error)
+}
+
+// maxAuditAppendAttempts bounds how often RepositoryAuditSink re-reads the
+// head after losing a race with another writer
+const maxAuditAppendAttempts = 5
+
+// RepositoryAuditSink keeps the audit log in an AuditRepository. Concurrent
+// writers, including other instances of the service, each chain to the head
+// they read; the repository lets only one win and the others retry.
+type RepositoryAuditSink struct {
+	repo repositories.AuditRepository
+}
+
+// NewRepositoryAuditSink creates a sink backed by repo
+func NewRepositoryAuditSink(repo repositories.AuditRepository) *RepositoryAuditSink {
+	return &RepositoryAuditSink{repo: repo}
+}
+
+// NewMemoryAuditSink creates a sink that keeps events in process, for
+// development and tests
+func NewMemoryAuditSink() *RepositoryAuditSink {
+	return NewRepositoryAuditSink(repositories.NewMemoryAuditRepository())
+}
+
+func (s *RepositoryAuditSink) Append(ctx context.Context, event *models.AuditEvent) error {
+	for attempt := 0; attempt < maxAuditAppendAttempts; attempt++ {
+		sequence, hash, err := s.repo.GetAuditHead(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to read audit head: %w", err)
+		}
+
+		sealAuditEvent(event, sequence+1, hash)
+		err = s.repo.AppendAuditEvent(ctx, event)
+		if err == nil {
+			return nil
+		}
+		if err != repositories.ErrAuditConflict {
+			return fmt.Errorf("failed to append audit event: %w", err)
+		}
+	}
+	return fmt.Errorf("failed to append audit event after %d attempts: %w", maxAuditAppendAttempts, repositories.ErrAuditConflict)
+}
+
+func (s *RepositoryAuditSink) Query(ctx context.Context, query *models.AuditQuery) ([]*models.AuditEvent, error) {
+	events, err := s.repo.QueryAuditEvents(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit events: %w", err)
+	}
+	return events, nil
+}
+
+// RecordAccessDenied audits a request the caller's roles didn't allow. The
+// caller is both actor and user, so the audit log's user filter finds it.
+func (s *AuthService) RecordAccessDenied(ctx context.Context, userID, sessionID, ipAddress string, details map[string]string) {
+	s.recordAudit(ctx, &models.AuditEvent{
+		Action:    models.AuditActionAccessDenied,
+		ActorID:   userID,
+		UserID:    userID,
+		SessionID: sessionID,
+		IPAddress: ipAddress,
+		Details:   details,
+	})
+}
+
+// recordAudit stamps an event and appends it to the audit log. The action it
+// describes has already happened, so a failure is logged, not returned.
+func (s *AuthService) recordAudit(ctx context.Context, event *models.AuditEvent) {
+	event.ID = s.newID()
+	event.Time = s.now().UTC()
+
+	if err := s.auditLog.Append(ctx, event); err != nil {
+		logger.ErrorCtx(ctx, "Failed to record audit event",
+			zap.String("action", event.Action),
+			zap.String("user_id", event.UserID),
+			zap.Error(err),
+		)
+	}
+}
+
+// sealAuditEvent numbers the event and chains it to the previous hash
+func sealAuditEvent(event *models.AuditEvent, sequence int64, prevHash string) {
+	event.Time = event.Time.UTC()
+	event.Sequence = sequence
+	event.PrevHash = prevHash
+	event.Hash = hashAuditEvent(event)
+}
+
+// hashAuditEvent returns the hex SHA-256 of the event's JSON with Hash left
+// out. encoding/json sorts map keys, so the encoding is stable.
+func hashAuditEvent(event *models.AuditEvent) string {
+	unhashed := *event
+	unhashed.Hash = ""
+	unhashed.Time = event.Time.UTC()
+
+	data, _ := json.Marshal(&unhashed)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyAuditChain checks a run of consecutive events, oldest first: every
+// hash must match its event and every event must chain to the one before.
+// A run starting at sequence 1 must also start from an empty hash.
+func VerifyAuditChain(events []*models.AuditEvent) error {
+	for i, event := range events {
+		if event.Hash != hashAuditEvent(event) {
+			return fmt.Errorf("audit event %d: hash mismatch", event.Sequence)
+		}
+
+		if i == 0 {
+			if event.Sequence == 1 && event.PrevHash != "" {
+				return fmt.Errorf("audit event 1: unexpected previous hash")
+			}
+			continue
+		}
+
+		prev := events[i-1]
+		if event.Sequence != prev.Sequence+1 {
+			return fmt.Errorf("audit event %d: follows event %d", event.Sequence, prev.Sequence)
+		}
+		if event.PrevHash != prev.Hash {
+			return fmt.Errorf("audit event %d: not chained to event %d", event.Sequence, prev.Sequence)
+		}
+	}
+	return nil
+}
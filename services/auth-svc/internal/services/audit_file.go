@@ -0,0 +1,128 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+)
+
+// FileAuditSink appends the audit log to a JSON-lines file, one event per
+// line. It suits a single long-running process; instances sharing a file
+// would fork the chain.
+type FileAuditSink struct {
+	mu       sync.Mutex
+	path     string
+	sequence int64  // of the last event in the file
+	hash     string // of the last event in the file
+}
+
+// NewFileAuditSink opens the audit log at path, creating its directory if
+// needed. An existing log is verified first, and a broken chain is an error
+// rather than something to append to.
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	events, err := readAuditFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := VerifyAuditChain(events); err != nil {
+		return nil, fmt.Errorf("audit log %s is corrupt: %w", path, err)
+	}
+
+	s := &FileAuditSink{path: path}
+	if len(events) > 0 {
+		last := events[len(events)-1]
+		s.sequence = last.Sequence
+		s.hash = last.Hash
+	}
+	return s, nil
+}
+
+func (s *FileAuditSink) Append(ctx context.Context, event *models.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sealAuditEvent(event, s.sequence+1, s.hash)
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit event: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+
+	s.sequence = event.Sequence
+	s.hash = event.Hash
+	return nil
+}
+
+// Query reads the whole file, so it slows down as the log grows
+func (s *FileAuditSink) Query(ctx context.Context, query *models.AuditQuery) ([]*models.AuditEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all, err := readAuditFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []*models.AuditEvent
+	for i := len(all) - 1; i >= 0 && len(events) < query.Limit; i-- {
+		if query.Matches(all[i]) {
+			events = append(events, all[i])
+		}
+	}
+	return events, nil
+}
+
+// readAuditFile loads every event in the file, oldest first. A missing file
+// is an empty log.
+func readAuditFile(path string) ([]*models.AuditEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var events []*models.AuditEvent
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event models.AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("failed to decode audit log line %d: %w", line, err)
+		}
+		events = append(events, &event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+)
+
+// auditChain returns n sealed events, oldest first
+func auditChain(n int) []*models.AuditEvent {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	events := make([]*models.AuditEvent, 0, n)
+	prevHash := ""
+	for i := 0; i < n; i++ {
+		event := &models.AuditEvent{
+			ID:      "event-" + string(rune('a'+i)),
+			Time:    start.Add(time.Duration(i) * time.Minute),
+			Action:  models.AuditActionAccessDenied,
+			ActorID: "user-1",
+			UserID:  "user-1",
+			Details: map[string]string{"path": "/v1/admin/users"},
+		}
+		sealAuditEvent(event, int64(i+1), prevHash)
+		prevHash = event.Hash
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestVerifyAuditChain(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(events []*models.AuditEvent) []*models.AuditEvent
+		wantErr string // substring of the error, empty for a valid chain
+	}{
+		{name: "valid", modify: func(events []*models.AuditEvent) []*models.AuditEvent { return events }},
+		{name: "empty", modify: func([]*models.AuditEvent) []*models.AuditEvent { return nil }},
+		{name: "run from the middle", modify: func(events []*models.AuditEvent) []*models.AuditEvent { return events[1:] }},
+		{
+			name: "edited details",
+			modify: func(events []*models.AuditEvent) []*models.AuditEvent {
+				events[1].Details["path"] = "/v1/auth/profile"
+				return events
+			},
+			wantErr: "audit event 2: hash mismatch",
+		},
+		{
+			name: "same instant in another zone",
+			modify: func(events []*models.AuditEvent) []*models.AuditEvent {
+				events[1].Time = events[1].Time.In(time.FixedZone("UTC+1", 3600))
+				return events
+			},
+		},
+		{
+			name: "edited and resealed",
+			modify: func(events []*models.AuditEvent) []*models.AuditEvent {
+				events[1].UserID = "user-2"
+				sealAuditEvent(events[1], events[1].Sequence, events[1].PrevHash)
+				return events
+			},
+			wantErr: "audit event 3: not chained to event 2",
+		},
+		{
+			name: "deleted event",
+			modify: func(events []*models.AuditEvent) []*models.AuditEvent {
+				return append(events[:1], events[2:]...)
+			},
+			wantErr: "audit event 3: follows event 1",
+		},
+		{
+			name: "reordered",
+			modify: func(events []*models.AuditEvent) []*models.AuditEvent {
+				events[1], events[2] = events[2], events[1]
+				return events
+			},
+			wantErr: "audit event 3: follows event 1",
+		},
+		{
+			name: "first event with a previous hash",
+			modify: func(events []*models.AuditEvent) []*models.AuditEvent {
+				sealAuditEvent(events[0], 1, strings.Repeat("0", 64))
+				return events[:1]
+			},
+			wantErr: "audit event 1: unexpected previous hash",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyAuditChain(tt.modify(auditChain(3)))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("VerifyAuditChain() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("VerifyAuditChain() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepositoryAuditSinkChains(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService(t, newTestConfig())
+
+	for _, userID := range []string{"user-1", "user-2", "user-1"} {
+		s.RecordAccessDenied(ctx, userID, "session-1", "192.0.2.1", map[string]string{"path": "/v1/admin/users"})
+	}
+
+	events, err := s.auditLog.Query(ctx, &models.AuditQuery{Limit: 10})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("Query() = %d events, want 3", len(events))
+	}
+
+	slices.Reverse(events)
+	if err := VerifyAuditChain(events); err != nil {
+		t.Fatalf("VerifyAuditChain() error = %v", err)
+	}
+	if events[0].Sequence != 1 || events[0].ActorID != "user-1" || events[0].Action != models.AuditActionAccessDenied {
+		t.Fatalf("first event = %+v, want access denied for user-1 at sequence 1", events[0])
+	}
+
+	mine, err := s.auditLog.Query(ctx, &models.AuditQuery{UserID: "user-1", Limit: 10})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(mine) != 2 {
+		t.Fatalf("Query() for user-1 = %d events, want 2", len(mine))
+	}
+}
+
+func TestFileAuditSink(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+
+	sink, err := NewFileAuditSink(path)
+	if err != nil {
+		t.Fatalf("NewFileAuditSink: %v", err)
+	}
+	for _, id := range []string{"first", "second"} {
+		if err := sink.Append(ctx, &models.AuditEvent{ID: id, Time: time.Now(), Action: models.AuditActionAccessDenied}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	// Reopening picks up the chain where it left off
+	sink, err = NewFileAuditSink(path)
+	if err != nil {
+		t.Fatalf("reopening NewFileAuditSink: %v", err)
+	}
+	event := &models.AuditEvent{ID: "third", Time: time.Now(), Action: models.AuditActionAccessDenied}
+	if err := sink.Append(ctx, event); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if event.Sequence != 3 {
+		t.Fatalf("Sequence after reopening = %d, want 3", event.Sequence)
+	}
+
+	events, err := readAuditFile(path)
+	if err != nil {
+		t.Fatalf("readAuditFile: %v", err)
+	}
+	if err := VerifyAuditChain(events); err != nil {
+		t.Fatalf("VerifyAuditChain() error = %v", err)
+	}
+
+	// A log edited on disk is refused
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Replace(string(data), `"id":"third"`, `"id":"forged"`, 1)), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := NewFileAuditSink(path); err == nil {
+		t.Fatal("NewFileAuditSink() accepted an edited log")
+	}
+}
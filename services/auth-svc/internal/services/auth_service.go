@@ -6,56 +6,246 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/oauth"
 	"github.com/multitask-platform/backend/services/auth-svc/internal/repositories"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/secretbox"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/webauthn"
 	"github.com/multitask-platform/backend/shared/config"
+	"github.com/multitask-platform/backend/shared/events"
+	"github.com/multitask-platform/backend/shared/jwtkeys"
 	"github.com/multitask-platform/backend/shared/logger"
+	"github.com/multitask-platform/backend/shared/revocation"
 )
 
 // Common errors
 var (
-	ErrInvalidCredentials = errors.New("invalid credentials")
-	ErrUserAlreadyExists  = errors.New("user already exists")
-	ErrUserNotFound       = errors.New("user not found")
-	ErrUserNotVerified    = errors.New("user not verified")
-	ErrUserDisabled       = errors.New("user disabled")
-	ErrInvalidToken       = errors.New("invalid token")
-	ErrTokenExpired       = errors.New("token expired")
-	ErrSessionNotFound    = errors.New("session not found")
+	ErrInvalidCredentials   = errors.New("invalid credentials")
+	ErrUserAlreadyExists    = errors.New("user already exists")
+	ErrUserNotFound         = errors.New("user not found")
+	ErrUserNotVerified      = errors.New("user not verified")
+	ErrUserDisabled         = errors.New("user disabled")
+	ErrInvalidToken         = errors.New("invalid token")
+	ErrTokenExpired         = errors.New("token expired")
+	ErrSessionNotFound      = errors.New("session not found")
+	ErrAccountLocked        = errors.New("account temporarily locked")
+	ErrTooManyLoginAttempts = errors.New("too many login attempts")
+	ErrRefreshTokenReused   = errors.New("refresh token reused")
+	ErrInvalidMFACode       = errors.New("invalid mfa code")
+	ErrMFANotEnrolled       = errors.New("mfa not enrolled")
+	ErrMFAAlreadyEnabled    = errors.New("mfa already enabled")
+	ErrInvalidPasskey       = errors.New("invalid passkey")
+	ErrPasskeyExists        = errors.New("passkey already registered")
+	ErrPasskeyNotFound      = errors.New("passkey not found")
+	ErrUnknownOAuthProvider = errors.New("unknown oauth provider")
+	ErrOAuthFailed          = errors.New("oauth login failed")
+	ErrOAuthEmailUnverified = errors.New("provider email not verified")
+	ErrClientNotFound       = errors.New("oauth client not found")
+	ErrFirstPartyClient     = errors.New("only admins can register first-party clients")
+	ErrIntrospectingClient  = errors.New("only admins can register clients that introspect first-party tokens")
+	ErrInvalidGrantTypes    = errors.New("invalid grant types for client")
+	ErrInvalidRedirectURI   = errors.New("invalid redirect uri")
+	ErrUnsupportedScope     = errors.New("unsupported scope")
+	ErrInvalidEmailCode     = errors.New("invalid or expired email code")
+	ErrInvalidRole          = errors.New("invalid role")
+	ErrInvalidCursor        = errors.New("invalid page cursor")
+	ErrAdminSelfAction      = errors.New("admins cannot disable, delete or demote themselves")
 )
 
 // AuthService handles authentication business logic
 type AuthService struct {
 	userRepo    repositories.UserRepository
 	sessionRepo repositories.SessionRepository
+	credentials repositories.CredentialRepository
+	identities  repositories.IdentityRepository
+	clients     repositories.ClientRepository
 	cfg         *config.Config
+	tokens      TokenIssuer
+	mailer      Mailer
+	revocations *revocation.Checker
+	secrets     *secretbox.Box
+	webauthn    *webauthn.RelyingParty
+	oauth       map[string]oauth.Provider
+	auditLog    AuditSink
+	now         func() time.Time
+	newID       func() string
 }
 
-// NewAuthService creates a new AuthService instance
-func NewAuthService() *AuthService {
-	return &AuthService{
-		userRepo:    repositories.NewDynamoDBUserRepository(),
-		sessionRepo: repositories.NewDynamoDBSessionRepository(),
-		cfg:         config.Get(),
+// Option configures optional AuthService dependencies
+type Option func(*AuthService)
+
+// WithClock overrides the time source (defaults to time.Now)
+func WithClock(now func() time.Time) Option {
+	return func(s *AuthService) {
+		s.now = now
+	}
+}
+
+// WithTokenIssuer sets the JWT issuer. It is required outside development;
+// in development NewAuthService otherwise signs with a throwaway Ed25519 key.
+func WithTokenIssuer(tokens TokenIssuer) Option {
+	return func(s *AuthService) {
+		s.tokens = tokens
+	}
+}
+
+// WithMailer overrides the mailer (defaults to logging emails)
+func WithMailer(mailer Mailer) Option {
+	return func(s *AuthService) {
+		s.mailer = mailer
+	}
+}
+
+// WithRevocations denylists the access tokens of sessions that are logged out
+// or revoked, so they stop working before they expire. Without it they stay
+// valid for up to DefaultSessionDuration.
+func WithRevocations(checker *revocation.Checker) Option {
+	return func(s *AuthService) {
+		s.revocations = checker
+	}
+}
+
+// WithSecretBox sets the key MFA secrets are encrypted with. It is required
+// outside development; in development NewAuthService otherwise uses a random
+// key, so enrollments don't survive a restart.
+func WithSecretBox(box *secretbox.Box) Option {
+	return func(s *AuthService) {
+		s.secrets = box
+	}
+}
+
+// WithCredentialRepository sets where passkeys are stored (defaults to
+// memory)
+func WithCredentialRepository(credentials repositories.CredentialRepository) Option {
+	return func(s *AuthService) {
+		s.credentials = credentials
+	}
+}
+
+// WithIdentityRepository sets where linked social login accounts are stored
+// (defaults to memory)
+func WithIdentityRepository(identities repositories.IdentityRepository) Option {
+	return func(s *AuthService) {
+		s.identities = identities
+	}
+}
+
+// WithClientRepository sets where OAuth2 clients and authorization codes are
+// stored (defaults to memory)
+func WithClientRepository(clients repositories.ClientRepository) Option {
+	return func(s *AuthService) {
+		s.clients = clients
+	}
+}
+
+// WithAuditSink sets where the audit log is kept (defaults to memory)
+func WithAuditSink(sink AuditSink) Option {
+	return func(s *AuthService) {
+		s.auditLog = sink
+	}
+}
+
+// WithOAuthProviders enables social login with the given providers, each
+// routed under its name
+func WithOAuthProviders(providers ...oauth.Provider) Option {
+	return func(s *AuthService) {
+		for _, provider := range providers {
+			s.oauth[provider.Name()] = provider
+		}
+	}
+}
+
+// WithIDGenerator overrides how user and session IDs are generated (defaults to UUIDv4)
+func WithIDGenerator(newID func() string) Option {
+	return func(s *AuthService) {
+		s.newID = newID
 	}
 }
 
-// Login authenticates a user and creates a session
+// NewAuthService creates a new AuthService instance. Outside development the
+// token issuer and secret box must be given; in development missing ones
+// fall back to ephemeral keys, which a restart invalidates.
+func NewAuthService(cfg *config.Config, userRepo repositories.UserRepository, sessionRepo repositories.SessionRepository, opts ...Option) (*AuthService, error) {
+	s := &AuthService{
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		cfg:         cfg,
+		webauthn:    webauthn.New(cfg.WebAuthn.RPID, cfg.WebAuthn.RPName, cfg.WebAuthn.Origins),
+		oauth:       make(map[string]oauth.Provider),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.now == nil {
+		s.now = time.Now
+	}
+	if s.newID == nil {
+		s.newID = func() string { return uuid.New().String() }
+	}
+	if s.tokens == nil {
+		if !cfg.IsDevelopment() {
+			return nil, errors.New("no token issuer configured")
+		}
+		keys, err := ephemeralKeySet()
+		if err != nil {
+			return nil, err
+		}
+		s.tokens = NewKeyTokenIssuer(keys, s.now)
+	}
+	if s.mailer == nil {
+		s.mailer = NewLogMailer()
+	}
+	if s.secrets == nil {
+		if !cfg.IsDevelopment() {
+			return nil, errors.New("no secret box configured")
+		}
+		box, err := secretbox.Generate()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate encryption key: %w", err)
+		}
+		s.secrets = box
+	}
+	if s.credentials == nil {
+		s.credentials = repositories.NewMemoryCredentialRepository()
+	}
+	if s.identities == nil {
+		s.identities = repositories.NewMemoryIdentityRepository()
+	}
+	if s.clients == nil {
+		s.clients = repositories.NewMemoryClientRepository()
+	}
+	if s.auditLog == nil {
+		s.auditLog = NewMemoryAuditSink()
+	}
+
+	return s, nil
+}
+
+// Login authenticates a user and creates a session. Accounts with a second
+// factor get a *MFARequiredError carrying the challenge instead; the login is
+// finished by LoginMFA.
 func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest) (*models.AuthResponse, error) {
 	logger.DebugCtx(ctx, "Attempting to login user", zap.String("email", req.Email))
 
+	// Reject sources that have been failing too often
+	if err := s.checkIPLock(ctx, req.IPAddress); err != nil {
+		return nil, err
+	}
+
 	// Get user by email
 	user, err := s.userRepo.GetUserByEmail(ctx, req.Email)
 	if err != nil {
 		if err == repositories.ErrUserNotFound {
-			return nil, ErrInvalidCredentials
+			return nil, s.recordLoginFailure(ctx, nil, req.IPAddress)
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
@@ -70,19 +260,43 @@ func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest) (*mod
 		return nil, ErrUserNotVerified
 	}
 
+	// Refuse to check passwords while the account is locked
+	if err := s.checkUserLock(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
 	// Verify password
 	err = s.verifyPassword(ctx, user.ID, req.Password)
 	if err != nil {
-		return nil, ErrInvalidCredentials
+		return nil, s.recordLoginFailure(ctx, user, req.IPAddress)
 	}
 
-	// Create session
-	sessionReq := &models.SessionCreateRequest{
-		UserID:    user.ID,
-		DeviceID:  req.DeviceID,
-		UserAgent: "", // This would be extracted from request headers
-		IPAddress: "", // This would be extracted from request context
+	// Failures are only cleared once the second factor is done too, so a
+	// known password can't be used to reset the count of wrong codes
+	mfa, err := s.mfaStatus(ctx, user.ID)
+	if err != nil {
+		return nil, err
 	}
+	if mfa.Enabled {
+		return nil, s.mfaChallenge(ctx, user, mfa.Methods, []string{models.AuthMethodPassword})
+	}
+
+	s.clearLoginFailures(ctx, user.ID)
+
+	return s.completeLogin(ctx, user, &models.SessionCreateRequest{
+		UserID:      user.ID,
+		DeviceID:    req.DeviceID,
+		UserAgent:   req.UserAgent,
+		IPAddress:   req.IPAddress,
+		AuthMethods: []string{models.AuthMethodPassword},
+	})
+}
+
+// completeLogin creates a session for an authenticated user and issues its
+// tokens
+func (s *AuthService) completeLogin(ctx context.Context, user *models.User, sessionReq *models.SessionCreateRequest) (*models.AuthResponse, error) {
+	// Check before the new session exists so it can't match itself
+	newDevice := s.isNewDevice(ctx, user, sessionReq)
 
 	session, err := s.createSession(ctx, sessionReq)
 	if err != nil {
@@ -90,23 +304,31 @@ func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest) (*mod
 	}
 
 	// Generate tokens
-	accessToken, err := s.generateAccessToken(user, session.ID)
+	accessToken, err := s.tokens.GenerateAccessToken(user, session.ID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
-	refreshToken, err := s.generateRefreshToken(user.ID, session.ID)
+	refreshToken, err := s.tokens.GenerateRefreshToken(user.ID, session.ID, session.RefreshTokenID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
 
 	// Update last login time
-	err = s.userRepo.UpdateLastLogin(ctx, user.ID, time.Now().UTC())
+	err = s.userRepo.UpdateLastLogin(ctx, user.ID, s.now().UTC())
 	if err != nil {
 		logger.WarnCtx(ctx, "Failed to update last login time", zap.Error(err))
 		// Don't fail login for this
 	}
 
+	if newDevice {
+		err = s.mailer.SendNewLoginEmail(ctx, user, session)
+		if err != nil {
+			logger.WarnCtx(ctx, "Failed to send new login email", zap.Error(err))
+			// Don't fail login for this
+		}
+	}
+
 	// Create response
 	response := &models.AuthResponse{
 		AccessToken:  accessToken,
@@ -115,6 +337,15 @@ func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest) (*mod
 		User:         user.SanitizeUser(),
 	}
 
+	s.recordAudit(ctx, &models.AuditEvent{
+		Action:    models.AuditActionLoginSucceeded,
+		ActorID:   user.ID,
+		UserID:    user.ID,
+		SessionID: session.ID,
+		IPAddress: sessionReq.IPAddress,
+		Details:   map[string]string{"methods": strings.Join(sessionReq.AuthMethods, ",")},
+	})
+
 	logger.InfoCtx(ctx, "User login successful", zap.String("user_id", user.ID))
 
 	return response, nil
@@ -140,7 +371,7 @@ func (s *AuthService) Register(ctx context.Context, req *models.RegisterRequest)
 	}
 
 	// Create user
-	userID := uuid.New().String()
+	userID := s.newID()
 	createReq := &models.UserCreateRequest{
 		Email:        req.Email,
 		PasswordHash: passwordHash,
@@ -155,12 +386,20 @@ func (s *AuthService) Register(ctx context.Context, req *models.RegisterRequest)
 		IsVerified: false, // User needs to verify email
 		IsActive:   true,
 		Roles:      createReq.Roles,
-		CreatedAt:  time.Now().UTC(),
-		UpdatedAt:  time.Now().UTC(),
+		CreatedAt:  s.now().UTC(),
+		UpdatedAt:  s.now().UTC(),
 	}
 
-	err = s.userRepo.CreateUser(ctx, user, createReq.PasswordHash)
+	err = s.userRepo.CreateUser(ctx, user, createReq.PasswordHash, s.outboxEvents(ctx, events.UserRegistered{
+		UserID: user.ID,
+		Email:  user.Email,
+		Name:   user.Name,
+		Method: "password",
+	})...)
 	if err != nil {
+		if err == repositories.ErrUserExists {
+			return nil, ErrUserAlreadyExists
+		}
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
@@ -185,83 +424,136 @@ func (s *AuthService) Logout(ctx context.Context, userID, sessionID string) erro
 
 	if sessionID != "" {
 		// Logout specific session
-		err := s.sessionRepo.DeactivateSession(ctx, sessionID)
+		err := s.sessionRepo.DeactivateSession(ctx, sessionID, s.outboxEvents(ctx, events.SessionRevoked{
+			UserID:    userID,
+			SessionID: sessionID,
+			Reason:    events.RevokeReasonLogout,
+		})...)
 		if err != nil {
 			return fmt.Errorf("failed to deactivate session: %w", err)
 		}
+		s.revokeSessionTokens(ctx, sessionID)
 	} else {
 		// Logout all sessions
-		err := s.sessionRepo.DeactivateUserSessions(ctx, userID)
+		err := s.sessionRepo.DeactivateUserSessions(ctx, userID, s.outboxEvents(ctx, events.SessionRevoked{
+			UserID: userID,
+			Reason: events.RevokeReasonLogout,
+		})...)
 		if err != nil {
 			return fmt.Errorf("failed to deactivate user sessions: %w", err)
 		}
+		s.revokeUserTokens(ctx, userID)
 	}
 
+	s.recordAudit(ctx, &models.AuditEvent{
+		Action:    models.AuditActionSessionRevoked,
+		ActorID:   userID,
+		UserID:    userID,
+		SessionID: sessionID, // Empty when every session was ended
+		Details:   map[string]string{"reason": "logout"},
+	})
+
 	logger.InfoCtx(ctx, "User logout successful", zap.String("user_id", userID))
 
 	return nil
 }
 
-// RefreshToken generates new access token using refresh token
+// RefreshToken exchanges a refresh token for a new access and refresh token.
+// Each refresh token is single use: presenting one that has already been
+// rotated means it was copied, so the whole session is revoked.
 func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (*models.AuthResponse, error) {
 	logger.DebugCtx(ctx, "Attempting to refresh token")
 
+	// Sessions held by OAuth2 clients are refreshed through the token
+	// endpoint, which keeps their tokens scoped
+	user, session, nextTokenID, err := s.rotateRefreshToken(ctx, refreshToken, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate new access token
+	accessToken, err := s.tokens.GenerateAccessToken(user, session.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	// Generate new refresh token
+	newRefreshToken, err := s.tokens.GenerateRefreshToken(user.ID, session.ID, nextTokenID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	// Create response
+	response := &models.AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
+		ExpiresIn:    int64(models.DefaultSessionDuration.Seconds()),
+		User:         user.SanitizeUser(),
+	}
+
+	logger.InfoCtx(ctx, "Token refresh successful", zap.String("user_id", user.ID))
+
+	return response, nil
+}
+
+// rotateRefreshToken checks a refresh token against its session, which must
+// belong to clientID ("" for the platform's own sessions), and moves the
+// session on to a new refresh token id. It returns the id to sign the next
+// refresh token with.
+func (s *AuthService) rotateRefreshToken(ctx context.Context, refreshToken, clientID string) (*models.User, *models.Session, string, error) {
 	// Parse and validate refresh token
-	claims, err := s.parseRefreshToken(refreshToken)
+	claims, err := s.tokens.ParseRefreshToken(refreshToken)
 	if err != nil {
-		return nil, ErrInvalidToken
+		return nil, nil, "", ErrInvalidToken
 	}
 
 	// Get user
 	user, err := s.userRepo.GetUser(ctx, claims.UserID)
 	if err != nil {
 		if err == repositories.ErrUserNotFound {
-			return nil, ErrInvalidToken
+			return nil, nil, "", ErrInvalidToken
 		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		return nil, nil, "", fmt.Errorf("failed to get user: %w", err)
 	}
 
 	// Check if user is still active
 	if !user.IsActive {
-		return nil, ErrUserDisabled
+		return nil, nil, "", ErrUserDisabled
 	}
 
 	// Verify session exists and is active
 	session, err := s.sessionRepo.GetSession(ctx, claims.SessionID)
 	if err != nil {
 		if err == repositories.ErrSessionNotFound {
-			return nil, ErrInvalidToken
+			return nil, nil, "", ErrInvalidToken
 		}
-		return nil, fmt.Errorf("failed to get session: %w", err)
+		return nil, nil, "", fmt.Errorf("failed to get session: %w", err)
 	}
 
-	if !session.IsActive || session.IsExpired() {
-		return nil, ErrTokenExpired
+	if session.UserID != claims.UserID || session.ClientID != clientID {
+		return nil, nil, "", ErrInvalidToken
 	}
 
-	// Generate new access token
-	accessToken, err := s.generateAccessToken(user, session.ID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	if !session.IsActive || s.now().After(session.ExpiresAt) {
+		return nil, nil, "", ErrTokenExpired
 	}
 
-	// Generate new refresh token
-	newRefreshToken, err := s.generateRefreshToken(user.ID, session.ID)
+	// Rotate the refresh token id before signing, so a concurrent refresh
+	// with the same token loses the race and is treated as reuse
+	nextTokenID := s.newID()
+	err = s.sessionRepo.RotateRefreshToken(ctx, session.ID, claims.TokenID, nextTokenID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
-	}
-
-	// Create response
-	response := &models.AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: newRefreshToken,
-		ExpiresIn:    int64(models.DefaultSessionDuration.Seconds()),
-		User:         user.SanitizeUser(),
+		if err == repositories.ErrTokenReused {
+			s.revokeReusedSession(ctx, session, claims.TokenID)
+			return nil, nil, "", ErrRefreshTokenReused
+		}
+		if err == repositories.ErrSessionNotFound {
+			return nil, nil, "", ErrInvalidToken
+		}
+		return nil, nil, "", fmt.Errorf("failed to rotate refresh token: %w", err)
 	}
 
-	logger.InfoCtx(ctx, "Token refresh successful", zap.String("user_id", user.ID))
-
-	return response, nil
+	return user, session, nextTokenID, nil
 }
 
 // ForgotPassword initiates password reset process
@@ -278,6 +570,11 @@ func (s *AuthService) ForgotPassword(ctx context.Context, email string) error {
 		return fmt.Errorf("failed to get user: %w", err)
 	}
 
+	return s.startPasswordReset(ctx, user)
+}
+
+// startPasswordReset emails the user a password reset link
+func (s *AuthService) startPasswordReset(ctx context.Context, user *models.User) error {
 	// Generate reset token
 	resetToken, err := s.generateSecureToken()
 	if err != nil {
@@ -315,6 +612,23 @@ func (s *AuthService) ResetPassword(ctx context.Context, token, newPassword stri
 		return fmt.Errorf("failed to verify reset token: %w", err)
 	}
 
+	if err := s.resetPassword(ctx, userID, newPassword); err != nil {
+		return err
+	}
+
+	// Mark token as used
+	err = s.userRepo.MarkPasswordResetTokenUsed(ctx, token)
+	if err != nil {
+		logger.WarnCtx(ctx, "Failed to mark reset token as used", zap.Error(err))
+		// Don't fail reset for this
+	}
+
+	return nil
+}
+
+// resetPassword sets a new password for a user who proved control of their
+// mailbox, and signs out everywhere else
+func (s *AuthService) resetPassword(ctx context.Context, userID, newPassword string) error {
 	// Hash new password
 	passwordHash, err := s.hashPassword(newPassword)
 	if err != nil {
@@ -322,24 +636,35 @@ func (s *AuthService) ResetPassword(ctx context.Context, token, newPassword stri
 	}
 
 	// Update password
-	err = s.userRepo.UpdatePassword(ctx, userID, passwordHash)
+	err = s.userRepo.UpdatePassword(ctx, userID, passwordHash, s.outboxEvents(ctx, events.UserPasswordChanged{
+		UserID: userID,
+		Reset:  true,
+	})...)
 	if err != nil {
 		return fmt.Errorf("failed to update password: %w", err)
 	}
 
-	// Mark token as used
-	err = s.userRepo.MarkPasswordResetTokenUsed(ctx, token)
-	if err != nil {
-		logger.WarnCtx(ctx, "Failed to mark reset token as used", zap.Error(err))
-		// Don't fail reset for this
-	}
-
 	// Deactivate all user sessions for security
-	err = s.sessionRepo.DeactivateUserSessions(ctx, userID)
+	err = s.sessionRepo.DeactivateUserSessions(ctx, userID, s.outboxEvents(ctx, events.SessionRevoked{
+		UserID: userID,
+		Reason: events.RevokeReasonPasswordReset,
+	})...)
 	if err != nil {
 		logger.WarnCtx(ctx, "Failed to deactivate user sessions", zap.Error(err))
 		// Don't fail reset for this
 	}
+	s.revokeUserTokens(ctx, userID)
+
+	// Proving control of the mailbox lifts any lockout
+	s.clearLoginFailures(ctx, userID)
+
+	s.sendPasswordChangedEmail(ctx, userID)
+
+	s.recordAudit(ctx, &models.AuditEvent{
+		Action:  models.AuditActionPasswordReset,
+		ActorID: userID,
+		UserID:  userID,
+	})
 
 	logger.InfoCtx(ctx, "Password reset successful", zap.String("user_id", userID))
 
@@ -363,11 +688,19 @@ func (s *AuthService) ChangePassword(ctx context.Context, userID, currentPasswor
 	}
 
 	// Update password
-	err = s.userRepo.UpdatePassword(ctx, userID, passwordHash)
+	err = s.userRepo.UpdatePassword(ctx, userID, passwordHash, s.outboxEvents(ctx, events.UserPasswordChanged{UserID: userID})...)
 	if err != nil {
 		return fmt.Errorf("failed to update password: %w", err)
 	}
 
+	s.sendPasswordChangedEmail(ctx, userID)
+
+	s.recordAudit(ctx, &models.AuditEvent{
+		Action:  models.AuditActionPasswordChanged,
+		ActorID: userID,
+		UserID:  userID,
+	})
+
 	logger.InfoCtx(ctx, "Password change successful", zap.String("user_id", userID))
 
 	return nil
@@ -387,7 +720,7 @@ func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
 	}
 
 	// Mark user as verified
-	err = s.userRepo.MarkUserVerified(ctx, userID)
+	err = s.userRepo.MarkUserVerified(ctx, userID, s.outboxEvents(ctx, events.UserVerified{UserID: userID})...)
 	if err != nil {
 		return fmt.Errorf("failed to mark user as verified: %w", err)
 	}
@@ -399,6 +732,13 @@ func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
 		// Don't fail verification for this
 	}
 
+	s.recordAudit(ctx, &models.AuditEvent{
+		Action:  models.AuditActionEmailVerified,
+		ActorID: userID,
+		UserID:  userID,
+		Details: map[string]string{"method": models.EmailMethodLink},
+	})
+
 	logger.InfoCtx(ctx, "Email verification successful", zap.String("user_id", userID))
 
 	return nil
@@ -441,15 +781,23 @@ func (s *AuthService) GetUser(ctx context.Context, userID string) (*models.User,
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
-	return user.SanitizeUser(), nil
+
+	mfa, err := s.mfaStatus(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sanitized := user.SanitizeUser()
+	sanitized.MFA = mfa
+	return sanitized, nil
 }
 
 // CreateAnonymousSession creates an anonymous session
 func (s *AuthService) CreateAnonymousSession(ctx context.Context) (*models.AnonymousSession, error) {
 	logger.DebugCtx(ctx, "Creating anonymous session")
 
-	sessionID := uuid.New().String()
-	token, err := s.generateAnonymousToken(sessionID)
+	sessionID := s.newID()
+	token, err := s.tokens.GenerateAnonymousToken(sessionID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate anonymous token: %w", err)
 	}
@@ -457,8 +805,8 @@ func (s *AuthService) CreateAnonymousSession(ctx context.Context) (*models.Anony
 	session := &models.AnonymousSession{
 		ID:        sessionID,
 		Token:     token,
-		CreatedAt: time.Now().UTC(),
-		ExpiresAt: time.Now().UTC().Add(models.DefaultAnonymousDuration),
+		CreatedAt: s.now().UTC(),
+		ExpiresAt: s.now().UTC().Add(models.DefaultAnonymousDuration),
 	}
 
 	err = s.sessionRepo.CreateAnonymousSession(ctx, session)
@@ -495,10 +843,23 @@ func (s *AuthService) RevokeSession(ctx context.Context, userID, sessionID strin
 		return ErrSessionNotFound // Don't reveal that session exists for another user
 	}
 
-	err = s.sessionRepo.DeactivateSession(ctx, sessionID)
+	err = s.sessionRepo.DeactivateSession(ctx, sessionID, s.outboxEvents(ctx, events.SessionRevoked{
+		UserID:    userID,
+		SessionID: sessionID,
+		Reason:    events.RevokeReasonRevoked,
+	})...)
 	if err != nil {
 		return fmt.Errorf("failed to deactivate session: %w", err)
 	}
+	s.revokeSessionTokens(ctx, sessionID)
+
+	s.recordAudit(ctx, &models.AuditEvent{
+		Action:    models.AuditActionSessionRevoked,
+		ActorID:   userID,
+		UserID:    userID,
+		SessionID: sessionID,
+		Details:   map[string]string{"reason": "revoked"},
+	})
 
 	logger.InfoCtx(ctx, "Session revoked", 
 		zap.String("user_id", userID),
@@ -529,118 +890,6 @@ func (s *AuthService) verifyPassword(ctx context.Context, userID, password strin
 	return nil
 }
 
-func (s *AuthService) generateAccessToken(user *models.User, sessionID string) (string, error) {
-	now := time.Now().UTC()
-	expiresAt := now.Add(models.DefaultSessionDuration)
-
-	claims := &models.TokenClaims{
-		UserID:    user.ID,
-		Email:     user.Email,
-		Name:      user.Name,
-		Roles:     user.Roles,
-		SessionID: sessionID,
-		IssuedAt:  now.Unix(),
-		ExpiresAt: expiresAt.Unix(),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub":        claims.UserID,
-		"email":      claims.Email,
-		"name":       claims.Name,
-		"roles":      claims.Roles,
-		"session_id": claims.SessionID,
-		"iat":        claims.IssuedAt,
-		"exp":        claims.ExpiresAt,
-		"type":       models.TokenTypeAccess,
-	})
-
-	tokenString, err := token.SignedString([]byte(s.cfg.JWTSecret))
-	if err != nil {
-		return "", fmt.Errorf("failed to sign token: %w", err)
-	}
-
-	return tokenString, nil
-}
-
-func (s *AuthService) generateRefreshToken(userID, sessionID string) (string, error) {
-	now := time.Now().UTC()
-	expiresAt := now.Add(models.DefaultRefreshDuration)
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub":        userID,
-		"session_id": sessionID,
-		"iat":        now.Unix(),
-		"exp":        expiresAt.Unix(),
-		"type":       models.TokenTypeRefresh,
-	})
-
-	tokenString, err := token.SignedString([]byte(s.cfg.JWTSecret))
-	if err != nil {
-		return "", fmt.Errorf("failed to sign refresh token: %w", err)
-	}
-
-	return tokenString, nil
-}
-
-func (s *AuthService) generateAnonymousToken(sessionID string) (string, error) {
-	now := time.Now().UTC()
-	expiresAt := now.Add(models.DefaultAnonymousDuration)
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"session_id": sessionID,
-		"iat":        now.Unix(),
-		"exp":        expiresAt.Unix(),
-		"type":       "anonymous",
-	})
-
-	tokenString, err := token.SignedString([]byte(s.cfg.JWTSecret))
-	if err != nil {
-		return "", fmt.Errorf("failed to sign anonymous token: %w", err)
-	}
-
-	return tokenString, nil
-}
-
-func (s *AuthService) parseRefreshToken(tokenString string) (*models.TokenClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.cfg.JWTSecret), nil
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	if !token.Valid {
-		return nil, ErrInvalidToken
-	}
-
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, ErrInvalidToken
-	}
-
-	// Verify token type
-	tokenType, _ := claims["type"].(string)
-	if tokenType != models.TokenTypeRefresh {
-		return nil, ErrInvalidToken
-	}
-
-	userID, _ := claims["sub"].(string)
-	sessionID, _ := claims["session_id"].(string)
-
-	if userID == "" || sessionID == "" {
-		return nil, ErrInvalidToken
-	}
-
-	return &models.TokenClaims{
-		UserID:    userID,
-		SessionID: sessionID,
-	}, nil
-}
-
 func (s *AuthService) generateSecureToken() (string, error) {
 	bytes := make([]byte, 32)
 	if _, err := rand.Read(bytes); err != nil {
@@ -650,18 +899,30 @@ func (s *AuthService) generateSecureToken() (string, error) {
 }
 
 func (s *AuthService) createSession(ctx context.Context, req *models.SessionCreateRequest) (*models.Session, error) {
-	sessionID := uuid.New().String()
-	now := time.Now().UTC()
+	sessionID := req.SessionID
+	if sessionID == "" {
+		sessionID = s.newID()
+	}
+	now := s.now().UTC()
+	authTime := req.AuthTime
+	if authTime.IsZero() {
+		authTime = now
+	}
 
 	session := &models.Session{
-		ID:        sessionID,
-		UserID:    req.UserID,
-		DeviceID:  req.DeviceID,
-		UserAgent: req.UserAgent,
-		IPAddress: req.IPAddress,
-		CreatedAt: now,
-		ExpiresAt: now.Add(models.DefaultRefreshDuration),
-		IsActive:  true,
+		ID:             sessionID,
+		UserID:         req.UserID,
+		RefreshTokenID: s.newID(),
+		DeviceID:       req.DeviceID,
+		UserAgent:      req.UserAgent,
+		IPAddress:      req.IPAddress,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(models.DefaultRefreshDuration),
+		IsActive:       true,
+		AuthTime:       authTime,
+		AuthMethods:    req.AuthMethods,
+		ClientID:       req.ClientID,
+		Scopes:         req.Scopes,
 	}
 
 	err := s.sessionRepo.CreateSession(ctx, session)
@@ -672,6 +933,37 @@ func (s *AuthService) createSession(ctx context.Context, req *models.SessionCrea
 	return session, nil
 }
 
+// revokeReusedSession ends a session whose refresh token was presented after
+// rotation. Either the legitimate client or an attacker holds a stale copy and
+// there's no telling which, so every token descended from the login goes.
+func (s *AuthService) revokeReusedSession(ctx context.Context, session *models.Session, tokenID string) {
+	logger.LogSecurityEvent(ctx, "refresh_token_reuse",
+		zap.String("user_id", session.UserID),
+		zap.String("session_id", session.ID),
+		zap.String("token_id", tokenID),
+	)
+
+	err := s.sessionRepo.DeactivateSession(ctx, session.ID, s.outboxEvents(ctx, events.SessionRevoked{
+		UserID:    session.UserID,
+		SessionID: session.ID,
+		Reason:    events.RevokeReasonTokenReuse,
+	})...)
+	if err != nil && err != repositories.ErrSessionNotFound {
+		logger.ErrorCtx(ctx, "Failed to revoke session after refresh token reuse",
+			zap.String("session_id", session.ID),
+			zap.Error(err),
+		)
+	}
+	s.revokeSessionTokens(ctx, session.ID)
+
+	s.recordAudit(ctx, &models.AuditEvent{
+		Action:    models.AuditActionSessionRevoked,
+		UserID:    session.UserID,
+		SessionID: session.ID,
+		Details:   map[string]string{"reason": "refresh_token_reused"},
+	})
+}
+
 func (s *AuthService) sendVerificationEmail(ctx context.Context, user *models.User) error {
 	// Generate verification token
 	verificationToken, err := s.generateSecureToken()
@@ -685,23 +977,58 @@ func (s *AuthService) sendVerificationEmail(ctx context.Context, user *models.Us
 		return fmt.Errorf("failed to store verification token: %w", err)
 	}
 
-	// TODO: Send actual email using SES
-	logger.InfoCtx(ctx, "Verification email would be sent", 
-		zap.String("user_id", user.ID),
-		zap.String("email", user.Email),
-		zap.String("token", verificationToken),
-	)
-
-	return nil
+	return s.mailer.SendVerificationEmail(ctx, user, verificationToken)
 }
 
 func (s *AuthService) sendPasswordResetEmail(ctx context.Context, user *models.User, resetToken string) error {
-	// TODO: Send actual email using SES
-	logger.InfoCtx(ctx, "Password reset email would be sent", 
-		zap.String("user_id", user.ID),
-		zap.String("email", user.Email),
-		zap.String("token", resetToken),
-	)
+	return s.mailer.SendPasswordResetEmail(ctx, user, resetToken)
+}
 
-	return nil
-}
\ No newline at end of file
+// sendPasswordChangedEmail notifies the user after a password change. Failures
+// are logged because the password has already been updated.
+func (s *AuthService) sendPasswordChangedEmail(ctx context.Context, userID string) {
+	user, err := s.userRepo.GetUser(ctx, userID)
+	if err != nil {
+		logger.WarnCtx(ctx, "Failed to get user for password changed email", zap.Error(err))
+		return
+	}
+
+	err = s.mailer.SendPasswordChangedEmail(ctx, user, s.now().UTC())
+	if err != nil {
+		logger.WarnCtx(ctx, "Failed to send password changed email", zap.Error(err))
+	}
+}
+
+// isNewDevice reports whether a login comes from a device none of the user's
+// sessions have used. First logins are never reported.
+func (s *AuthService) isNewDevice(ctx context.Context, user *models.User, req *models.SessionCreateRequest) bool {
+	if user.LastLoginAt == nil {
+		return false
+	}
+
+	sessions, err := s.sessionRepo.GetUserSessions(ctx, user.ID)
+	if err != nil {
+		logger.WarnCtx(ctx, "Failed to get sessions for new device check", zap.Error(err))
+		return false
+	}
+
+	for _, session := range sessions {
+		if req.DeviceID != "" && session.DeviceID == req.DeviceID {
+			return false
+		}
+		if session.UserAgent == req.UserAgent && session.IPAddress == req.IPAddress {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ephemeralKeySet returns a key set with a freshly generated Ed25519 key
+func ephemeralKeySet() (*jwtkeys.KeySet, error) {
+	key, err := jwtkeys.GenerateSigningKey(jwtkeys.AlgorithmEdDSA)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	return jwtkeys.NewKeySet([]*jwtkeys.Key{key})
+}
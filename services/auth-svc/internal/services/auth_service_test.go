@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/repositories"
+	"github.com/multitask-platform/backend/shared/config"
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+func TestMain(m *testing.M) {
+	if err := logger.Initialize(zap.NewAtomicLevelAt(zap.FatalLevel), false); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// testMailer keeps the verification tokens AuthService emails out, keyed by
+// address
+type testMailer struct {
+	*LogMailer
+
+	mu           sync.Mutex
+	verification map[string]string
+}
+
+func (m *testMailer) SendVerificationEmail(ctx context.Context, user *models.User, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.verification[user.Email] = token
+	return nil
+}
+
+// newTestConfig returns a development config, so AuthService generates its
+// own keys
+func newTestConfig() *config.Config {
+	cfg := &config.Config{Stage: "dev"}
+	cfg.WebAuthn.RPID = "localhost"
+	cfg.WebAuthn.RPName = "Multitask"
+	cfg.WebAuthn.Origins = []string{"http://localhost:3000"}
+	cfg.OAuth2.Issuer = "http://localhost:8080/v1/auth"
+	cfg.OAuth2.ConsentURL = "http://localhost:3000/oauth2/consent"
+	cfg.OAuth2.Scopes = []string{"openid", "profile", "email"}
+	return cfg
+}
+
+// newTestService creates an AuthService on in-memory repositories
+func newTestService(t *testing.T, cfg *config.Config, opts ...Option) (*AuthService, *testMailer) {
+	t.Helper()
+
+	mailer := &testMailer{LogMailer: NewLogMailer(), verification: make(map[string]string)}
+	outbox := repositories.NewMemoryOutboxRepository()
+	opts = append([]Option{WithMailer(mailer)}, opts...)
+
+	s, err := NewAuthService(cfg, repositories.NewMemoryUserRepository(outbox), repositories.NewMemorySessionRepository(outbox), opts...)
+	if err != nil {
+		t.Fatalf("NewAuthService: %v", err)
+	}
+	return s, mailer
+}
+
+// registerVerifiedUser registers a user and follows the verification email
+func registerVerifiedUser(t *testing.T, s *AuthService, mailer *testMailer, email, password string) *models.User {
+	t.Helper()
+	ctx := context.Background()
+
+	user, err := s.Register(ctx, &models.RegisterRequest{Email: email, Password: password, Name: "Test User"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	mailer.mu.Lock()
+	token := mailer.verification[email]
+	mailer.mu.Unlock()
+	if err := s.VerifyEmail(ctx, token); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	return user
+}
+
+func TestNewAuthServiceOutsideDevelopment(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Stage = "prod"
+
+	outbox := repositories.NewMemoryOutboxRepository()
+	_, err := NewAuthService(cfg, repositories.NewMemoryUserRepository(outbox), repositories.NewMemorySessionRepository(outbox))
+	if err == nil {
+		t.Fatal("NewAuthService() without a token issuer outside development succeeded")
+	}
+}
+
+func TestAuthServiceRegisterAndLogin(t *testing.T) {
+	ctx := context.Background()
+	s, mailer := newTestService(t, newTestConfig())
+
+	if _, err := s.Register(ctx, &models.RegisterRequest{Email: "jane@example.com", Password: "correct horse", Name: "Jane"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		verify   bool // follow the verification email first
+		wantErr  error
+	}{
+		{name: "unverified", email: "jane@example.com", password: "correct horse", wantErr: ErrUserNotVerified},
+		{name: "wrong password", email: "jane@example.com", password: "wrong horse", verify: true, wantErr: ErrInvalidCredentials},
+		{name: "unknown email", email: "john@example.com", password: "correct horse", wantErr: ErrInvalidCredentials},
+		{name: "email in another case", email: "JANE@example.com", password: "correct horse"},
+		{name: "valid", email: "jane@example.com", password: "correct horse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.verify {
+				mailer.mu.Lock()
+				token := mailer.verification["jane@example.com"]
+				mailer.mu.Unlock()
+				if err := s.VerifyEmail(ctx, token); err != nil {
+					t.Fatalf("VerifyEmail: %v", err)
+				}
+			}
+
+			response, err := s.Login(ctx, &models.LoginRequest{Email: tt.email, Password: tt.password})
+			if err != tt.wantErr {
+				t.Fatalf("Login() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (response.AccessToken == "" || response.RefreshToken == "") {
+				t.Fatalf("Login() = %+v, want both tokens", response)
+			}
+		})
+	}
+
+	_, err := s.Register(ctx, &models.RegisterRequest{Email: "Jane@Example.com", Password: "another one", Name: "Jane"})
+	if err != ErrUserAlreadyExists {
+		t.Fatalf("second Register() error = %v, want %v", err, ErrUserAlreadyExists)
+	}
+}
+
+// TestAuthServiceRefreshTokenReuse rotates a refresh token and then replays
+// each token in turn. Replaying a rotated token revokes the session, so even
+// the newest token stops working.
+func TestAuthServiceRefreshTokenReuse(t *testing.T) {
+	ctx := context.Background()
+	s, mailer := newTestService(t, newTestConfig())
+	registerVerifiedUser(t, s, mailer, "jane@example.com", "correct horse")
+
+	login, err := s.Login(ctx, &models.LoginRequest{Email: "jane@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	refreshed, err := s.RefreshToken(ctx, login.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if refreshed.RefreshToken == login.RefreshToken {
+		t.Fatal("RefreshToken() returned the same refresh token")
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "rotated token", token: login.RefreshToken, wantErr: ErrRefreshTokenReused},
+		{name: "newest token after reuse", token: refreshed.RefreshToken, wantErr: ErrTokenExpired},
+		{name: "access token", token: login.AccessToken, wantErr: ErrInvalidToken},
+		{name: "garbage", token: "not-a-token", wantErr: ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.RefreshToken(ctx, tt.token); err != tt.wantErr {
+				t.Fatalf("RefreshToken() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	sessions, err := s.GetUserSessions(ctx, login.User.ID)
+	if err != nil {
+		t.Fatalf("GetUserSessions: %v", err)
+	}
+	if len(sessions) != 0 {
+		t.Fatalf("GetUserSessions() = %d sessions after reuse, want 0", len(sessions))
+	}
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/repositories"
+	"github.com/multitask-platform/backend/shared/events"
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+// Email codes are a typed-in alternative to the links sent for email
+// verification and password resets, and a second login step for accounts
+// with MFA. A code is only good for the purpose it was sent for, and is
+// thrown away after EmailOTPMaxAttempts wrong guesses.
+
+// SendVerificationCode emails a code that verifies the account's address.
+// Like ResendVerification it says nothing about whether the account exists.
+func (s *AuthService) SendVerificationCode(ctx context.Context, email string) error {
+	logger.DebugCtx(ctx, "Processing verification code request", zap.String("email", email))
+
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if err == repositories.ErrUserNotFound {
+			// Don't reveal that user doesn't exist
+			return nil
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user.IsVerified {
+		// Don't reveal that user is already verified
+		return nil
+	}
+
+	return s.sendEmailOTP(ctx, user, models.EmailOTPPurposeVerify)
+}
+
+// VerifyEmailCode verifies the account's address with an emailed code
+func (s *AuthService) VerifyEmailCode(ctx context.Context, email, code string) error {
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if err == repositories.ErrUserNotFound {
+			return ErrInvalidEmailCode
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if err := s.verifyEmailOTP(ctx, user.ID, models.EmailOTPPurposeVerify, code); err != nil {
+		return err
+	}
+
+	err = s.userRepo.MarkUserVerified(ctx, user.ID, s.outboxEvents(ctx, events.UserVerified{UserID: user.ID})...)
+	if err != nil {
+		return fmt.Errorf("failed to mark user as verified: %w", err)
+	}
+
+	s.recordAudit(ctx, &models.AuditEvent{
+		Action:  models.AuditActionEmailVerified,
+		ActorID: user.ID,
+		UserID:  user.ID,
+		Details: map[string]string{"method": models.EmailMethodCode},
+	})
+
+	logger.InfoCtx(ctx, "Email verification successful", zap.String("user_id", user.ID))
+
+	return nil
+}
+
+// SendPasswordResetCode emails a code for resetting the password. Like
+// ForgotPassword it says nothing about whether the account exists.
+func (s *AuthService) SendPasswordResetCode(ctx context.Context, email string) error {
+	logger.DebugCtx(ctx, "Processing password reset code request", zap.String("email", email))
+
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if err == repositories.ErrUserNotFound {
+			// Don't reveal that user doesn't exist
+			return nil
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return s.sendEmailOTP(ctx, user, models.EmailOTPPurposeReset)
+}
+
+// ResetPasswordWithCode resets the password with an emailed code
+func (s *AuthService) ResetPasswordWithCode(ctx context.Context, email, code, newPassword string) error {
+	logger.DebugCtx(ctx, "Processing password reset with code")
+
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if err == repositories.ErrUserNotFound {
+			return ErrInvalidEmailCode
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if err := s.verifyEmailOTP(ctx, user.ID, models.EmailOTPPurposeReset, code); err != nil {
+		return err
+	}
+
+	return s.resetPassword(ctx, user.ID, newPassword)
+}
+
+// SendLoginCode emails a code for the second step of the login the MFA token
+// belongs to
+func (s *AuthService) SendLoginCode(ctx context.Context, mfaToken string) error {
+	claims, err := s.tokens.ParseMFAChallengeToken(mfaToken)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	// The mailbox can't be both steps of the same login
+	if !emailCodeAllowed(claims.AuthMethods) {
+		return ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetUser(ctx, claims.UserID)
+	if err != nil {
+		if err == repositories.ErrUserNotFound {
+			return ErrInvalidToken
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if !user.IsActive {
+		return ErrUserDisabled
+	}
+
+	return s.sendEmailOTP(ctx, user, models.EmailOTPPurposeLogin)
+}
+
+// emailCodeAllowed reports whether a login that got this far with
+// authMethods may finish with an emailed code
+func emailCodeAllowed(authMethods []string) bool {
+	return !slices.Contains(authMethods, models.AuthMethodEmail)
+}
+
+// sendEmailOTP emails the user a new code for purpose, replacing any code
+// sent for it before
+func (s *AuthService) sendEmailOTP(ctx context.Context, user *models.User, purpose string) error {
+	code, err := generateEmailOTP()
+	if err != nil {
+		return fmt.Errorf("failed to generate email code: %w", err)
+	}
+
+	now := s.now().UTC()
+	err = s.userRepo.SaveEmailOTP(ctx, &models.EmailOTP{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		CodeHash:  hashEmailOTP(code),
+		ExpiresAt: now.Add(models.EmailOTPDuration),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store email code: %w", err)
+	}
+
+	err = s.mailer.SendEmailCode(ctx, user, purpose, code)
+	if err != nil {
+		logger.ErrorCtx(ctx, "Failed to send email code", zap.String("purpose", purpose), zap.Error(err))
+		return fmt.Errorf("failed to send email code: %w", err)
+	}
+
+	logger.InfoCtx(ctx, "Email code sent",
+		zap.String("user_id", user.ID),
+		zap.String("purpose", purpose),
+	)
+
+	return nil
+}
+
+// verifyEmailOTP checks a code the user typed in and uses it up. Every guess
+// is counted before it is compared, and the code is deleted once it expires,
+// runs out of attempts or is accepted.
+func (s *AuthService) verifyEmailOTP(ctx context.Context, userID, purpose, code string) error {
+	otp, err := s.userRepo.GetEmailOTP(ctx, userID, purpose)
+	if err != nil {
+		if err == repositories.ErrTokenNotFound {
+			return ErrInvalidEmailCode
+		}
+		return fmt.Errorf("failed to get email code: %w", err)
+	}
+
+	if s.now().After(otp.ExpiresAt) {
+		s.discardEmailOTP(ctx, userID, purpose)
+		return ErrInvalidEmailCode
+	}
+
+	attempts, err := s.userRepo.RecordEmailOTPAttempt(ctx, userID, purpose)
+	if err != nil {
+		if err == repositories.ErrTokenNotFound {
+			return ErrInvalidEmailCode
+		}
+		return fmt.Errorf("failed to record email code attempt: %w", err)
+	}
+	if attempts > models.EmailOTPMaxAttempts {
+		logger.LogSecurityEvent(ctx, "email_code_attempts_exceeded",
+			zap.String("user_id", userID),
+			zap.String("purpose", purpose),
+		)
+		s.discardEmailOTP(ctx, userID, purpose)
+		return ErrInvalidEmailCode
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashEmailOTP(code)), []byte(otp.CodeHash)) != 1 {
+		return ErrInvalidEmailCode
+	}
+
+	// Only one of two requests racing with the right code gets to delete it
+	err = s.userRepo.DeleteEmailOTP(ctx, userID, purpose)
+	if err != nil {
+		if err == repositories.ErrTokenNotFound {
+			return ErrInvalidEmailCode
+		}
+		return fmt.Errorf("failed to use email code: %w", err)
+	}
+
+	return nil
+}
+
+// discardEmailOTP deletes a code that can no longer be used. A failure only
+// leaves it for the TTL to remove.
+func (s *AuthService) discardEmailOTP(ctx context.Context, userID, purpose string) {
+	err := s.userRepo.DeleteEmailOTP(ctx, userID, purpose)
+	if err != nil && err != repositories.ErrTokenNotFound {
+		logger.WarnCtx(ctx, "Failed to delete email code", zap.Error(err))
+	}
+}
+
+// generateEmailOTP returns a uniformly random code of EmailOTPLength digits
+func generateEmailOTP() (string, error) {
+	limit := big.NewInt(1)
+	for i := 0; i < models.EmailOTPLength; i++ {
+		limit.Mul(limit, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", models.EmailOTPLength, n), nil
+}
+
+// hashEmailOTP returns the SHA-256 of a code. Six digits are quick to brute
+// force from a hash; storing it only keeps live codes out of table dumps and
+// logs.
+func hashEmailOTP(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
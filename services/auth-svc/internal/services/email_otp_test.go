@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+)
+
+// emailCodeMailer keeps the codes AuthService emails out, keyed by address
+// and purpose
+type emailCodeMailer struct {
+	*magicLinkMailer
+
+	codes map[string]string
+}
+
+func (m *emailCodeMailer) SendEmailCode(ctx context.Context, user *models.User, purpose, code string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.codes[user.Email+" "+purpose] = code
+	return nil
+}
+
+// code returns the last code sent to email for purpose and forgets it
+func (m *emailCodeMailer) code(email, purpose string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	code := m.codes[email+" "+purpose]
+	delete(m.codes, email+" "+purpose)
+	return code
+}
+
+// wrongCode returns a code of the right length that isn't code
+func wrongCode(code string) string {
+	if code == "000000" {
+		return "111111"
+	}
+	return "000000"
+}
+
+func newEmailCodeService(t *testing.T, opts ...Option) (*AuthService, *emailCodeMailer) {
+	t.Helper()
+
+	mailer := &emailCodeMailer{
+		magicLinkMailer: &magicLinkMailer{
+			testMailer: &testMailer{LogMailer: NewLogMailer(), verification: make(map[string]string)},
+			links:      make(map[string]string),
+		},
+		codes: make(map[string]string),
+	}
+	s, _ := newTestService(t, newTestConfig(), append(opts, WithMailer(mailer))...)
+	return s, mailer
+}
+
+func TestAuthServiceVerifyEmailCode(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	s, mailer := newEmailCodeService(t, WithClock(func() time.Time { return now }))
+
+	tests := []struct {
+		name    string
+		wrong   int           // wrong guesses before the right code
+		age     time.Duration // how old the code is when typed in
+		wantErr error
+	}{
+		{name: "right away"},
+		{name: "just before expiry", age: models.EmailOTPDuration},
+		{name: "expired", age: models.EmailOTPDuration + time.Second, wantErr: ErrInvalidEmailCode},
+		{name: "after wrong guesses", wrong: models.EmailOTPMaxAttempts - 1},
+		{name: "out of attempts", wrong: models.EmailOTPMaxAttempts, wantErr: ErrInvalidEmailCode},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := fmt.Sprintf("user%d@example.com", i)
+			now = start
+			if _, err := s.Register(ctx, &models.RegisterRequest{Email: email, Password: "correct horse", Name: "Jane"}); err != nil {
+				t.Fatalf("Register: %v", err)
+			}
+			if err := s.SendVerificationCode(ctx, email); err != nil {
+				t.Fatalf("SendVerificationCode: %v", err)
+			}
+			code := mailer.code(email, models.EmailOTPPurposeVerify)
+			if len(code) != models.EmailOTPLength {
+				t.Fatalf("code %q, want %d digits", code, models.EmailOTPLength)
+			}
+
+			now = start.Add(tt.age)
+			for j := 0; j < tt.wrong; j++ {
+				if err := s.VerifyEmailCode(ctx, email, wrongCode(code)); err != ErrInvalidEmailCode {
+					t.Fatalf("wrong guess %d error = %v, want %v", j+1, err, ErrInvalidEmailCode)
+				}
+			}
+			if err := s.VerifyEmailCode(ctx, email, code); err != tt.wantErr {
+				t.Fatalf("VerifyEmailCode() error = %v, want %v", err, tt.wantErr)
+			}
+
+			user, err := s.userRepo.GetUserByEmail(ctx, email)
+			if err != nil {
+				t.Fatalf("GetUserByEmail: %v", err)
+			}
+			if user.IsVerified != (tt.wantErr == nil) {
+				t.Fatalf("verified = %v, want %v", user.IsVerified, tt.wantErr == nil)
+			}
+
+			// Codes work once, and expired or exhausted ones are gone
+			if err := s.VerifyEmailCode(ctx, email, code); err != ErrInvalidEmailCode {
+				t.Fatalf("second VerifyEmailCode() error = %v, want %v", err, ErrInvalidEmailCode)
+			}
+		})
+	}
+}
+
+func TestAuthServiceEmailCodeRequests(t *testing.T) {
+	ctx := context.Background()
+	s, mailer := newEmailCodeService(t)
+	registerVerifiedUser(t, s, mailer.testMailer, "jane@example.com", "correct horse")
+	if _, err := s.Register(ctx, &models.RegisterRequest{Email: "john@example.com", Password: "correct horse", Name: "John"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	// Nothing tells the caller which addresses have accounts, or which of
+	// them are verified
+	tests := []struct {
+		name     string
+		send     func(email string) error
+		purpose  string
+		email    string
+		wantCode bool
+	}{
+		{name: "verification for an unverified account", send: func(email string) error { return s.SendVerificationCode(ctx, email) }, purpose: models.EmailOTPPurposeVerify, email: "john@example.com", wantCode: true},
+		{name: "verification for a verified account", send: func(email string) error { return s.SendVerificationCode(ctx, email) }, purpose: models.EmailOTPPurposeVerify, email: "jane@example.com"},
+		{name: "verification for an unknown address", send: func(email string) error { return s.SendVerificationCode(ctx, email) }, purpose: models.EmailOTPPurposeVerify, email: "nobody@example.com"},
+		{name: "reset", send: func(email string) error { return s.SendPasswordResetCode(ctx, email) }, purpose: models.EmailOTPPurposeReset, email: "jane@example.com", wantCode: true},
+		{name: "reset for an unknown address", send: func(email string) error { return s.SendPasswordResetCode(ctx, email) }, purpose: models.EmailOTPPurposeReset, email: "nobody@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.send(tt.email); err != nil {
+				t.Fatalf("send error = %v, want none", err)
+			}
+			if got := mailer.code(tt.email, tt.purpose) != ""; got != tt.wantCode {
+				t.Fatalf("code sent = %v, want %v", got, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestAuthServiceResetPasswordWithCode(t *testing.T) {
+	ctx := context.Background()
+	s, mailer := newEmailCodeService(t)
+	registerVerifiedUser(t, s, mailer.testMailer, "jane@example.com", "correct horse")
+
+	if err := s.SendPasswordResetCode(ctx, "jane@example.com"); err != nil {
+		t.Fatalf("SendPasswordResetCode: %v", err)
+	}
+	replaced := mailer.code("jane@example.com", models.EmailOTPPurposeReset)
+	if err := s.SendPasswordResetCode(ctx, "jane@example.com"); err != nil {
+		t.Fatalf("SendPasswordResetCode: %v", err)
+	}
+	code := mailer.code("jane@example.com", models.EmailOTPPurposeReset)
+
+	// A code is only good for what it was sent for, and a new one replaces
+	// the last
+	if err := s.VerifyEmailCode(ctx, "jane@example.com", code); err != ErrInvalidEmailCode {
+		t.Fatalf("VerifyEmailCode() with a reset code error = %v, want %v", err, ErrInvalidEmailCode)
+	}
+	if replaced != code {
+		if err := s.ResetPasswordWithCode(ctx, "jane@example.com", replaced, "new horse battery"); err != ErrInvalidEmailCode {
+			t.Fatalf("ResetPasswordWithCode() with the replaced code error = %v, want %v", err, ErrInvalidEmailCode)
+		}
+	}
+	if err := s.ResetPasswordWithCode(ctx, "nobody@example.com", code, "new horse battery"); err != ErrInvalidEmailCode {
+		t.Fatalf("ResetPasswordWithCode() for an unknown address error = %v, want %v", err, ErrInvalidEmailCode)
+	}
+
+	if err := s.ResetPasswordWithCode(ctx, "jane@example.com", code, "new horse battery"); err != nil {
+		t.Fatalf("ResetPasswordWithCode: %v", err)
+	}
+	if _, err := s.Login(ctx, &models.LoginRequest{Email: "jane@example.com", Password: "new horse battery"}); err != nil {
+		t.Fatalf("Login() with the new password: %v", err)
+	}
+	if err := s.ResetPasswordWithCode(ctx, "jane@example.com", code, "another horse"); err != ErrInvalidEmailCode {
+		t.Fatalf("second ResetPasswordWithCode() error = %v, want %v", err, ErrInvalidEmailCode)
+	}
+}
+
+func TestAuthServiceLoginWithEmailCode(t *testing.T) {
+	ctx := context.Background()
+	s, mailer := newEmailCodeService(t)
+	user := registerVerifiedUser(t, s, mailer.testMailer, "jane@example.com", "correct horse")
+	enableTOTP(t, s, user.ID)
+
+	// The password step offers a code by email
+	_, err := s.Login(ctx, &models.LoginRequest{Email: "jane@example.com", Password: "correct horse"})
+	var mfaErr *MFARequiredError
+	if !errors.As(err, &mfaErr) {
+		t.Fatalf("Login() error = %v, want an MFA challenge", err)
+	}
+	if !slices.Contains(mfaErr.Challenge.Methods, models.MFAMethodEmail) {
+		t.Fatalf("challenge methods = %v, want %s offered", mfaErr.Challenge.Methods, models.MFAMethodEmail)
+	}
+	token := mfaErr.Challenge.MFAToken
+
+	if err := s.SendLoginCode(ctx, token); err != nil {
+		t.Fatalf("SendLoginCode: %v", err)
+	}
+	code := mailer.code("jane@example.com", models.EmailOTPPurposeLogin)
+
+	if _, err := s.LoginMFA(ctx, &models.MFALoginRequest{MFAToken: token, EmailCode: wrongCode(code)}); err != ErrInvalidMFACode {
+		t.Fatalf("LoginMFA() with a wrong code error = %v, want %v", err, ErrInvalidMFACode)
+	}
+	response, err := s.LoginMFA(ctx, &models.MFALoginRequest{MFAToken: token, EmailCode: code})
+	if err != nil {
+		t.Fatalf("LoginMFA: %v", err)
+	}
+	if response.AccessToken == "" {
+		t.Fatalf("LoginMFA() = %+v, want tokens", response)
+	}
+	if _, err := s.LoginMFA(ctx, &models.MFALoginRequest{MFAToken: token, EmailCode: code}); err != ErrInvalidMFACode {
+		t.Fatalf("second LoginMFA() error = %v, want %v", err, ErrInvalidMFACode)
+	}
+
+	// A login link already used the mailbox, so it can't be the second step
+	if err := s.RequestMagicLink(ctx, "jane@example.com"); err != nil {
+		t.Fatalf("RequestMagicLink: %v", err)
+	}
+	_, err = s.VerifyMagicLink(ctx, &models.MagicLinkVerifyRequest{Token: mailer.link("jane@example.com")})
+	if !errors.As(err, &mfaErr) {
+		t.Fatalf("VerifyMagicLink() error = %v, want an MFA challenge", err)
+	}
+	if slices.Contains(mfaErr.Challenge.Methods, models.MFAMethodEmail) {
+		t.Fatalf("challenge after a login link offers %s", models.MFAMethodEmail)
+	}
+	if err := s.SendLoginCode(ctx, mfaErr.Challenge.MFAToken); err != ErrInvalidToken {
+		t.Fatalf("SendLoginCode() after a login link error = %v, want %v", err, ErrInvalidToken)
+	}
+	if _, err := s.LoginMFA(ctx, &models.MFALoginRequest{MFAToken: mfaErr.Challenge.MFAToken, EmailCode: code}); err != ErrInvalidMFACode {
+		t.Fatalf("LoginMFA() with an email code after a login link error = %v, want %v", err, ErrInvalidMFACode)
+	}
+}
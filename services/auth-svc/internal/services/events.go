@@ -0,0 +1,34 @@
+package services
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/shared/events"
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+// EventPublisher delivers domain events to other services. A failed call
+// returns the events it didn't deliver; see events.FailedEvents.
+type EventPublisher interface {
+	Publish(ctx context.Context, events ...*events.Event) error
+}
+
+// outboxEvents wraps payloads in events for a repository write to add to
+// the outbox; OutboxRelay publishes them once the write has committed
+func (s *AuthService) outboxEvents(ctx context.Context, payloads ...events.Payload) []*events.Event {
+	outbox := make([]*events.Event, 0, len(payloads))
+	for _, payload := range payloads {
+		event, err := events.New(s.newID(), events.SourceAuth, s.now(), payload)
+		if err != nil {
+			logger.ErrorCtx(ctx, "Failed to build event",
+				zap.String("event_type", string(payload.EventType())),
+				zap.Error(err),
+			)
+			continue
+		}
+		outbox = append(outbox, event)
+	}
+	return outbox
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+// Brute-force protection for Login.
+//
+// Failed attempts are counted per user and per source IP within
+// models.LoginAttemptWindow. Past the backoff threshold each further failure
+// blocks the key for an exponentially growing delay (1s, 2s, 4s, ...); at the
+// maximum the key is locked for models.AccountLockoutDuration and, for users,
+// an email is sent. Wrong TOTP codes at /login/mfa count like wrong
+// passwords. A completed login (including any second factor) or a password
+// reset clears the user's counter; IP counters only expire.
+
+func userAttemptKey(userID string) string {
+	return "user:" + userID
+}
+
+func ipAttemptKey(ipAddress string) string {
+	return "ip:" + ipAddress
+}
+
+// checkIPLock returns ErrTooManyLoginAttempts while the source IP is blocked
+func (s *AuthService) checkIPLock(ctx context.Context, ipAddress string) error {
+	if ipAddress == "" {
+		return nil
+	}
+
+	attempts, err := s.userRepo.GetLoginAttempts(ctx, ipAttemptKey(ipAddress))
+	if err != nil {
+		logger.WarnCtx(ctx, "Failed to get login attempts for IP", zap.Error(err))
+		return nil
+	}
+
+	if attempts.IsLocked(s.now()) {
+		return ErrTooManyLoginAttempts
+	}
+	return nil
+}
+
+// checkUserLock returns ErrAccountLocked while the account is blocked
+func (s *AuthService) checkUserLock(ctx context.Context, userID string) error {
+	attempts, err := s.userRepo.GetLoginAttempts(ctx, userAttemptKey(userID))
+	if err != nil {
+		logger.WarnCtx(ctx, "Failed to get login attempts for user", zap.Error(err))
+		return nil
+	}
+
+	if attempts.IsLocked(s.now()) {
+		return ErrAccountLocked
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed attempt against the IP and, when known,
+// the user. It returns ErrAccountLocked if this failure locked the account and
+// ErrInvalidCredentials otherwise.
+func (s *AuthService) recordLoginFailure(ctx context.Context, user *models.User, ipAddress string) error {
+	now := s.now().UTC()
+
+	if ipAddress != "" {
+		s.applyFailure(ctx, ipAttemptKey(ipAddress), now, models.LoginBackoffThresholdPerIP, models.MaxFailedLoginAttemptsPerIP)
+	}
+
+	if user == nil {
+		s.recordAudit(ctx, &models.AuditEvent{
+			Action:    models.AuditActionLoginFailed,
+			IPAddress: ipAddress,
+		})
+		return ErrInvalidCredentials
+	}
+
+	lockedUntil, locked := s.applyFailure(ctx, userAttemptKey(user.ID), now, models.LoginBackoffThreshold, models.MaxFailedLoginAttempts)
+
+	event := &models.AuditEvent{
+		Action:    models.AuditActionLoginFailed,
+		UserID:    user.ID,
+		IPAddress: ipAddress,
+	}
+	if locked {
+		event.Details = map[string]string{"locked_until": lockedUntil.Format(time.RFC3339)}
+	}
+	s.recordAudit(ctx, event)
+
+	if !locked {
+		return ErrInvalidCredentials
+	}
+
+	logger.WarnCtx(ctx, "Account locked after repeated failed logins",
+		zap.String("user_id", user.ID),
+		zap.Time("locked_until", lockedUntil),
+	)
+
+	if err := s.mailer.SendAccountLockedEmail(ctx, user, lockedUntil); err != nil {
+		logger.WarnCtx(ctx, "Failed to send account locked email", zap.Error(err))
+	}
+
+	return ErrAccountLocked
+}
+
+// applyFailure records a failure for key and blocks it according to the
+// backoff/lockout thresholds. It reports whether the key is now fully locked.
+func (s *AuthService) applyFailure(ctx context.Context, key string, now time.Time, backoffThreshold, maxAttempts int) (time.Time, bool) {
+	attempts, err := s.userRepo.RecordFailedLogin(ctx, key, now, models.LoginAttemptWindow)
+	if err != nil {
+		logger.WarnCtx(ctx, "Failed to record failed login", zap.Error(err))
+		return time.Time{}, false
+	}
+
+	var until time.Time
+	switch {
+	case attempts.FailedCount >= maxAttempts:
+		until = now.Add(models.AccountLockoutDuration)
+	case attempts.FailedCount >= backoffThreshold:
+		until = now.Add(backoffDelay(attempts.FailedCount - backoffThreshold))
+	default:
+		return time.Time{}, false
+	}
+
+	if err := s.userRepo.LockLogin(ctx, key, until); err != nil {
+		logger.WarnCtx(ctx, "Failed to lock login", zap.Error(err))
+		return time.Time{}, false
+	}
+
+	return until, attempts.FailedCount >= maxAttempts
+}
+
+// clearLoginFailures forgets the user's failed attempts and any lock
+func (s *AuthService) clearLoginFailures(ctx context.Context, userID string) {
+	if err := s.userRepo.ClearLoginAttempts(ctx, userAttemptKey(userID)); err != nil {
+		logger.WarnCtx(ctx, "Failed to clear login attempts", zap.Error(err))
+	}
+}
+
+// backoffDelay returns 2^step seconds, capped at the lockout duration
+func backoffDelay(step int) time.Duration {
+	if step > 10 {
+		return models.AccountLockoutDuration
+	}
+	delay := time.Duration(1<<step) * time.Second
+	if delay > models.AccountLockoutDuration {
+		return models.AccountLockoutDuration
+	}
+	return delay
+}
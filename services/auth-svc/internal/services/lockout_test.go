@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+)
+
+// lockoutMailer records the account locked emails on top of testMailer
+type lockoutMailer struct {
+	*testMailer
+
+	locked []time.Time
+}
+
+func (m *lockoutMailer) SendAccountLockedEmail(ctx context.Context, user *models.User, lockedUntil time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.locked = append(m.locked, lockedUntil)
+	return nil
+}
+
+// TestAuthServiceLockout runs one user's logins in order, with the clock
+// moving between them
+func TestAuthServiceLockout(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+
+	mailer := &lockoutMailer{testMailer: &testMailer{LogMailer: NewLogMailer(), verification: make(map[string]string)}}
+	s, _ := newTestService(t, newTestConfig(), WithClock(func() time.Time { return now }), WithMailer(mailer))
+	registerVerifiedUser(t, s, mailer.testMailer, "jane@example.com", "correct horse")
+
+	lockedAt := 3 * time.Second
+	lockedUntil := start.Add(lockedAt + models.AccountLockoutDuration)
+
+	tests := []struct {
+		name     string
+		at       time.Duration // after start
+		password string
+		wantErr  error
+	}{
+		{name: "first failure", at: 0, password: "wrong", wantErr: ErrInvalidCredentials},
+		{name: "second failure", at: 0, password: "wrong", wantErr: ErrInvalidCredentials},
+		{name: "success resets the count", at: 0, password: "correct horse"},
+		{name: "first failure again", at: 0, password: "wrong", wantErr: ErrInvalidCredentials},
+		{name: "second failure again", at: 0, password: "wrong", wantErr: ErrInvalidCredentials},
+		{name: "failure at the backoff threshold", at: 0, password: "wrong", wantErr: ErrInvalidCredentials},
+		{name: "right password during the 1s backoff", at: 0, password: "correct horse", wantErr: ErrAccountLocked},
+		{name: "failure after the backoff", at: time.Second, password: "wrong", wantErr: ErrInvalidCredentials},
+		{name: "right password during the 2s backoff", at: 2 * time.Second, password: "correct horse", wantErr: ErrAccountLocked},
+		{name: "failure that locks the account", at: lockedAt, password: "wrong", wantErr: ErrAccountLocked},
+		{name: "right password just before the lock ends", at: lockedAt + models.AccountLockoutDuration - time.Second, password: "correct horse", wantErr: ErrAccountLocked},
+		{name: "right password once the lock ends", at: lockedAt + models.AccountLockoutDuration, password: "correct horse"},
+		{name: "count starts over after the lock", at: lockedAt + models.AccountLockoutDuration, password: "wrong", wantErr: ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = start.Add(tt.at)
+
+			_, err := s.Login(ctx, &models.LoginRequest{Email: "jane@example.com", Password: tt.password})
+			if err != tt.wantErr {
+				t.Fatalf("Login() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if len(mailer.locked) != 1 || !mailer.locked[0].Equal(lockedUntil) {
+		t.Fatalf("account locked emails = %v, want one saying %v", mailer.locked, lockedUntil)
+	}
+}
+
+func TestAuthServiceLockoutWindow(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	s, mailer := newTestService(t, newTestConfig(), WithClock(func() time.Time { return now }))
+	registerVerifiedUser(t, s, mailer, "jane@example.com", "correct horse")
+
+	login := func(password string) error {
+		_, err := s.Login(ctx, &models.LoginRequest{Email: "jane@example.com", Password: password})
+		return err
+	}
+
+	for i := 0; i < models.LoginBackoffThreshold-1; i++ {
+		if err := login("wrong"); err != ErrInvalidCredentials {
+			t.Fatalf("Login() error = %v, want %v", err, ErrInvalidCredentials)
+		}
+	}
+
+	// Failures older than the window are forgotten, so this one doesn't
+	// reach the backoff threshold
+	now = start.Add(models.LoginAttemptWindow + time.Second)
+	if err := login("wrong"); err != ErrInvalidCredentials {
+		t.Fatalf("Login() error = %v, want %v", err, ErrInvalidCredentials)
+	}
+	if err := login("correct horse"); err != nil {
+		t.Fatalf("Login() after the window: %v", err)
+	}
+}
+
+func TestAuthServiceLockoutPerIP(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	s, mailer := newTestService(t, newTestConfig(), WithClock(func() time.Time { return now }))
+	registerVerifiedUser(t, s, mailer, "jane@example.com", "correct horse")
+
+	login := func(email, password, ip string) error {
+		_, err := s.Login(ctx, &models.LoginRequest{Email: email, Password: password, IPAddress: ip})
+		return err
+	}
+
+	// Guessing across accounts from one address blocks the address, not the
+	// accounts
+	for i := 0; i < models.LoginBackoffThresholdPerIP; i++ {
+		if err := login("nobody@example.com", "wrong", "203.0.113.7"); err != ErrInvalidCredentials {
+			t.Fatalf("failure %d: Login() error = %v, want %v", i+1, err, ErrInvalidCredentials)
+		}
+	}
+	if err := login("jane@example.com", "correct horse", "203.0.113.7"); err != ErrTooManyLoginAttempts {
+		t.Fatalf("Login() from the blocked address error = %v, want %v", err, ErrTooManyLoginAttempts)
+	}
+	if err := login("jane@example.com", "correct horse", "198.51.100.1"); err != nil {
+		t.Fatalf("Login() from another address: %v", err)
+	}
+
+	// A successful login doesn't clear the address's count
+	now = start.Add(time.Second)
+	if err := login("jane@example.com", "correct horse", "203.0.113.7"); err != nil {
+		t.Fatalf("Login() after the backoff: %v", err)
+	}
+	if err := login("nobody@example.com", "wrong", "203.0.113.7"); err != ErrInvalidCredentials {
+		t.Fatalf("Login() error = %v, want %v", err, ErrInvalidCredentials)
+	}
+	if err := login("jane@example.com", "correct horse", "203.0.113.7"); err != ErrTooManyLoginAttempts {
+		t.Fatalf("Login() after another failure error = %v, want %v", err, ErrTooManyLoginAttempts)
+	}
+}
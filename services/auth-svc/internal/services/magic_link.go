@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/repositories"
+	"github.com/multitask-platform/backend/shared/events"
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+// Magic link login emails a single-use link that stands in for the password.
+// Like a password, it only covers the first step: accounts with MFA still get
+// the second one.
+
+// RequestMagicLink emails a login link to the account with the given
+// address. Unknown and disabled accounts are skipped without an error so the
+// response can't be used to find out which addresses are registered.
+func (s *AuthService) RequestMagicLink(ctx context.Context, email string) error {
+	logger.DebugCtx(ctx, "Processing magic link request", zap.String("email", email))
+
+	// Get user by email
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if err == repositories.ErrUserNotFound {
+			// Don't reveal that user doesn't exist
+			return nil
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if !user.IsActive {
+		logger.InfoCtx(ctx, "Magic link not sent to disabled user", zap.String("user_id", user.ID))
+		return nil
+	}
+
+	// Generate login token
+	token, err := s.generateSecureToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate magic link token: %w", err)
+	}
+
+	// Store login token
+	err = s.userRepo.CreateMagicLinkToken(ctx, user.ID, token, models.DefaultMagicLinkDuration)
+	if err != nil {
+		return fmt.Errorf("failed to store magic link token: %w", err)
+	}
+
+	// Send login email
+	err = s.mailer.SendMagicLinkEmail(ctx, user, token)
+	if err != nil {
+		logger.ErrorCtx(ctx, "Failed to send magic link email", zap.Error(err))
+		return fmt.Errorf("failed to send magic link email: %w", err)
+	}
+
+	logger.InfoCtx(ctx, "Magic link sent", zap.String("user_id", user.ID))
+
+	return nil
+}
+
+// VerifyMagicLink logs in with the token from a login link. The token is
+// used up whether or not the login succeeds.
+func (s *AuthService) VerifyMagicLink(ctx context.Context, req *models.MagicLinkVerifyRequest) (*models.AuthResponse, error) {
+	logger.DebugCtx(ctx, "Processing magic link login")
+
+	userID, err := s.userRepo.ConsumeMagicLinkToken(ctx, req.Token)
+	if err != nil {
+		if err == repositories.ErrTokenNotFound || err == repositories.ErrTokenExpired {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to verify magic link token: %w", err)
+	}
+
+	user, err := s.userRepo.GetUser(ctx, userID)
+	if err != nil {
+		if err == repositories.ErrUserNotFound {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if !user.IsActive {
+		return nil, ErrUserDisabled
+	}
+
+	if err := s.checkUserLock(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	// Following the link proves control of the mailbox, the same as the
+	// verification link would
+	if !user.IsVerified {
+		err := s.userRepo.MarkUserVerified(ctx, user.ID, s.outboxEvents(ctx, events.UserVerified{UserID: user.ID})...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to mark user as verified: %w", err)
+		}
+		user.IsVerified = true
+	}
+
+	mfa, err := s.mfaStatus(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa.Enabled {
+		return nil, s.mfaChallenge(ctx, user, mfa.Methods, []string{models.AuthMethodEmail})
+	}
+
+	s.clearLoginFailures(ctx, user.ID)
+
+	return s.completeLogin(ctx, user, &models.SessionCreateRequest{
+		UserID:      user.ID,
+		DeviceID:    req.DeviceID,
+		UserAgent:   req.UserAgent,
+		IPAddress:   req.IPAddress,
+		AuthMethods: []string{models.AuthMethodEmail},
+	})
+}
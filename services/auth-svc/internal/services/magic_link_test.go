@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/repositories"
+)
+
+// magicLinkMailer keeps the login links AuthService emails out, keyed by
+// address
+type magicLinkMailer struct {
+	*testMailer
+
+	links map[string]string
+}
+
+func (m *magicLinkMailer) SendMagicLinkEmail(ctx context.Context, user *models.User, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.links[user.Email] = token
+	return nil
+}
+
+// link returns the last login link sent to email and forgets it
+func (m *magicLinkMailer) link(email string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token := m.links[email]
+	delete(m.links, email)
+	return token
+}
+
+// agedMagicLinks issues magic link tokens as if they had been created age
+// ago, since the repositories time them with the wall clock
+type agedMagicLinks struct {
+	repositories.UserRepository
+
+	age time.Duration
+}
+
+func (r *agedMagicLinks) CreateMagicLinkToken(ctx context.Context, userID, token string, duration time.Duration) error {
+	return r.UserRepository.CreateMagicLinkToken(ctx, userID, token, duration-r.age)
+}
+
+// newMagicLinkService returns a service whose magic links go to the returned
+// mailer and age by the returned repository's age
+func newMagicLinkService(t *testing.T) (*AuthService, *magicLinkMailer, *agedMagicLinks) {
+	t.Helper()
+
+	mailer := &magicLinkMailer{
+		testMailer: &testMailer{LogMailer: NewLogMailer(), verification: make(map[string]string)},
+		links:      make(map[string]string),
+	}
+	s, _ := newTestService(t, newTestConfig(), WithMailer(mailer))
+	users := &agedMagicLinks{UserRepository: s.userRepo}
+	s.userRepo = users
+	return s, mailer, users
+}
+
+func TestAuthServiceMagicLink(t *testing.T) {
+	ctx := context.Background()
+	s, mailer, users := newMagicLinkService(t)
+	registerVerifiedUser(t, s, mailer.testMailer, "jane@example.com", "correct horse")
+
+	tests := []struct {
+		name    string
+		age     time.Duration // how old the link is when followed
+		token   func(link string) string
+		wantErr error
+	}{
+		{name: "fresh link", token: func(link string) string { return link }},
+		{name: "just before expiry", age: models.DefaultMagicLinkDuration - time.Minute, token: func(link string) string { return link }},
+		{name: "expired link", age: models.DefaultMagicLinkDuration + time.Second, token: func(link string) string { return link }, wantErr: ErrInvalidToken},
+		{name: "made-up token", token: func(link string) string { return link + "x" }, wantErr: ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users.age = tt.age
+			if err := s.RequestMagicLink(ctx, "jane@example.com"); err != nil {
+				t.Fatalf("RequestMagicLink: %v", err)
+			}
+			link := mailer.link("jane@example.com")
+			if link == "" {
+				t.Fatal("no login link sent")
+			}
+
+			token := tt.token(link)
+			response, err := s.VerifyMagicLink(ctx, &models.MagicLinkVerifyRequest{Token: token})
+			if err != tt.wantErr {
+				t.Fatalf("VerifyMagicLink() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && response.AccessToken == "" {
+				t.Fatalf("VerifyMagicLink() = %+v, want tokens", response)
+			}
+
+			// Each link works once, whether or not it worked the first time
+			if _, err := s.VerifyMagicLink(ctx, &models.MagicLinkVerifyRequest{Token: token}); err != ErrInvalidToken {
+				t.Fatalf("second VerifyMagicLink() error = %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestAuthServiceMagicLinkRequest(t *testing.T) {
+	ctx := context.Background()
+	s, mailer, _ := newMagicLinkService(t)
+	registerVerifiedUser(t, s, mailer.testMailer, "jane@example.com", "correct horse")
+	disabled := registerVerifiedUser(t, s, mailer.testMailer, "john@example.com", "correct horse")
+	disabled.IsActive = false
+	if err := s.userRepo.UpdateUser(ctx, disabled); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+
+	// Nothing tells the caller which addresses have accounts
+	tests := []struct {
+		name     string
+		email    string
+		wantLink bool
+	}{
+		{name: "registered", email: "jane@example.com", wantLink: true},
+		{name: "unknown", email: "nobody@example.com"},
+		{name: "disabled", email: "john@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.RequestMagicLink(ctx, tt.email); err != nil {
+				t.Fatalf("RequestMagicLink() error = %v, want none", err)
+			}
+			if got := mailer.link(tt.email) != ""; got != tt.wantLink {
+				t.Fatalf("link sent = %v, want %v", got, tt.wantLink)
+			}
+		})
+	}
+}
+
+func TestAuthServiceMagicLinkLogin(t *testing.T) {
+	ctx := context.Background()
+	s, mailer, _ := newMagicLinkService(t)
+
+	follow := func(email string) (*models.AuthResponse, error) {
+		t.Helper()
+		if err := s.RequestMagicLink(ctx, email); err != nil {
+			t.Fatalf("RequestMagicLink: %v", err)
+		}
+		return s.VerifyMagicLink(ctx, &models.MagicLinkVerifyRequest{Token: mailer.link(email)})
+	}
+
+	// Following a link proves the address, so it verifies the account
+	if _, err := s.Register(ctx, &models.RegisterRequest{Email: "jane@example.com", Password: "correct horse", Name: "Jane"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	response, err := follow("jane@example.com")
+	if err != nil {
+		t.Fatalf("VerifyMagicLink() for an unverified account: %v", err)
+	}
+	if !response.User.IsVerified {
+		t.Fatal("account still unverified after following a login link")
+	}
+
+	// The link stands in for the password only
+	enableTOTP(t, s, response.User.ID)
+	var mfaErr *MFARequiredError
+	if _, err := follow("jane@example.com"); !errors.As(err, &mfaErr) {
+		t.Fatalf("VerifyMagicLink() with MFA error = %v, want an MFA challenge", err)
+	}
+
+	// Locked accounts can't get in by link either
+	for i := 0; i < models.MaxFailedLoginAttempts; i++ {
+		s.recordLoginFailure(ctx, response.User, "")
+	}
+	if _, err := follow("jane@example.com"); err != ErrAccountLocked {
+		t.Fatalf("VerifyMagicLink() while locked error = %v, want %v", err, ErrAccountLocked)
+	}
+}
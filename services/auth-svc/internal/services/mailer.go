@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/mail"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+// Mailer delivers the transactional emails sent by AuthService
+type Mailer interface {
+	SendVerificationEmail(ctx context.Context, user *models.User, token string) error
+	SendPasswordResetEmail(ctx context.Context, user *models.User, token string) error
+	SendPasswordChangedEmail(ctx context.Context, user *models.User, changedAt time.Time) error
+	SendNewLoginEmail(ctx context.Context, user *models.User, session *models.Session) error
+	SendAccountLockedEmail(ctx context.Context, user *models.User, lockedUntil time.Time) error
+	SendMagicLinkEmail(ctx context.Context, user *models.User, token string) error
+	SendEmailCode(ctx context.Context, user *models.User, purpose, code string) error
+}
+
+// LogMailer writes a line to the log instead of sending emails. Tokens are
+// never logged; use a mail.FileSender to read messages during development.
+type LogMailer struct{}
+
+// NewLogMailer creates a Mailer that only logs
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+func (m *LogMailer) SendVerificationEmail(ctx context.Context, user *models.User, token string) error {
+	return m.log(ctx, mail.TemplateVerification, user)
+}
+
+func (m *LogMailer) SendPasswordResetEmail(ctx context.Context, user *models.User, token string) error {
+	return m.log(ctx, mail.TemplatePasswordReset, user)
+}
+
+func (m *LogMailer) SendPasswordChangedEmail(ctx context.Context, user *models.User, changedAt time.Time) error {
+	return m.log(ctx, mail.TemplatePasswordChanged, user)
+}
+
+func (m *LogMailer) SendNewLoginEmail(ctx context.Context, user *models.User, session *models.Session) error {
+	return m.log(ctx, mail.TemplateNewLogin, user)
+}
+
+func (m *LogMailer) SendAccountLockedEmail(ctx context.Context, user *models.User, lockedUntil time.Time) error {
+	return m.log(ctx, mail.TemplateAccountLocked, user)
+}
+
+func (m *LogMailer) SendMagicLinkEmail(ctx context.Context, user *models.User, token string) error {
+	return m.log(ctx, mail.TemplateMagicLink, user)
+}
+
+func (m *LogMailer) SendEmailCode(ctx context.Context, user *models.User, purpose, code string) error {
+	return m.log(ctx, mail.TemplateEmailCode, user)
+}
+
+func (m *LogMailer) log(ctx context.Context, template string, user *models.User) error {
+	logger.InfoCtx(ctx, "Email would be sent",
+		zap.String("template", template),
+		zap.String("user_id", user.ID),
+	)
+	return nil
+}
+
+// MailerConfig holds the values TemplateMailer puts into every message
+type MailerConfig struct {
+	From        string
+	BaseURL     string // Frontend origin, e.g. https://multitask.com
+	ProductName string
+}
+
+// TemplateMailer renders the embedded templates and hands the result to a
+// mail.Sender (SMTP, SES or a local Maildir)
+type TemplateMailer struct {
+	sender    mail.Sender
+	templates *mail.Templates
+	cfg       MailerConfig
+}
+
+// NewTemplateMailer creates a Mailer that delivers through sender
+func NewTemplateMailer(sender mail.Sender, cfg MailerConfig) (*TemplateMailer, error) {
+	templates, err := mail.LoadTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+
+	return &TemplateMailer{
+		sender:    sender,
+		templates: templates,
+		cfg:       cfg,
+	}, nil
+}
+
+func (m *TemplateMailer) SendVerificationEmail(ctx context.Context, user *models.User, token string) error {
+	return m.send(ctx, mail.TemplateVerification, user, mail.TemplateData{
+		Link:      m.link("/verify-email", token),
+		ExpiresIn: formatDuration(models.DefaultVerifyTokenDuration),
+	})
+}
+
+func (m *TemplateMailer) SendPasswordResetEmail(ctx context.Context, user *models.User, token string) error {
+	return m.send(ctx, mail.TemplatePasswordReset, user, mail.TemplateData{
+		Link:      m.link("/reset-password", token),
+		ExpiresIn: formatDuration(models.DefaultResetTokenDuration),
+	})
+}
+
+func (m *TemplateMailer) SendPasswordChangedEmail(ctx context.Context, user *models.User, changedAt time.Time) error {
+	return m.send(ctx, mail.TemplatePasswordChanged, user, mail.TemplateData{
+		Link: m.link("/forgot-password", ""),
+		Time: formatTime(changedAt),
+	})
+}
+
+func (m *TemplateMailer) SendNewLoginEmail(ctx context.Context, user *models.User, session *models.Session) error {
+	return m.send(ctx, mail.TemplateNewLogin, user, mail.TemplateData{
+		Link:      m.link("/forgot-password", ""),
+		Time:      formatTime(session.CreatedAt),
+		IPAddress: valueOrUnknown(session.IPAddress),
+		UserAgent: valueOrUnknown(session.UserAgent),
+	})
+}
+
+func (m *TemplateMailer) SendAccountLockedEmail(ctx context.Context, user *models.User, lockedUntil time.Time) error {
+	return m.send(ctx, mail.TemplateAccountLocked, user, mail.TemplateData{
+		Link: m.link("/forgot-password", ""),
+		Time: formatTime(lockedUntil),
+	})
+}
+
+func (m *TemplateMailer) SendMagicLinkEmail(ctx context.Context, user *models.User, token string) error {
+	return m.send(ctx, mail.TemplateMagicLink, user, mail.TemplateData{
+		Link:      m.link("/login/magic-link", token),
+		ExpiresIn: formatDuration(models.DefaultMagicLinkDuration),
+	})
+}
+
+func (m *TemplateMailer) SendEmailCode(ctx context.Context, user *models.User, purpose, code string) error {
+	return m.send(ctx, mail.TemplateEmailCode, user, mail.TemplateData{
+		Code:      code,
+		Action:    emailCodeActions[purpose],
+		ExpiresIn: formatDuration(models.EmailOTPDuration),
+	})
+}
+
+// emailCodeActions finish the sentence "Enter this code to ..."
+var emailCodeActions = map[string]string{
+	models.EmailOTPPurposeVerify: "verify your email address",
+	models.EmailOTPPurposeReset:  "reset your password",
+	models.EmailOTPPurposeLogin:  "finish signing in",
+}
+
+func (m *TemplateMailer) send(ctx context.Context, template string, user *models.User, data mail.TemplateData) error {
+	data.Product = m.cfg.ProductName
+	data.Name = user.Name
+	if data.Name == "" {
+		data.Name = "there"
+	}
+
+	msg := &mail.Message{
+		From: m.cfg.From,
+		To:   []string{user.Email},
+	}
+	if err := m.templates.Render(template, data, msg); err != nil {
+		return err
+	}
+
+	if err := m.sender.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send %s email: %w", template, err)
+	}
+
+	logger.InfoCtx(ctx, "Email sent",
+		zap.String("template", template),
+		zap.String("user_id", user.ID),
+	)
+	return nil
+}
+
+// link builds a frontend URL, passing token as a query parameter when set
+func (m *TemplateMailer) link(path, token string) string {
+	if token == "" {
+		return m.cfg.BaseURL + path
+	}
+	return m.cfg.BaseURL + path + "?token=" + url.QueryEscape(token)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("Jan 2, 2006 at 15:04 MST")
+}
+
+func formatDuration(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		hours := int(d / time.Hour)
+		if hours == 1 {
+			return "1 hour"
+		}
+		return fmt.Sprintf("%d hours", hours)
+	}
+	return fmt.Sprintf("%d minutes", int(d/time.Minute))
+}
+
+func valueOrUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/mail"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+)
+
+// maildirMessage is a message read back from a Maildir
+type maildirMessage struct {
+	header netmail.Header
+	text   string
+	html   string
+}
+
+// readMaildir returns the only message delivered into dir, checking that it
+// was moved out of tmp/
+func readMaildir(t *testing.T, dir string) *maildirMessage {
+	t.Helper()
+
+	if tmp, err := os.ReadDir(filepath.Join(dir, "tmp")); err != nil || len(tmp) != 0 {
+		t.Fatalf("tmp/ = %v, %v; want it empty", tmp, err)
+	}
+	delivered, err := os.ReadDir(filepath.Join(dir, "new"))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(delivered) != 1 || !strings.HasSuffix(delivered[0].Name(), ".eml") {
+		t.Fatalf("new/ = %v, want one .eml file", delivered)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "new", delivered[0].Name()))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+
+	parsed, err := netmail.ReadMessage(f)
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q (%v), want multipart/alternative", parsed.Header.Get("Content-Type"), err)
+	}
+
+	msg := &maildirMessage{header: parsed.Header}
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("reading part: %v", err)
+		}
+
+		switch part.Header.Get("Content-Type") {
+		case "text/plain; charset=utf-8":
+			msg.text = string(body)
+		case "text/html; charset=utf-8":
+			msg.html = string(body)
+		default:
+			t.Fatalf("unexpected part %q", part.Header.Get("Content-Type"))
+		}
+	}
+	return msg
+}
+
+func TestTemplateMailer(t *testing.T) {
+	ctx := context.Background()
+	user := &models.User{ID: "user-1", Email: "jane@example.com", Name: "Jane <Doe>"}
+	at := time.Date(2026, 3, 14, 15, 9, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		send        func(m Mailer) error
+		wantSubject string
+		wantText    []string // in both bodies
+	}{
+		{
+			name:        "verification",
+			send:        func(m Mailer) error { return m.SendVerificationEmail(ctx, user, "token/1") },
+			wantSubject: "Verify your email address",
+			wantText:    []string{"https://multitask.com/verify-email?token=token%2F1", "24 hours"},
+		},
+		{
+			name:        "password reset",
+			send:        func(m Mailer) error { return m.SendPasswordResetEmail(ctx, user, "token-2") },
+			wantSubject: "Reset your password",
+			wantText:    []string{"https://multitask.com/reset-password?token=token-2", "1 hour"},
+		},
+		{
+			name:        "password changed",
+			send:        func(m Mailer) error { return m.SendPasswordChangedEmail(ctx, user, at) },
+			wantSubject: "Your password was changed",
+			wantText:    []string{"https://multitask.com/forgot-password", "Mar 14, 2026 at 15:09 UTC"},
+		},
+		{
+			name: "new login",
+			send: func(m Mailer) error {
+				return m.SendNewLoginEmail(ctx, user, &models.Session{CreatedAt: at, IPAddress: "192.0.2.1"})
+			},
+			wantSubject: "New sign-in to your account",
+			wantText:    []string{"https://multitask.com/forgot-password", "Mar 14, 2026 at 15:09 UTC", "192.0.2.1", "unknown"},
+		},
+		{
+			name:        "account locked",
+			send:        func(m Mailer) error { return m.SendAccountLockedEmail(ctx, user, at) },
+			wantSubject: "Your account was temporarily locked",
+			wantText:    []string{"https://multitask.com/forgot-password", "Mar 14, 2026 at 15:09 UTC"},
+		},
+		{
+			name:        "magic link",
+			send:        func(m Mailer) error { return m.SendMagicLinkEmail(ctx, user, "token-3") },
+			wantSubject: "Your sign-in link",
+			wantText:    []string{"https://multitask.com/login/magic-link?token=token-3", "15 minutes"},
+		},
+		{
+			name:        "email code",
+			send:        func(m Mailer) error { return m.SendEmailCode(ctx, user, models.EmailOTPPurposeReset, "123456") },
+			wantSubject: "123456 is your Multitask code",
+			wantText:    []string{"Enter this code to reset your password", "123456", "10 minutes"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			sender, err := mail.NewFileSender(dir)
+			if err != nil {
+				t.Fatalf("NewFileSender: %v", err)
+			}
+			m, err := NewTemplateMailer(sender, MailerConfig{
+				From:        "Multitask <noreply@multitask.com>",
+				BaseURL:     "https://multitask.com/",
+				ProductName: "Multitask",
+			})
+			if err != nil {
+				t.Fatalf("NewTemplateMailer: %v", err)
+			}
+
+			if err := tt.send(m); err != nil {
+				t.Fatalf("send: %v", err)
+			}
+			msg := readMaildir(t, dir)
+
+			if got := msg.header.Get("From"); got != "Multitask <noreply@multitask.com>" {
+				t.Errorf("From = %q", got)
+			}
+			if got := msg.header.Get("To"); got != user.Email {
+				t.Errorf("To = %q, want %q", got, user.Email)
+			}
+			subject, err := new(mime.WordDecoder).DecodeHeader(msg.header.Get("Subject"))
+			if err != nil || subject != tt.wantSubject {
+				t.Errorf("Subject = %q (%v), want %q", subject, err, tt.wantSubject)
+			}
+			if !strings.HasSuffix(msg.header.Get("Message-ID"), "@multitask.com>") {
+				t.Errorf("Message-ID = %q, want one in the sender's domain", msg.header.Get("Message-ID"))
+			}
+
+			// The name is escaped in HTML only
+			if !strings.Contains(msg.text, "Hi Jane <Doe>,") {
+				t.Errorf("text body does not greet the user:\n%s", msg.text)
+			}
+			if !strings.Contains(msg.html, "Hi Jane &lt;Doe&gt;,") {
+				t.Errorf("html body does not greet the user escaped:\n%s", msg.html)
+			}
+			for _, want := range tt.wantText {
+				if !strings.Contains(msg.text, want) {
+					t.Errorf("text body lacks %q:\n%s", want, msg.text)
+				}
+				if !strings.Contains(msg.html, want) {
+					t.Errorf("html body lacks %q:\n%s", want, msg.html)
+				}
+			}
+		})
+	}
+}
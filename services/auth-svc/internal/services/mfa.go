@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/repositories"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/totp"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/webauthn"
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+// MFARequiredError is returned by Login when the password was right but the
+// account has a second factor. Challenge goes back to the client, which
+// completes the login with LoginMFA.
+type MFARequiredError struct {
+	Challenge *models.MFAChallenge
+}
+
+func (e *MFARequiredError) Error() string {
+	return "mfa required"
+}
+
+// SetupTOTP starts a TOTP enrollment and returns the secret for the user's
+// authenticator app. It doesn't protect the account until confirmed with
+// VerifyTOTP; calling it again replaces an unconfirmed secret.
+func (s *AuthService) SetupTOTP(ctx context.Context, userID string) (*models.TOTPSetupResponse, error) {
+	user, err := s.userRepo.GetUser(ctx, userID)
+	if err != nil {
+		if err == repositories.ErrUserNotFound {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	// Bind the ciphertext to the user so it can't be copied to another account
+	sealed, err := s.secrets.Seal(secret, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt TOTP secret: %w", err)
+	}
+
+	err = s.userRepo.SaveTOTPEnrollment(ctx, &models.TOTPEnrollment{
+		UserID:    user.ID,
+		Secret:    sealed,
+		CreatedAt: s.now().UTC(),
+	})
+	if err != nil {
+		if err == repositories.ErrMFAEnabled {
+			return nil, ErrMFAAlreadyEnabled
+		}
+		return nil, fmt.Errorf("failed to save TOTP enrollment: %w", err)
+	}
+
+	logger.InfoCtx(ctx, "TOTP enrollment started", zap.String("user_id", user.ID))
+
+	return &models.TOTPSetupResponse{
+		Secret: secret,
+		URI:    totp.KeyURI(s.cfg.MFA.Issuer, user.Email, secret),
+	}, nil
+}
+
+// VerifyTOTP confirms a pending enrollment with a code from the
+// authenticator, after which logins require a code. It returns the user's
+// first set of recovery codes.
+func (s *AuthService) VerifyTOTP(ctx context.Context, userID, code string) (*models.RecoveryCodesResponse, error) {
+	enrollment, err := s.userRepo.GetTOTPEnrollment(ctx, userID)
+	if err != nil {
+		if err == repositories.ErrMFANotFound {
+			return nil, ErrMFANotEnrolled
+		}
+		return nil, fmt.Errorf("failed to get TOTP enrollment: %w", err)
+	}
+
+	if enrollment.IsConfirmed() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, err := s.validateTOTP(enrollment, code)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.userRepo.ConfirmTOTPEnrollment(ctx, userID, s.now().UTC(), step)
+	if err != nil {
+		if err == repositories.ErrMFAEnabled {
+			return nil, ErrMFAAlreadyEnabled
+		}
+		return nil, fmt.Errorf("failed to confirm TOTP enrollment: %w", err)
+	}
+
+	logger.InfoCtx(ctx, "TOTP enabled", zap.String("user_id", userID))
+
+	return s.issueRecoveryCodes(ctx, userID)
+}
+
+// LoginMFA completes a login started by Login with the challenge token and a
+// TOTP code, a passkey assertion, a recovery code or an emailed code. Wrong
+// codes count towards the same lockout as wrong passwords.
+func (s *AuthService) LoginMFA(ctx context.Context, req *models.MFALoginRequest) (*models.AuthResponse, error) {
+	if err := s.checkIPLock(ctx, req.IPAddress); err != nil {
+		return nil, err
+	}
+
+	claims, err := s.tokens.ParseMFAChallengeToken(req.MFAToken)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetUser(ctx, claims.UserID)
+	if err != nil {
+		if err == repositories.ErrUserNotFound {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if !user.IsActive {
+		return nil, ErrUserDisabled
+	}
+
+	if err := s.checkUserLock(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	secondFactor := models.AuthMethodOTP
+	switch {
+	case req.WebAuthn != nil:
+		secondFactor = models.AuthMethodHardwareKey
+		err = s.verifyMFAPasskey(ctx, user.ID, req.WebAuthn, claims.Challenge)
+		if err == ErrInvalidPasskey {
+			err = ErrInvalidMFACode
+		}
+	case req.RecoveryCode != "":
+		err = s.redeemRecoveryCode(ctx, user.ID, req.RecoveryCode)
+	case req.EmailCode != "":
+		secondFactor = models.AuthMethodEmail
+		err = ErrInvalidMFACode
+		if emailCodeAllowed(claims.AuthMethods) {
+			err = s.verifyEmailOTP(ctx, user.ID, models.EmailOTPPurposeLogin, req.EmailCode)
+			if err == ErrInvalidEmailCode {
+				err = ErrInvalidMFACode
+			}
+		}
+	default:
+		err = s.verifyMFACode(ctx, user.ID, req.Code)
+	}
+	if err == ErrInvalidMFACode {
+		if err := s.recordLoginFailure(ctx, user, req.IPAddress); err != ErrInvalidCredentials {
+			return nil, err
+		}
+		return nil, ErrInvalidMFACode
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.clearLoginFailures(ctx, user.ID)
+
+	return s.completeLogin(ctx, user, &models.SessionCreateRequest{
+		UserID:      user.ID,
+		DeviceID:    req.DeviceID,
+		UserAgent:   req.UserAgent,
+		IPAddress:   req.IPAddress,
+		AuthMethods: append(claims.AuthMethods, secondFactor, models.AuthMethodMFA),
+	})
+}
+
+// mfaStatus reports the second factors the user has enabled. TOTP turns MFA
+// on; passkeys and recovery codes are then offered alongside it.
+func (s *AuthService) mfaStatus(ctx context.Context, userID string) (*models.MFAStatus, error) {
+	status := &models.MFAStatus{Methods: []string{}}
+
+	enrollment, err := s.userRepo.GetTOTPEnrollment(ctx, userID)
+	switch {
+	case err == nil && enrollment.IsConfirmed():
+		status.Enabled = true
+		status.Methods = append(status.Methods, models.MFAMethodTOTP)
+	case err != nil && err != repositories.ErrMFANotFound:
+		return nil, fmt.Errorf("failed to get TOTP enrollment: %w", err)
+	}
+
+	if !status.Enabled {
+		return status, nil
+	}
+
+	credentials, err := s.credentials.GetUserCredentials(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get passkeys: %w", err)
+	}
+	if len(credentials) > 0 {
+		status.Methods = append(status.Methods, models.MFAMethodWebAuthn)
+	}
+
+	hashes, err := s.userRepo.GetRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recovery codes: %w", err)
+	}
+	status.RecoveryCodesRemaining = len(hashes)
+	if len(hashes) > 0 {
+		status.Methods = append(status.Methods, models.MFAMethodRecoveryCode)
+	}
+
+	return status, nil
+}
+
+// mfaChallenge builds the error Login returns for accounts with a second
+// factor. Users with passkeys also get WebAuthn options, whose challenge rides
+// along in the MFA token and is stored so it can only be answered once. A
+// code by email is offered unless the first step already relied on the
+// mailbox.
+func (s *AuthService) mfaChallenge(ctx context.Context, user *models.User, methods, authMethods []string) error {
+	if emailCodeAllowed(authMethods) {
+		methods = append(slices.Clone(methods), models.MFAMethodEmail)
+	}
+
+	challenge := &models.MFAChallenge{
+		MFARequired: true,
+		Methods:     methods,
+		ExpiresIn:   int64(models.MFAChallengeDuration.Seconds()),
+	}
+
+	var webauthnChallenge []byte
+	if slices.Contains(methods, models.MFAMethodWebAuthn) {
+		var err error
+		webauthnChallenge, err = webauthn.NewChallenge()
+		if err != nil {
+			return err
+		}
+		challenge.WebAuthn, err = s.passkeyOptions(ctx, user.ID, webauthnChallenge)
+		if err != nil {
+			return err
+		}
+		if err := s.saveChallenge(ctx, models.TokenTypeMFAChallenge, user.ID, webauthnChallenge); err != nil {
+			return err
+		}
+	}
+
+	token, err := s.tokens.GenerateMFAChallengeToken(user.ID, authMethods, webauthnChallenge)
+	if err != nil {
+		return fmt.Errorf("failed to generate MFA challenge: %w", err)
+	}
+	challenge.MFAToken = token
+
+	logger.InfoCtx(ctx, "MFA challenge issued", zap.String("user_id", user.ID))
+
+	return &MFARequiredError{Challenge: challenge}
+}
+
+// verifyMFACode checks a login code against the user's confirmed TOTP
+// enrollment and marks its time step used so it can't be replayed
+func (s *AuthService) verifyMFACode(ctx context.Context, userID, code string) error {
+	enrollment, err := s.userRepo.GetTOTPEnrollment(ctx, userID)
+	if err != nil {
+		if err == repositories.ErrMFANotFound {
+			return ErrInvalidMFACode
+		}
+		return fmt.Errorf("failed to get TOTP enrollment: %w", err)
+	}
+
+	if !enrollment.IsConfirmed() {
+		return ErrInvalidMFACode
+	}
+
+	step, err := s.validateTOTP(enrollment, code)
+	if err != nil {
+		return err
+	}
+
+	err = s.userRepo.UseTOTPStep(ctx, userID, step)
+	if err != nil {
+		if err == repositories.ErrCodeReused {
+			logger.WarnCtx(ctx, "TOTP code replayed", zap.String("user_id", userID))
+			return ErrInvalidMFACode
+		}
+		return fmt.Errorf("failed to record TOTP code use: %w", err)
+	}
+
+	return nil
+}
+
+// validateTOTP decrypts the enrollment's secret and checks code against it,
+// returning the code's time step
+func (s *AuthService) validateTOTP(enrollment *models.TOTPEnrollment, code string) (int64, error) {
+	secret, err := s.secrets.Open(enrollment.Secret, enrollment.UserID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decrypt TOTP secret: %w", err)
+	}
+
+	step, ok := totp.Validate(secret, code, s.now())
+	if !ok {
+		return 0, ErrInvalidMFACode
+	}
+	return step, nil
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/totp"
+)
+
+// totpCode returns the code for secret at time step step
+func totpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+
+	code, err := totp.Code(secret, step)
+	if err != nil {
+		t.Fatalf("Code: %v", err)
+	}
+	return code
+}
+
+// enableTOTP enrolls the user and confirms it with the current code,
+// returning the secret
+func enableTOTP(t *testing.T, s *AuthService, userID string) string {
+	t.Helper()
+	ctx := context.Background()
+
+	setup, err := s.SetupTOTP(ctx, userID)
+	if err != nil {
+		t.Fatalf("SetupTOTP: %v", err)
+	}
+	if _, err := s.VerifyTOTP(ctx, userID, totpCode(t, setup.Secret, totp.Step(s.now()))); err != nil {
+		t.Fatalf("VerifyTOTP: %v", err)
+	}
+	return setup.Secret
+}
+
+// mfaToken logs in with a password and returns the token for the second step
+func mfaToken(t *testing.T, s *AuthService, email, password string) string {
+	t.Helper()
+
+	_, err := s.Login(context.Background(), &models.LoginRequest{Email: email, Password: password})
+	var mfaErr *MFARequiredError
+	if !errors.As(err, &mfaErr) {
+		t.Fatalf("Login() error = %v, want an MFA challenge", err)
+	}
+	return mfaErr.Challenge.MFAToken
+}
+
+func TestAuthServiceTOTPEnrollment(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s, mailer := newTestService(t, newTestConfig(), WithClock(func() time.Time { return now }))
+	user := registerVerifiedUser(t, s, mailer, "jane@example.com", "correct horse")
+
+	if _, err := s.VerifyTOTP(ctx, user.ID, "123456"); err != ErrMFANotEnrolled {
+		t.Fatalf("VerifyTOTP() before setup error = %v, want %v", err, ErrMFANotEnrolled)
+	}
+
+	// A second setup replaces the unconfirmed secret
+	first, err := s.SetupTOTP(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("SetupTOTP: %v", err)
+	}
+	setup, err := s.SetupTOTP(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("SetupTOTP: %v", err)
+	}
+	if setup.Secret == first.Secret {
+		t.Fatal("SetupTOTP() returned the same secret twice")
+	}
+	if _, err := s.VerifyTOTP(ctx, user.ID, totpCode(t, first.Secret, totp.Step(now))); err != ErrInvalidMFACode {
+		t.Fatalf("VerifyTOTP() with the replaced secret error = %v, want %v", err, ErrInvalidMFACode)
+	}
+
+	// Until confirmed the password alone still logs in
+	if _, err := s.Login(ctx, &models.LoginRequest{Email: "jane@example.com", Password: "correct horse"}); err != nil {
+		t.Fatalf("Login() before confirmation: %v", err)
+	}
+
+	if _, err := s.VerifyTOTP(ctx, user.ID, totpCode(t, setup.Secret, totp.Step(now))); err != nil {
+		t.Fatalf("VerifyTOTP: %v", err)
+	}
+	if _, err := s.VerifyTOTP(ctx, user.ID, totpCode(t, setup.Secret, totp.Step(now))); err != ErrMFAAlreadyEnabled {
+		t.Fatalf("second VerifyTOTP() error = %v, want %v", err, ErrMFAAlreadyEnabled)
+	}
+	if _, err := s.SetupTOTP(ctx, user.ID); err != ErrMFAAlreadyEnabled {
+		t.Fatalf("SetupTOTP() once enabled error = %v, want %v", err, ErrMFAAlreadyEnabled)
+	}
+
+	got, err := s.GetUser(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if !got.MFA.Enabled {
+		t.Fatal("MFA not reported enabled after confirmation")
+	}
+}
+
+// TestAuthServiceLoginMFA runs logins in order against one enrollment. Each
+// accepted code uses up its time step and every step before it.
+func TestAuthServiceLoginMFA(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	s, mailer := newTestService(t, newTestConfig(), WithClock(func() time.Time { return now }))
+	registerVerifiedUser(t, s, mailer, "jane@example.com", "correct horse")
+	login, err := s.Login(ctx, &models.LoginRequest{Email: "jane@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	secret := enableTOTP(t, s, login.User.ID)
+	enrolled := totp.Step(start)
+
+	tests := []struct {
+		name    string
+		advance time.Duration // how far the clock moves first
+		step    int64         // relative to the enrollment's step
+		code    string        // used instead of the step's code when set
+		wantErr error
+	}{
+		{name: "code used to enroll", step: 0, wantErr: ErrInvalidMFACode},
+		{name: "next period", advance: totp.Period, step: 1},
+		{name: "same code again", step: 1, wantErr: ErrInvalidMFACode},
+		{name: "previous step within the skew", advance: totp.Period, step: 1, wantErr: ErrInvalidMFACode},
+		{name: "next step within the skew", step: 3},
+		{name: "current step after a later one", step: 2, wantErr: ErrInvalidMFACode},
+		{name: "beyond the skew", advance: 2 * totp.Period, step: 6, wantErr: ErrInvalidMFACode},
+		{name: "wrong code", code: "000000", wantErr: ErrInvalidMFACode},
+		{name: "after the backoff", advance: totp.Period, step: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.advance)
+			token := mfaToken(t, s, "jane@example.com", "correct horse")
+
+			code := tt.code
+			if code == "" {
+				code = totpCode(t, secret, enrolled+tt.step)
+			}
+			response, err := s.LoginMFA(ctx, &models.MFALoginRequest{MFAToken: token, Code: code})
+			if err != tt.wantErr {
+				t.Fatalf("LoginMFA() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && response.AccessToken == "" {
+				t.Fatalf("LoginMFA() = %+v, want tokens", response)
+			}
+		})
+	}
+
+	if _, err := s.LoginMFA(ctx, &models.MFALoginRequest{MFAToken: login.AccessToken, Code: totpCode(t, secret, totp.Step(now))}); err != ErrInvalidToken {
+		t.Fatalf("LoginMFA() with an access token error = %v, want %v", err, ErrInvalidToken)
+	}
+}
@@ -0,0 +1,831 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/oauth"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/repositories"
+	"github.com/multitask-platform/backend/shared/events"
+	"github.com/multitask-platform/backend/shared/logger"
+	"github.com/multitask-platform/backend/shared/revocation"
+)
+
+// The authorization server lets registered applications act for a user
+// (authorization code grant, RFC 6749 section 4.1) or for themselves (client
+// credentials, section 4.4). Users approve a client on the frontend's consent
+// page, which posts the decision back with the user's own token.
+//
+// Every client must use PKCE (RFC 7636) with S256, confidential or not. The
+// tokens a client gets carry its client_id and scopes and no roles, and
+// redeeming a code starts a session the user can see and revoke like any
+// other.
+
+// OAuth2 error codes (RFC 6749 sections 4.1.2.1 and 5.2)
+const (
+	OAuth2InvalidRequest          = "invalid_request"
+	OAuth2InvalidClient           = "invalid_client"
+	OAuth2InvalidGrant            = "invalid_grant"
+	OAuth2UnauthorizedClient      = "unauthorized_client"
+	OAuth2UnsupportedGrantType    = "unsupported_grant_type"
+	OAuth2UnsupportedResponseType = "unsupported_response_type"
+	OAuth2InvalidScope            = "invalid_scope"
+	OAuth2AccessDenied            = "access_denied"
+)
+
+// OAuth2Error is a failure reported to a client in the format RFC 6749 lays
+// out. With a RedirectURI the authorization endpoint reports it through the
+// user's browser instead of to the user.
+type OAuth2Error struct {
+	Code        string
+	Description string
+	RedirectURI string
+	State       string
+}
+
+func (e *OAuth2Error) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// RedirectURL is where to send the browser to report the error to the client
+func (e *OAuth2Error) RedirectURL() string {
+	params := map[string]string{
+		"error":             e.Code,
+		"error_description": e.Description,
+	}
+	if e.State != "" {
+		params["state"] = e.State
+	}
+	return withQuery(e.RedirectURI, params)
+}
+
+func oauth2Error(code, description string) *OAuth2Error {
+	return &OAuth2Error{Code: code, Description: description}
+}
+
+// RegisterClient registers an OAuth2 client owned by the user. The secret of
+// a confidential client is returned once and only its hash is kept.
+func (s *AuthService) RegisterClient(ctx context.Context, ownerID string, isAdmin bool, req *models.RegisterClientRequest) (*models.RegisterClientResponse, error) {
+	if req.FirstParty && !isAdmin {
+		return nil, ErrFirstPartyClient
+	}
+	if req.Introspect && !isAdmin {
+		return nil, ErrIntrospectingClient
+	}
+
+	grantTypes := req.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = []string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken}
+	}
+	grantTypes = dedupe(grantTypes)
+
+	switch {
+	case req.Type == models.ClientTypePublic && slices.Contains(grantTypes, models.GrantTypeClientCredentials):
+		// A public client has nothing to prove who it is with
+		return nil, ErrInvalidGrantTypes
+	case slices.Contains(grantTypes, models.GrantTypeRefreshToken) && !slices.Contains(grantTypes, models.GrantTypeAuthorizationCode):
+		return nil, ErrInvalidGrantTypes
+	case slices.Contains(grantTypes, models.GrantTypeAuthorizationCode) && len(req.RedirectURIs) == 0:
+		return nil, ErrInvalidRedirectURI
+	}
+
+	for _, redirectURI := range req.RedirectURIs {
+		if !validRedirectURI(redirectURI, req.Type) {
+			return nil, ErrInvalidRedirectURI
+		}
+	}
+
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = s.cfg.OAuth2.Scopes
+	}
+	scopes = dedupe(scopes)
+	for _, scope := range scopes {
+		if !slices.Contains(s.cfg.OAuth2.Scopes, scope) {
+			return nil, ErrUnsupportedScope
+		}
+	}
+
+	client := &models.OAuthClient{
+		ID:           s.newID(),
+		Name:         req.Name,
+		Type:         req.Type,
+		RedirectURIs: dedupe(req.RedirectURIs),
+		GrantTypes:   grantTypes,
+		Scopes:       scopes,
+		FirstParty:   req.FirstParty,
+		Introspect:   req.Introspect,
+		OwnerID:      ownerID,
+		CreatedAt:    s.now().UTC(),
+	}
+
+	var secret string
+	if client.IsConfidential() {
+		var err error
+		secret, err = s.generateSecureToken()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate client secret: %w", err)
+		}
+		client.SecretHash = hashClientSecret(secret)
+	}
+
+	err := s.clients.CreateClient(ctx, client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+
+	logger.LogSecurityEvent(ctx, "oauth2_client_registered",
+		zap.String("user_id", ownerID),
+		zap.String("client_id", client.ID),
+		zap.Bool("first_party", client.FirstParty),
+		zap.Bool("introspect", client.Introspect),
+	)
+
+	return &models.RegisterClientResponse{
+		OAuthClient:  client,
+		ClientSecret: secret,
+	}, nil
+}
+
+// GetUserClients returns the OAuth2 clients the user registered
+func (s *AuthService) GetUserClients(ctx context.Context, ownerID string) ([]*models.OAuthClient, error) {
+	clients, err := s.clients.GetUserClients(ctx, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clients: %w", err)
+	}
+	return clients, nil
+}
+
+// DeleteClient removes one of the user's OAuth2 clients. Its refresh tokens
+// stop working at once, as the client can no longer authenticate; access
+// tokens already issued run out on their own.
+func (s *AuthService) DeleteClient(ctx context.Context, ownerID, clientID string) error {
+	err := s.clients.DeleteClient(ctx, ownerID, clientID)
+	if err != nil {
+		if err == repositories.ErrClientNotFound {
+			return ErrClientNotFound
+		}
+		return fmt.Errorf("failed to delete client: %w", err)
+	}
+
+	logger.LogSecurityEvent(ctx, "oauth2_client_deleted",
+		zap.String("user_id", ownerID),
+		zap.String("client_id", clientID),
+	)
+
+	return nil
+}
+
+// AuthorizationRedirect validates an authorization request as it arrives from
+// the client and says where to send the browser: on to the consent page with
+// the request's parameters, or back to the client with an error
+func (s *AuthService) AuthorizationRedirect(ctx context.Context, req *models.AuthorizationRequest) (string, error) {
+	_, err := s.checkAuthorizationRequest(ctx, req)
+	var oauthErr *OAuth2Error
+	if errors.As(err, &oauthErr) && oauthErr.RedirectURI != "" {
+		return oauthErr.RedirectURL(), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	params := map[string]string{
+		"response_type":         req.ResponseType,
+		"client_id":             req.ClientID,
+		"code_challenge":        req.CodeChallenge,
+		"code_challenge_method": req.CodeChallengeMethod,
+	}
+	for key, value := range map[string]string{"redirect_uri": req.RedirectURI, "scope": req.Scope, "state": req.State, "nonce": req.Nonce} {
+		if value != "" {
+			params[key] = value
+		}
+	}
+	return withQuery(s.cfg.OAuth2.ConsentURL, params), nil
+}
+
+// Authorize records the decision of the user signed in to sessionID on an
+// authorization request. Without a decision it says whether the consent page
+// has to ask; first-party clients are approved straight away. Errors the
+// client should hear about come back as a redirect.
+func (s *AuthService) Authorize(ctx context.Context, userID, sessionID string, req *models.AuthorizationRequest) (*models.AuthorizationResponse, error) {
+	grant, err := s.checkAuthorizationRequest(ctx, req)
+	var oauthErr *OAuth2Error
+	if errors.As(err, &oauthErr) && oauthErr.RedirectURI != "" {
+		return &models.AuthorizationResponse{RedirectTo: oauthErr.RedirectURL()}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.Decision {
+	case "":
+		if !grant.client.FirstParty {
+			return &models.AuthorizationResponse{
+				ConsentRequired: true,
+				Client:          grant.client,
+				Scopes:          grant.scopes,
+			}, nil
+		}
+	case models.DecisionApprove:
+	case models.DecisionDeny:
+		logger.InfoCtx(ctx, "Authorization request denied",
+			zap.String("user_id", userID),
+			zap.String("client_id", grant.client.ID),
+		)
+		return &models.AuthorizationResponse{RedirectTo: grant.fail(OAuth2AccessDenied, "the user denied the request").RedirectURL()}, nil
+	default:
+		return nil, oauth2Error(OAuth2InvalidRequest, "decision must be approve or deny")
+	}
+
+	user, err := s.userRepo.GetUser(ctx, userID)
+	if err != nil {
+		if err == repositories.ErrUserNotFound {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.IsActive {
+		return nil, ErrUserDisabled
+	}
+
+	// The client learns how and when the user signed in from the ID token
+	session, err := s.sessionRepo.GetSession(ctx, sessionID)
+	if err != nil {
+		if err == repositories.ErrSessionNotFound {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session.UserID != user.ID || !session.IsActive {
+		return nil, ErrSessionNotFound
+	}
+
+	code, err := s.generateSecureToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate authorization code: %w", err)
+	}
+
+	err = s.clients.SaveAuthorizationCode(ctx, &models.AuthorizationCode{
+		Code:          code,
+		ClientID:      grant.client.ID,
+		UserID:        user.ID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        grant.scopes,
+		CodeChallenge: req.CodeChallenge,
+		SessionID:     s.newID(),
+		Nonce:         req.Nonce,
+		AuthTime:      sessionAuthTime(session),
+		AuthMethods:   session.AuthMethods,
+		ExpiresAt:     s.now().UTC().Add(models.AuthorizationCodeDuration),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save authorization code: %w", err)
+	}
+
+	logger.LogSecurityEvent(ctx, "oauth2_client_authorized",
+		zap.String("user_id", user.ID),
+		zap.String("client_id", grant.client.ID),
+		zap.Strings("scopes", grant.scopes),
+	)
+
+	params := map[string]string{"code": code}
+	if req.State != "" {
+		params["state"] = req.State
+	}
+	return &models.AuthorizationResponse{RedirectTo: withQuery(grant.redirectURI, params)}, nil
+}
+
+// authorizationGrant is what a valid authorization request asks for
+type authorizationGrant struct {
+	client      *models.OAuthClient
+	redirectURI string
+	scopes      []string
+	state       string
+}
+
+// fail builds an error reported back to the client's redirect URI
+func (g *authorizationGrant) fail(code, description string) *OAuth2Error {
+	return &OAuth2Error{
+		Code:        code,
+		Description: description,
+		RedirectURI: g.redirectURI,
+		State:       g.state,
+	}
+}
+
+func (s *AuthService) checkAuthorizationRequest(ctx context.Context, req *models.AuthorizationRequest) (*authorizationGrant, error) {
+	// Until the redirect URI is known to be the client's, errors are shown to
+	// the user rather than sent anywhere (RFC 6749 section 4.1.2.1)
+	if req.ClientID == "" {
+		return nil, oauth2Error(OAuth2InvalidRequest, "client_id is required")
+	}
+
+	client, err := s.clients.GetClient(ctx, req.ClientID)
+	if err != nil {
+		if err == repositories.ErrClientNotFound {
+			return nil, oauth2Error(OAuth2InvalidRequest, "unknown client_id")
+		}
+		return nil, fmt.Errorf("failed to get client: %w", err)
+	}
+
+	redirectURI := req.RedirectURI
+	switch {
+	case redirectURI == "" && len(client.RedirectURIs) == 1:
+		redirectURI = client.RedirectURIs[0]
+	case !slices.Contains(client.RedirectURIs, redirectURI):
+		return nil, oauth2Error(OAuth2InvalidRequest, "redirect_uri is not registered for the client")
+	}
+
+	grant := &authorizationGrant{
+		client:      client,
+		redirectURI: redirectURI,
+		state:       req.State,
+	}
+
+	switch {
+	case req.ResponseType != "code":
+		return nil, grant.fail(OAuth2UnsupportedResponseType, "response_type must be code")
+	case !client.AllowsGrant(models.GrantTypeAuthorizationCode):
+		return nil, grant.fail(OAuth2UnauthorizedClient, "the client may not use the authorization code grant")
+	case req.CodeChallengeMethod != "S256" || len(req.CodeChallenge) != 43:
+		return nil, grant.fail(OAuth2InvalidRequest, "a code_challenge with code_challenge_method S256 is required")
+	}
+
+	grant.scopes, err = resolveScopes(req.Scope, client.Scopes)
+	if err != nil {
+		return nil, grant.fail(OAuth2InvalidScope, err.Error())
+	}
+
+	return grant, nil
+}
+
+// Token handles a token endpoint request from the client with the given
+// credentials (RFC 6749 section 3.2)
+func (s *AuthService) Token(ctx context.Context, clientID, clientSecret string, req *models.TokenRequest) (*models.TokenResponse, error) {
+	client, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.GrantType {
+	case "":
+		return nil, oauth2Error(OAuth2InvalidRequest, "grant_type is required")
+	case models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken, models.GrantTypeClientCredentials:
+	default:
+		return nil, oauth2Error(OAuth2UnsupportedGrantType, "grant_type "+req.GrantType+" is not supported")
+	}
+
+	if !client.AllowsGrant(req.GrantType) {
+		return nil, oauth2Error(OAuth2UnauthorizedClient, "the client may not use this grant type")
+	}
+
+	switch req.GrantType {
+	case models.GrantTypeAuthorizationCode:
+		return s.redeemAuthorizationCode(ctx, client, req)
+	case models.GrantTypeRefreshToken:
+		return s.refreshClientToken(ctx, client, req)
+	default:
+		return s.issueClientCredentialsToken(ctx, client, req)
+	}
+}
+
+// redeemAuthorizationCode exchanges a code for tokens (RFC 6749 section
+// 4.1.3). A code presented twice was intercepted or replayed, so the session
+// the first redemption started is revoked (section 4.1.2).
+func (s *AuthService) redeemAuthorizationCode(ctx context.Context, client *models.OAuthClient, req *models.TokenRequest) (*models.TokenResponse, error) {
+	if req.Code == "" || req.CodeVerifier == "" {
+		return nil, oauth2Error(OAuth2InvalidRequest, "code and code_verifier are required")
+	}
+
+	code, err := s.clients.ConsumeAuthorizationCode(ctx, req.Code)
+	switch {
+	case err == repositories.ErrCodeReused:
+		s.revokeReusedCode(ctx, code)
+		return nil, oauth2Error(OAuth2InvalidGrant, "authorization code already used")
+	case err == repositories.ErrTokenNotFound || err == repositories.ErrTokenExpired:
+		return nil, oauth2Error(OAuth2InvalidGrant, "invalid or expired authorization code")
+	case err != nil:
+		return nil, fmt.Errorf("failed to consume authorization code: %w", err)
+	}
+
+	challenge := oauth.CodeChallenge(req.CodeVerifier)
+	switch {
+	case code.ClientID != client.ID:
+		return nil, oauth2Error(OAuth2InvalidGrant, "authorization code was issued to another client")
+	case code.RedirectURI != "" && req.RedirectURI != code.RedirectURI:
+		return nil, oauth2Error(OAuth2InvalidGrant, "redirect_uri does not match the authorization request")
+	case subtle.ConstantTimeCompare([]byte(challenge), []byte(code.CodeChallenge)) != 1:
+		return nil, oauth2Error(OAuth2InvalidGrant, "code_verifier does not match the code challenge")
+	}
+
+	user, err := s.userRepo.GetUser(ctx, code.UserID)
+	if err != nil {
+		if err == repositories.ErrUserNotFound {
+			return nil, oauth2Error(OAuth2InvalidGrant, "the user no longer exists")
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.IsActive {
+		return nil, oauth2Error(OAuth2InvalidGrant, "the user's account is disabled")
+	}
+
+	session, err := s.createSession(ctx, &models.SessionCreateRequest{
+		SessionID:   code.SessionID,
+		UserID:      user.ID,
+		ClientID:    client.ID,
+		Scopes:      code.Scopes,
+		UserAgent:   req.UserAgent,
+		IPAddress:   req.IPAddress,
+		AuthTime:    code.AuthTime,
+		AuthMethods: code.AuthMethods,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.InfoCtx(ctx, "Authorization code redeemed",
+		zap.String("user_id", user.ID),
+		zap.String("client_id", client.ID),
+		zap.String("session_id", session.ID),
+	)
+
+	return s.delegatedTokens(user, session, client, session.RefreshTokenID, code.Nonce)
+}
+
+// refreshClientToken rotates a client's refresh token (RFC 6749 section 6).
+// The new tokens keep the scopes the user approved, and a new ID token keeps
+// the original login's auth_time but has no nonce.
+func (s *AuthService) refreshClientToken(ctx context.Context, client *models.OAuthClient, req *models.TokenRequest) (*models.TokenResponse, error) {
+	if req.RefreshToken == "" {
+		return nil, oauth2Error(OAuth2InvalidRequest, "refresh_token is required")
+	}
+
+	user, session, nextTokenID, err := s.rotateRefreshToken(ctx, req.RefreshToken, client.ID)
+	if err != nil {
+		switch err {
+		case ErrInvalidToken, ErrTokenExpired, ErrRefreshTokenReused, ErrUserDisabled:
+			return nil, oauth2Error(OAuth2InvalidGrant, "invalid or expired refresh token")
+		default:
+			return nil, err
+		}
+	}
+
+	return s.delegatedTokens(user, session, client, nextTokenID, "")
+}
+
+// issueClientCredentialsToken gives a confidential client an access token for
+// itself (RFC 6749 section 4.4). There is no refresh token; the client just
+// asks again.
+func (s *AuthService) issueClientCredentialsToken(ctx context.Context, client *models.OAuthClient, req *models.TokenRequest) (*models.TokenResponse, error) {
+	if !client.IsConfidential() {
+		return nil, oauth2Error(OAuth2UnauthorizedClient, "public clients may not use client credentials")
+	}
+
+	// There's no user for an ID token to be about
+	allowed := slices.DeleteFunc(slices.Clone(client.Scopes), func(scope string) bool {
+		return scope == models.ScopeOpenID
+	})
+	scopes, err := resolveScopes(req.Scope, allowed)
+	if err != nil {
+		return nil, oauth2Error(OAuth2InvalidScope, err.Error())
+	}
+
+	accessToken, err := s.tokens.GenerateClientAccessToken(client.ID, scopes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	logger.InfoCtx(ctx, "Client credentials token issued", zap.String("client_id", client.ID))
+
+	return &models.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(models.DefaultSessionDuration.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
+
+// delegatedTokens signs the tokens a client holds for a user's session, with
+// an ID token if the user granted the openid scope
+func (s *AuthService) delegatedTokens(user *models.User, session *models.Session, client *models.OAuthClient, refreshTokenID, nonce string) (*models.TokenResponse, error) {
+	accessToken, err := s.tokens.GenerateScopedAccessToken(user, session.ID, client.ID, session.Scopes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	response := &models.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(models.DefaultSessionDuration.Seconds()),
+		Scope:       strings.Join(session.Scopes, " "),
+	}
+
+	if client.AllowsGrant(models.GrantTypeRefreshToken) {
+		response.RefreshToken, err = s.tokens.GenerateRefreshToken(user.ID, session.ID, refreshTokenID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+		}
+	}
+
+	if slices.Contains(session.Scopes, models.ScopeOpenID) {
+		response.IDToken, err = s.tokens.GenerateIDToken(&models.IDTokenClaims{
+			UserInfo:         userInfo(user, session.Scopes),
+			Issuer:           s.cfg.OAuth2.Issuer,
+			Audience:         client.ID,
+			AuthTime:         sessionAuthTime(session),
+			Nonce:            nonce,
+			AuthMethods:      session.AuthMethods,
+			AuthContextClass: authContextClass(session.AuthMethods),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate ID token: %w", err)
+		}
+	}
+
+	return response, nil
+}
+
+// revokeReusedCode ends the session a replayed authorization code was first
+// redeemed for
+func (s *AuthService) revokeReusedCode(ctx context.Context, code *models.AuthorizationCode) {
+	logger.LogSecurityEvent(ctx, "authorization_code_reuse",
+		zap.String("user_id", code.UserID),
+		zap.String("client_id", code.ClientID),
+		zap.String("session_id", code.SessionID),
+	)
+
+	err := s.sessionRepo.DeactivateSession(ctx, code.SessionID, s.outboxEvents(ctx, events.SessionRevoked{
+		UserID:    code.UserID,
+		SessionID: code.SessionID,
+		Reason:    events.RevokeReasonTokenReuse,
+	})...)
+	if err != nil && err != repositories.ErrSessionNotFound {
+		logger.ErrorCtx(ctx, "Failed to revoke session after authorization code reuse",
+			zap.String("session_id", code.SessionID),
+			zap.Error(err),
+		)
+	}
+	s.revokeSessionTokens(ctx, code.SessionID)
+}
+
+// IntrospectToken tells a confidential client whether a token is active and
+// what it grants (RFC 7662). Clients only learn about tokens issued to them;
+// tokens from the platform's own logins need the introspect permission.
+// Anything else is reported inactive.
+func (s *AuthService) IntrospectToken(ctx context.Context, clientID, clientSecret, token string) (*models.IntrospectionResponse, error) {
+	client, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if !client.IsConfidential() {
+		return nil, oauth2Error(OAuth2UnauthorizedClient, "introspection requires a confidential client")
+	}
+
+	inactive := &models.IntrospectionResponse{Active: false}
+
+	claims, err := s.tokens.ParseToken(token)
+	if err != nil {
+		return inactive, nil
+	}
+
+	response := &models.IntrospectionResponse{
+		Active:    true,
+		ClientID:  claims.ClientID,
+		Subject:   claims.UserID,
+		ExpiresAt: claims.ExpiresAt,
+		IssuedAt:  claims.IssuedAt,
+		TokenID:   claims.TokenID,
+		Scope:     strings.Join(claims.Scopes, " "),
+	}
+
+	if claims.Type == models.TokenTypeRefresh {
+		// Refresh tokens are only good until rotated
+		session, err := s.sessionRepo.GetSession(ctx, claims.SessionID)
+		if err != nil {
+			if err == repositories.ErrSessionNotFound {
+				return inactive, nil
+			}
+			return nil, fmt.Errorf("failed to get session: %w", err)
+		}
+		if !session.IsActive || s.now().After(session.ExpiresAt) || session.RefreshTokenID != claims.TokenID {
+			return inactive, nil
+		}
+		if !s.mayIntrospect(ctx, client, session.ClientID) {
+			return inactive, nil
+		}
+
+		response.TokenType = "refresh_token"
+		response.ClientID = session.ClientID
+		response.Scope = strings.Join(session.Scopes, " ")
+		return response, nil
+	}
+
+	if !s.mayIntrospect(ctx, client, claims.ClientID) {
+		return inactive, nil
+	}
+
+	if s.revocations != nil {
+		revoked, err := s.revocations.IsRevoked(ctx, revocation.Token{
+			UserID:    claims.UserID,
+			SessionID: claims.SessionID,
+			TokenID:   claims.TokenID,
+			IssuedAt:  time.Unix(claims.IssuedAt, 0),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to check revocation: %w", err)
+		}
+		if revoked {
+			return inactive, nil
+		}
+	}
+
+	if claims.SessionID != "" {
+		session, err := s.sessionRepo.GetSession(ctx, claims.SessionID)
+		if err != nil {
+			if err == repositories.ErrSessionNotFound {
+				return inactive, nil
+			}
+			return nil, fmt.Errorf("failed to get session: %w", err)
+		}
+		if !session.IsActive {
+			return inactive, nil
+		}
+	}
+
+	response.TokenType = "access_token"
+	return response, nil
+}
+
+// mayIntrospect reports whether client may see a token issued to owner, the
+// client ID the token carries or empty for the platform's own logins
+func (s *AuthService) mayIntrospect(ctx context.Context, client *models.OAuthClient, owner string) bool {
+	if owner == client.ID || (owner == "" && client.Introspect) {
+		return true
+	}
+	logger.WarnCtx(ctx, "Client tried to introspect a token it may not see",
+		zap.String("client_id", client.ID),
+		zap.String("token_client_id", owner),
+	)
+	return false
+}
+
+// RevokeToken lets a client give up one of its tokens (RFC 7009). Revoking a
+// refresh token ends the whole session. Tokens that are invalid or belong to
+// another client are ignored, as the RFC asks.
+func (s *AuthService) RevokeToken(ctx context.Context, clientID, clientSecret, token string) error {
+	client, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return err
+	}
+
+	claims, err := s.tokens.ParseToken(token)
+	if err != nil {
+		return nil
+	}
+
+	if claims.Type == models.TokenTypeRefresh {
+		session, err := s.sessionRepo.GetSession(ctx, claims.SessionID)
+		if err != nil {
+			if err == repositories.ErrSessionNotFound {
+				return nil
+			}
+			return fmt.Errorf("failed to get session: %w", err)
+		}
+		if session.ClientID != client.ID {
+			logger.WarnCtx(ctx, "Client tried to revoke another client's token", zap.String("client_id", client.ID))
+			return nil
+		}
+
+		err = s.sessionRepo.DeactivateSession(ctx, session.ID, s.outboxEvents(ctx, events.SessionRevoked{
+			UserID:    session.UserID,
+			SessionID: session.ID,
+			Reason:    events.RevokeReasonRevoked,
+		})...)
+		if err != nil && err != repositories.ErrSessionNotFound {
+			return fmt.Errorf("failed to deactivate session: %w", err)
+		}
+		s.revokeSessionTokens(ctx, session.ID)
+	} else {
+		if claims.ClientID != client.ID {
+			logger.WarnCtx(ctx, "Client tried to revoke another client's token", zap.String("client_id", client.ID))
+			return nil
+		}
+		s.revoke(ctx, revocation.TokenKey(claims.TokenID))
+	}
+
+	logger.LogSecurityEvent(ctx, "oauth2_token_revoked",
+		zap.String("client_id", client.ID),
+		zap.String("token_type", claims.Type),
+		zap.String("session_id", claims.SessionID),
+	)
+
+	return nil
+}
+
+// authenticateClient checks the credentials a client presented. Public
+// clients only identify themselves.
+func (s *AuthService) authenticateClient(ctx context.Context, clientID, clientSecret string) (*models.OAuthClient, error) {
+	if clientID == "" {
+		return nil, oauth2Error(OAuth2InvalidClient, "client authentication is required")
+	}
+
+	client, err := s.clients.GetClient(ctx, clientID)
+	if err != nil {
+		if err == repositories.ErrClientNotFound {
+			return nil, oauth2Error(OAuth2InvalidClient, "client authentication failed")
+		}
+		return nil, fmt.Errorf("failed to get client: %w", err)
+	}
+
+	if client.IsConfidential() {
+		hash := hashClientSecret(clientSecret)
+		if clientSecret == "" || subtle.ConstantTimeCompare([]byte(hash), []byte(client.SecretHash)) != 1 {
+			logger.LogSecurityEvent(ctx, "oauth2_client_auth_failed", zap.String("client_id", clientID))
+			return nil, oauth2Error(OAuth2InvalidClient, "client authentication failed")
+		}
+	}
+
+	return client, nil
+}
+
+// resolveScopes parses a space-separated scope parameter, which may only ask
+// for allowed scopes. Leaving it out asks for all of them.
+func resolveScopes(scope string, allowed []string) ([]string, error) {
+	requested := strings.Fields(scope)
+	if len(requested) == 0 {
+		return slices.Clone(allowed), nil
+	}
+
+	for _, s := range requested {
+		if !slices.Contains(allowed, s) {
+			return nil, fmt.Errorf("scope %q is not allowed for the client", s)
+		}
+	}
+	return dedupe(requested), nil
+}
+
+// validRedirectURI applies RFC 6749 section 3.1.2 and RFC 8252 section 7:
+// https anywhere, plain http only back to the same machine, and private-use
+// schemes such as com.example.app:/callback for native (public) clients
+func validRedirectURI(raw, clientType string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Fragment != "" {
+		return false
+	}
+
+	switch u.Scheme {
+	case "https":
+		return u.Host != ""
+	case "http":
+		host := u.Hostname()
+		ip := net.ParseIP(host)
+		return host == "localhost" || (ip != nil && ip.IsLoopback())
+	default:
+		return clientType == models.ClientTypePublic && strings.Contains(u.Scheme, ".")
+	}
+}
+
+// withQuery adds params to the query of a redirect URI, keeping any it
+// already has
+func withQuery(rawURL string, params map[string]string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	q := u.Query()
+	for key, value := range params {
+		q.Set(key, value)
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+func hashClientSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// dedupe drops repeated values, keeping the first of each
+func dedupe(values []string) []string {
+	var unique []string
+	for _, v := range values {
+		if !slices.Contains(unique, v) {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
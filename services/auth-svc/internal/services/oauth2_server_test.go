@@ -0,0 +1,402 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/oauth"
+)
+
+const (
+	testRedirectURI  = "https://client.example.com/callback"
+	testCodeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+// oauth2Fixture is a signed-in user and the service they use
+type oauth2Fixture struct {
+	s         *AuthService
+	login     *models.AuthResponse
+	sessionID string
+}
+
+func newOAuth2Fixture(t *testing.T) *oauth2Fixture {
+	t.Helper()
+	ctx := context.Background()
+
+	s, mailer := newTestService(t, newTestConfig())
+	registerVerifiedUser(t, s, mailer, "jane@example.com", "correct horse")
+	login, err := s.Login(ctx, &models.LoginRequest{Email: "jane@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	claims, err := s.tokens.ParseToken(login.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseToken: %v", err)
+	}
+
+	return &oauth2Fixture{s: s, login: login, sessionID: claims.SessionID}
+}
+
+// registerClient registers a client owned by the signed-in user
+func (f *oauth2Fixture) registerClient(t *testing.T, isAdmin bool, req models.RegisterClientRequest) *models.RegisterClientResponse {
+	t.Helper()
+
+	if req.Name == "" {
+		req.Name = "Test Client"
+	}
+	if req.Type == "" {
+		req.Type = models.ClientTypeConfidential
+	}
+	if req.RedirectURIs == nil {
+		req.RedirectURIs = []string{testRedirectURI}
+	}
+
+	client, err := f.s.RegisterClient(context.Background(), f.login.User.ID, isAdmin, &req)
+	if err != nil {
+		t.Fatalf("RegisterClient: %v", err)
+	}
+	return client
+}
+
+// authorize approves client for the signed-in user and returns the code
+func (f *oauth2Fixture) authorize(t *testing.T, clientID, scope string) string {
+	t.Helper()
+
+	resp, err := f.s.Authorize(context.Background(), f.login.User.ID, f.sessionID, &models.AuthorizationRequest{
+		ResponseType:        "code",
+		ClientID:            clientID,
+		RedirectURI:         testRedirectURI,
+		Scope:               scope,
+		State:               "state-1",
+		CodeChallenge:       oauth.CodeChallenge(testCodeVerifier),
+		CodeChallengeMethod: "S256",
+		Nonce:               "nonce-1",
+		Decision:            models.DecisionApprove,
+	})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+
+	redirect, err := url.Parse(resp.RedirectTo)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if redirect.Query().Get("state") != "state-1" || redirect.Query().Get("code") == "" {
+		t.Fatalf("Authorize() redirected to %s, want a code and the state", resp.RedirectTo)
+	}
+	return redirect.Query().Get("code")
+}
+
+// redeem exchanges a code the way a well-behaved client would
+func (f *oauth2Fixture) redeem(t *testing.T, client *models.RegisterClientResponse, code string) *models.TokenResponse {
+	t.Helper()
+
+	tokens, err := f.s.Token(context.Background(), client.ID, client.ClientSecret, &models.TokenRequest{
+		GrantType:    models.GrantTypeAuthorizationCode,
+		Code:         code,
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: testCodeVerifier,
+	})
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	return tokens
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+// oauth2ErrorCode returns the RFC 6749 error code of err, or "" if it has none
+func oauth2ErrorCode(err error) string {
+	var oauthErr *OAuth2Error
+	if errors.As(err, &oauthErr) {
+		return oauthErr.Code
+	}
+	return ""
+}
+
+func TestAuthServiceAuthorizationCodeGrant(t *testing.T) {
+	ctx := context.Background()
+	f := newOAuth2Fixture(t)
+	client := f.registerClient(t, false, models.RegisterClientRequest{})
+	other := f.registerClient(t, false, models.RegisterClientRequest{})
+
+	tests := []struct {
+		name     string
+		clientID *string // defaults to the client the code was issued to
+		secret   *string
+		modify   func(req *models.TokenRequest)
+		wantCode string // OAuth2 error code, empty for success
+	}{
+		{name: "valid"},
+		{name: "wrong verifier", modify: func(req *models.TokenRequest) { req.CodeVerifier = "x" + testCodeVerifier[1:] }, wantCode: OAuth2InvalidGrant},
+		{name: "no verifier", modify: func(req *models.TokenRequest) { req.CodeVerifier = "" }, wantCode: OAuth2InvalidRequest},
+		{name: "other redirect URI", modify: func(req *models.TokenRequest) { req.RedirectURI = "https://client.example.com/other" }, wantCode: OAuth2InvalidGrant},
+		{name: "unknown code", modify: func(req *models.TokenRequest) { req.Code = "forged" }, wantCode: OAuth2InvalidGrant},
+		{name: "redeemed by another client", clientID: &other.ID, secret: &other.ClientSecret, wantCode: OAuth2InvalidGrant},
+		{name: "wrong client secret", secret: ptr("wrong"), wantCode: OAuth2InvalidClient},
+		{name: "no client secret", secret: ptr(""), wantCode: OAuth2InvalidClient},
+		{name: "no client", clientID: ptr(""), wantCode: OAuth2InvalidClient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientID, secret := client.ID, client.ClientSecret
+			if tt.clientID != nil {
+				clientID = *tt.clientID
+			}
+			if tt.secret != nil {
+				secret = *tt.secret
+			}
+
+			req := &models.TokenRequest{
+				GrantType:    models.GrantTypeAuthorizationCode,
+				Code:         f.authorize(t, client.ID, "openid email"),
+				RedirectURI:  testRedirectURI,
+				CodeVerifier: testCodeVerifier,
+			}
+			if tt.modify != nil {
+				tt.modify(req)
+			}
+
+			tokens, err := f.s.Token(ctx, clientID, secret, req)
+			if got := oauth2ErrorCode(err); got != tt.wantCode || (err != nil && tt.wantCode == "") {
+				t.Fatalf("Token() error = %v, want code %q", err, tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+
+			if tokens.Scope != "openid email" || tokens.RefreshToken == "" || tokens.IDToken == "" {
+				t.Fatalf("Token() = %+v, want an access, refresh and ID token for openid email", tokens)
+			}
+			claims, err := f.s.tokens.ParseToken(tokens.AccessToken)
+			if err != nil {
+				t.Fatalf("ParseToken: %v", err)
+			}
+			if claims.Type != models.TokenTypeAccess || claims.ClientID != client.ID || claims.UserID != f.login.User.ID {
+				t.Fatalf("access token claims = %+v, want a delegated token for the user", claims)
+			}
+		})
+	}
+}
+
+// TestAuthServiceAuthorizationCodeReuse redeems a code twice. The second
+// attempt must fail and end the session the first one started.
+func TestAuthServiceAuthorizationCodeReuse(t *testing.T) {
+	ctx := context.Background()
+	f := newOAuth2Fixture(t)
+	client := f.registerClient(t, false, models.RegisterClientRequest{})
+
+	code := f.authorize(t, client.ID, "")
+	tokens := f.redeem(t, client, code)
+
+	_, err := f.s.Token(ctx, client.ID, client.ClientSecret, &models.TokenRequest{
+		GrantType:    models.GrantTypeAuthorizationCode,
+		Code:         code,
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: testCodeVerifier,
+	})
+	if got := oauth2ErrorCode(err); got != OAuth2InvalidGrant {
+		t.Fatalf("second Token() error = %v, want %s", err, OAuth2InvalidGrant)
+	}
+
+	_, err = f.s.Token(ctx, client.ID, client.ClientSecret, &models.TokenRequest{
+		GrantType:    models.GrantTypeRefreshToken,
+		RefreshToken: tokens.RefreshToken,
+	})
+	if got := oauth2ErrorCode(err); got != OAuth2InvalidGrant {
+		t.Fatalf("refresh after code reuse error = %v, want %s", err, OAuth2InvalidGrant)
+	}
+
+	introspection, err := f.s.IntrospectToken(ctx, client.ID, client.ClientSecret, tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("IntrospectToken: %v", err)
+	}
+	if introspection.Active {
+		t.Fatal("access token is still active after code reuse")
+	}
+}
+
+func TestAuthServiceClientRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	f := newOAuth2Fixture(t)
+	client := f.registerClient(t, false, models.RegisterClientRequest{})
+	other := f.registerClient(t, false, models.RegisterClientRequest{})
+	noRefresh := f.registerClient(t, false, models.RegisterClientRequest{GrantTypes: []string{models.GrantTypeAuthorizationCode}})
+
+	issued := f.redeem(t, client, f.authorize(t, client.ID, "openid"))
+	if tokens := f.redeem(t, noRefresh, f.authorize(t, noRefresh.ID, "")); tokens.RefreshToken != "" {
+		t.Fatal("client without the refresh_token grant got a refresh token")
+	}
+
+	refresh := func(client *models.RegisterClientResponse, token string) (*models.TokenResponse, error) {
+		return f.s.Token(ctx, client.ID, client.ClientSecret, &models.TokenRequest{
+			GrantType:    models.GrantTypeRefreshToken,
+			RefreshToken: token,
+		})
+	}
+
+	// Another client's attempt doesn't burn the token
+	if _, err := refresh(other, issued.RefreshToken); oauth2ErrorCode(err) != OAuth2InvalidGrant {
+		t.Fatalf("refresh by another client error = %v, want %s", err, OAuth2InvalidGrant)
+	}
+	if _, err := refresh(noRefresh, issued.RefreshToken); oauth2ErrorCode(err) != OAuth2UnauthorizedClient {
+		t.Fatalf("refresh by a client without the grant error = %v, want %s", err, OAuth2UnauthorizedClient)
+	}
+
+	rotated, err := refresh(client, issued.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if rotated.RefreshToken == issued.RefreshToken || rotated.Scope != "openid" || rotated.IDToken == "" {
+		t.Fatalf("refresh = %+v, want a new refresh token keeping the openid scope", rotated)
+	}
+
+	// Replaying the rotated token revokes the session, newest token included
+	if _, err := refresh(client, issued.RefreshToken); oauth2ErrorCode(err) != OAuth2InvalidGrant {
+		t.Fatalf("replayed refresh error = %v, want %s", err, OAuth2InvalidGrant)
+	}
+	if _, err := refresh(client, rotated.RefreshToken); oauth2ErrorCode(err) != OAuth2InvalidGrant {
+		t.Fatalf("refresh after reuse error = %v, want %s", err, OAuth2InvalidGrant)
+	}
+}
+
+func TestAuthServiceClientCredentialsGrant(t *testing.T) {
+	ctx := context.Background()
+	f := newOAuth2Fixture(t)
+	client := f.registerClient(t, false, models.RegisterClientRequest{
+		RedirectURIs: []string{},
+		GrantTypes:   []string{models.GrantTypeClientCredentials},
+	})
+	codeOnly := f.registerClient(t, false, models.RegisterClientRequest{})
+
+	tests := []struct {
+		name      string
+		client    *models.RegisterClientResponse
+		scope     string
+		wantScope string
+		wantCode  string
+	}{
+		{name: "every scope but openid", client: client, wantScope: "profile email"},
+		{name: "narrower scope", client: client, scope: "email", wantScope: "email"},
+		{name: "openid", client: client, scope: "openid", wantCode: OAuth2InvalidScope},
+		{name: "unknown scope", client: client, scope: "admin", wantCode: OAuth2InvalidScope},
+		{name: "client without the grant", client: codeOnly, wantCode: OAuth2UnauthorizedClient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := f.s.Token(ctx, tt.client.ID, tt.client.ClientSecret, &models.TokenRequest{
+				GrantType: models.GrantTypeClientCredentials,
+				Scope:     tt.scope,
+			})
+			if got := oauth2ErrorCode(err); got != tt.wantCode || (err != nil && tt.wantCode == "") {
+				t.Fatalf("Token() error = %v, want code %q", err, tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+
+			if tokens.Scope != tt.wantScope || tokens.RefreshToken != "" || tokens.IDToken != "" {
+				t.Fatalf("Token() = %+v, want only an access token for %q", tokens, tt.wantScope)
+			}
+			claims, err := f.s.tokens.ParseToken(tokens.AccessToken)
+			if err != nil {
+				t.Fatalf("ParseToken: %v", err)
+			}
+			if claims.Type != models.TokenTypeClientAccess || claims.ClientID != client.ID || claims.UserID != "" {
+				t.Fatalf("access token claims = %+v, want a client token with no subject", claims)
+			}
+		})
+	}
+
+	_, err := f.s.RegisterClient(ctx, f.login.User.ID, false, &models.RegisterClientRequest{
+		Name:       "Public Client",
+		Type:       models.ClientTypePublic,
+		GrantTypes: []string{models.GrantTypeClientCredentials},
+	})
+	if err != ErrInvalidGrantTypes {
+		t.Fatalf("RegisterClient() public client credentials error = %v, want %v", err, ErrInvalidGrantTypes)
+	}
+}
+
+func TestAuthServiceIntrospectToken(t *testing.T) {
+	ctx := context.Background()
+	f := newOAuth2Fixture(t)
+	client := f.registerClient(t, false, models.RegisterClientRequest{
+		GrantTypes: []string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken, models.GrantTypeClientCredentials},
+	})
+	other := f.registerClient(t, false, models.RegisterClientRequest{
+		RedirectURIs: []string{},
+		GrantTypes:   []string{models.GrantTypeClientCredentials},
+	})
+	resourceServer := f.registerClient(t, true, models.RegisterClientRequest{
+		RedirectURIs: []string{},
+		GrantTypes:   []string{models.GrantTypeClientCredentials},
+		Introspect:   true,
+	})
+	public := f.registerClient(t, false, models.RegisterClientRequest{Type: models.ClientTypePublic})
+
+	delegated := f.redeem(t, client, f.authorize(t, client.ID, "profile"))
+	clientToken, err := f.s.Token(ctx, other.ID, other.ClientSecret, &models.TokenRequest{GrantType: models.GrantTypeClientCredentials})
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		introspector  *models.RegisterClientResponse
+		token         string
+		wantActive    bool
+		wantTokenType string
+		wantSubject   string
+	}{
+		{name: "own access token", introspector: client, token: delegated.AccessToken, wantActive: true, wantTokenType: "access_token", wantSubject: f.login.User.ID},
+		{name: "own refresh token", introspector: client, token: delegated.RefreshToken, wantActive: true, wantTokenType: "refresh_token", wantSubject: f.login.User.ID},
+		{name: "own client token", introspector: other, token: clientToken.AccessToken, wantActive: true, wantTokenType: "access_token"},
+		{name: "another client's access token", introspector: other, token: delegated.AccessToken},
+		{name: "another client's refresh token", introspector: other, token: delegated.RefreshToken},
+		{name: "another client's client token", introspector: client, token: clientToken.AccessToken},
+		{name: "first-party token", introspector: client, token: f.login.AccessToken},
+		{name: "first-party refresh token", introspector: client, token: f.login.RefreshToken},
+		{name: "first-party token with introspect", introspector: resourceServer, token: f.login.AccessToken, wantActive: true, wantTokenType: "access_token", wantSubject: f.login.User.ID},
+		{name: "client token with introspect", introspector: resourceServer, token: clientToken.AccessToken},
+		{name: "garbage", introspector: client, token: "not-a-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.s.IntrospectToken(ctx, tt.introspector.ID, tt.introspector.ClientSecret, tt.token)
+			if err != nil {
+				t.Fatalf("IntrospectToken: %v", err)
+			}
+			if !tt.wantActive {
+				if *got != (models.IntrospectionResponse{}) {
+					t.Fatalf("IntrospectToken() = %+v, want only inactive", got)
+				}
+				return
+			}
+			if !got.Active || got.TokenType != tt.wantTokenType || got.Subject != tt.wantSubject {
+				t.Fatalf("IntrospectToken() = %+v, want active %s for %q", got, tt.wantTokenType, tt.wantSubject)
+			}
+		})
+	}
+
+	if _, err := f.s.IntrospectToken(ctx, public.ID, "", delegated.AccessToken); oauth2ErrorCode(err) != OAuth2UnauthorizedClient {
+		t.Fatalf("IntrospectToken() by a public client error = %v, want %s", err, OAuth2UnauthorizedClient)
+	}
+	_, err = f.s.RegisterClient(ctx, f.login.User.ID, false, &models.RegisterClientRequest{
+		Name:         "Sneaky Client",
+		Type:         models.ClientTypeConfidential,
+		RedirectURIs: []string{testRedirectURI},
+		Introspect:   true,
+	})
+	if err != ErrIntrospectingClient {
+		t.Fatalf("RegisterClient() with introspect by a user error = %v, want %v", err, ErrIntrospectingClient)
+	}
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/repositories"
+)
+
+// UserInfo returns the claims about a user that the given scopes allow a
+// client to see (OpenID Connect Core section 5.3)
+func (s *AuthService) UserInfo(ctx context.Context, userID string, scopes []string) (*models.UserInfo, error) {
+	user, err := s.userRepo.GetUser(ctx, userID)
+	if err != nil {
+		if err == repositories.ErrUserNotFound {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.IsActive {
+		return nil, ErrUserDisabled
+	}
+
+	return userInfo(user, scopes), nil
+}
+
+// OpenIDConfiguration describes the authorization server for OpenID Connect
+// discovery. Every endpoint hangs off the configured issuer.
+func (s *AuthService) OpenIDConfiguration() *models.OpenIDConfiguration {
+	issuer := s.cfg.OAuth2.Issuer
+
+	return &models.OpenIDConfiguration{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth2/authorize",
+		TokenEndpoint:                     issuer + "/oauth2/token",
+		UserInfoEndpoint:                  issuer + "/userinfo",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		RevocationEndpoint:                issuer + "/oauth2/revoke",
+		IntrospectionEndpoint:             issuer + "/oauth2/introspect",
+		ScopesSupported:                   s.cfg.OAuth2.Scopes,
+		ResponseTypesSupported:            []string{"code"},
+		ResponseModesSupported:            []string{"query"},
+		GrantTypesSupported:               []string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken, models.GrantTypeClientCredentials},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  s.tokens.SigningAlgorithms(),
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "amr", "acr",
+			"name", "updated_at", "email", "email_verified",
+		},
+		ACRValuesSupported: []string{models.ACRSingleFactor, models.ACRMultiFactor},
+	}
+}
+
+// userInfo filters a user's details down to what the scopes cover. The
+// subject is always included.
+func userInfo(user *models.User, scopes []string) *models.UserInfo {
+	info := &models.UserInfo{Subject: user.ID}
+
+	if slices.Contains(scopes, models.ScopeProfile) {
+		info.Name = user.Name
+		info.UpdatedAt = user.UpdatedAt.Unix()
+	}
+	if slices.Contains(scopes, models.ScopeEmail) {
+		verified := user.IsVerified
+		info.Email = user.Email
+		info.EmailVerified = &verified
+	}
+
+	return info
+}
+
+// authContextClass reports the assurance level of a login from how the user
+// authenticated. A passkey counts as multi-factor on its own, since it needs
+// both the device and the user's verification.
+func authContextClass(authMethods []string) string {
+	if slices.Contains(authMethods, models.AuthMethodMFA) || slices.Contains(authMethods, models.AuthMethodHardwareKey) {
+		return models.ACRMultiFactor
+	}
+	return models.ACRSingleFactor
+}
+
+// sessionAuthTime is when the user logged in to a session. Sessions created
+// before auth_time was recorded fall back to when they were created.
+func sessionAuthTime(session *models.Session) time.Time {
+	if session.AuthTime.IsZero() {
+		return session.CreatedAt
+	}
+	return session.AuthTime
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+)
+
+// parseIDToken verifies an ID token against the service's keys and the
+// client it was issued to
+func (f *oauth2Fixture) parseIDToken(t *testing.T, idToken, clientID string) jwt.MapClaims {
+	t.Helper()
+
+	issuer, ok := f.s.tokens.(*KeyTokenIssuer)
+	if !ok {
+		t.Fatalf("token issuer is a %T, want a *KeyTokenIssuer", f.s.tokens)
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, issuer.Keys().Keyfunc,
+		jwt.WithIssuer(f.s.cfg.OAuth2.Issuer),
+		jwt.WithAudience(clientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		t.Fatalf("parsing ID token: %v", err)
+	}
+	return claims
+}
+
+func TestAuthServiceOpenIDConfiguration(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.OAuth2.Issuer = "https://api.multitask.com/v1/auth"
+	s, _ := newTestService(t, cfg)
+
+	got := s.OpenIDConfiguration()
+
+	if got.Issuer != cfg.OAuth2.Issuer {
+		t.Fatalf("issuer = %q, want %q", got.Issuer, cfg.OAuth2.Issuer)
+	}
+	endpoints := map[string]string{
+		"authorization": got.AuthorizationEndpoint,
+		"token":         got.TokenEndpoint,
+		"userinfo":      got.UserInfoEndpoint,
+		"jwks":          got.JWKSURI,
+		"revocation":    got.RevocationEndpoint,
+		"introspection": got.IntrospectionEndpoint,
+	}
+	for name, endpoint := range endpoints {
+		if !strings.HasPrefix(endpoint, cfg.OAuth2.Issuer+"/") {
+			t.Errorf("%s endpoint %q is not under the issuer", name, endpoint)
+		}
+	}
+	if got.JWKSURI != cfg.OAuth2.Issuer+"/.well-known/jwks.json" {
+		t.Errorf("jwks_uri = %q", got.JWKSURI)
+	}
+
+	if !slices.Equal(got.ScopesSupported, cfg.OAuth2.Scopes) {
+		t.Errorf("scopes_supported = %v, want %v", got.ScopesSupported, cfg.OAuth2.Scopes)
+	}
+	signingKey := s.tokens.(*KeyTokenIssuer).Keys().SigningKey()
+	if !slices.Contains(got.IDTokenSigningAlgValuesSupported, signingKey.Algorithm) {
+		t.Errorf("id_token_signing_alg_values_supported = %v, want it to include %s", got.IDTokenSigningAlgValuesSupported, signingKey.Algorithm)
+	}
+	if !slices.Equal(got.CodeChallengeMethodsSupported, []string{"S256"}) || !slices.Equal(got.ResponseTypesSupported, []string{"code"}) {
+		t.Errorf("discovery = %+v, want only the code flow with S256", got)
+	}
+}
+
+func TestAuthServiceUserInfo(t *testing.T) {
+	ctx := context.Background()
+	f := newOAuth2Fixture(t)
+	user, err := f.s.GetUser(ctx, f.login.User.ID)
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	verified := true
+
+	tests := []struct {
+		name   string
+		userID string
+		scopes []string
+		want   *models.UserInfo
+	}{
+		{name: "openid only", userID: user.ID, scopes: []string{"openid"}, want: &models.UserInfo{Subject: user.ID}},
+		{
+			name: "profile", userID: user.ID, scopes: []string{"openid", "profile"},
+			want: &models.UserInfo{Subject: user.ID, Name: "Test User", UpdatedAt: user.UpdatedAt.Unix()},
+		},
+		{
+			name: "email", userID: user.ID, scopes: []string{"openid", "email"},
+			want: &models.UserInfo{Subject: user.ID, Email: "jane@example.com", EmailVerified: &verified},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.s.UserInfo(ctx, tt.userID, tt.scopes)
+			if err != nil {
+				t.Fatalf("UserInfo: %v", err)
+			}
+			if got.Subject != tt.want.Subject || got.Name != tt.want.Name || got.UpdatedAt != tt.want.UpdatedAt || got.Email != tt.want.Email ||
+				(got.EmailVerified == nil) != (tt.want.EmailVerified == nil) ||
+				(got.EmailVerified != nil && *got.EmailVerified != *tt.want.EmailVerified) {
+				t.Fatalf("UserInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := f.s.UserInfo(ctx, "missing", []string{"openid"}); err != ErrUserNotFound {
+		t.Fatalf("UserInfo(missing) error = %v, want %v", err, ErrUserNotFound)
+	}
+
+	user.IsActive = false
+	if err := f.s.userRepo.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if _, err := f.s.UserInfo(ctx, user.ID, []string{"openid"}); err != ErrUserDisabled {
+		t.Fatalf("UserInfo(disabled) error = %v, want %v", err, ErrUserDisabled)
+	}
+}
+
+func TestAuthServiceIDToken(t *testing.T) {
+	ctx := context.Background()
+	f := newOAuth2Fixture(t)
+	client := f.registerClient(t, false, models.RegisterClientRequest{})
+
+	session, err := f.s.sessionRepo.GetSession(ctx, f.sessionID)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	authTime := float64(sessionAuthTime(session).Unix())
+
+	tests := []struct {
+		name      string
+		scope     string
+		wantToken bool
+		wantEmail bool
+		wantName  bool
+	}{
+		{name: "openid", scope: "openid", wantToken: true},
+		{name: "openid with profile and email", scope: "openid profile email", wantToken: true, wantEmail: true, wantName: true},
+		{name: "no openid", scope: "profile email"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := f.redeem(t, client, f.authorize(t, client.ID, tt.scope))
+			if !tt.wantToken {
+				if tokens.IDToken != "" {
+					t.Fatal("got an ID token without the openid scope")
+				}
+				return
+			}
+
+			claims := f.parseIDToken(t, tokens.IDToken, client.ID)
+			if claims["sub"] != f.login.User.ID || claims["nonce"] != "nonce-1" || claims["type"] != models.TokenTypeID {
+				t.Fatalf("claims = %v, want the user, the request's nonce and type %q", claims, models.TokenTypeID)
+			}
+			if claims["auth_time"] != authTime || claims["acr"] != models.ACRSingleFactor {
+				t.Fatalf("claims = %v, want auth_time %v and acr %s", claims, authTime, models.ACRSingleFactor)
+			}
+			if amr, _ := claims["amr"].([]interface{}); len(amr) != 1 || amr[0] != models.AuthMethodPassword {
+				t.Fatalf("amr = %v, want [%s]", claims["amr"], models.AuthMethodPassword)
+			}
+			if lifetime := claims["exp"].(float64) - claims["iat"].(float64); lifetime != models.IDTokenDuration.Seconds() {
+				t.Fatalf("ID token lives %vs, want %v", lifetime, models.IDTokenDuration)
+			}
+			if _, ok := claims["email"]; ok != tt.wantEmail {
+				t.Fatalf("email claim present = %v, want %v", ok, tt.wantEmail)
+			}
+			if _, ok := claims["name"]; ok != tt.wantName {
+				t.Fatalf("name claim present = %v, want %v", ok, tt.wantName)
+			}
+
+			// An ID token is not an access token
+			if _, err := f.s.tokens.ParseToken(tokens.IDToken); err == nil {
+				t.Fatal("ParseToken() accepted an ID token")
+			}
+
+			// Refreshed ID tokens keep auth_time but carry no nonce
+			refreshed, err := f.s.Token(ctx, client.ID, client.ClientSecret, &models.TokenRequest{
+				GrantType:    models.GrantTypeRefreshToken,
+				RefreshToken: tokens.RefreshToken,
+			})
+			if err != nil {
+				t.Fatalf("refresh: %v", err)
+			}
+			claims = f.parseIDToken(t, refreshed.IDToken, client.ID)
+			if _, ok := claims["nonce"]; ok || claims["auth_time"] != authTime {
+				t.Fatalf("refreshed claims = %v, want auth_time %v and no nonce", claims, authTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/repositories"
+	"github.com/multitask-platform/backend/shared/events"
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+// Outbox relay defaults
+const (
+	DefaultOutboxBatchSize   = 100
+	DefaultOutboxMaxAttempts = 10
+	DefaultOutboxBaseBackoff = time.Second
+	DefaultOutboxMaxBackoff  = 15 * time.Minute
+)
+
+// OutboxRelayConfig tunes an OutboxRelay. Zero fields take the defaults.
+type OutboxRelayConfig struct {
+	BatchSize   int              // Messages read and published at a time
+	MaxAttempts int              // Failed attempts before a message is dead-lettered
+	BaseBackoff time.Duration    // Wait after the first failure, doubled after each one after it
+	MaxBackoff  time.Duration    // Longest wait between attempts
+	Now         func() time.Time // Defaults to time.Now
+}
+
+// OutboxRelay publishes the events the repositories stored in the outbox.
+// A message is deleted once published. One that fails is retried with
+// exponential backoff and, after MaxAttempts failures, left in the outbox as
+// dead for an operator to look at. Any number of relays can run at once; at
+// worst an event is published twice, and consumers drop repeated eventIds.
+type OutboxRelay struct {
+	repo      repositories.OutboxRepository
+	publisher EventPublisher
+	cfg       OutboxRelayConfig
+}
+
+// NewOutboxRelay creates a relay from repo to publisher
+func NewOutboxRelay(repo repositories.OutboxRepository, publisher EventPublisher, cfg OutboxRelayConfig) *OutboxRelay {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultOutboxBatchSize
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultOutboxMaxAttempts
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = DefaultOutboxBaseBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultOutboxMaxBackoff
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	return &OutboxRelay{
+		repo:      repo,
+		publisher: publisher,
+		cfg:       cfg,
+	}
+}
+
+// Drain publishes the due messages, a batch at a time, until none are left,
+// and returns how many it published. Messages that fail to publish are
+// rescheduled, so they don't hold up the rest; only outbox errors stop it.
+func (r *OutboxRelay) Drain(ctx context.Context) (int, error) {
+	published := 0
+	for {
+		messages, err := r.repo.GetDueOutboxMessages(ctx, r.cfg.Now(), r.cfg.BatchSize)
+		if err != nil {
+			return published, fmt.Errorf("failed to read outbox: %w", err)
+		}
+		if len(messages) == 0 {
+			return published, nil
+		}
+
+		n, err := r.publish(ctx, messages)
+		published += n
+		if err != nil {
+			return published, err
+		}
+		if len(messages) < r.cfg.BatchSize {
+			return published, nil
+		}
+	}
+}
+
+// Run drains the outbox every interval until ctx is done, for server mode
+func (r *OutboxRelay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Drain(ctx); err != nil && ctx.Err() == nil {
+			logger.ErrorCtx(ctx, "Failed to drain outbox", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publish sends one batch, deleting the messages that went out and
+// rescheduling the others
+func (r *OutboxRelay) publish(ctx context.Context, messages []*models.OutboxMessage) (int, error) {
+	batch := make([]*events.Event, len(messages))
+	for i, message := range messages {
+		batch[i] = message.Event
+	}
+
+	publishErr := r.publisher.Publish(ctx, batch...)
+	failed := make(map[string]bool)
+	for _, event := range events.FailedEvents(publishErr, batch) {
+		failed[event.ID] = true
+	}
+
+	published := 0
+	for _, message := range messages {
+		if failed[message.ID] {
+			if err := r.retryLater(ctx, message, publishErr); err != nil {
+				return published, err
+			}
+			continue
+		}
+
+		if err := r.repo.DeleteOutboxMessage(ctx, message.ID); err != nil {
+			return published, fmt.Errorf("failed to delete published outbox message: %w", err)
+		}
+		published++
+	}
+	return published, nil
+}
+
+// retryLater records a failed attempt and schedules the next one, or
+// dead-letters the message when it has run out of attempts
+func (r *OutboxRelay) retryLater(ctx context.Context, message *models.OutboxMessage, publishErr error) error {
+	message.Attempts++
+	message.LastError = publishErr.Error()
+
+	if message.Attempts >= r.cfg.MaxAttempts {
+		message.Status = models.OutboxStatusDead
+		logger.ErrorCtx(ctx, "Giving up on publishing event",
+			zap.String("event_id", message.ID),
+			zap.String("event_type", string(message.Event.Type)),
+			zap.Int("attempts", message.Attempts),
+			zap.Error(publishErr),
+		)
+	} else {
+		message.NextAttemptAt = r.cfg.Now().Add(r.backoff(message.Attempts))
+		logger.WarnCtx(ctx, "Failed to publish event, will retry",
+			zap.String("event_id", message.ID),
+			zap.String("event_type", string(message.Event.Type)),
+			zap.Int("attempts", message.Attempts),
+			zap.Time("next_attempt_at", message.NextAttemptAt),
+			zap.Error(publishErr),
+		)
+	}
+
+	err := r.repo.UpdateOutboxMessage(ctx, message)
+	if err != nil && err != repositories.ErrOutboxMessageNotFound { // Another relay published it
+		return fmt.Errorf("failed to reschedule outbox message: %w", err)
+	}
+	return nil
+}
+
+// backoff is how long to wait after the given number of failed attempts
+func (r *OutboxRelay) backoff(attempts int) time.Duration {
+	wait := r.cfg.BaseBackoff
+	for i := 1; i < attempts && wait < r.cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, r.cfg.MaxBackoff)
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/repositories"
+	"github.com/multitask-platform/backend/shared/events"
+)
+
+// testOutbox is an OutboxRepository that also exposes dead messages
+type testOutbox struct {
+	mu       sync.Mutex
+	messages map[string]*models.OutboxMessage
+}
+
+// newTestOutbox stores one event per ID, a second apart starting at start
+func newTestOutbox(t *testing.T, start time.Time, ids ...string) *testOutbox {
+	t.Helper()
+
+	o := &testOutbox{messages: make(map[string]*models.OutboxMessage)}
+	for i, id := range ids {
+		event, err := events.New(id, events.SourceAuth, start.Add(time.Duration(i)*time.Second), events.UserRegistered{UserID: "user-" + id})
+		if err != nil {
+			t.Fatalf("events.New: %v", err)
+		}
+		o.messages[id] = models.NewOutboxMessage(event)
+	}
+	return o
+}
+
+func (o *testOutbox) GetDueOutboxMessages(ctx context.Context, now time.Time, limit int) ([]*models.OutboxMessage, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var messages []*models.OutboxMessage
+	for _, message := range o.messages {
+		if message.Status == models.OutboxStatusPending && !message.NextAttemptAt.After(now) {
+			copied := *message
+			messages = append(messages, &copied)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].CreatedAt.Before(messages[j].CreatedAt) })
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+func (o *testOutbox) UpdateOutboxMessage(ctx context.Context, message *models.OutboxMessage) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, ok := o.messages[message.ID]; !ok {
+		return repositories.ErrOutboxMessageNotFound
+	}
+	copied := *message
+	o.messages[message.ID] = &copied
+	return nil
+}
+
+func (o *testOutbox) DeleteOutboxMessage(ctx context.Context, id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delete(o.messages, id)
+	return nil
+}
+
+// message returns the stored message with id, or nil once it is deleted
+func (o *testOutbox) message(id string) *models.OutboxMessage {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.messages[id]
+}
+
+// failingPublisher fails every call with a plain error
+type failingPublisher struct {
+	calls int
+}
+
+func (p *failingPublisher) Publish(ctx context.Context, batch ...*events.Event) error {
+	p.calls++
+	return errors.New("event bus unavailable")
+}
+
+// failEvents makes bus reject the events with the given IDs
+func failEvents(bus *events.MemoryBus, ids ...string) {
+	bus.Subscribe(events.TypeUserRegistered, func(ctx context.Context, event *events.Event) error {
+		if slices.Contains(ids, event.ID) {
+			return errors.New("rejected " + event.ID)
+		}
+		return nil
+	})
+}
+
+func eventIDs(published []*events.Event) []string {
+	ids := make([]string, len(published))
+	for i, event := range published {
+		ids[i] = event.ID
+	}
+	return ids
+}
+
+func TestOutboxRelayDrain(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start.Add(time.Minute)
+
+	tests := []struct {
+		name          string
+		batchSize     int
+		failIDs       []string
+		wantPublished []string
+		wantRetried   []string
+	}{
+		{name: "all published", batchSize: 10, wantPublished: []string{"a", "b", "c", "d", "e"}},
+		{name: "several batches", batchSize: 2, wantPublished: []string{"a", "b", "c", "d", "e"}},
+		{
+			// The memory bus fails the rejected event and every one after it
+			// in the batch; later batches still go out
+			name:          "partial failure",
+			batchSize:     3,
+			failIDs:       []string{"b"},
+			wantPublished: []string{"a", "d", "e"},
+			wantRetried:   []string{"b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outbox := newTestOutbox(t, start, "a", "b", "c", "d", "e")
+			bus := events.NewMemoryBus()
+			failEvents(bus, tt.failIDs...)
+			relay := NewOutboxRelay(outbox, bus, OutboxRelayConfig{
+				BatchSize: tt.batchSize,
+				Now:       func() time.Time { return now },
+			})
+
+			published, err := relay.Drain(context.Background())
+			if err != nil {
+				t.Fatalf("Drain: %v", err)
+			}
+			if published != len(tt.wantPublished) {
+				t.Fatalf("Drain() = %d, want %d", published, len(tt.wantPublished))
+			}
+			if got := eventIDs(bus.Events()); !slices.Equal(got, tt.wantPublished) {
+				t.Fatalf("published %v, want %v", got, tt.wantPublished)
+			}
+
+			for _, id := range tt.wantPublished {
+				if outbox.message(id) != nil {
+					t.Errorf("published message %s is still in the outbox", id)
+				}
+			}
+			for _, id := range tt.wantRetried {
+				message := outbox.message(id)
+				if message == nil {
+					t.Fatalf("failed message %s was deleted", id)
+				}
+				if message.Attempts != 1 || message.LastError == "" || !message.NextAttemptAt.Equal(now.Add(DefaultOutboxBaseBackoff)) {
+					t.Errorf("failed message %s = %+v, want one attempt retried after %v", id, message, DefaultOutboxBaseBackoff)
+				}
+			}
+		})
+	}
+}
+
+// TestOutboxRelayDeadLetter retries a message that never publishes until it
+// runs out of attempts
+func TestOutboxRelayDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	outbox := newTestOutbox(t, now, "a")
+	publisher := &failingPublisher{}
+	relay := NewOutboxRelay(outbox, publisher, OutboxRelayConfig{
+		MaxAttempts: 3,
+		BaseBackoff: time.Second,
+		MaxBackoff:  time.Minute,
+		Now:         func() time.Time { return now },
+	})
+
+	tests := []struct {
+		advance      time.Duration // clock change before draining
+		wantCalls    int
+		wantAttempts int
+		wantStatus   string
+	}{
+		{advance: 0, wantCalls: 1, wantAttempts: 1, wantStatus: models.OutboxStatusPending},
+		{advance: 500 * time.Millisecond, wantCalls: 1, wantAttempts: 1, wantStatus: models.OutboxStatusPending}, // not due yet
+		{advance: 500 * time.Millisecond, wantCalls: 2, wantAttempts: 2, wantStatus: models.OutboxStatusPending},
+		{advance: 2 * time.Second, wantCalls: 3, wantAttempts: 3, wantStatus: models.OutboxStatusDead},
+		{advance: time.Hour, wantCalls: 3, wantAttempts: 3, wantStatus: models.OutboxStatusDead}, // dead messages stay put
+	}
+
+	for i, tt := range tests {
+		now = now.Add(tt.advance)
+
+		published, err := relay.Drain(ctx)
+		if err != nil {
+			t.Fatalf("drain %d: %v", i, err)
+		}
+		if published != 0 {
+			t.Fatalf("drain %d published %d messages", i, published)
+		}
+
+		message := outbox.message("a")
+		if message == nil {
+			t.Fatalf("drain %d deleted the message", i)
+		}
+		if publisher.calls != tt.wantCalls || message.Attempts != tt.wantAttempts || message.Status != tt.wantStatus {
+			t.Fatalf("drain %d: %d calls, message %+v; want %d calls, %d attempts, %s",
+				i, publisher.calls, message, tt.wantCalls, tt.wantAttempts, tt.wantStatus)
+		}
+	}
+}
+
+func TestOutboxRelayBackoff(t *testing.T) {
+	relay := NewOutboxRelay(nil, nil, OutboxRelayConfig{
+		BaseBackoff: time.Second,
+		MaxBackoff:  10 * time.Second,
+	})
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: time.Second},
+		{attempts: 2, want: 2 * time.Second},
+		{attempts: 3, want: 4 * time.Second},
+		{attempts: 4, want: 8 * time.Second},
+		{attempts: 5, want: 10 * time.Second},
+		{attempts: 100, want: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := relay.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/repositories"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/webauthn"
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+// Passkeys (WebAuthn credentials) are registered by a signed-in user and then
+// either sign in on their own, with user verification standing in for the
+// password, or complete the MFA step of a password login.
+//
+// Challenges travel in signed tokens and are also stored until the ceremony
+// finishes, so each one is accepted once. The signature counter can't be
+// relied on for that, since synced passkeys always report 0.
+
+// BeginPasskeyRegistration returns the options for creating a passkey for the
+// user, excluding authenticators they've already registered
+func (s *AuthService) BeginPasskeyRegistration(ctx context.Context, userID string) (*models.WebAuthnRegisterBeginResponse, error) {
+	user, err := s.userRepo.GetUser(ctx, userID)
+	if err != nil {
+		if err == repositories.ErrUserNotFound {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	existing, err := s.credentials.GetUserCredentials(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get passkeys: %w", err)
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.tokens.GenerateWebAuthnChallengeToken(models.TokenTypeWebAuthnRegister, user.ID, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.saveChallenge(ctx, models.TokenTypeWebAuthnRegister, user.ID, challenge); err != nil {
+		return nil, err
+	}
+
+	options := s.webauthn.CreationOptions(challenge, webauthn.UserEntity{
+		ID:          []byte(user.ID),
+		Name:        user.Email,
+		DisplayName: user.Name,
+	}, credentialDescriptors(existing))
+
+	return &models.WebAuthnRegisterBeginResponse{
+		ChallengeToken: token,
+		PublicKey:      options,
+	}, nil
+}
+
+// FinishPasskeyRegistration verifies the authenticator's response and stores
+// the new passkey
+func (s *AuthService) FinishPasskeyRegistration(ctx context.Context, userID string, req *models.WebAuthnRegisterFinishRequest) (*models.WebAuthnCredential, error) {
+	claims, err := s.tokens.ParseWebAuthnChallengeToken(req.ChallengeToken, models.TokenTypeWebAuthnRegister)
+	if err != nil || claims.UserID != userID {
+		return nil, ErrInvalidToken
+	}
+
+	if err := s.consumeChallenge(ctx, models.TokenTypeWebAuthnRegister, userID, claims.Challenge); err != nil {
+		return nil, err
+	}
+
+	created, err := s.webauthn.VerifyRegistration(&req.Credential, claims.Challenge)
+	if err != nil {
+		if errors.Is(err, webauthn.ErrVerification) {
+			logger.WarnCtx(ctx, "Passkey registration rejected", zap.String("user_id", userID), zap.Error(err))
+			return nil, ErrInvalidPasskey
+		}
+		return nil, err
+	}
+
+	name := req.Name
+	if name == "" {
+		name = models.DefaultPasskeyName
+	}
+
+	credential := &models.WebAuthnCredential{
+		ID:             base64.RawURLEncoding.EncodeToString(created.ID),
+		UserID:         userID,
+		Name:           name,
+		PublicKey:      created.PublicKey,
+		SignCount:      created.SignCount,
+		Transports:     created.Transports,
+		BackupEligible: created.BackupEligible,
+		CreatedAt:      s.now().UTC(),
+	}
+
+	if err := s.credentials.CreateCredential(ctx, credential); err != nil {
+		if err == repositories.ErrCredentialExists {
+			return nil, ErrPasskeyExists
+		}
+		return nil, fmt.Errorf("failed to save passkey: %w", err)
+	}
+
+	logger.LogSecurityEvent(ctx, "passkey_registered",
+		zap.String("user_id", userID),
+		zap.String("credential_id", credential.ID),
+	)
+
+	return credential, nil
+}
+
+// GetPasskeys lists the user's passkeys
+func (s *AuthService) GetPasskeys(ctx context.Context, userID string) ([]*models.WebAuthnCredential, error) {
+	credentials, err := s.credentials.GetUserCredentials(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get passkeys: %w", err)
+	}
+	if credentials == nil {
+		credentials = []*models.WebAuthnCredential{}
+	}
+	return credentials, nil
+}
+
+// DeletePasskey removes one of the user's passkeys
+func (s *AuthService) DeletePasskey(ctx context.Context, userID, credentialID string) error {
+	err := s.credentials.DeleteCredential(ctx, userID, credentialID)
+	if err != nil {
+		if err == repositories.ErrCredentialNotFound {
+			return ErrPasskeyNotFound
+		}
+		return fmt.Errorf("failed to delete passkey: %w", err)
+	}
+
+	logger.LogSecurityEvent(ctx, "passkey_deleted",
+		zap.String("user_id", userID),
+		zap.String("credential_id", credentialID),
+	)
+
+	return nil
+}
+
+// BeginPasskeyLogin returns the options for a passwordless login. The user
+// isn't known yet, so the browser offers any passkey it has for the site.
+func (s *AuthService) BeginPasskeyLogin(ctx context.Context) (*models.WebAuthnLoginBeginResponse, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.tokens.GenerateWebAuthnChallengeToken(models.TokenTypeWebAuthnLogin, "", challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.saveChallenge(ctx, models.TokenTypeWebAuthnLogin, "", challenge); err != nil {
+		return nil, err
+	}
+
+	return &models.WebAuthnLoginBeginResponse{
+		ChallengeToken: token,
+		PublicKey:      s.webauthn.RequestOptions(challenge, nil, webauthn.UserVerificationRequired),
+	}, nil
+}
+
+// FinishPasskeyLogin verifies a passkey assertion and logs its owner in.
+// User verification is required, so the passkey is both factors and no MFA
+// step follows.
+func (s *AuthService) FinishPasskeyLogin(ctx context.Context, req *models.WebAuthnLoginFinishRequest) (*models.AuthResponse, error) {
+	if err := s.checkIPLock(ctx, req.IPAddress); err != nil {
+		return nil, err
+	}
+
+	claims, err := s.tokens.ParseWebAuthnChallengeToken(req.ChallengeToken, models.TokenTypeWebAuthnLogin)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if err := s.consumeChallenge(ctx, models.TokenTypeWebAuthnLogin, "", claims.Challenge); err != nil {
+		return nil, err
+	}
+
+	credential, err := s.credentials.GetCredential(ctx, base64.RawURLEncoding.EncodeToString(req.Credential.RawID))
+	if err != nil {
+		if err == repositories.ErrCredentialNotFound {
+			s.recordLoginFailure(ctx, nil, req.IPAddress)
+			return nil, ErrInvalidPasskey
+		}
+		return nil, fmt.Errorf("failed to get passkey: %w", err)
+	}
+
+	user, err := s.userRepo.GetUser(ctx, credential.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if !user.IsActive {
+		return nil, ErrUserDisabled
+	}
+
+	if err := s.checkUserLock(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	err = s.verifyPasskey(ctx, credential, &req.Credential, claims.Challenge, true)
+	if err == ErrInvalidPasskey {
+		if err := s.recordLoginFailure(ctx, user, req.IPAddress); err != ErrInvalidCredentials {
+			return nil, err
+		}
+		return nil, ErrInvalidPasskey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.clearLoginFailures(ctx, user.ID)
+
+	return s.completeLogin(ctx, user, &models.SessionCreateRequest{
+		UserID:      user.ID,
+		DeviceID:    req.DeviceID,
+		UserAgent:   req.UserAgent,
+		IPAddress:   req.IPAddress,
+		AuthMethods: []string{models.AuthMethodHardwareKey},
+	})
+}
+
+// passkeyOptions returns the options for using one of the user's passkeys as
+// a second factor
+func (s *AuthService) passkeyOptions(ctx context.Context, userID string, challenge []byte) (*webauthn.RequestOptions, error) {
+	credentials, err := s.credentials.GetUserCredentials(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get passkeys: %w", err)
+	}
+
+	// The password was already checked, so presence is enough here
+	return s.webauthn.RequestOptions(challenge, credentialDescriptors(credentials), webauthn.UserVerificationDiscouraged), nil
+}
+
+// verifyMFAPasskey checks a passkey assertion made at the MFA step
+func (s *AuthService) verifyMFAPasskey(ctx context.Context, userID string, assertion *webauthn.AssertionResponse, challenge []byte) error {
+	if len(challenge) == 0 {
+		return ErrInvalidPasskey
+	}
+
+	err := s.consumeChallenge(ctx, models.TokenTypeMFAChallenge, userID, challenge)
+	if err == ErrInvalidToken {
+		return ErrInvalidPasskey
+	}
+	if err != nil {
+		return err
+	}
+
+	credential, err := s.credentials.GetCredential(ctx, base64.RawURLEncoding.EncodeToString(assertion.RawID))
+	if err != nil {
+		if err == repositories.ErrCredentialNotFound {
+			return ErrInvalidPasskey
+		}
+		return fmt.Errorf("failed to get passkey: %w", err)
+	}
+
+	if credential.UserID != userID {
+		return ErrInvalidPasskey
+	}
+
+	return s.verifyPasskey(ctx, credential, assertion, challenge, false)
+}
+
+// verifyPasskey checks an assertion against a stored credential and records
+// its new signature counter
+func (s *AuthService) verifyPasskey(ctx context.Context, credential *models.WebAuthnCredential, assertion *webauthn.AssertionResponse, challenge []byte, requireUserVerification bool) error {
+	if handle := assertion.Response.UserHandle; len(handle) > 0 && !bytes.Equal(handle, []byte(credential.UserID)) {
+		return ErrInvalidPasskey
+	}
+
+	result, err := s.webauthn.VerifyAssertion(assertion, challenge, credential.PublicKey, requireUserVerification)
+	if err != nil {
+		if errors.Is(err, webauthn.ErrVerification) {
+			logger.WarnCtx(ctx, "Passkey assertion rejected",
+				zap.String("user_id", credential.UserID),
+				zap.Error(err),
+			)
+			return ErrInvalidPasskey
+		}
+		return err
+	}
+
+	// Authenticators that count must count up; anything else suggests a
+	// cloned key. Synced passkeys usually report 0 every time.
+	if (result.SignCount != 0 || credential.SignCount != 0) && result.SignCount <= credential.SignCount {
+		logger.LogSecurityEvent(ctx, "passkey_counter_regressed",
+			zap.String("user_id", credential.UserID),
+			zap.String("credential_id", credential.ID),
+			zap.Uint32("stored", credential.SignCount),
+			zap.Uint32("received", result.SignCount),
+		)
+		return ErrInvalidPasskey
+	}
+
+	err = s.credentials.UpdateCredentialUsage(ctx, credential.ID, result.SignCount, s.now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to update passkey: %w", err)
+	}
+
+	return nil
+}
+
+// saveChallenge stores a ceremony's challenge until consumeChallenge uses it
+func (s *AuthService) saveChallenge(ctx context.Context, ceremony, userID string, challenge []byte) error {
+	err := s.credentials.SaveChallenge(ctx, &models.WebAuthnChallenge{
+		Challenge: base64.RawURLEncoding.EncodeToString(challenge),
+		Ceremony:  ceremony,
+		UserID:    userID,
+		ExpiresAt: s.now().UTC().Add(webauthn.ChallengeTimeout),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save webauthn challenge: %w", err)
+	}
+	return nil
+}
+
+// consumeChallenge uses up a stored challenge, whatever the outcome of the
+// ceremony, and checks it was issued for this ceremony and user
+func (s *AuthService) consumeChallenge(ctx context.Context, ceremony, userID string, challenge []byte) error {
+	stored, err := s.credentials.ConsumeChallenge(ctx, base64.RawURLEncoding.EncodeToString(challenge))
+	if err != nil {
+		if err == repositories.ErrTokenNotFound || err == repositories.ErrTokenExpired {
+			logger.LogSecurityEvent(ctx, "webauthn_challenge_rejected",
+				zap.String("user_id", userID),
+				zap.String("ceremony", ceremony),
+			)
+			return ErrInvalidToken
+		}
+		return fmt.Errorf("failed to consume webauthn challenge: %w", err)
+	}
+
+	if stored.Ceremony != ceremony || stored.UserID != userID {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// credentialDescriptors refers to stored credentials in ceremony options
+func credentialDescriptors(credentials []*models.WebAuthnCredential) []webauthn.CredentialDescriptor {
+	descriptors := make([]webauthn.CredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		id, err := base64.RawURLEncoding.DecodeString(credential.ID)
+		if err != nil {
+			continue
+		}
+		descriptors = append(descriptors, webauthn.CredentialDescriptor{
+			Type:       "public-key",
+			ID:         id,
+			Transports: credential.Transports,
+		})
+	}
+	return descriptors
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/totp"
+)
+
+var recoveryCodeFormat = regexp.MustCompile(`^[` + recoveryCodeAlphabet + `]{5}-[` + recoveryCodeAlphabet + `]{5}$`)
+
+func TestAuthServiceRecoveryCodes(t *testing.T) {
+	ctx := context.Background()
+	s, mailer := newTestService(t, newTestConfig())
+	user := registerVerifiedUser(t, s, mailer, "jane@example.com", "correct horse")
+
+	if _, err := s.RegenerateRecoveryCodes(ctx, user.ID); err != ErrMFANotEnrolled {
+		t.Fatalf("RegenerateRecoveryCodes() without MFA error = %v, want %v", err, ErrMFANotEnrolled)
+	}
+
+	// Confirming TOTP hands out the first set
+	setup, err := s.SetupTOTP(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("SetupTOTP: %v", err)
+	}
+	issued, err := s.VerifyTOTP(ctx, user.ID, totpCode(t, setup.Secret, totp.Step(s.now())))
+	if err != nil {
+		t.Fatalf("VerifyTOTP: %v", err)
+	}
+	codes := issued.RecoveryCodes
+	if len(codes) != models.RecoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), models.RecoveryCodeCount)
+	}
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if !recoveryCodeFormat.MatchString(code) || seen[code] {
+			t.Fatalf("recovery code %q is malformed or repeated", code)
+		}
+		seen[code] = true
+	}
+
+	redeem := func(code string) error {
+		_, err := s.LoginMFA(ctx, &models.MFALoginRequest{
+			MFAToken:     mfaToken(t, s, "jane@example.com", "correct horse"),
+			RecoveryCode: code,
+		})
+		return err
+	}
+	remaining := func() int {
+		got, err := s.GetUser(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("GetUser: %v", err)
+		}
+		return got.MFA.RecoveryCodesRemaining
+	}
+
+	if err := redeem(codes[0]); err != nil {
+		t.Fatalf("redeeming a recovery code: %v", err)
+	}
+	if err := redeem(codes[0]); err != ErrInvalidMFACode {
+		t.Fatalf("redeeming it again error = %v, want %v", err, ErrInvalidMFACode)
+	}
+	// Codes can be typed without the dash and in any case
+	if err := redeem(strings.ToUpper(strings.ReplaceAll(codes[1], "-", ""))); err != nil {
+		t.Fatalf("redeeming a reformatted recovery code: %v", err)
+	}
+	if got := remaining(); got != models.RecoveryCodeCount-2 {
+		t.Fatalf("%d recovery codes remaining, want %d", got, models.RecoveryCodeCount-2)
+	}
+
+	// A new set replaces every unused code
+	regenerated, err := s.RegenerateRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("RegenerateRecoveryCodes: %v", err)
+	}
+	if got := remaining(); got != models.RecoveryCodeCount {
+		t.Fatalf("%d recovery codes remaining after regenerating, want %d", got, models.RecoveryCodeCount)
+	}
+	for _, code := range regenerated.RecoveryCodes {
+		if seen[code] {
+			t.Fatalf("regenerated set repeats %q", code)
+		}
+	}
+	if err := redeem(codes[2]); err != ErrInvalidMFACode {
+		t.Fatalf("redeeming a code from the old set error = %v, want %v", err, ErrInvalidMFACode)
+	}
+	if err := redeem(regenerated.RecoveryCodes[0]); err != nil {
+		t.Fatalf("redeeming a code from the new set: %v", err)
+	}
+	if err := redeem("not-a-code"); err != ErrInvalidMFACode {
+		t.Fatalf("redeeming garbage error = %v, want %v", err, ErrInvalidMFACode)
+	}
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/shared/logger"
+	"github.com/multitask-platform/backend/shared/revocation"
+)
+
+// revokeSessionTokens denylists the access tokens already issued for a
+// session. Entries only need to outlive the longest access token.
+func (s *AuthService) revokeSessionTokens(ctx context.Context, sessionID string) {
+	s.revoke(ctx, revocation.SessionKey(sessionID))
+}
+
+// revokeUserTokens denylists every access token issued to the user so far,
+// e.g. after a password reset or when the account is disabled. Tokens from
+// later logins are unaffected.
+func (s *AuthService) revokeUserTokens(ctx context.Context, userID string) {
+	s.revoke(ctx, revocation.UserKey(userID))
+}
+
+func (s *AuthService) revoke(ctx context.Context, key string) {
+	if s.revocations == nil {
+		return
+	}
+
+	// The session is already deactivated, so failing here only means its
+	// access tokens live out their remaining minutes
+	err := s.revocations.Revoke(ctx, key, models.DefaultSessionDuration)
+	if err != nil {
+		logger.WarnCtx(ctx, "Failed to revoke access tokens",
+			zap.String("key", key),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/oauth"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/repositories"
+	"github.com/multitask-platform/backend/shared/events"
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+// Social login sends the user to an external provider, which redirects them
+// to the frontend with an authorization code for the frontend to hand back.
+// The provider account is matched to a user by an earlier link first, then by
+// verified email; with no match it becomes a new passwordless account.
+//
+// The provider stands in for the password only: accounts with MFA still get
+// the second step.
+//
+// The state alone doesn't prove the callback comes from the browser that
+// started the login: an attacker could hand a victim a callback carrying
+// their own code and state and sign the victim into the attacker's account.
+// Each login therefore also gets a binding secret, kept by the browser in a
+// cookie and only as a hash in the state.
+
+// StartOAuthLogin begins a login with the named provider and returns where
+// to send the user
+func (s *AuthService) StartOAuthLogin(ctx context.Context, providerName string) (*models.OAuthStartResponse, error) {
+	provider, ok := s.oauth[providerName]
+	if !ok {
+		return nil, ErrUnknownOAuthProvider
+	}
+
+	authReq, err := oauth.NewAuthRequest(s.oauthRedirectURI(providerName))
+	if err != nil {
+		return nil, err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, authReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build authorization URL: %w", err)
+	}
+
+	binding, err := s.generateSecureToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate oauth binding: %w", err)
+	}
+
+	err = s.identities.SaveOAuthState(ctx, &models.OAuthState{
+		State:        authReq.State,
+		Provider:     providerName,
+		Nonce:        authReq.Nonce,
+		CodeVerifier: authReq.CodeVerifier,
+		RedirectURI:  authReq.RedirectURI,
+		BindingHash:  hashOAuthBinding(binding),
+		ExpiresAt:    s.now().UTC().Add(models.OAuthStateDuration),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save oauth state: %w", err)
+	}
+
+	return &models.OAuthStartResponse{
+		AuthorizationURL: authURL,
+		State:            authReq.State,
+		ExpiresIn:        int64(models.OAuthStateDuration.Seconds()),
+		Binding:          binding,
+	}, nil
+}
+
+// FinishOAuthLogin redeems the code the provider sent the user back with and
+// logs in the user its account belongs to
+func (s *AuthService) FinishOAuthLogin(ctx context.Context, providerName string, req *models.OAuthCallbackRequest) (*models.AuthResponse, error) {
+	provider, ok := s.oauth[providerName]
+	if !ok {
+		return nil, ErrUnknownOAuthProvider
+	}
+
+	// Consumed whatever happens next, so a state can't be tried twice
+	state, err := s.identities.ConsumeOAuthState(ctx, req.State)
+	if err != nil {
+		if err == repositories.ErrTokenNotFound || err == repositories.ErrTokenExpired {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get oauth state: %w", err)
+	}
+	if state.Provider != providerName {
+		return nil, ErrInvalidToken
+	}
+	if req.Binding == "" || subtle.ConstantTimeCompare([]byte(hashOAuthBinding(req.Binding)), []byte(state.BindingHash)) != 1 {
+		logger.LogSecurityEvent(ctx, "oauth_binding_mismatch",
+			zap.String("provider", providerName),
+			zap.String("ip_address", req.IPAddress),
+		)
+		return nil, ErrInvalidToken
+	}
+
+	identity, err := provider.Exchange(ctx, req.Code, &oauth.AuthRequest{
+		State:        state.State,
+		Nonce:        state.Nonce,
+		CodeVerifier: state.CodeVerifier,
+		RedirectURI:  state.RedirectURI,
+	})
+	if err != nil {
+		if errors.Is(err, oauth.ErrAuthentication) {
+			logger.WarnCtx(ctx, "OAuth login rejected", zap.String("provider", providerName), zap.Error(err))
+			return nil, ErrOAuthFailed
+		}
+		return nil, fmt.Errorf("failed to exchange oauth code: %w", err)
+	}
+
+	user, err := s.oauthUser(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.IsActive {
+		return nil, ErrUserDisabled
+	}
+
+	if err := s.checkUserLock(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	mfa, err := s.mfaStatus(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa.Enabled {
+		return nil, s.mfaChallenge(ctx, user, mfa.Methods, []string{models.AuthMethodFederated})
+	}
+
+	return s.completeLogin(ctx, user, &models.SessionCreateRequest{
+		UserID:      user.ID,
+		DeviceID:    req.DeviceID,
+		UserAgent:   req.UserAgent,
+		IPAddress:   req.IPAddress,
+		AuthMethods: []string{models.AuthMethodFederated},
+	})
+}
+
+// oauthUser finds the user a provider account signs in as, linking or
+// creating one on its first login
+func (s *AuthService) oauthUser(ctx context.Context, identity *oauth.Identity) (*models.User, error) {
+	linked, err := s.identities.GetIdentity(ctx, identity.Provider, identity.Subject)
+	if err == nil {
+		user, err := s.userRepo.GetUser(ctx, linked.UserID)
+		if err != nil {
+			if err == repositories.ErrUserNotFound {
+				logger.WarnCtx(ctx, "Linked identity belongs to a deleted user", zap.String("provider", identity.Provider))
+				return nil, ErrOAuthFailed
+			}
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		return user, nil
+	}
+	if err != repositories.ErrIdentityNotFound {
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	// Matching by email trusts the provider's word that the address is theirs
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, ErrOAuthEmailUnverified
+	}
+
+	user, err := s.userRepo.GetUserByEmail(ctx, identity.Email)
+	switch {
+	case err == repositories.ErrUserNotFound:
+		user, err = s.createOAuthUser(ctx, identity)
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	case !user.IsVerified:
+		// Anyone can register an address they don't own; linking would let
+		// whoever set that account's password into the provider user's account
+		return nil, ErrUserNotVerified
+	}
+
+	err = s.identities.LinkIdentity(ctx, &models.LinkedIdentity{
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		UserID:   user.ID,
+		Email:    identity.Email,
+		LinkedAt: s.now().UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	logger.LogSecurityEvent(ctx, "oauth_identity_linked",
+		zap.String("user_id", user.ID),
+		zap.String("provider", identity.Provider),
+	)
+
+	return user, nil
+}
+
+// createOAuthUser registers a passwordless user for a provider account. The
+// provider verified the email, so the account starts verified; a password
+// can be added later through the reset flow.
+func (s *AuthService) createOAuthUser(ctx context.Context, identity *oauth.Identity) (*models.User, error) {
+	name := identity.Name
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+
+	now := s.now().UTC()
+	user := &models.User{
+		ID:         s.newID(),
+		Email:      identity.Email,
+		Name:       name,
+		IsVerified: true,
+		IsActive:   true,
+		Roles:      []string{models.RoleUser},
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	err := s.userRepo.CreateUser(ctx, user, "", s.outboxEvents(ctx, events.UserRegistered{
+		UserID: user.ID,
+		Email:  user.Email,
+		Name:   user.Name,
+		Method: identity.Provider,
+	})...)
+	if err != nil {
+		if err == repositories.ErrUserExists {
+			return nil, ErrUserAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	logger.InfoCtx(ctx, "User registered through social login",
+		zap.String("user_id", user.ID),
+		zap.String("provider", identity.Provider),
+	)
+
+	return user, nil
+}
+
+// oauthRedirectURI is where the provider sends the user back to
+func (s *AuthService) oauthRedirectURI(providerName string) string {
+	return strings.TrimSuffix(s.cfg.OAuth.RedirectBaseURL, "/") + "/" + providerName + "/callback"
+}
+
+// hashOAuthBinding returns the SHA-256 of a browser binding, which is all the
+// state keeps of it
+func hashOAuthBinding(binding string) string {
+	sum := sha256.Sum256([]byte(binding))
+	return hex.EncodeToString(sum[:])
+}
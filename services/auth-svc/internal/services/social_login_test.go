@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/oauth"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/oauth/oauthtest"
+)
+
+// newSocialLoginService creates an AuthService with the fake OIDC provider
+// registered as "dev"
+func newSocialLoginService(t *testing.T, providerCfg oauthtest.Config) (*AuthService, *testMailer) {
+	t.Helper()
+
+	providerCfg.ClientID = "dev-client"
+	providerCfg.ClientSecret = "dev-secret"
+	srv, err := oauthtest.NewServer(providerCfg)
+	if err != nil {
+		t.Fatalf("oauthtest.NewServer: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	provider, err := oauth.New(oauth.Config{
+		Name:         "dev",
+		ClientID:     "dev-client",
+		ClientSecret: "dev-secret",
+		Issuer:       srv.URL,
+	}, srv.Client())
+	if err != nil {
+		t.Fatalf("oauth.New: %v", err)
+	}
+
+	cfg := newTestConfig()
+	cfg.OAuth.RedirectBaseURL = "http://localhost:3000/oauth"
+	return newTestService(t, cfg, WithOAuthProviders(provider))
+}
+
+// authorize follows an authorization URL, optionally signing in as
+// loginHint, and returns the code and state the provider redirects back with
+func authorize(t *testing.T, authorizationURL, loginHint string) (code, state string) {
+	t.Helper()
+
+	u, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if loginHint != "" {
+		q := u.Query()
+		q.Set("login_hint", loginHint)
+		u.RawQuery = q.Encode()
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		t.Fatalf("GET %s: %v", u, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize status = %d, want %d", resp.StatusCode, http.StatusFound)
+	}
+
+	callback, err := resp.Location()
+	if err != nil {
+		t.Fatalf("Location: %v", err)
+	}
+	if got := callback.Scheme + "://" + callback.Host + callback.Path; got != "http://localhost:3000/oauth/dev/callback" {
+		t.Fatalf("redirected to %s, want the dev callback", got)
+	}
+	return callback.Query().Get("code"), callback.Query().Get("state")
+}
+
+func TestAuthServiceSocialLogin(t *testing.T) {
+	ctx := context.Background()
+	s, mailer := newSocialLoginService(t, oauthtest.Config{Email: "jane@example.com", Name: "Jane"})
+
+	// An unverified password account for the address the provider vouches for
+	if _, err := s.Register(ctx, &models.RegisterRequest{Email: "pending@example.com", Password: "correct horse", Name: "Pending"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	existing := registerVerifiedUser(t, s, mailer, "john@example.com", "correct horse")
+
+	tests := []struct {
+		name      string
+		loginHint string
+		// finish adjusts the callback before it is sent
+		finish     func(t *testing.T, provider *string, req *models.OAuthCallbackRequest)
+		wantErr    error
+		wantUserID string // when set, the user the login must resolve to
+	}{
+		{name: "new user"},
+		{name: "existing verified user is linked", loginHint: "john@example.com", wantUserID: existing.ID},
+		{name: "unverified local account", loginHint: "pending@example.com", wantErr: ErrUserNotVerified},
+		{
+			name:    "binding from another browser",
+			finish:  func(t *testing.T, _ *string, req *models.OAuthCallbackRequest) { req.Binding = "someone-elses-cookie" },
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "no binding cookie",
+			finish:  func(t *testing.T, _ *string, req *models.OAuthCallbackRequest) { req.Binding = "" },
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "unknown state",
+			finish:  func(t *testing.T, _ *string, req *models.OAuthCallbackRequest) { req.State = "forged" },
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "unknown provider",
+			finish:  func(t *testing.T, provider *string, _ *models.OAuthCallbackRequest) { *provider = "github" },
+			wantErr: ErrUnknownOAuthProvider,
+		},
+		{
+			// The code was issued for another login's PKCE challenge
+			name: "code from another login",
+			finish: func(t *testing.T, _ *string, req *models.OAuthCallbackRequest) {
+				other, err := s.StartOAuthLogin(ctx, "dev")
+				if err != nil {
+					t.Fatalf("StartOAuthLogin: %v", err)
+				}
+				req.Code, _ = authorize(t, other.AuthorizationURL, "")
+			},
+			wantErr: ErrOAuthFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, err := s.StartOAuthLogin(ctx, "dev")
+			if err != nil {
+				t.Fatalf("StartOAuthLogin: %v", err)
+			}
+			code, state := authorize(t, start.AuthorizationURL, tt.loginHint)
+			if state != start.State {
+				t.Fatalf("provider returned state %q, want %q", state, start.State)
+			}
+
+			provider := "dev"
+			req := &models.OAuthCallbackRequest{Code: code, State: state, Binding: start.Binding}
+			if tt.finish != nil {
+				tt.finish(t, &provider, req)
+			}
+
+			response, err := s.FinishOAuthLogin(ctx, provider, req)
+			if err != tt.wantErr {
+				t.Fatalf("FinishOAuthLogin() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if response.AccessToken == "" || !response.User.IsVerified {
+				t.Fatalf("FinishOAuthLogin() = %+v, want a session for a verified user", response)
+			}
+			if tt.wantUserID != "" && response.User.ID != tt.wantUserID {
+				t.Fatalf("signed in as %s, want %s", response.User.ID, tt.wantUserID)
+			}
+
+			// The state is single use
+			if _, err := s.FinishOAuthLogin(ctx, provider, req); err != ErrInvalidToken {
+				t.Fatalf("second FinishOAuthLogin() error = %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestAuthServiceSocialLoginSignsInTheLinkedUser(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newSocialLoginService(t, oauthtest.Config{Email: "jane@example.com", Name: "Jane"})
+
+	login := func() *models.AuthResponse {
+		t.Helper()
+		start, err := s.StartOAuthLogin(ctx, "dev")
+		if err != nil {
+			t.Fatalf("StartOAuthLogin: %v", err)
+		}
+		code, state := authorize(t, start.AuthorizationURL, "")
+		response, err := s.FinishOAuthLogin(ctx, "dev", &models.OAuthCallbackRequest{Code: code, State: state, Binding: start.Binding})
+		if err != nil {
+			t.Fatalf("FinishOAuthLogin: %v", err)
+		}
+		return response
+	}
+
+	first, second := login(), login()
+	if first.User.ID != second.User.ID {
+		t.Fatalf("second login signed in as %s, want %s", second.User.ID, first.User.ID)
+	}
+}
+
+func TestAuthServiceSocialLoginUnverifiedEmail(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newSocialLoginService(t, oauthtest.Config{Email: "jane@example.com", EmailUnverified: true})
+
+	start, err := s.StartOAuthLogin(ctx, "dev")
+	if err != nil {
+		t.Fatalf("StartOAuthLogin: %v", err)
+	}
+	code, state := authorize(t, start.AuthorizationURL, "")
+
+	_, err = s.FinishOAuthLogin(ctx, "dev", &models.OAuthCallbackRequest{Code: code, State: state, Binding: start.Binding})
+	if err != ErrOAuthEmailUnverified {
+		t.Fatalf("FinishOAuthLogin() error = %v, want %v", err, ErrOAuthEmailUnverified)
+	}
+}
@@ -0,0 +1,437 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/multitask-platform/backend/services/auth-svc/internal/models"
+	"github.com/multitask-platform/backend/services/auth-svc/internal/webauthn"
+	"github.com/multitask-platform/backend/shared/jwtkeys"
+)
+
+// TokenIssuer signs and parses the JWTs handed out by AuthService
+type TokenIssuer interface {
+	GenerateAccessToken(user *models.User, sessionID string) (string, error)
+	GenerateScopedAccessToken(user *models.User, sessionID, clientID string, scopes []string) (string, error)
+	GenerateClientAccessToken(clientID string, scopes []string) (string, error)
+	GenerateRefreshToken(userID, sessionID, tokenID string) (string, error)
+	GenerateAnonymousToken(sessionID string) (string, error)
+	GenerateMFAChallengeToken(userID string, authMethods []string, webauthnChallenge []byte) (string, error)
+	GenerateWebAuthnChallengeToken(tokenType, userID string, challenge []byte) (string, error)
+	GenerateIDToken(claims *models.IDTokenClaims) (string, error)
+	SigningAlgorithms() []string
+	ParseRefreshToken(tokenString string) (*models.TokenClaims, error)
+	ParseToken(tokenString string) (*models.TokenClaims, error)
+	ParseMFAChallengeToken(tokenString string) (*models.ChallengeClaims, error)
+	ParseWebAuthnChallengeToken(tokenString, tokenType string) (*models.ChallengeClaims, error)
+}
+
+// KeyTokenIssuer signs tokens with the asymmetric signing key of a key set.
+// Every token carries the key's id in its kid header so verifiers holding only
+// the public keys can pick the right one.
+type KeyTokenIssuer struct {
+	keys *jwtkeys.KeySet
+	now  func() time.Time
+}
+
+// NewKeyTokenIssuer creates a token issuer. now supplies the issue time and
+// defaults to the wall clock when nil.
+func NewKeyTokenIssuer(keys *jwtkeys.KeySet, now func() time.Time) *KeyTokenIssuer {
+	if now == nil {
+		now = time.Now
+	}
+	return &KeyTokenIssuer{
+		keys: keys,
+		now:  now,
+	}
+}
+
+// Keys returns the key set, e.g. to publish it as a JWKS
+func (i *KeyTokenIssuer) Keys() *jwtkeys.KeySet {
+	return i.keys
+}
+
+func (i *KeyTokenIssuer) GenerateAccessToken(user *models.User, sessionID string) (string, error) {
+	now := i.now().UTC()
+	expiresAt := now.Add(models.DefaultSessionDuration)
+
+	claims := &models.TokenClaims{
+		UserID:    user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		Roles:     user.Roles,
+		SessionID: sessionID,
+		TokenID:   uuid.New().String(),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	}
+
+	tokenString, err := i.keys.Sign(jwt.MapClaims{
+		"sub":        claims.UserID,
+		"email":      claims.Email,
+		"name":       claims.Name,
+		"roles":      claims.Roles,
+		"session_id": claims.SessionID,
+		"jti":        claims.TokenID,
+		"iat":        claims.IssuedAt,
+		"exp":        claims.ExpiresAt,
+		"type":       models.TokenTypeAccess,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return tokenString, nil
+}
+
+// GenerateScopedAccessToken signs an access token delegated to an OAuth2
+// client. It never carries the user's roles, and carries their email and name
+// only if the matching scopes were granted.
+func (i *KeyTokenIssuer) GenerateScopedAccessToken(user *models.User, sessionID, clientID string, scopes []string) (string, error) {
+	now := i.now().UTC()
+	expiresAt := now.Add(models.DefaultSessionDuration)
+
+	claims := jwt.MapClaims{
+		"sub":        user.ID,
+		"session_id": sessionID,
+		"client_id":  clientID,
+		"scope":      strings.Join(scopes, " "),
+		"jti":        uuid.New().String(),
+		"iat":        now.Unix(),
+		"exp":        expiresAt.Unix(),
+		"type":       models.TokenTypeAccess,
+	}
+	if slices.Contains(scopes, models.ScopeEmail) {
+		claims["email"] = user.Email
+	}
+	if slices.Contains(scopes, models.ScopeProfile) {
+		claims["name"] = user.Name
+	}
+
+	tokenString, err := i.keys.Sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return tokenString, nil
+}
+
+// GenerateClientAccessToken signs an access token for a client acting on its
+// own behalf. It has its own type and no subject, so it can't pass for a
+// user's token where services look at sub.
+func (i *KeyTokenIssuer) GenerateClientAccessToken(clientID string, scopes []string) (string, error) {
+	now := i.now().UTC()
+	expiresAt := now.Add(models.DefaultSessionDuration)
+
+	tokenString, err := i.keys.Sign(jwt.MapClaims{
+		"client_id": clientID,
+		"scope":     strings.Join(scopes, " "),
+		"jti":       uuid.New().String(),
+		"iat":       now.Unix(),
+		"exp":       expiresAt.Unix(),
+		"type":      models.TokenTypeClientAccess,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return tokenString, nil
+}
+
+// GenerateRefreshToken signs a refresh token; tokenID becomes its jti so the
+// session can tell the current token from ones it has already rotated
+func (i *KeyTokenIssuer) GenerateRefreshToken(userID, sessionID, tokenID string) (string, error) {
+	now := i.now().UTC()
+	expiresAt := now.Add(models.DefaultRefreshDuration)
+
+	tokenString, err := i.keys.Sign(jwt.MapClaims{
+		"sub":        userID,
+		"session_id": sessionID,
+		"jti":        tokenID,
+		"iat":        now.Unix(),
+		"exp":        expiresAt.Unix(),
+		"type":       models.TokenTypeRefresh,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign refresh token: %w", err)
+	}
+
+	return tokenString, nil
+}
+
+func (i *KeyTokenIssuer) GenerateAnonymousToken(sessionID string) (string, error) {
+	now := i.now().UTC()
+	expiresAt := now.Add(models.DefaultAnonymousDuration)
+
+	tokenString, err := i.keys.Sign(jwt.MapClaims{
+		"session_id": sessionID,
+		"iat":        now.Unix(),
+		"exp":        expiresAt.Unix(),
+		"type":       "anonymous",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign anonymous token: %w", err)
+	}
+
+	return tokenString, nil
+}
+
+// GenerateMFAChallengeToken signs the short-lived token that links a
+// password check to the second factor that completes the login. authMethods
+// says how the first step was passed. For users with passkeys it also carries
+// the WebAuthn challenge they must sign.
+func (i *KeyTokenIssuer) GenerateMFAChallengeToken(userID string, authMethods []string, webauthnChallenge []byte) (string, error) {
+	now := i.now().UTC()
+	expiresAt := now.Add(models.MFAChallengeDuration)
+
+	claims := jwt.MapClaims{
+		"sub":  userID,
+		"amr":  authMethods,
+		"iat":  now.Unix(),
+		"exp":  expiresAt.Unix(),
+		"type": models.TokenTypeMFAChallenge,
+	}
+	if len(webauthnChallenge) > 0 {
+		claims["challenge"] = base64.RawURLEncoding.EncodeToString(webauthnChallenge)
+	}
+
+	tokenString, err := i.keys.Sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign MFA challenge token: %w", err)
+	}
+
+	return tokenString, nil
+}
+
+// GenerateWebAuthnChallengeToken signs a WebAuthn ceremony's challenge and
+// binds it to the ceremony and user. The challenge itself is also stored
+// server-side and consumed on finish, so each token works once.
+// userID is empty for passwordless logins, where the user isn't known yet.
+func (i *KeyTokenIssuer) GenerateWebAuthnChallengeToken(tokenType, userID string, challenge []byte) (string, error) {
+	now := i.now().UTC()
+	expiresAt := now.Add(webauthn.ChallengeTimeout)
+
+	tokenString, err := i.keys.Sign(jwt.MapClaims{
+		"sub":       userID,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"iat":       now.Unix(),
+		"exp":       expiresAt.Unix(),
+		"type":      tokenType,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign WebAuthn challenge token: %w", err)
+	}
+
+	return tokenString, nil
+}
+
+// GenerateIDToken signs an OpenID Connect ID token for the client in
+// claims.Audience
+func (i *KeyTokenIssuer) GenerateIDToken(claims *models.IDTokenClaims) (string, error) {
+	now := i.now().UTC()
+	expiresAt := now.Add(models.IDTokenDuration)
+
+	token := jwt.MapClaims{
+		"iss":       claims.Issuer,
+		"sub":       claims.Subject,
+		"aud":       claims.Audience,
+		"iat":       now.Unix(),
+		"exp":       expiresAt.Unix(),
+		"auth_time": claims.AuthTime.Unix(),
+		"type":      models.TokenTypeID,
+	}
+	if claims.Nonce != "" {
+		token["nonce"] = claims.Nonce
+	}
+	if len(claims.AuthMethods) > 0 {
+		token["amr"] = claims.AuthMethods
+	}
+	if claims.AuthContextClass != "" {
+		token["acr"] = claims.AuthContextClass
+	}
+	if claims.Name != "" {
+		token["name"] = claims.Name
+	}
+	if claims.UpdatedAt != 0 {
+		token["updated_at"] = claims.UpdatedAt
+	}
+	if claims.Email != "" {
+		token["email"] = claims.Email
+	}
+	if claims.EmailVerified != nil {
+		token["email_verified"] = *claims.EmailVerified
+	}
+
+	tokenString, err := i.keys.Sign(token)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign ID token: %w", err)
+	}
+
+	return tokenString, nil
+}
+
+// SigningAlgorithms lists the algorithms of the published keys, including
+// ones that haven't started signing yet, for discovery
+func (i *KeyTokenIssuer) SigningAlgorithms() []string {
+	now := i.now()
+
+	var algorithms []string
+	for _, key := range i.keys.Keys() {
+		if !key.ExpiredAt(now) && !slices.Contains(algorithms, key.Algorithm) {
+			algorithms = append(algorithms, key.Algorithm)
+		}
+	}
+	return algorithms
+}
+
+func (i *KeyTokenIssuer) ParseRefreshToken(tokenString string) (*models.TokenClaims, error) {
+	claims, err := i.parse(tokenString, models.TokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, _ := claims["sub"].(string)
+	sessionID, _ := claims["session_id"].(string)
+	tokenID, _ := claims["jti"].(string)
+
+	if userID == "" || sessionID == "" {
+		return nil, ErrInvalidToken
+	}
+
+	return &models.TokenClaims{
+		UserID:    userID,
+		SessionID: sessionID,
+		TokenID:   tokenID,
+	}, nil
+}
+
+// ParseToken parses an access or refresh token, e.g. one presented for
+// introspection or revocation
+func (i *KeyTokenIssuer) ParseToken(tokenString string) (*models.TokenClaims, error) {
+	claims, err := i.parse(tokenString, models.TokenTypeAccess, models.TokenTypeClientAccess, models.TokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := &models.TokenClaims{}
+	parsed.UserID, _ = claims["sub"].(string)
+	parsed.Email, _ = claims["email"].(string)
+	parsed.Name, _ = claims["name"].(string)
+	parsed.SessionID, _ = claims["session_id"].(string)
+	parsed.TokenID, _ = claims["jti"].(string)
+	parsed.Type, _ = claims["type"].(string)
+	parsed.ClientID, _ = claims["client_id"].(string)
+	if scope, _ := claims["scope"].(string); scope != "" {
+		parsed.Scopes = strings.Fields(scope)
+	}
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		parsed.IssuedAt = iat.Unix()
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		parsed.ExpiresAt = exp.Unix()
+	}
+
+	if parsed.Type == models.TokenTypeClientAccess {
+		if parsed.ClientID == "" || parsed.UserID != "" {
+			return nil, ErrInvalidToken
+		}
+		return parsed, nil
+	}
+	if parsed.UserID == "" {
+		return nil, ErrInvalidToken
+	}
+	return parsed, nil
+}
+
+func (i *KeyTokenIssuer) ParseMFAChallengeToken(tokenString string) (*models.ChallengeClaims, error) {
+	claims, err := i.parse(tokenString, models.TokenTypeMFAChallenge)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := challengeClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.UserID == "" {
+		return nil, ErrInvalidToken
+	}
+	return challenge, nil
+}
+
+func (i *KeyTokenIssuer) ParseWebAuthnChallengeToken(tokenString, tokenType string) (*models.ChallengeClaims, error) {
+	claims, err := i.parse(tokenString, tokenType)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := challengeClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+	if len(challenge.Challenge) == 0 {
+		return nil, ErrInvalidToken
+	}
+	return challenge, nil
+}
+
+// challengeClaims extracts the user and WebAuthn challenge from a challenge
+// token's claims
+func challengeClaims(claims jwt.MapClaims) (*models.ChallengeClaims, error) {
+	userID, _ := claims["sub"].(string)
+	encoded, _ := claims["challenge"].(string)
+
+	challenge, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var authMethods []string
+	amr, _ := claims["amr"].([]interface{})
+	for _, method := range amr {
+		if m, ok := method.(string); ok {
+			authMethods = append(authMethods, m)
+		}
+	}
+
+	return &models.ChallengeClaims{
+		UserID:      userID,
+		Challenge:   challenge,
+		AuthMethods: authMethods,
+	}, nil
+}
+
+// parse verifies the token's signature and expiry and that its type claim is
+// one of tokenTypes
+func (i *KeyTokenIssuer) parse(tokenString string, tokenTypes ...string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, i.keys.Keyfunc,
+		jwt.WithValidMethods(jwtkeys.ValidMethods),
+		jwt.WithTimeFunc(i.now),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	// Verify token type
+	if claimType, _ := claims["type"].(string); !slices.Contains(tokenTypes, claimType) {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
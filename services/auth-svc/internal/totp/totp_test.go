@@ -0,0 +1,123 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 seed from RFC 6238 appendix B, "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// RFC 6238 appendix B gives 8 digit codes; 6 digit codes are their last
+	// six digits since both are the same value mod a power of ten
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(time.Unix(tt.unix, 0).UTC().Format(time.RFC3339), func(t *testing.T) {
+			got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+			if err != nil {
+				t.Fatalf("Code: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Code() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// Secrets are accepted in either case
+	if got, err := Code(strings.ToLower(rfcSecret), Step(time.Unix(59, 0))); err != nil || got != "287082" {
+		t.Fatalf("Code(lower case secret) = %s, %v; want 287082", got, err)
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Fatal("Code() accepted an invalid secret")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+
+	codeAt := func(step int64) string {
+		code, err := Code(rfcSecret, step)
+		if err != nil {
+			t.Fatalf("Code: %v", err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current step", code: codeAt(current), wantStep: current, wantOK: true},
+		{name: "previous step", code: codeAt(current - Skew), wantStep: current - Skew, wantOK: true},
+		{name: "next step", code: codeAt(current + Skew), wantStep: current + Skew, wantOK: true},
+		{name: "beyond the skew in the past", code: codeAt(current - Skew - 1)},
+		{name: "beyond the skew in the future", code: codeAt(current + Skew + 1)},
+		{name: "typed with a space", code: codeAt(current)[:3] + " " + codeAt(current)[3:], wantStep: current, wantOK: true},
+		{name: "too short", code: codeAt(current)[:Digits-1]},
+		{name: "too long", code: codeAt(current) + "0"},
+		{name: "empty", code: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, tt.code, now)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Fatalf("Validate() = %d, %v; want %d, %v", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+	key, err := encoding.DecodeString(secret)
+	if err != nil || len(key) != secretSize {
+		t.Fatalf("secret %q decodes to %d bytes (%v), want %d", secret, len(key), err, secretSize)
+	}
+
+	other, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+	if other == secret {
+		t.Fatal("GenerateSecret() returned the same secret twice")
+	}
+}
+
+func TestKeyURI(t *testing.T) {
+	uri, err := url.Parse(KeyURI("Multitask", "jane@example.com", rfcSecret))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Multitask:jane@example.com" {
+		t.Fatalf("KeyURI() = %s, want otpauth://totp/Multitask:jane@example.com", uri)
+	}
+	query := uri.Query()
+	want := map[string]string{"secret": rfcSecret, "issuer": "Multitask", "algorithm": "SHA1", "digits": "6", "period": "30"}
+	for key, value := range want {
+		if got := query.Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
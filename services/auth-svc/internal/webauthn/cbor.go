@@ -0,0 +1,145 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// A minimal CBOR (RFC 8949) decoder covering what authenticators send:
+// definite-length integers, byte and text strings, arrays, maps and simple
+// values. Integers decode to int64, byte strings to []byte, text to string,
+// arrays to []interface{} and maps to map[interface{}]interface{}.
+
+var errCBORTruncated = errors.New("cbor: unexpected end of data")
+
+// maxCBORDepth bounds nesting so hostile input can't exhaust the stack
+const maxCBORDepth = 16
+
+// decodeCBOR decodes one item from data and returns it with the bytes that
+// follow it
+func decodeCBOR(data []byte) (interface{}, []byte, error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (interface{}, []byte, error) {
+	if depth > maxCBORDepth {
+		return nil, nil, errors.New("cbor: nesting too deep")
+	}
+	if len(data) == 0 {
+		return nil, nil, errCBORTruncated
+	}
+
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+
+	// Simple values and floats carry their payload in the argument
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, data[1:], nil
+		case 21:
+			return true, data[1:], nil
+		case 22, 23:
+			return nil, data[1:], nil
+		default:
+			return nil, nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+		}
+	}
+
+	arg, rest, err := readCBORArgument(info, data[1:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > 1<<63-1 {
+			return nil, nil, errors.New("cbor: integer overflows int64")
+		}
+		return int64(arg), rest, nil
+	case 1:
+		if arg > 1<<63-1 {
+			return nil, nil, errors.New("cbor: integer overflows int64")
+		}
+		return -1 - int64(arg), rest, nil
+	case 2, 3:
+		if uint64(len(rest)) < arg {
+			return nil, nil, errCBORTruncated
+		}
+		if major == 2 {
+			return append([]byte(nil), rest[:arg]...), rest[arg:], nil
+		}
+		return string(rest[:arg]), rest[arg:], nil
+	case 4:
+		// Every item takes at least one byte
+		if uint64(len(rest)) < arg {
+			return nil, nil, errCBORTruncated
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			var item interface{}
+			item, rest, err = decodeCBORItem(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, rest, nil
+	case 5:
+		if uint64(len(rest))/2 < arg {
+			return nil, nil, errCBORTruncated
+		}
+		m := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			var key, value interface{}
+			key, rest, err = decodeCBORItem(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errors.New("cbor: unsupported map key type")
+			}
+			value, rest, err = decodeCBORItem(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			m[key] = value
+		}
+		return m, rest, nil
+	default:
+		return nil, nil, fmt.Errorf("cbor: unsupported major type %d", major)
+	}
+}
+
+// readCBORArgument reads the argument that follows an initial byte
+func readCBORArgument(info byte, data []byte) (uint64, []byte, error) {
+	switch {
+	case info < 24:
+		return uint64(info), data, nil
+	case info == 24:
+		if len(data) < 1 {
+			return 0, nil, errCBORTruncated
+		}
+		return uint64(data[0]), data[1:], nil
+	case info == 25:
+		if len(data) < 2 {
+			return 0, nil, errCBORTruncated
+		}
+		return uint64(binary.BigEndian.Uint16(data)), data[2:], nil
+	case info == 26:
+		if len(data) < 4 {
+			return 0, nil, errCBORTruncated
+		}
+		return uint64(binary.BigEndian.Uint32(data)), data[4:], nil
+	case info == 27:
+		if len(data) < 8 {
+			return 0, nil, errCBORTruncated
+		}
+		return binary.BigEndian.Uint64(data), data[8:], nil
+	default:
+		return 0, nil, errors.New("cbor: indefinite lengths are not supported")
+	}
+}
@@ -0,0 +1,172 @@
+package webauthn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// encodeCBOR is the inverse of decodeCBOR for the types it returns, so tests
+// can build attestation objects and COSE keys
+func encodeCBOR(v interface{}) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n <= 0xff:
+			return []byte{major<<5 | 24, byte(n)}
+		case n <= 0xffff:
+			return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+		case n <= 0xffffffff:
+			return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+		default:
+			return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, n)
+		}
+	}
+
+	switch v := v.(type) {
+	case int:
+		return encodeCBOR(int64(v))
+	case int64:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case []interface{}:
+		out := head(4, uint64(len(v)))
+		for _, item := range v {
+			out = append(out, encodeCBOR(item)...)
+		}
+		return out
+	case map[interface{}]interface{}:
+		out := head(5, uint64(len(v)))
+		for key, value := range v {
+			out = append(out, encodeCBOR(key)...)
+			out = append(out, encodeCBOR(value)...)
+		}
+		return out
+	case bool:
+		if v {
+			return []byte{0xf5}
+		}
+		return []byte{0xf4}
+	case nil:
+		return []byte{0xf6}
+	default:
+		panic(fmt.Sprintf("encodeCBOR: unsupported type %T", v))
+	}
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	// Most inputs are from RFC 8949 appendix A
+	tests := []struct {
+		name     string
+		input    []byte
+		want     interface{}
+		wantRest []byte
+	}{
+		{name: "zero", input: []byte{0x00}, want: int64(0)},
+		{name: "largest direct integer", input: []byte{0x17}, want: int64(23)},
+		{name: "one byte integer", input: []byte{0x18, 0x18}, want: int64(24)},
+		{name: "two byte integer", input: []byte{0x19, 0x03, 0xe8}, want: int64(1000)},
+		{name: "four byte integer", input: []byte{0x1a, 0x00, 0x0f, 0x42, 0x40}, want: int64(1000000)},
+		{name: "eight byte integer", input: []byte{0x1b, 0x00, 0x00, 0x00, 0xe8, 0xd4, 0xa5, 0x10, 0x00}, want: int64(1000000000000)},
+		{name: "minus one", input: []byte{0x20}, want: int64(-1)},
+		{name: "negative", input: []byte{0x39, 0x03, 0xe7}, want: int64(-1000)},
+		{name: "byte string", input: []byte{0x44, 0x01, 0x02, 0x03, 0x04}, want: []byte{1, 2, 3, 4}},
+		{name: "text string", input: []byte{0x64, 0x49, 0x45, 0x54, 0x46}, want: "IETF"},
+		{name: "empty text string", input: []byte{0x60}, want: ""},
+		{name: "array", input: []byte{0x83, 0x01, 0x02, 0x03}, want: []interface{}{int64(1), int64(2), int64(3)}},
+		{name: "nested array", input: []byte{0x82, 0x01, 0x82, 0x02, 0x03}, want: []interface{}{int64(1), []interface{}{int64(2), int64(3)}}},
+		{name: "integer keyed map", input: []byte{0xa2, 0x01, 0x02, 0x03, 0x04}, want: map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(4)}},
+		{name: "text keyed map", input: []byte{0xa1, 0x61, 0x61, 0x01}, want: map[interface{}]interface{}{"a": int64(1)}},
+		{name: "false", input: []byte{0xf4}, want: false},
+		{name: "true", input: []byte{0xf5}, want: true},
+		{name: "null", input: []byte{0xf6}, want: nil},
+		{name: "trailing bytes are returned", input: []byte{0x01, 0x02, 0x03}, want: int64(1), wantRest: []byte{0x02, 0x03}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rest, err := decodeCBOR(tt.input)
+			if err != nil {
+				t.Fatalf("decodeCBOR() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("decodeCBOR() = %#v, want %#v", got, tt.want)
+			}
+			if !bytes.Equal(rest, tt.wantRest) {
+				t.Fatalf("decodeCBOR() rest = %x, want %x", rest, tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{name: "empty", input: nil},
+		{name: "truncated argument", input: []byte{0x19, 0x03}},
+		{name: "truncated byte string", input: []byte{0x44, 0x01, 0x02}},
+		{name: "truncated array", input: []byte{0x83, 0x01, 0x02}},
+		{name: "array longer than the input", input: []byte{0x9a, 0xff, 0xff, 0xff, 0xff}},
+		{name: "map longer than the input", input: []byte{0xba, 0xff, 0xff, 0xff, 0xff, 0x00}},
+		{name: "unsigned overflows int64", input: []byte{0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{name: "negative overflows int64", input: []byte{0x3b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{name: "indefinite length", input: []byte{0x5f, 0x41, 0x01, 0xff}},
+		{name: "tag", input: []byte{0xc1, 0x00}},
+		{name: "float", input: []byte{0xf9, 0x3c, 0x00}},
+		{name: "byte string map key", input: []byte{0xa1, 0x41, 0x00, 0x01}},
+		{name: "map missing a value", input: []byte{0xa1, 0x01}},
+		{name: "nested too deep", input: append(bytes.Repeat([]byte{0x81}, maxCBORDepth+1), 0x00)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _, err := decodeCBOR(tt.input); err == nil {
+				t.Fatalf("decodeCBOR(%x) = %#v, want an error", tt.input, got)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORMaxDepth(t *testing.T) {
+	input := append(bytes.Repeat([]byte{0x81}, maxCBORDepth), 0x00)
+	if _, _, err := decodeCBOR(input); err != nil {
+		t.Fatalf("decodeCBOR() at the depth limit error = %v", err)
+	}
+}
+
+func TestDecodeCBORRoundTrip(t *testing.T) {
+	values := []interface{}{
+		int64(255),
+		int64(256),
+		int64(65536),
+		int64(-257),
+		int64(1 << 40),
+		bytes.Repeat([]byte{0xab}, 300),
+		map[interface{}]interface{}{
+			"fmt":     "none",
+			"attStmt": map[interface{}]interface{}{},
+			int64(-2): []byte{1, 2},
+		},
+	}
+
+	for _, want := range values {
+		got, rest, err := decodeCBOR(encodeCBOR(want))
+		if err != nil {
+			t.Fatalf("decodeCBOR(encodeCBOR(%#v)) error = %v", want, err)
+		}
+		if len(rest) != 0 || !reflect.DeepEqual(got, want) {
+			t.Fatalf("decodeCBOR(encodeCBOR(%#v)) = %#v, rest %x", want, got, rest)
+		}
+	}
+}
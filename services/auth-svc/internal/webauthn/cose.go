@@ -0,0 +1,129 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers (RFC 9053) accepted for credentials, in order of
+// preference
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+// SupportedAlgorithms is offered to authenticators at registration
+var SupportedAlgorithms = []int64{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters (RFC 9052 section 7, RFC 9053 section 7)
+const (
+	coseKeyType  = 1
+	coseKeyAlg   = 3
+	coseCurve    = -1
+	coseX        = -2
+	coseY        = -3
+	coseRSAN     = -1
+	coseRSAE     = -2
+	coseKtyOKP   = 1
+	coseKtyEC2   = 2
+	coseKtyRSA   = 3
+	coseCrvP256  = 1
+	coseCrvEd255 = 6
+)
+
+// minRSABits rejects weak RSA credential keys
+const minRSABits = 2048
+
+// publicKey is a parsed COSE_Key
+type publicKey struct {
+	alg int64
+	key crypto.PublicKey
+}
+
+// parsePublicKey parses a CBOR-encoded COSE_Key
+func parsePublicKey(data []byte) (*publicKey, error) {
+	decoded, rest, err := decodeCBOR(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("trailing data after public key")
+	}
+	return publicKeyFromCOSE(decoded)
+}
+
+func publicKeyFromCOSE(decoded interface{}) (*publicKey, error) {
+	m, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("public key is not a COSE_Key map")
+	}
+
+	kty, _ := m[int64(coseKeyType)].(int64)
+	alg, _ := m[int64(coseKeyAlg)].(int64)
+
+	switch {
+	case kty == coseKtyEC2 && alg == AlgES256:
+		crv, _ := m[int64(coseCurve)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+		if crv != coseCrvP256 || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid P-256 public key")
+		}
+		key := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("public key is not on P-256")
+		}
+		return &publicKey{alg: alg, key: key}, nil
+
+	case kty == coseKtyOKP && alg == AlgEdDSA:
+		crv, _ := m[int64(coseCurve)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		if crv != coseCrvEd255 || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key")
+		}
+		return &publicKey{alg: alg, key: ed25519.PublicKey(x)}, nil
+
+	case kty == coseKtyRSA && alg == AlgRS256:
+		n, _ := m[int64(coseRSAN)].([]byte)
+		e, _ := m[int64(coseRSAE)].([]byte)
+		if len(n)*8 < minRSABits || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA public key")
+		}
+		key := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		return &publicKey{alg: alg, key: key}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported public key type %d with algorithm %d", kty, alg)
+	}
+}
+
+// verify checks sig over message with the key's algorithm
+func (k *publicKey) verify(message, sig []byte) bool {
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		return ecdsa.VerifyASN1(key, digest[:], sig)
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, message, sig)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(message)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+	default:
+		return false
+	}
+}
@@ -0,0 +1,129 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"testing"
+)
+
+// coseEC2Key encodes an ES256 COSE_Key for key
+func coseEC2Key(t *testing.T, key *ecdsa.PublicKey) map[interface{}]interface{} {
+	t.Helper()
+
+	pub, err := key.ECDH()
+	if err != nil {
+		t.Fatalf("ECDH: %v", err)
+	}
+	point := pub.Bytes() // 0x04 || X || Y
+	return map[interface{}]interface{}{
+		int64(coseKeyType): int64(coseKtyEC2),
+		int64(coseKeyAlg):  AlgES256,
+		int64(coseCurve):   int64(coseCrvP256),
+		int64(coseX):       point[1:33],
+		int64(coseY):       point[33:],
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, minRSABits)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	message := []byte("authenticator data || client data hash")
+	digest := sha256.Sum256(message)
+	ecSig, err := ecdsa.SignASN1(rand.Reader, ecKey, digest[:])
+	if err != nil {
+		t.Fatalf("SignASN1: %v", err)
+	}
+	rsaSig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("SignPKCS1v15: %v", err)
+	}
+
+	ec2 := coseEC2Key(t, &ecKey.PublicKey)
+	okp := map[interface{}]interface{}{
+		int64(coseKeyType): int64(coseKtyOKP),
+		int64(coseKeyAlg):  AlgEdDSA,
+		int64(coseCurve):   int64(coseCrvEd255),
+		int64(coseX):       []byte(edPub),
+	}
+	rsaCOSE := map[interface{}]interface{}{
+		int64(coseKeyType): int64(coseKtyRSA),
+		int64(coseKeyAlg):  AlgRS256,
+		int64(coseRSAN):    rsaKey.N.Bytes(),
+		int64(coseRSAE):    []byte{0x01, 0x00, 0x01},
+	}
+
+	// with returns a copy of key with one parameter changed
+	with := func(key map[interface{}]interface{}, param int64, value interface{}) map[interface{}]interface{} {
+		out := make(map[interface{}]interface{}, len(key))
+		for k, v := range key {
+			out[k] = v
+		}
+		out[param] = value
+		return out
+	}
+
+	offCurve := make([]byte, 32)
+	offCurve[31] = 1
+
+	tests := []struct {
+		name      string
+		input     []byte
+		wantAlg   int64
+		signature []byte
+		wantErr   bool
+	}{
+		{name: "ES256", input: encodeCBOR(ec2), wantAlg: AlgES256, signature: ecSig},
+		{name: "EdDSA", input: encodeCBOR(okp), wantAlg: AlgEdDSA, signature: ed25519.Sign(edKey, message)},
+		{name: "RS256", input: encodeCBOR(rsaCOSE), wantAlg: AlgRS256, signature: rsaSig},
+		{name: "EC2 on another curve", input: encodeCBOR(with(ec2, coseCurve, int64(2))), wantErr: true},
+		{name: "EC2 point not on the curve", input: encodeCBOR(with(ec2, coseY, offCurve)), wantErr: true},
+		{name: "EC2 short coordinate", input: encodeCBOR(with(ec2, coseX, []byte{1, 2, 3})), wantErr: true},
+		{name: "EC2 with EdDSA", input: encodeCBOR(with(ec2, coseKeyAlg, AlgEdDSA)), wantErr: true},
+		{name: "OKP on X25519", input: encodeCBOR(with(okp, coseCurve, int64(4))), wantErr: true},
+		{name: "RSA below the minimum size", input: encodeCBOR(with(rsaCOSE, coseRSAN, make([]byte, 128))), wantErr: true},
+		{name: "RSA oversized exponent", input: encodeCBOR(with(rsaCOSE, coseRSAE, make([]byte, 5))), wantErr: true},
+		{name: "RSA with PS256", input: encodeCBOR(with(rsaCOSE, coseKeyAlg, int64(-37))), wantErr: true},
+		{name: "missing key type", input: encodeCBOR(map[interface{}]interface{}{int64(coseKeyAlg): AlgES256}), wantErr: true},
+		{name: "not a map", input: encodeCBOR([]interface{}{int64(coseKtyEC2)}), wantErr: true},
+		{name: "trailing data", input: append(encodeCBOR(okp), 0x00), wantErr: true},
+		{name: "invalid CBOR", input: []byte{0xa5, 0x01}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := parsePublicKey(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePublicKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if key.alg != tt.wantAlg {
+				t.Fatalf("parsePublicKey() alg = %d, want %d", key.alg, tt.wantAlg)
+			}
+			if !key.verify(message, tt.signature) {
+				t.Fatal("verify() rejected a valid signature")
+			}
+			if key.verify([]byte("something else"), tt.signature) {
+				t.Fatal("verify() accepted a signature over another message")
+			}
+		})
+	}
+}
@@ -0,0 +1,423 @@
+// Package webauthn implements the relying party side of the WebAuthn
+// registration and authentication ceremonies (W3C Web Authentication Level 2)
+// for passkeys. Only "none" attestation is accepted: credentials are trusted
+// because the signed-in user registered them, not because of who made the
+// authenticator.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ChallengeTimeout is how long a ceremony may take, sent to the browser as the
+// options' timeout
+const ChallengeTimeout = 5 * time.Minute
+
+// challengeSize is the number of random bytes in a challenge
+const challengeSize = 32
+
+// maxCredentialIDLength is the limit from the spec
+const maxCredentialIDLength = 1023
+
+// User verification requirements
+const (
+	UserVerificationRequired    = "required"
+	UserVerificationPreferred   = "preferred"
+	UserVerificationDiscouraged = "discouraged"
+)
+
+// Authenticator data flags
+const (
+	flagUserPresent    = 0x01
+	flagUserVerified   = 0x04
+	flagBackupEligible = 0x08
+	flagAttestedData   = 0x40
+	flagExtensionData  = 0x80
+)
+
+// ErrVerification wraps every reason a ceremony response is rejected
+var ErrVerification = errors.New("webauthn verification failed")
+
+func verificationError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrVerification, fmt.Sprintf(format, args...))
+}
+
+// URLEncodedBytes is binary data that travels as unpadded base64url in JSON,
+// as in the browser's PublicKeyCredential.toJSON()
+type URLEncodedBytes []byte
+
+func (b URLEncodedBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *URLEncodedBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return fmt.Errorf("invalid base64url: %w", err)
+	}
+	*b = decoded
+	return nil
+}
+
+// RelyingParty verifies ceremonies for one RP ID (the site's registrable
+// domain) and the origins allowed to use it
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+// New creates a RelyingParty
+func New(id, name string, origins []string) *RelyingParty {
+	return &RelyingParty{
+		ID:      id,
+		Name:    name,
+		Origins: origins,
+	}
+}
+
+// NewChallenge returns a random challenge for a ceremony
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, challengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// RPEntity identifies the relying party to the authenticator
+type RPEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// UserEntity identifies the account a credential is created for. ID is
+// returned as the user handle when the credential is used.
+type UserEntity struct {
+	ID          URLEncodedBytes `json:"id"`
+	Name        string          `json:"name"`
+	DisplayName string          `json:"displayName"`
+}
+
+// CredentialParameter is an acceptable credential type and algorithm
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+// CredentialDescriptor refers to an existing credential
+type CredentialDescriptor struct {
+	Type       string          `json:"type"`
+	ID         URLEncodedBytes `json:"id"`
+	Transports []string        `json:"transports,omitempty"`
+}
+
+// AuthenticatorSelection states what kind of authenticator is wanted
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions is passed to navigator.credentials.create() as publicKey
+type CreationOptions struct {
+	RP                     RPEntity               `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	Challenge              URLEncodedBytes        `json:"challenge"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"` // milliseconds
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials,omitempty"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions is passed to navigator.credentials.get() as publicKey
+type RequestOptions struct {
+	Challenge        URLEncodedBytes        `json:"challenge"`
+	Timeout          int64                  `json:"timeout"` // milliseconds
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials,omitempty"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// AttestationResponse is the JSON form of the credential returned by
+// navigator.credentials.create()
+type AttestationResponse struct {
+	ID       string          `json:"id"`
+	RawID    URLEncodedBytes `json:"rawId"`
+	Type     string          `json:"type"`
+	Response struct {
+		ClientDataJSON    URLEncodedBytes `json:"clientDataJSON"`
+		AttestationObject URLEncodedBytes `json:"attestationObject"`
+		Transports        []string        `json:"transports,omitempty"`
+	} `json:"response"`
+}
+
+// AssertionResponse is the JSON form of the credential returned by
+// navigator.credentials.get()
+type AssertionResponse struct {
+	ID       string          `json:"id"`
+	RawID    URLEncodedBytes `json:"rawId"`
+	Type     string          `json:"type"`
+	Response struct {
+		ClientDataJSON    URLEncodedBytes `json:"clientDataJSON"`
+		AuthenticatorData URLEncodedBytes `json:"authenticatorData"`
+		Signature         URLEncodedBytes `json:"signature"`
+		UserHandle        URLEncodedBytes `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
+
+// Credential is a newly registered credential
+type Credential struct {
+	ID             []byte
+	PublicKey      []byte // CBOR-encoded COSE_Key
+	SignCount      uint32
+	Transports     []string
+	BackupEligible bool // Synced passkey rather than a device-bound key
+}
+
+// Assertion is the verified result of an authentication ceremony
+type Assertion struct {
+	CredentialID []byte
+	UserHandle   []byte
+	SignCount    uint32
+	UserVerified bool
+}
+
+// CreationOptions builds the options for registering a credential for user.
+// exclude lists the user's existing credentials so an authenticator isn't
+// registered twice.
+func (rp *RelyingParty) CreationOptions(challenge []byte, user UserEntity, exclude []CredentialDescriptor) *CreationOptions {
+	params := make([]CredentialParameter, 0, len(SupportedAlgorithms))
+	for _, alg := range SupportedAlgorithms {
+		params = append(params, CredentialParameter{Type: "public-key", Alg: alg})
+	}
+
+	return &CreationOptions{
+		RP:                 RPEntity{ID: rp.ID, Name: rp.Name},
+		User:               user,
+		Challenge:          challenge,
+		PubKeyCredParams:   params,
+		Timeout:            ChallengeTimeout.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: UserVerificationRequired,
+		},
+		Attestation: "none",
+	}
+}
+
+// RequestOptions builds the options for an authentication ceremony. An empty
+// allow list lets the user pick any discoverable credential for the site.
+func (rp *RelyingParty) RequestOptions(challenge []byte, allow []CredentialDescriptor, userVerification string) *RequestOptions {
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          ChallengeTimeout.Milliseconds(),
+		RPID:             rp.ID,
+		AllowCredentials: allow,
+		UserVerification: userVerification,
+	}
+}
+
+// VerifyRegistration checks the response to CreationOptions built with
+// challenge and returns the new credential
+func (rp *RelyingParty) VerifyRegistration(resp *AttestationResponse, challenge []byte) (*Credential, error) {
+	if resp.Type != "public-key" {
+		return nil, verificationError("unexpected credential type %q", resp.Type)
+	}
+
+	if err := rp.verifyClientData(resp.Response.ClientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	decoded, _, err := decodeCBOR(resp.Response.AttestationObject)
+	if err != nil {
+		return nil, verificationError("invalid attestation object: %v", err)
+	}
+	attestation, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, verificationError("invalid attestation object")
+	}
+
+	// We ask for "none" and browsers strip attestation accordingly
+	if format, _ := attestation["fmt"].(string); format != "none" {
+		return nil, verificationError("unsupported attestation format %q", format)
+	}
+	if stmt, ok := attestation["attStmt"].(map[interface{}]interface{}); !ok || len(stmt) != 0 {
+		return nil, verificationError("unexpected attestation statement")
+	}
+
+	rawAuthData, _ := attestation["authData"].([]byte)
+	authData, err := rp.parseAuthenticatorData(rawAuthData, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if authData.flags&flagAttestedData == 0 {
+		return nil, verificationError("no attested credential data")
+	}
+	if !bytes.Equal(authData.credentialID, resp.RawID) {
+		return nil, verificationError("credential ID mismatch")
+	}
+
+	return &Credential{
+		ID:             authData.credentialID,
+		PublicKey:      authData.publicKey,
+		SignCount:      authData.signCount,
+		Transports:     resp.Response.Transports,
+		BackupEligible: authData.flags&flagBackupEligible != 0,
+	}, nil
+}
+
+// VerifyAssertion checks the response to RequestOptions built with challenge
+// against the stored public key of the credential it names. Callers must also
+// check the returned sign count against the stored one.
+func (rp *RelyingParty) VerifyAssertion(resp *AssertionResponse, challenge, storedPublicKey []byte, requireUserVerification bool) (*Assertion, error) {
+	if resp.Type != "public-key" {
+		return nil, verificationError("unexpected credential type %q", resp.Type)
+	}
+
+	if err := rp.verifyClientData(resp.Response.ClientDataJSON, "webauthn.get", challenge); err != nil {
+		return nil, err
+	}
+
+	authData, err := rp.parseAuthenticatorData(resp.Response.AuthenticatorData, requireUserVerification)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := parsePublicKey(storedPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("stored public key: %w", err)
+	}
+
+	clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+	signed := append(append([]byte(nil), resp.Response.AuthenticatorData...), clientDataHash[:]...)
+	if !key.verify(signed, resp.Response.Signature) {
+		return nil, verificationError("invalid signature")
+	}
+
+	return &Assertion{
+		CredentialID: resp.RawID,
+		UserHandle:   resp.Response.UserHandle,
+		SignCount:    authData.signCount,
+		UserVerified: authData.flags&flagUserVerified != 0,
+	}, nil
+}
+
+// clientData is the JSON the browser signs over
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+func (rp *RelyingParty) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return verificationError("invalid client data: %v", err)
+	}
+
+	if data.Type != ceremony {
+		return verificationError("unexpected client data type %q", data.Type)
+	}
+
+	got, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(data.Challenge, "="))
+	if err != nil || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return verificationError("challenge mismatch")
+	}
+
+	if data.CrossOrigin {
+		return verificationError("cross-origin requests are not allowed")
+	}
+	for _, origin := range rp.Origins {
+		if data.Origin == origin {
+			return nil
+		}
+	}
+	return verificationError("unexpected origin %q", data.Origin)
+}
+
+// authenticatorData is the parsed authenticator data structure
+type authenticatorData struct {
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    []byte
+}
+
+func (rp *RelyingParty) parseAuthenticatorData(raw []byte, requireUserVerification bool) (*authenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, verificationError("authenticator data too short")
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if subtle.ConstantTimeCompare(raw[:32], rpIDHash[:]) != 1 {
+		return nil, verificationError("RP ID mismatch")
+	}
+
+	data := &authenticatorData{
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+
+	if data.flags&flagUserPresent == 0 {
+		return nil, verificationError("user not present")
+	}
+	if requireUserVerification && data.flags&flagUserVerified == 0 {
+		return nil, verificationError("user not verified")
+	}
+
+	rest := raw[37:]
+	if data.flags&flagAttestedData != 0 {
+		// AAGUID (16 bytes), then the length-prefixed credential ID
+		if len(rest) < 18 {
+			return nil, verificationError("attested credential data too short")
+		}
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLen == 0 || idLen > maxCredentialIDLength || len(rest) < idLen {
+			return nil, verificationError("invalid credential ID length")
+		}
+		data.credentialID = append([]byte(nil), rest[:idLen]...)
+		rest = rest[idLen:]
+
+		decoded, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, verificationError("invalid credential public key: %v", err)
+		}
+		if _, err := publicKeyFromCOSE(decoded); err != nil {
+			return nil, verificationError("%v", err)
+		}
+		data.publicKey = append([]byte(nil), rest[:len(rest)-len(after)]...)
+		rest = after
+	}
+
+	if data.flags&flagExtensionData != 0 {
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, verificationError("invalid extension data: %v", err)
+		}
+		rest = after
+	}
+	if len(rest) != 0 {
+		return nil, verificationError("trailing authenticator data")
+	}
+
+	return data, nil
+}
@@ -0,0 +1,329 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://app.example.com"
+)
+
+// testAuthenticator is a software authenticator holding one ES256 credential
+type testAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	publicKey    []byte
+	credentialID []byte
+	signCount    uint32
+}
+
+func newTestAuthenticator(t *testing.T) *testAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	return &testAuthenticator{
+		key:          key,
+		publicKey:    encodeCBOR(coseEC2Key(t, &key.PublicKey)),
+		credentialID: []byte("credential-1"),
+		signCount:    7,
+	}
+}
+
+// ceremony is what the browser and authenticator put into a response. Tests
+// start from a valid ceremony and break one thing.
+type ceremony struct {
+	credentialType string
+	clientType     string
+	challenge      []byte
+	origin         string
+	crossOrigin    bool
+	rpID           string
+	flags          byte
+	format         string
+	rawID          []byte
+	trailing       []byte // appended to the authenticator data
+}
+
+func (a *testAuthenticator) clientData(t *testing.T, c *ceremony) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(clientData{
+		Type:        c.clientType,
+		Challenge:   base64.RawURLEncoding.EncodeToString(c.challenge),
+		Origin:      c.origin,
+		CrossOrigin: c.crossOrigin,
+	})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	return data
+}
+
+func (a *testAuthenticator) authenticatorData(c *ceremony) []byte {
+	rpIDHash := sha256.Sum256([]byte(c.rpID))
+	data := append(rpIDHash[:], c.flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if c.flags&flagAttestedData != 0 {
+		data = append(data, make([]byte, 16)...) // AAGUID
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, a.publicKey...)
+	}
+	return append(data, c.trailing...)
+}
+
+func (a *testAuthenticator) register(t *testing.T, c *ceremony) *AttestationResponse {
+	t.Helper()
+
+	resp := &AttestationResponse{ID: base64.RawURLEncoding.EncodeToString(c.rawID), RawID: c.rawID, Type: c.credentialType}
+	resp.Response.ClientDataJSON = a.clientData(t, c)
+	resp.Response.AttestationObject = encodeCBOR(map[interface{}]interface{}{
+		"fmt":      c.format,
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": a.authenticatorData(c),
+	})
+	resp.Response.Transports = []string{"internal"}
+	return resp
+}
+
+func (a *testAuthenticator) assert(t *testing.T, c *ceremony) *AssertionResponse {
+	t.Helper()
+
+	resp := &AssertionResponse{ID: base64.RawURLEncoding.EncodeToString(c.rawID), RawID: c.rawID, Type: c.credentialType}
+	resp.Response.ClientDataJSON = a.clientData(t, c)
+	resp.Response.AuthenticatorData = a.authenticatorData(c)
+	resp.Response.UserHandle = []byte("user-1")
+
+	clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), resp.Response.AuthenticatorData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatalf("SignASN1: %v", err)
+	}
+	resp.Response.Signature = signature
+	return resp
+}
+
+func TestVerifyRegistration(t *testing.T) {
+	rp := New(testRPID, "Example", []string{testOrigin})
+	authenticator := newTestAuthenticator(t)
+	challenge, err := NewChallenge()
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+
+	valid := func() *ceremony {
+		return &ceremony{
+			credentialType: "public-key",
+			clientType:     "webauthn.create",
+			challenge:      challenge,
+			origin:         testOrigin,
+			rpID:           testRPID,
+			flags:          flagUserPresent | flagUserVerified | flagBackupEligible | flagAttestedData,
+			format:         "none",
+			rawID:          authenticator.credentialID,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(c *ceremony)
+		wantErr bool
+	}{
+		{name: "valid", modify: func(c *ceremony) {}},
+		{name: "credential type", modify: func(c *ceremony) { c.credentialType = "password" }, wantErr: true},
+		{name: "assertion client data", modify: func(c *ceremony) { c.clientType = "webauthn.get" }, wantErr: true},
+		{name: "other challenge", modify: func(c *ceremony) { c.challenge = []byte("other") }, wantErr: true},
+		{name: "unknown origin", modify: func(c *ceremony) { c.origin = "https://evil.example.net" }, wantErr: true},
+		{name: "cross origin", modify: func(c *ceremony) { c.crossOrigin = true }, wantErr: true},
+		{name: "other RP ID", modify: func(c *ceremony) { c.rpID = "evil.example.net" }, wantErr: true},
+		{name: "user not present", modify: func(c *ceremony) { c.flags &^= flagUserPresent }, wantErr: true},
+		{name: "user not verified", modify: func(c *ceremony) { c.flags &^= flagUserVerified }, wantErr: true},
+		{name: "no attested data", modify: func(c *ceremony) { c.flags &^= flagAttestedData }, wantErr: true},
+		{name: "packed attestation", modify: func(c *ceremony) { c.format = "packed" }, wantErr: true},
+		{name: "raw ID mismatch", modify: func(c *ceremony) { c.rawID = []byte("credential-2") }, wantErr: true},
+		{name: "trailing data", modify: func(c *ceremony) { c.trailing = []byte{0x00} }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.modify(c)
+
+			credential, err := rp.VerifyRegistration(authenticator.register(t, c), challenge)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyRegistration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrVerification) {
+					t.Fatalf("VerifyRegistration() error = %v, want ErrVerification", err)
+				}
+				return
+			}
+
+			if !bytes.Equal(credential.ID, authenticator.credentialID) {
+				t.Fatalf("credential ID = %q, want %q", credential.ID, authenticator.credentialID)
+			}
+			if !bytes.Equal(credential.PublicKey, authenticator.publicKey) {
+				t.Fatal("credential public key does not match the authenticator's")
+			}
+			if credential.SignCount != authenticator.signCount || !credential.BackupEligible {
+				t.Fatalf("credential = %+v, want sign count %d and backup eligible", credential, authenticator.signCount)
+			}
+		})
+	}
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	rp := New(testRPID, "Example", []string{"https://other.example.com", testOrigin})
+	authenticator := newTestAuthenticator(t)
+	challenge, err := NewChallenge()
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+
+	valid := func() *ceremony {
+		return &ceremony{
+			credentialType: "public-key",
+			clientType:     "webauthn.get",
+			challenge:      challenge,
+			origin:         testOrigin,
+			rpID:           testRPID,
+			flags:          flagUserPresent | flagUserVerified,
+			rawID:          authenticator.credentialID,
+		}
+	}
+
+	tests := []struct {
+		name                    string
+		modify                  func(c *ceremony)
+		modifyResponse          func(resp *AssertionResponse)
+		requireUserVerification bool
+		wantUserVerified        bool
+		wantErr                 bool
+	}{
+		{name: "valid", requireUserVerification: true, wantUserVerified: true},
+		{name: "presence only when verification is optional", modify: func(c *ceremony) { c.flags &^= flagUserVerified }},
+		{name: "presence only when verification is required", modify: func(c *ceremony) { c.flags &^= flagUserVerified }, requireUserVerification: true, wantErr: true},
+		{name: "registration client data", modify: func(c *ceremony) { c.clientType = "webauthn.create" }, wantErr: true},
+		{name: "other challenge", modify: func(c *ceremony) { c.challenge = []byte("other") }, wantErr: true},
+		{name: "other RP ID", modify: func(c *ceremony) { c.rpID = "evil.example.net" }, wantErr: true},
+		{name: "user not present", modify: func(c *ceremony) { c.flags &^= flagUserPresent }, wantErr: true},
+		{
+			name:           "tampered authenticator data",
+			modifyResponse: func(resp *AssertionResponse) { resp.Response.AuthenticatorData[36]++ },
+			wantErr:        true,
+		},
+		{
+			name: "tampered client data",
+			modifyResponse: func(resp *AssertionResponse) {
+				resp.Response.ClientDataJSON = append(resp.Response.ClientDataJSON, ' ')
+			},
+			wantErr: true,
+		},
+		{
+			name:           "truncated signature",
+			modifyResponse: func(resp *AssertionResponse) { resp.Response.Signature = resp.Response.Signature[:10] },
+			wantErr:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			if tt.modify != nil {
+				tt.modify(c)
+			}
+			resp := authenticator.assert(t, c)
+			if tt.modifyResponse != nil {
+				tt.modifyResponse(resp)
+			}
+
+			assertion, err := rp.VerifyAssertion(resp, challenge, authenticator.publicKey, tt.requireUserVerification)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyAssertion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrVerification) {
+					t.Fatalf("VerifyAssertion() error = %v, want ErrVerification", err)
+				}
+				return
+			}
+
+			if assertion.SignCount != authenticator.signCount || assertion.UserVerified != tt.wantUserVerified {
+				t.Fatalf("VerifyAssertion() = %+v, want sign count %d and user verified %v", assertion, authenticator.signCount, tt.wantUserVerified)
+			}
+			if !bytes.Equal(assertion.UserHandle, []byte("user-1")) {
+				t.Fatalf("user handle = %q, want %q", assertion.UserHandle, "user-1")
+			}
+		})
+	}
+}
+
+func TestVerifyAssertionWithAnotherKey(t *testing.T) {
+	rp := New(testRPID, "Example", []string{testOrigin})
+	authenticator := newTestAuthenticator(t)
+	other := newTestAuthenticator(t)
+	challenge := []byte("challenge")
+
+	resp := authenticator.assert(t, &ceremony{
+		credentialType: "public-key",
+		clientType:     "webauthn.get",
+		challenge:      challenge,
+		origin:         testOrigin,
+		rpID:           testRPID,
+		flags:          flagUserPresent | flagUserVerified,
+		rawID:          authenticator.credentialID,
+	})
+
+	if _, err := rp.VerifyAssertion(resp, challenge, other.publicKey, true); !errors.Is(err, ErrVerification) {
+		t.Fatalf("VerifyAssertion() with another credential's key error = %v, want ErrVerification", err)
+	}
+}
+
+func TestURLEncodedBytesJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []byte
+		wantErr bool
+	}{
+		{name: "unpadded", input: `"AQID_w"`, want: []byte{1, 2, 3, 0xff}},
+		{name: "padded", input: `"AQID_w=="`, want: []byte{1, 2, 3, 0xff}},
+		{name: "standard alphabet", input: `"AQID/w"`, wantErr: true},
+		{name: "not a string", input: `[1,2,3]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got URLEncodedBytes
+			err := json.Unmarshal([]byte(tt.input), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(got, tt.want) {
+				t.Fatalf("Unmarshal() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+
+	encoded, err := json.Marshal(URLEncodedBytes{1, 2, 3, 0xff})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if string(encoded) != `"AQID_w"` {
+		t.Fatalf("Marshal() = %s, want %q", encoded, "AQID_w")
+	}
+}
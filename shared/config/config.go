@@ -1,6 +1,8 @@
 package config
 
 import (
+	"net"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -18,8 +20,11 @@ type Config struct {
 
 	// AWS Resources
 	DynamoDB struct {
+		Endpoint        string // Optional override, e.g. DynamoDB Local
 		AuthSessions    string
 		AuthAnonymous   string
+		AuthRateLimits  string
+		AuthRevocations string
 		Profiles        string
 		ProfileAliases  string
 		ChatMessages    string
@@ -40,29 +45,108 @@ type Config struct {
 	}
 
 	EventBridge struct {
-		BusName string
+		BusName  string
+		Endpoint string // Optional override, e.g. LocalStack
+	}
+
+	// JWT signing and verification keys (PEM inline or from a file)
+	JWT struct {
+		KeySet         string // JSON key set with validity windows; takes precedence over PrivateKey
+		KeySetFile     string
+		PrivateKey     string
+		PrivateKeyFile string
+		PublicKeys     string // Extra verification keys, or the only keys for non-issuing services
+		PublicKeysFile string
+		JWKSURL        string // Fetch verification keys from the auth service instead
 	}
 
 	// External APIs
-	JWTSecret    string
 	GeminiAPIKey string
 	OpenAIAPIKey string
 
 	// CORS
 	CORSOrigin string
 
+	// Proxies (IPs or CIDRs) whose X-Forwarded-For the standalone HTTP server
+	// believes; requests from anywhere else are keyed by their own address
+	TrustedProxies []string
+
 	// Service Settings
 	ServiceName        string
 	LogLevel           string
 	CloudFrontDomain   string
 	WebSocketEndpoint  string
 
+	// Transactional email
+	Mail struct {
+		Driver      string // "log", "smtp", "file" or "ses"
+		From        string
+		BaseURL     string // Frontend origin used to build links in emails
+		ProductName string
+
+		SMTPHost     string
+		SMTPPort     int
+		SMTPUsername string
+		SMTPPassword string
+
+		Dir string // Maildir for the file driver
+
+		SESRegion           string
+		SESConfigurationSet string
+	}
+
 	// Rate Limiting
 	RateLimit struct {
 		RequestsPerMinute int
 		BurstSize         int
 	}
 
+	// Security audit log
+	Audit struct {
+		Driver string // "repository" (alongside the other auth data) or "file"
+		File   string // JSON-lines log for the file driver
+	}
+
+	// Transactional outbox relaying domain events to EventBridge
+	Outbox struct {
+		RelayOnly    bool          // Run the Lambda as the scheduled relay instead of the API
+		PollInterval time.Duration // How often server mode drains the outbox
+		BatchSize    int
+		MaxAttempts  int // Failed publishes before an event is dead-lettered
+	}
+
+	// Access token revocation
+	Revocation struct {
+		CacheTTL time.Duration // How long a denylist lookup is reused in process
+		FailOpen bool          // Let tokens through when the denylist can't be read
+	}
+
+	// Multi-factor authentication
+	MFA struct {
+		EncryptionKey string // Base64 AES-256 key sealing TOTP secrets at rest
+		Issuer        string // Name shown in authenticator apps
+	}
+
+	// WebAuthn relying party for passkeys
+	WebAuthn struct {
+		RPID    string   // Registrable domain passkeys are bound to
+		RPName  string   // Name shown by the browser
+		Origins []string // Origins allowed to run ceremonies
+	}
+
+	// Social login through external OAuth 2.0 / OpenID Connect providers
+	OAuth struct {
+		RedirectBaseURL string // Providers send users back to <base>/<provider>/callback
+		Providers       []OAuthProvider
+	}
+
+	// OAuth2 authorization server for delegated access by other clients
+	OAuth2 struct {
+		Issuer     string   // Base URL of the auth API, published for OpenID Connect discovery
+		ConsentURL string   // Frontend page where users approve a client
+		Scopes     []string // Scopes clients can register for and request
+	}
+
 	// Timeouts
 	Timeouts struct {
 		DatabaseTimeout time.Duration
@@ -71,6 +155,17 @@ type Config struct {
 	}
 }
 
+// OAuthProvider is an external login provider, configured from
+// OAUTH_<NAME>_* for each name listed in OAUTH_PROVIDERS
+type OAuthProvider struct {
+	Name         string
+	Type         string // "oidc" or "github"
+	ClientID     string
+	ClientSecret string
+	Issuer       string // OIDC issuer URL, discovered on first use
+	Scopes       []string
+}
+
 var globalConfig *Config
 
 // Load initializes and returns the configuration
@@ -91,16 +186,31 @@ func Load() (*Config, error) {
 		CloudFrontDomain:  getEnv("CLOUDFRONT_DOMAIN", ""),
 		WebSocketEndpoint: getEnv("WEBSOCKET_API_ENDPOINT", ""),
 
-		JWTSecret:    getEnv("JWT_SECRET", ""),
 		GeminiAPIKey: getEnv("GEMINI_API_KEY", ""),
 		OpenAIAPIKey: getEnv("OPENAI_API_KEY", ""),
 
 		CORSOrigin: getEnv("CORS_ORIGIN", "*"),
+
+		TrustedProxies: getEnvList("TRUSTED_PROXIES", nil),
 	}
 
+	// JWT keys
+	config.JWT.KeySet = getEnv("JWT_KEYSET", "")
+	config.JWT.KeySetFile = getEnv("JWT_KEYSET_FILE", "")
+	config.JWT.PrivateKey = getEnv("JWT_PRIVATE_KEY", "")
+	config.JWT.PrivateKeyFile = getEnv("JWT_PRIVATE_KEY_FILE", "")
+	config.JWT.PublicKeys = getEnv("JWT_PUBLIC_KEYS", "")
+	config.JWT.PublicKeysFile = getEnv("JWT_PUBLIC_KEYS_FILE", "")
+	config.JWT.JWKSURL = getEnv("JWT_JWKS_URL", "")
+
+	// DynamoDB endpoint override (empty uses the regional AWS endpoint)
+	config.DynamoDB.Endpoint = getEnv("DYNAMODB_ENDPOINT", "")
+
 	// DynamoDB table names
 	config.DynamoDB.AuthSessions = getEnv("DYNAMODB_TABLE_AUTH_SESSIONS", "")
 	config.DynamoDB.AuthAnonymous = getEnv("DYNAMODB_TABLE_AUTH_ANONYMOUS", "")
+	config.DynamoDB.AuthRateLimits = getEnv("DYNAMODB_TABLE_AUTH_RATE_LIMITS", "")
+	config.DynamoDB.AuthRevocations = getEnv("DYNAMODB_TABLE_AUTH_REVOCATIONS", "")
 	config.DynamoDB.Profiles = getEnv("DYNAMODB_TABLE_PROFILES", "")
 	config.DynamoDB.ProfileAliases = getEnv("DYNAMODB_TABLE_PROFILE_ALIASES", "")
 	config.DynamoDB.ChatMessages = getEnv("DYNAMODB_TABLE_CHAT_MESSAGES", "")
@@ -119,11 +229,60 @@ func Load() (*Config, error) {
 
 	// EventBridge
 	config.EventBridge.BusName = getEnv("EVENTBRIDGE_BUS_NAME", "")
+	config.EventBridge.Endpoint = getEnv("EVENTBRIDGE_ENDPOINT", "")
+
+	// Transactional email; deployed stages must name their sender and frontend
+	config.Mail.Driver = getEnv("MAIL_DRIVER", "log")
+	config.Mail.From = getEnv("FROM_EMAIL", config.devDefault("noreply@multitask.com"))
+	config.Mail.BaseURL = getEnv("APP_BASE_URL", config.devDefault("http://localhost:5173"))
+	config.Mail.ProductName = getEnv("PRODUCT_NAME", "Multitask")
+	config.Mail.SMTPHost = getEnv("SMTP_HOST", "localhost")
+	config.Mail.SMTPPort = getEnvInt("SMTP_PORT", 1025)
+	config.Mail.SMTPUsername = getEnv("SMTP_USERNAME", "")
+	config.Mail.SMTPPassword = getEnv("SMTP_PASSWORD", "")
+	config.Mail.Dir = getEnv("MAIL_DIR", "tmp/maildir")
+	config.Mail.SESRegion = getEnv("SES_REGION", config.Region)
+	config.Mail.SESConfigurationSet = getEnv("SES_CONFIGURATION_SET", "")
 
 	// Rate limiting
 	config.RateLimit.RequestsPerMinute = getEnvInt("RATE_LIMIT_REQUESTS_PER_MINUTE", 60)
 	config.RateLimit.BurstSize = getEnvInt("RATE_LIMIT_BURST_SIZE", 10)
 
+	// Security audit log
+	config.Audit.Driver = getEnv("AUDIT_DRIVER", "repository")
+	config.Audit.File = getEnv("AUDIT_FILE", "tmp/audit.jsonl")
+
+	// Domain event outbox
+	config.Outbox.RelayOnly = getEnvBool("OUTBOX_RELAY_ONLY", false)
+	config.Outbox.PollInterval = getEnvDuration("OUTBOX_POLL_INTERVAL", 5*time.Second)
+	config.Outbox.BatchSize = getEnvInt("OUTBOX_BATCH_SIZE", 100)
+	config.Outbox.MaxAttempts = getEnvInt("OUTBOX_MAX_ATTEMPTS", 10)
+
+	// Token revocation
+	config.Revocation.CacheTTL = getEnvDuration("TOKEN_REVOCATION_CACHE_TTL", 10*time.Second)
+	config.Revocation.FailOpen = getEnvBool("TOKEN_REVOCATION_FAIL_OPEN", false)
+
+	// Multi-factor authentication
+	config.MFA.EncryptionKey = getEnv("MFA_ENCRYPTION_KEY", "")
+	config.MFA.Issuer = getEnv("TOTP_ISSUER", config.Mail.ProductName)
+
+	// WebAuthn; by default passkeys belong to the frontend's host
+	config.WebAuthn.RPID = getEnv("WEBAUTHN_RP_ID", hostname(config.Mail.BaseURL))
+	config.WebAuthn.RPName = getEnv("WEBAUTHN_RP_NAME", config.Mail.ProductName)
+	config.WebAuthn.Origins = getEnvList("WEBAUTHN_ORIGINS", []string{config.Mail.BaseURL})
+
+	// Social login; the frontend's callback page hands the code to the API
+	config.OAuth.RedirectBaseURL = getEnv("OAUTH_REDIRECT_BASE_URL", strings.TrimSuffix(config.Mail.BaseURL, "/")+"/oauth")
+	for _, name := range getEnvList("OAUTH_PROVIDERS", nil) {
+		config.OAuth.Providers = append(config.OAuth.Providers, loadOAuthProvider(strings.ToLower(name)))
+	}
+
+	// OAuth2 authorization server; the frontend's consent page posts the
+	// user's decision to the API
+	config.OAuth2.Issuer = strings.TrimSuffix(getEnv("OAUTH2_ISSUER", config.devDefault("http://localhost:8080/v1/auth")), "/")
+	config.OAuth2.ConsentURL = getEnv("OAUTH2_CONSENT_URL", strings.TrimSuffix(config.Mail.BaseURL, "/")+"/oauth2/consent")
+	config.OAuth2.Scopes = getEnvList("OAUTH2_SCOPES", []string{"openid", "profile", "email"})
+
 	// Timeouts
 	config.Timeouts.DatabaseTimeout = getEnvDuration("DATABASE_TIMEOUT", 5*time.Second)
 	config.Timeouts.HTTPTimeout = getEnvDuration("HTTP_TIMEOUT", 30*time.Second)
@@ -151,6 +310,15 @@ func (c *Config) IsDevelopment() bool {
 	return c.Stage == "dev"
 }
 
+// devDefault returns value in development and "" elsewhere, for defaults that
+// only fit a local setup. Validate then reports the setting as missing.
+func (c *Config) devDefault(value string) string {
+	if c.IsDevelopment() {
+		return value
+	}
+	return ""
+}
+
 // GetLogLevel returns the zap log level
 func (c *Config) GetLogLevel() zap.AtomicLevel {
 	switch strings.ToLower(c.LogLevel) {
@@ -167,13 +335,20 @@ func (c *Config) GetLogLevel() zap.AtomicLevel {
 	}
 }
 
-// Validate checks if all required configuration values are set
+// Validate checks if all required configuration values are set. Development
+// runs against in-memory stores, so nothing is required there.
 func (c *Config) Validate() error {
+	if c.IsDevelopment() {
+		return nil
+	}
+
 	required := map[string]string{
-		"JWT_SECRET":                    c.JWTSecret,
 		"DYNAMODB_TABLE_AUTH_SESSIONS": c.DynamoDB.AuthSessions,
 		"COGNITO_USER_POOL_ID":         c.Cognito.UserPoolID,
 		"COGNITO_CLIENT_ID":            c.Cognito.ClientID,
+		"APP_BASE_URL":                 c.Mail.BaseURL,
+		"FROM_EMAIL":                   c.Mail.From,
+		"OAUTH2_ISSUER":                c.OAuth2.Issuer,
 	}
 
 	for key, value := range required {
@@ -182,6 +357,16 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	// Passkeys bound to localhost would be useless to real users
+	if isLocalhost(c.WebAuthn.RPID) {
+		return &ValidationError{Field: "WEBAUTHN_RP_ID", Message: "must not be localhost outside development"}
+	}
+	for _, origin := range c.WebAuthn.Origins {
+		if isLocalhost(hostname(origin)) {
+			return &ValidationError{Field: "WEBAUTHN_ORIGINS", Message: "must not include localhost outside development"}
+		}
+	}
+
 	return nil
 }
 
@@ -220,4 +405,71 @@ func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 		}
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getEnvList reads a comma-separated list
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// loadOAuthProvider reads OAUTH_<NAME>_* for a provider. Google and GitHub
+// only need their client credentials.
+func loadOAuthProvider(name string) OAuthProvider {
+	prefix := "OAUTH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+	defaultType, defaultIssuer := "oidc", ""
+	switch name {
+	case "google":
+		defaultIssuer = "https://accounts.google.com"
+	case "github":
+		defaultType = "github"
+	}
+
+	return OAuthProvider{
+		Name:         name,
+		Type:         getEnv(prefix+"TYPE", defaultType),
+		ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+		ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+		Issuer:       getEnv(prefix+"ISSUER", defaultIssuer),
+		Scopes:       getEnvList(prefix+"SCOPES", nil),
+	}
+}
+
+// hostname returns the host of rawURL without its port, or "" if it doesn't
+// parse
+func hostname(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// isLocalhost reports whether host names the local machine
+func isLocalhost(host string) bool {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
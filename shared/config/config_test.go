@@ -0,0 +1,92 @@
+package config
+
+import "testing"
+
+// deployedConfig is a complete configuration for a deployed stage
+func deployedConfig() *Config {
+	c := &Config{Stage: "prod"}
+	c.DynamoDB.AuthSessions = "auth-sessions-prod"
+	c.Cognito.UserPoolID = "us-east-1_abcd1234"
+	c.Cognito.ClientID = "client"
+	c.Mail.From = "noreply@multitask.com"
+	c.Mail.BaseURL = "https://multitask.com"
+	c.WebAuthn.RPID = "multitask.com"
+	c.WebAuthn.Origins = []string{"https://multitask.com", "https://www.multitask.com"}
+	c.OAuth2.Issuer = "https://api.multitask.com/v1/auth"
+	return c
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(c *Config)
+		wantField string // empty when the configuration is valid
+	}{
+		{name: "complete", modify: func(c *Config) {}},
+		{name: "development needs nothing", modify: func(c *Config) { *c = Config{Stage: "dev"} }},
+		{name: "no table", modify: func(c *Config) { c.DynamoDB.AuthSessions = "" }, wantField: "DYNAMODB_TABLE_AUTH_SESSIONS"},
+		{name: "no sender", modify: func(c *Config) { c.Mail.From = "" }, wantField: "FROM_EMAIL"},
+		{name: "no frontend", modify: func(c *Config) { c.Mail.BaseURL = "" }, wantField: "APP_BASE_URL"},
+		{name: "no issuer", modify: func(c *Config) { c.OAuth2.Issuer = "" }, wantField: "OAUTH2_ISSUER"},
+		{name: "localhost RP ID", modify: func(c *Config) { c.WebAuthn.RPID = "localhost" }, wantField: "WEBAUTHN_RP_ID"},
+		{name: "localhost subdomain RP ID", modify: func(c *Config) { c.WebAuthn.RPID = "app.localhost" }, wantField: "WEBAUTHN_RP_ID"},
+		{name: "localhost origin", modify: func(c *Config) { c.WebAuthn.Origins = append(c.WebAuthn.Origins, "http://localhost:5173") }, wantField: "WEBAUTHN_ORIGINS"},
+		{name: "loopback origin", modify: func(c *Config) { c.WebAuthn.Origins = []string{"http://127.0.0.1:5173"} }, wantField: "WEBAUTHN_ORIGINS"},
+		{name: "localhost in development", modify: func(c *Config) {
+			c.Stage = "dev"
+			c.WebAuthn.RPID = "localhost"
+			c.WebAuthn.Origins = []string{"http://localhost:5173"}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := deployedConfig()
+			tt.modify(c)
+
+			err := c.Validate()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v", err)
+				}
+				return
+			}
+			verr, ok := err.(*ValidationError)
+			if !ok || verr.Field != tt.wantField {
+				t.Fatalf("Validate() = %v, want an error about %s", err, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	tests := []struct {
+		stage       string
+		wantFrom    string
+		wantBaseURL string
+		wantIssuer  string
+	}{
+		{stage: "dev", wantFrom: "noreply@multitask.com", wantBaseURL: "http://localhost:5173", wantIssuer: "http://localhost:8080/v1/auth"},
+		{stage: "prod"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.stage, func(t *testing.T) {
+			t.Setenv("STAGE", tt.stage)
+			t.Setenv("FROM_EMAIL", "")
+			t.Setenv("APP_BASE_URL", "")
+			t.Setenv("OAUTH2_ISSUER", "")
+			globalConfig = nil
+			t.Cleanup(func() { globalConfig = nil })
+
+			c, err := Load()
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if c.Mail.From != tt.wantFrom || c.Mail.BaseURL != tt.wantBaseURL || c.OAuth2.Issuer != tt.wantIssuer {
+				t.Fatalf("From %q, BaseURL %q, Issuer %q; want %q, %q, %q",
+					c.Mail.From, c.Mail.BaseURL, c.OAuth2.Issuer, tt.wantFrom, tt.wantBaseURL, tt.wantIssuer)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+// maxPutEventsEntries is the most entries one PutEvents call accepts
+const maxPutEventsEntries = 10
+
+// EventBridgeEntry is one event in a PutEvents request
+type EventBridgeEntry struct {
+	EventBusName string
+	Source       string
+	DetailType   string
+	Detail       string
+	Time         int64 // Unix seconds
+}
+
+// EventBridgeResult is EventBridge's answer for the entry at the same
+// index. ErrorCode is set if the entry was rejected.
+type EventBridgeResult struct {
+	EventId      string
+	ErrorCode    string
+	ErrorMessage string
+}
+
+// EventBridgeAPI is the PutEvents call EventBridgePublisher makes
+type EventBridgeAPI interface {
+	PutEvents(ctx context.Context, entries []EventBridgeEntry) ([]EventBridgeResult, error)
+}
+
+// EventBridgeClient calls the EventBridge PutEvents API directly, signing
+// requests with the default AWS credential chain
+type EventBridgeClient struct {
+	httpClient  *http.Client
+	endpoint    string
+	region      string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+}
+
+// NewEventBridgeClient creates a client for region. endpoint overrides the
+// regional endpoint, e.g. for LocalStack; leave it empty for AWS.
+func NewEventBridgeClient(ctx context.Context, region, endpoint string) (*EventBridgeClient, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://events.%s.amazonaws.com", region)
+	}
+
+	return &EventBridgeClient{
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		endpoint:    strings.TrimSuffix(endpoint, "/") + "/",
+		region:      region,
+		credentials: awsCfg.Credentials,
+		signer:      v4.NewSigner(),
+	}, nil
+}
+
+func (c *EventBridgeClient) PutEvents(ctx context.Context, entries []EventBridgeEntry) ([]EventBridgeResult, error) {
+	body, err := json.Marshal(map[string][]EventBridgeEntry{"Entries": entries})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode PutEvents request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build PutEvents request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "AWSEvents.PutEvents")
+
+	credentials, err := c.credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get AWS credentials: %w", err)
+	}
+	sum := sha256.Sum256(body)
+	err = c.signer.SignHTTP(ctx, credentials, req, hex.EncodeToString(sum[:]), "events", c.region, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign PutEvents request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("PutEvents request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PutEvents response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(respBody, &apiErr)
+		return nil, fmt.Errorf("PutEvents returned %d: %s %s", resp.StatusCode, apiErr.Type, apiErr.Message)
+	}
+
+	var out struct {
+		Entries []EventBridgeResult
+	}
+	if err := json.Unmarshal(respBody, &out); err != nil {
+		return nil, fmt.Errorf("failed to decode PutEvents response: %w", err)
+	}
+	if len(out.Entries) != len(entries) {
+		return nil, fmt.Errorf("PutEvents returned %d results for %d entries", len(out.Entries), len(entries))
+	}
+	return out.Entries, nil
+}
+
+// EventBridgePublisher publishes events to an EventBridge bus. The whole
+// envelope is the detail, and the event type the detail-type, so rules can
+// match on either.
+type EventBridgePublisher struct {
+	client  EventBridgeAPI
+	busName string
+}
+
+// NewEventBridgePublisher creates a publisher for the bus named busName
+func NewEventBridgePublisher(client EventBridgeAPI, busName string) *EventBridgePublisher {
+	return &EventBridgePublisher{
+		client:  client,
+		busName: busName,
+	}
+}
+
+// Publish sends the events in batches of up to ten. Events EventBridge
+// rejects, and whole batches that fail, are returned in a *PublishError.
+func (p *EventBridgePublisher) Publish(ctx context.Context, events ...*Event) error {
+	var failed []*Event
+	var errs []error
+
+	for start := 0; start < len(events); start += maxPutEventsEntries {
+		batch := events[start:min(start+maxPutEventsEntries, len(events))]
+
+		entries := make([]EventBridgeEntry, 0, len(batch))
+		for _, event := range batch {
+			detail, err := json.Marshal(event)
+			if err != nil {
+				return fmt.Errorf("failed to encode event %s: %w", event.ID, err)
+			}
+			entries = append(entries, EventBridgeEntry{
+				EventBusName: p.busName,
+				Source:       event.Source,
+				DetailType:   string(event.Type),
+				Detail:       string(detail),
+				Time:         event.Time.Unix(),
+			})
+		}
+
+		results, err := p.client.PutEvents(ctx, entries)
+		for i, event := range batch {
+			eventErr := err
+			if err == nil && results[i].ErrorCode != "" {
+				eventErr = fmt.Errorf("%s: %s", results[i].ErrorCode, results[i].ErrorMessage)
+			}
+			logger.LogEventBridge(ctx, string(event.Type), event.Source, eventErr, zap.String("event_id", event.ID))
+
+			if eventErr != nil {
+				failed = append(failed, event)
+				if err == nil {
+					errs = append(errs, eventErr)
+				}
+			}
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(failed) > 0 {
+		return &PublishError{Failed: failed, Err: errors.Join(errs...)}
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+func TestMain(m *testing.M) {
+	if err := logger.Initialize(zap.NewAtomicLevelAt(zap.FatalLevel), false); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeEventBridge records PutEvents batches. It rejects the entries whose
+// detail mentions an ID in reject, and fails whole batches by number.
+type fakeEventBridge struct {
+	batches     [][]EventBridgeEntry
+	reject      map[string]bool
+	failBatches map[int]bool
+}
+
+func (f *fakeEventBridge) PutEvents(ctx context.Context, entries []EventBridgeEntry) ([]EventBridgeResult, error) {
+	f.batches = append(f.batches, entries)
+	if f.failBatches[len(f.batches)-1] {
+		return nil, errors.New("throttled")
+	}
+
+	results := make([]EventBridgeResult, len(entries))
+	for i, entry := range entries {
+		var event Event
+		if err := json.Unmarshal([]byte(entry.Detail), &event); err != nil {
+			return nil, err
+		}
+		if f.reject[event.ID] {
+			results[i] = EventBridgeResult{ErrorCode: "InternalFailure", ErrorMessage: "try again"}
+			continue
+		}
+		results[i] = EventBridgeResult{EventId: "eb-" + event.ID}
+	}
+	return results, nil
+}
+
+// newEvents returns n UserVerified events with IDs event-0, event-1, ...
+func newEvents(t *testing.T, n int) []*Event {
+	t.Helper()
+
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	events := make([]*Event, n)
+	for i := range events {
+		event, err := New(fmt.Sprintf("event-%d", i), SourceAuth, at, UserVerified{UserID: fmt.Sprintf("user-%d", i)})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		events[i] = event
+	}
+	return events
+}
+
+func eventIDs(events []*Event) []string {
+	ids := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	return ids
+}
+
+func TestEventBridgePublisher(t *testing.T) {
+	tests := []struct {
+		name        string
+		events      int
+		reject      []string
+		failBatches []int
+		wantBatches []int // entries per PutEvents call
+		wantFailed  []string
+	}{
+		{name: "nothing to publish", events: 0},
+		{name: "one batch", events: 3, wantBatches: []int{3}},
+		{name: "a full batch", events: 10, wantBatches: []int{10}},
+		{name: "batches of ten", events: 23, wantBatches: []int{10, 10, 3}},
+		{name: "rejected entries", events: 12, reject: []string{"event-1", "event-11"}, wantBatches: []int{10, 2}, wantFailed: []string{"event-1", "event-11"}},
+		{name: "failed batch", events: 21, failBatches: []int{1}, wantBatches: []int{10, 10, 1}, wantFailed: []string{
+			"event-10", "event-11", "event-12", "event-13", "event-14", "event-15", "event-16", "event-17", "event-18", "event-19",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeEventBridge{reject: make(map[string]bool), failBatches: make(map[int]bool)}
+			for _, id := range tt.reject {
+				client.reject[id] = true
+			}
+			for _, batch := range tt.failBatches {
+				client.failBatches[batch] = true
+			}
+			publisher := NewEventBridgePublisher(client, "multitask-dev")
+			events := newEvents(t, tt.events)
+
+			err := publisher.Publish(context.Background(), events...)
+
+			var sizes []int
+			for _, batch := range client.batches {
+				sizes = append(sizes, len(batch))
+			}
+			if fmt.Sprint(sizes) != fmt.Sprint(tt.wantBatches) {
+				t.Fatalf("PutEvents batches = %v, want %v", sizes, tt.wantBatches)
+			}
+
+			if tt.wantFailed == nil {
+				if err != nil {
+					t.Fatalf("Publish: %v", err)
+				}
+				return
+			}
+			var publishErr *PublishError
+			if !errors.As(err, &publishErr) {
+				t.Fatalf("Publish() error = %v, want a *PublishError", err)
+			}
+			if got := eventIDs(publishErr.Failed); fmt.Sprint(got) != fmt.Sprint(tt.wantFailed) {
+				t.Fatalf("failed events = %v, want %v", got, tt.wantFailed)
+			}
+			if got := eventIDs(FailedEvents(err, events)); fmt.Sprint(got) != fmt.Sprint(tt.wantFailed) {
+				t.Fatalf("FailedEvents() = %v, want %v", got, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestEventBridgePublisherEntries(t *testing.T) {
+	client := &fakeEventBridge{}
+	publisher := NewEventBridgePublisher(client, "multitask-dev")
+	events := newEvents(t, 1)
+
+	if err := publisher.Publish(context.Background(), events...); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	entry := client.batches[0][0]
+	if entry.EventBusName != "multitask-dev" || entry.Source != SourceAuth || entry.DetailType != string(TypeUserVerified) || entry.Time != events[0].Time.Unix() {
+		t.Fatalf("entry = %+v", entry)
+	}
+
+	// The detail is the whole envelope
+	var detail Event
+	if err := json.Unmarshal([]byte(entry.Detail), &detail); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	var payload UserVerified
+	if err := detail.Decode(&payload); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if detail.ID != events[0].ID || payload.UserID != "user-0" {
+		t.Fatalf("detail = %+v with %+v", detail, payload)
+	}
+}
+
+func TestEventBridgeClient(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+
+	var (
+		status   int
+		response string
+		received struct {
+			Entries []EventBridgeEntry
+		}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "AWSEvents.PutEvents" || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+			http.Error(w, `{"__type":"UnrecognizedClientException"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	client, err := NewEventBridgeClient(context.Background(), "us-east-1", server.URL)
+	if err != nil {
+		t.Fatalf("NewEventBridgeClient: %v", err)
+	}
+	entries := []EventBridgeEntry{{EventBusName: "bus", Source: SourceAuth, DetailType: "user.verified", Detail: "{}"}, {EventBusName: "bus"}}
+
+	tests := []struct {
+		name     string
+		status   int
+		response string
+		want     []EventBridgeResult
+		wantErr  bool
+	}{
+		{
+			name:     "accepted",
+			status:   http.StatusOK,
+			response: `{"FailedEntryCount":1,"Entries":[{"EventId":"1"},{"ErrorCode":"InternalFailure","ErrorMessage":"try again"}]}`,
+			want:     []EventBridgeResult{{EventId: "1"}, {ErrorCode: "InternalFailure", ErrorMessage: "try again"}},
+		},
+		{name: "error status", status: http.StatusBadRequest, response: `{"__type":"ValidationException","message":"bad"}`, wantErr: true},
+		{name: "too few results", status: http.StatusOK, response: `{"Entries":[{"EventId":"1"}]}`, wantErr: true},
+		{name: "garbage", status: http.StatusOK, response: `not json`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, response = tt.status, tt.response
+
+			got, err := client.PutEvents(context.Background(), entries)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PutEvents() error = %v, want error %v", err, tt.wantErr)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("PutEvents() = %+v, want %+v", got, tt.want)
+			}
+			if len(received.Entries) != len(entries) || received.Entries[0].DetailType != "user.verified" {
+				t.Fatalf("request entries = %+v", received.Entries)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+// Package events defines the domain events services publish to the platform
+// event bus, and the publishers that deliver them.
+//
+// Every event travels in an Event envelope. Its Data is the JSON of one of
+// the payload types below, in the schema version named by Version; the JSON
+// Schema of each type and version is embedded under schemas/ and returned by
+// Schema. A payload gains a new version, and a new schema file, whenever a
+// change could break a consumer.
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Type names a domain event. On EventBridge it is the detail-type.
+type Type string
+
+// Auth events
+const (
+	TypeUserRegistered      Type = "user.registered"
+	TypeUserVerified        Type = "user.verified"
+	TypeUserPasswordChanged Type = "user.password_changed"
+	TypeSessionRevoked      Type = "session.revoked"
+	TypeUserDeleted         Type = "user.deleted"
+)
+
+// Event sources
+const (
+	SourceAuth = "multitask.auth"
+)
+
+// Event is the envelope every domain event is published in
+type Event struct {
+	ID      string          `json:"eventId"` // Unique per event; consumers use it to drop duplicates
+	Type    Type            `json:"eventType"`
+	Source  string          `json:"source"` // Service that published the event
+	Version string          `json:"version"`
+	Time    time.Time       `json:"timestamp"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Payload is the data of one type of event
+type Payload interface {
+	EventType() Type
+	SchemaVersion() string
+}
+
+// New wraps a payload in an envelope
+func New(id, source string, at time.Time, payload Payload) (*Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s event: %w", payload.EventType(), err)
+	}
+
+	return &Event{
+		ID:      id,
+		Type:    payload.EventType(),
+		Source:  source,
+		Version: payload.SchemaVersion(),
+		Time:    at.UTC(),
+		Data:    data,
+	}, nil
+}
+
+// Decode unmarshals the event's data into payload, which must be the
+// payload type and schema version the event carries
+func (e *Event) Decode(payload Payload) error {
+	if e.Type != payload.EventType() || e.Version != payload.SchemaVersion() {
+		return fmt.Errorf("cannot decode %s v%s event as %s v%s", e.Type, e.Version, payload.EventType(), payload.SchemaVersion())
+	}
+	if err := json.Unmarshal(e.Data, payload); err != nil {
+		return fmt.Errorf("failed to decode %s event: %w", e.Type, err)
+	}
+	return nil
+}
+
+// PublishError reports the events a publisher failed to deliver. The
+// others were delivered.
+type PublishError struct {
+	Failed []*Event
+	Err    error
+}
+
+func (e *PublishError) Error() string {
+	return fmt.Sprintf("failed to publish %d events: %v", len(e.Failed), e.Err)
+}
+
+func (e *PublishError) Unwrap() error {
+	return e.Err
+}
+
+// FailedEvents returns the events a Publish call that returned err didn't
+// deliver: those in a *PublishError, or all of them for any other error
+func FailedEvents(err error, events []*Event) []*Event {
+	if err == nil {
+		return nil
+	}
+	var publishErr *PublishError
+	if errors.As(err, &publishErr) {
+		return publishErr.Failed
+	}
+	return events
+}
+
+// UserRegistered is published when an account is created. Accounts created
+// through social login start verified; others are followed by UserVerified.
+type UserRegistered struct {
+	UserID string `json:"userId"`
+	Email  string `json:"email"`
+	Name   string `json:"name"`
+	Method string `json:"method"` // "password", or the social login provider's name
+}
+
+func (UserRegistered) EventType() Type       { return TypeUserRegistered }
+func (UserRegistered) SchemaVersion() string { return "1" }
+
+// UserVerified is published when an account's email address is verified
+type UserVerified struct {
+	UserID string `json:"userId"`
+}
+
+func (UserVerified) EventType() Type       { return TypeUserVerified }
+func (UserVerified) SchemaVersion() string { return "1" }
+
+// UserPasswordChanged is published when a password is changed, or reset
+// through the email flow
+type UserPasswordChanged struct {
+	UserID string `json:"userId"`
+	Reset  bool   `json:"reset"`
+}
+
+func (UserPasswordChanged) EventType() Type       { return TypeUserPasswordChanged }
+func (UserPasswordChanged) SchemaVersion() string { return "1" }
+
+// Reasons a session is revoked
+const (
+	RevokeReasonLogout        = "logout"         // The user logged out
+	RevokeReasonRevoked       = "revoked"        // The user, or the OAuth2 client holding it, ended it
+	RevokeReasonTokenReuse    = "token_reuse"    // A refresh token or authorization code was used twice
+	RevokeReasonAdmin         = "admin"          // An admin logged the user out
+	RevokeReasonUserDisabled  = "user_disabled"  // An admin disabled the account
+	RevokeReasonPasswordReset = "password_reset" // The password was reset
+)
+
+// SessionRevoked is published when sessions end before they expire
+type SessionRevoked struct {
+	UserID    string `json:"userId"`
+	SessionID string `json:"sessionId,omitempty"` // Empty when all of the user's sessions ended
+	Reason    string `json:"reason"`
+}
+
+func (SessionRevoked) EventType() Type       { return TypeSessionRevoked }
+func (SessionRevoked) SchemaVersion() string { return "1" }
+
+// UserDeleted is published when an account is deleted. Consumers should
+// delete or anonymize what they hold about the user.
+type UserDeleted struct {
+	UserID string `json:"userId"`
+}
+
+func (UserDeleted) EventType() Type       { return TypeUserDeleted }
+func (UserDeleted) SchemaVersion() string { return "1" }
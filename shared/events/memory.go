@@ -0,0 +1,68 @@
+package events
+
+import (
+	"context"
+	"maps"
+	"sync"
+)
+
+// Handler receives the events a MemoryBus delivers
+type Handler func(ctx context.Context, event *Event) error
+
+// MemoryBus is an in-process event bus for local development and tests. It
+// keeps every delivered event and hands each to the handlers subscribed to
+// its type, synchronously.
+type MemoryBus struct {
+	mu       sync.Mutex
+	events   []*Event
+	handlers map[Type][]Handler
+}
+
+// NewMemoryBus creates an empty in-memory bus
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		handlers: make(map[Type][]Handler),
+	}
+}
+
+// Subscribe calls handler for every event of the type published from now on
+func (b *MemoryBus) Subscribe(eventType Type, handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+// Publish delivers the events in order. A handler error stops delivery and
+// fails the events from that one on.
+func (b *MemoryBus) Publish(ctx context.Context, events ...*Event) error {
+	b.mu.Lock()
+	handlers := maps.Clone(b.handlers)
+	b.mu.Unlock()
+
+	for i, event := range events {
+		for _, handler := range handlers[event.Type] {
+			if err := handler(ctx, event); err != nil {
+				b.record(events[:i])
+				return &PublishError{Failed: events[i:], Err: err}
+			}
+		}
+	}
+	b.record(events)
+	return nil
+}
+
+func (b *MemoryBus) record(events []*Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.events = append(b.events, events...)
+}
+
+// Events returns everything delivered so far, oldest first
+func (b *MemoryBus) Events() []*Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]*Event(nil), b.events...)
+}
@@ -0,0 +1,18 @@
+package events
+
+import (
+	"embed"
+	"fmt"
+)
+
+//go:embed schemas/*.json
+var schemas embed.FS
+
+// Schema returns the JSON Schema for the data of an event type at a version
+func Schema(eventType Type, version string) ([]byte, error) {
+	schema, err := schemas.ReadFile(fmt.Sprintf("schemas/%s.v%s.json", eventType, version))
+	if err != nil {
+		return nil, fmt.Errorf("no schema for %s v%s", eventType, version)
+	}
+	return schema, nil
+}
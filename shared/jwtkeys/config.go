@@ -0,0 +1,120 @@
+package jwtkeys
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/multitask-platform/backend/shared/config"
+)
+
+// LoadSigningKeySet builds the issuer's key set from JWT_KEYSET (or
+// JWT_KEYSET_FILE), falling back to a single JWT_PRIVATE_KEY (or
+// JWT_PRIVATE_KEY_FILE), and adds any JWT_PUBLIC_KEYS as extra verification
+// keys. Single keys get their RFC 7638 thumbprint as id, so verifiers
+// configured with the same public keys derive the same kid.
+func LoadSigningKeySet(cfg *config.Config, opts ...KeySetOption) (*KeySet, error) {
+	keys, err := loadPrivateKeys(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoSigningKey
+	}
+
+	extra, err := loadPublicKeys(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKeySet(append(keys, extra...), opts...)
+}
+
+func loadPrivateKeys(cfg *config.Config) ([]*Key, error) {
+	keySetJSON, err := pemValue(cfg.JWT.KeySet, cfg.JWT.KeySetFile)
+	if err != nil {
+		return nil, err
+	}
+	if keySetJSON != nil {
+		return ParseKeySetJSON(keySetJSON)
+	}
+
+	privatePEM, err := pemValue(cfg.JWT.PrivateKey, cfg.JWT.PrivateKeyFile)
+	if err != nil || privatePEM == nil {
+		return nil, err
+	}
+
+	signer, err := ParsePrivateKeyPEM(privatePEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT private key: %w", err)
+	}
+
+	key, err := NewSigningKey("", signer)
+	if err != nil {
+		return nil, err
+	}
+	return []*Key{key}, nil
+}
+
+// LoadVerifier returns the verifier services use to check access tokens, in
+// order of preference: the auth service's JWKS_URL, a static JWT_PUBLIC_KEYS
+// bundle, or the issuer's own keys when running inside auth-svc
+func LoadVerifier(cfg *config.Config) (Verifier, error) {
+	if cfg.JWT.JWKSURL != "" {
+		return NewRemoteKeySet(cfg.JWT.JWKSURL, nil), nil
+	}
+
+	keys, err := loadPublicKeys(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) > 0 {
+		return NewKeySet(keys)
+	}
+
+	ks, err := LoadSigningKeySet(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("no JWT verification keys configured: %w", err)
+	}
+	return ks, nil
+}
+
+// loadPublicKeys parses JWT_PUBLIC_KEYS (or JWT_PUBLIC_KEYS_FILE)
+func loadPublicKeys(cfg *config.Config) ([]*Key, error) {
+	publicPEM, err := pemValue(cfg.JWT.PublicKeys, cfg.JWT.PublicKeysFile)
+	if err != nil || publicPEM == nil {
+		return nil, err
+	}
+
+	publics, err := ParsePublicKeysPEM(publicPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT public keys: %w", err)
+	}
+
+	keys := make([]*Key, 0, len(publics))
+	for _, public := range publics {
+		key, err := NewVerificationKey("", public)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// pemValue returns the inline value if set, else the contents of path, else nil.
+// Inline values may use literal "\n" for newlines, as single-line env vars do.
+func pemValue(inline, path string) ([]byte, error) {
+	if inline != "" {
+		return []byte(strings.ReplaceAll(inline, `\n`, "\n")), nil
+	}
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,169 @@
+package jwtkeys
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// JWK is the public part of a key in JSON Web Key form (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC and OKP
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+
+	// Validity window as Unix seconds. Not registered by RFC 7517, which
+	// allows extra members; verifiers that don't know them ignore them.
+	NotBefore int64 `json:"nbf,omitempty"`
+	NotAfter  int64 `json:"exp,omitempty"`
+}
+
+// JSONWebKeySet is the document served at /.well-known/jwks.json
+type JSONWebKeySet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK returns the public key as a JWK
+func (k *Key) JWK() (JWK, error) {
+	jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Algorithm}
+	if !k.NotBefore.IsZero() {
+		jwk.NotBefore = k.NotBefore.Unix()
+	}
+	if !k.NotAfter.IsZero() {
+		jwk.NotAfter = k.NotAfter.Unix()
+	}
+
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encodeBigInt(pub.N, 0)
+		jwk.E = encodeBigInt(big.NewInt(int64(pub.E)), 0)
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = "P-256"
+		jwk.X = encodeBigInt(pub.X, size)
+		jwk.Y = encodeBigInt(pub.Y, size)
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		return JWK{}, fmt.Errorf("%w: %T", ErrUnsupportedKey, k.Public)
+	}
+
+	return jwk, nil
+}
+
+// Key converts the JWK back into a verification key
+func (j JWK) Key() (*Key, error) {
+	var public interface{}
+
+	switch j.Kty {
+	case "RSA":
+		n, err := decodeBigInt(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(j.E)
+		if err != nil {
+			return nil, err
+		}
+		public = &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		if j.Crv != "P-256" {
+			return nil, fmt.Errorf("%w: curve %q", ErrUnsupportedKey, j.Crv)
+		}
+		x, err := decodeBigInt(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		public = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("%w: curve %q", ErrUnsupportedKey, j.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key %q", j.Kid)
+		}
+		public = ed25519.PublicKey(x)
+	default:
+		return nil, fmt.Errorf("%w: kty %q", ErrUnsupportedKey, j.Kty)
+	}
+
+	key, err := NewVerificationKey(j.Kid, public)
+	if err != nil {
+		return nil, err
+	}
+	if j.Alg != "" && j.Alg != key.Algorithm {
+		return nil, fmt.Errorf("key %q: alg %q does not match key type", j.Kid, j.Alg)
+	}
+	if j.NotBefore != 0 {
+		key.NotBefore = time.Unix(j.NotBefore, 0).UTC()
+	}
+	if j.NotAfter != 0 {
+		key.NotAfter = time.Unix(j.NotAfter, 0).UTC()
+	}
+	return key, nil
+}
+
+// ParseJWKS decodes a JWKS document, skipping keys that are not for
+// signatures or use unsupported types
+func ParseJWKS(data []byte) ([]*Key, error) {
+	var set JSONWebKeySet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make([]*Key, 0, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.Key()
+		if err != nil {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func encodeBigInt(n *big.Int, size int) string {
+	b := n.Bytes()
+	if len(b) < size {
+		padded := make([]byte, size)
+		copy(padded[size-len(b):], b)
+		b = padded
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64url value: %w", err)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
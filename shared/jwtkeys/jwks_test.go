@@ -0,0 +1,168 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// publicKey lets keys of every type be compared
+type publicKey interface {
+	Equal(x crypto.PublicKey) bool
+}
+
+func TestKeySetJWKS(t *testing.T) {
+	var keys []*Key
+	for _, alg := range algorithms {
+		keys = append(keys, generateKey(t, alg))
+	}
+	ks, err := NewKeySet(keys)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+
+	set, err := ks.JWKS()
+	if err != nil {
+		t.Fatalf("JWKS: %v", err)
+	}
+	document, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	// No private material is published
+	var raw struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}
+	if err := json.Unmarshal(document, &raw); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	wantKty := map[string]string{AlgorithmRS256: "RSA", AlgorithmES256: "EC", AlgorithmEdDSA: "OKP"}
+	for i, jwk := range raw.Keys {
+		for _, private := range []string{"d", "p", "q", "dp", "dq", "qi"} {
+			if _, ok := jwk[private]; ok {
+				t.Fatalf("JWK %d has private member %q", i, private)
+			}
+		}
+		if jwk["kid"] != keys[i].ID || jwk["alg"] != keys[i].Algorithm || jwk["use"] != "sig" || jwk["kty"] != wantKty[keys[i].Algorithm] {
+			t.Fatalf("JWK %d = %v, want kid %s, alg %s, use sig", i, jwk, keys[i].ID, keys[i].Algorithm)
+		}
+	}
+
+	parsed, err := ParseJWKS(document)
+	if err != nil {
+		t.Fatalf("ParseJWKS: %v", err)
+	}
+	if len(parsed) != len(keys) {
+		t.Fatalf("ParseJWKS() = %d keys, want %d", len(parsed), len(keys))
+	}
+	for i, key := range parsed {
+		if key.ID != keys[i].ID || key.Algorithm != keys[i].Algorithm || key.CanSign() {
+			t.Fatalf("key %d = %s (%s), want verification key %s (%s)", i, key.ID, key.Algorithm, keys[i].ID, keys[i].Algorithm)
+		}
+		if !key.Public.(publicKey).Equal(keys[i].Public) {
+			t.Fatalf("key %d: public key changed in the round trip", i)
+		}
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	key := generateKey(t, AlgorithmEdDSA)
+	jwk, err := key.JWK()
+	if err != nil {
+		t.Fatalf("JWK: %v", err)
+	}
+
+	encryption := jwk
+	encryption.Kid = "enc"
+	encryption.Use = "enc"
+	wrongAlg := jwk
+	wrongAlg.Kid = "wrong-alg"
+	wrongAlg.Alg = AlgorithmRS256
+	symmetric := JWK{Kty: "oct", Kid: "oct"}
+
+	document, err := json.Marshal(JSONWebKeySet{Keys: []JWK{encryption, wrongAlg, symmetric, jwk}})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	// Only the signing key with a consistent alg survives
+	keys, err := ParseJWKS(document)
+	if err != nil {
+		t.Fatalf("ParseJWKS: %v", err)
+	}
+	if len(keys) != 1 || keys[0].ID != key.ID {
+		t.Fatalf("ParseJWKS() = %v, want only %s", keys, key.ID)
+	}
+
+	if _, err := ParseJWKS([]byte("not json")); err == nil {
+		t.Fatal("ParseJWKS() accepted garbage")
+	}
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	first := generateKey(t, AlgorithmES256)
+	second := generateKey(t, AlgorithmES256)
+
+	var (
+		published atomic.Value
+		fetches   atomic.Int32
+	)
+	publish := func(keys ...*Key) {
+		ks, err := NewKeySet(keys)
+		if err != nil {
+			t.Fatalf("NewKeySet: %v", err)
+		}
+		set, err := ks.JWKS()
+		if err != nil {
+			t.Fatalf("JWKS: %v", err)
+		}
+		published.Store(set)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		json.NewEncoder(w).Encode(published.Load())
+	}))
+	defer server.Close()
+
+	signWith := func(key *Key) string {
+		ks, err := NewKeySet([]*Key{key})
+		if err != nil {
+			t.Fatalf("NewKeySet: %v", err)
+		}
+		tokenString, err := ks.Sign(jwt.MapClaims{"sub": "user-1"})
+		if err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+		return tokenString
+	}
+
+	publish(first)
+	remote := NewRemoteKeySet(server.URL, server.Client())
+
+	if _, err := verify(remote, signWith(first)); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if _, err := verify(remote, signWith(first)); err != nil || fetches.Load() != 1 {
+		t.Fatalf("second verify: %v after %d fetches, want the cached keys", err, fetches.Load())
+	}
+
+	// A new kid is only looked up again once the minimum interval has passed
+	publish(first, second)
+	if _, err := verify(remote, signWith(second)); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("verify() with a new kid right away error = %v, want %v", err, ErrUnknownKey)
+	}
+	remote.fetchedAt = remote.fetchedAt.Add(-2 * remote.minRefreshInterval)
+	if _, err := verify(remote, signWith(second)); err != nil {
+		t.Fatalf("verify() with a new kid later: %v", err)
+	}
+	if fetches.Load() != 2 {
+		t.Fatalf("JWKS fetched %d times, want 2", fetches.Load())
+	}
+}
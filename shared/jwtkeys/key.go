@@ -0,0 +1,268 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+var (
+	ErrUnsupportedKey = errors.New("unsupported key type")
+	ErrNoPEMBlock     = errors.New("no PEM block found")
+)
+
+// Key is a JWT signing or verification key. Signer is nil for keys that can
+// only verify, such as keys fetched from a JWKS endpoint. A zero NotBefore or
+// NotAfter leaves that end of the validity window open.
+type Key struct {
+	ID        string
+	Algorithm string
+	Signer    crypto.Signer
+	Public    crypto.PublicKey
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// NewSigningKey wraps a private key. The algorithm follows from the key type
+// (RSA: RS256, P-256: ES256, Ed25519: EdDSA). An empty id defaults to the
+// RFC 7638 thumbprint of the public key.
+func NewSigningKey(id string, signer crypto.Signer) (*Key, error) {
+	key, err := NewVerificationKey(id, signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.Signer = signer
+	return key, nil
+}
+
+// NewVerificationKey wraps a public key; see NewSigningKey for how the
+// algorithm and default id are chosen
+func NewVerificationKey(id string, public crypto.PublicKey) (*Key, error) {
+	alg, err := algorithmFor(public)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: id, Algorithm: alg, Public: public}
+	if key.ID == "" {
+		key.ID, err = key.Thumbprint()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// CanSign reports whether the key holds private material
+func (k *Key) CanSign() bool {
+	return k.Signer != nil
+}
+
+// ValidAt reports whether t falls inside the key's validity window
+func (k *Key) ValidAt(t time.Time) bool {
+	if !k.NotBefore.IsZero() && t.Before(k.NotBefore) {
+		return false
+	}
+	return !k.ExpiredAt(t)
+}
+
+// ExpiredAt reports whether the key's window closed before t
+func (k *Key) ExpiredAt(t time.Time) bool {
+	return !k.NotAfter.IsZero() && !t.Before(k.NotAfter)
+}
+
+// SigningMethod returns the jwt-go method for the key's algorithm
+func (k *Key) SigningMethod() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// GenerateSigningKey creates a new random key for alg with an id derived
+// from its thumbprint
+func GenerateSigningKey(alg string) (*Key, error) {
+	var (
+		signer crypto.Signer
+		err    error
+	)
+
+	switch alg {
+	case AlgorithmRS256:
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgorithmES256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgorithmEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("%w: algorithm %q", ErrUnsupportedKey, alg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key: %w", alg, err)
+	}
+
+	return NewSigningKey("", signer)
+}
+
+// Thumbprint returns the base64url SHA-256 JWK thumbprint (RFC 7638)
+func (k *Key) Thumbprint() (string, error) {
+	jwk, err := k.JWK()
+	if err != nil {
+		return "", err
+	}
+
+	// Required members only, in lexicographic order
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+func algorithmFor(public crypto.PublicKey) (string, error) {
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return "", fmt.Errorf("%w: RSA keys must be at least 2048 bits", ErrUnsupportedKey)
+		}
+		return AlgorithmRS256, nil
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return "", fmt.Errorf("%w: only P-256 EC keys are supported", ErrUnsupportedKey)
+		}
+		return AlgorithmES256, nil
+	case ed25519.PublicKey:
+		return AlgorithmEdDSA, nil
+	default:
+		return "", fmt.Errorf("%w: %T", ErrUnsupportedKey, public)
+	}
+}
+
+// ParsePrivateKeyPEM parses the first private key in data (PKCS#8, PKCS#1 RSA
+// or SEC 1 EC)
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, ErrNoPEMBlock
+		}
+
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse PKCS#8 key: %w", err)
+			}
+			signer, ok := key.(crypto.Signer)
+			if !ok {
+				return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+			}
+			return signer, nil
+		case "RSA PRIVATE KEY":
+			key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse RSA key: %w", err)
+			}
+			return key, nil
+		case "EC PRIVATE KEY":
+			key, err := x509.ParseECPrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse EC key: %w", err)
+			}
+			return key, nil
+		}
+	}
+}
+
+// ParsePublicKeysPEM parses every public key in data (PKIX or PKCS#1 RSA), so
+// several verification keys can be supplied as one bundle
+func ParsePublicKeysPEM(data []byte) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse public key: %w", err)
+			}
+			keys = append(keys, key)
+		case "RSA PUBLIC KEY":
+			key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse RSA public key: %w", err)
+			}
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, ErrNoPEMBlock
+	}
+	return keys, nil
+}
+
+// MarshalPrivateKeyPEM encodes signer as a PKCS#8 PEM block
+func MarshalPrivateKeyPEM(signer crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// MarshalPublicKeyPEM encodes public as a PKIX PEM block
+func MarshalPublicKeyPEM(public crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
@@ -0,0 +1,198 @@
+package jwtkeys
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var algorithms = []string{AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA}
+
+// generateKey creates a signing key or fails the test
+func generateKey(t *testing.T, alg string) *Key {
+	t.Helper()
+
+	key, err := GenerateSigningKey(alg)
+	if err != nil {
+		t.Fatalf("GenerateSigningKey(%s): %v", alg, err)
+	}
+	return key
+}
+
+// verify parses tokenString the way services do
+func verify(verifier Verifier, tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, verifier.Keyfunc, jwt.WithValidMethods(ValidMethods))
+}
+
+func TestKeySetSignAndVerify(t *testing.T) {
+	for _, alg := range algorithms {
+		t.Run(alg, func(t *testing.T) {
+			key := generateKey(t, alg)
+			ks, err := NewKeySet([]*Key{key})
+			if err != nil {
+				t.Fatalf("NewKeySet: %v", err)
+			}
+
+			tokenString, err := ks.Sign(jwt.MapClaims{"sub": "user-1"})
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+
+			token, err := verify(ks, tokenString)
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			if token.Header["alg"] != alg || token.Header["kid"] != key.ID {
+				t.Fatalf("header = %v, want alg %s and kid %s", token.Header, alg, key.ID)
+			}
+
+			// Only the public key is needed to verify
+			public, err := NewVerificationKey("", key.Public)
+			if err != nil {
+				t.Fatalf("NewVerificationKey: %v", err)
+			}
+			verifier, err := NewKeySet([]*Key{public})
+			if err != nil {
+				t.Fatalf("NewKeySet: %v", err)
+			}
+			if _, err := verify(verifier, tokenString); err != nil {
+				t.Fatalf("verify with the public key: %v", err)
+			}
+			if _, err := verifier.Sign(jwt.MapClaims{}); err != ErrNoSigningKey {
+				t.Fatalf("Sign() without a private key error = %v, want %v", err, ErrNoSigningKey)
+			}
+
+			parts := strings.Split(tokenString, ".")
+			tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin"}`)) + "." + parts[2]
+			if _, err := verify(ks, tampered); err == nil {
+				t.Fatal("verify() accepted a token with altered claims")
+			}
+		})
+	}
+}
+
+func TestKeySetKeyfunc(t *testing.T) {
+	signing := generateKey(t, AlgorithmES256)
+	other := generateKey(t, AlgorithmRS256)
+	otherPublic, err := NewVerificationKey(other.ID, other.Public)
+	if err != nil {
+		t.Fatalf("NewVerificationKey: %v", err)
+	}
+	ks, err := NewKeySet([]*Key{signing, otherPublic})
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+
+	// sign signs with key's private key under the given header values
+	sign := func(key *Key, method jwt.SigningMethod, kid interface{}) string {
+		token := jwt.NewWithClaims(method, jwt.MapClaims{"sub": "user-1"})
+		if kid != nil {
+			token.Header["kid"] = kid
+		}
+		tokenString, err := token.SignedString(key.Signer)
+		if err != nil {
+			t.Fatalf("SignedString: %v", err)
+		}
+		return tokenString
+	}
+
+	none, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"sub": "user-1"}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error // nil when any error will do
+		wantOK  bool
+	}{
+		{name: "signing key", token: sign(signing, jwt.SigningMethodES256, signing.ID), wantOK: true},
+		{name: "verification-only key", token: sign(other, jwt.SigningMethodRS256, other.ID), wantOK: true},
+		{name: "unknown kid", token: sign(signing, jwt.SigningMethodES256, "unknown"), wantErr: ErrUnknownKey},
+		{name: "no kid", token: sign(signing, jwt.SigningMethodES256, nil), wantErr: ErrUnknownKey},
+		{name: "kid of another key", token: sign(signing, jwt.SigningMethodES256, other.ID)},
+		{name: "alg none", token: none},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := verify(ks, tt.token)
+			if tt.wantOK {
+				if err != nil {
+					t.Fatalf("verify: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("verify() succeeded")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := NewKeySet([]*Key{signing, signing}); err == nil {
+		t.Fatal("NewKeySet() accepted a duplicate kid")
+	}
+}
+
+func TestKeyPEM(t *testing.T) {
+	for _, alg := range algorithms {
+		t.Run(alg, func(t *testing.T) {
+			key := generateKey(t, alg)
+
+			privatePEM, err := MarshalPrivateKeyPEM(key.Signer)
+			if err != nil {
+				t.Fatalf("MarshalPrivateKeyPEM: %v", err)
+			}
+			signer, err := ParsePrivateKeyPEM(privatePEM)
+			if err != nil {
+				t.Fatalf("ParsePrivateKeyPEM: %v", err)
+			}
+			parsed, err := NewSigningKey("", signer)
+			if err != nil {
+				t.Fatalf("NewSigningKey: %v", err)
+			}
+			// The thumbprint id survives the round trip
+			if parsed.ID != key.ID || parsed.Algorithm != alg {
+				t.Fatalf("parsed key %s (%s), want %s (%s)", parsed.ID, parsed.Algorithm, key.ID, alg)
+			}
+		})
+	}
+
+	// Public keys come as one bundle
+	var bundle []byte
+	for _, alg := range algorithms {
+		publicPEM, err := MarshalPublicKeyPEM(generateKey(t, alg).Public)
+		if err != nil {
+			t.Fatalf("MarshalPublicKeyPEM: %v", err)
+		}
+		bundle = append(bundle, publicPEM...)
+	}
+	publics, err := ParsePublicKeysPEM(bundle)
+	if err != nil || len(publics) != len(algorithms) {
+		t.Fatalf("ParsePublicKeysPEM() = %d keys, %v; want %d", len(publics), err, len(algorithms))
+	}
+
+	if _, err := ParsePrivateKeyPEM([]byte("not a key")); err != ErrNoPEMBlock {
+		t.Fatalf("ParsePrivateKeyPEM(garbage) error = %v, want %v", err, ErrNoPEMBlock)
+	}
+
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	if _, err := NewSigningKey("", weak); !errors.Is(err, ErrUnsupportedKey) {
+		t.Fatalf("NewSigningKey(1024 bit RSA) error = %v, want %v", err, ErrUnsupportedKey)
+	}
+	if _, err := GenerateSigningKey("HS256"); !errors.Is(err, ErrUnsupportedKey) {
+		t.Fatalf("GenerateSigningKey(HS256) error = %v, want %v", err, ErrUnsupportedKey)
+	}
+}
@@ -0,0 +1,155 @@
+package jwtkeys
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrNoSigningKey = errors.New("no signing key configured")
+	ErrUnknownKey   = errors.New("unknown key id")
+	ErrKeyNotValid  = errors.New("key outside its validity window")
+)
+
+// ValidMethods lists the algorithms accepted when verifying. Pass it to
+// jwt.WithValidMethods so tokens using "none" or HMAC are rejected up front.
+var ValidMethods = []string{AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA}
+
+// verificationLeeway tolerates clock skew between the issuer and verifiers at
+// either end of a key's validity window
+const verificationLeeway = time.Minute
+
+// Verifier resolves the public key for a token from its kid header
+type Verifier interface {
+	Keyfunc(token *jwt.Token) (interface{}, error)
+}
+
+// KeySet holds every key the issuer knows about. The active signing key is
+// the private key with the latest NotBefore that is valid now, so a key added
+// with a future NotBefore is published (and cached by verifiers) before it
+// starts signing, and the key it replaces keeps verifying until its NotAfter.
+type KeySet struct {
+	keys  map[string]*Key
+	order []*Key
+	now   func() time.Time
+}
+
+// KeySetOption configures a KeySet
+type KeySetOption func(*KeySet)
+
+// WithClock overrides the time source used to pick and check keys (defaults
+// to time.Now)
+func WithClock(now func() time.Time) KeySetOption {
+	return func(ks *KeySet) {
+		ks.now = now
+	}
+}
+
+// NewKeySet creates a key set from signing and verification-only keys
+func NewKeySet(keys []*Key, opts ...KeySetOption) (*KeySet, error) {
+	ks := &KeySet{
+		keys: make(map[string]*Key),
+		now:  time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(ks)
+	}
+
+	for _, key := range keys {
+		if _, ok := ks.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+		ks.order = append(ks.order, key)
+	}
+
+	return ks, nil
+}
+
+// Keys returns all keys in the order they were added
+func (ks *KeySet) Keys() []*Key {
+	return append([]*Key(nil), ks.order...)
+}
+
+// SigningKey returns the key new tokens are signed with, or nil if no private
+// key is valid now
+func (ks *KeySet) SigningKey() *Key {
+	now := ks.now()
+
+	var active *Key
+	for _, key := range ks.order {
+		if !key.CanSign() || !key.ValidAt(now) {
+			continue
+		}
+		if active == nil || !key.NotBefore.Before(active.NotBefore) {
+			active = key
+		}
+	}
+	return active
+}
+
+// Key returns the key with the given id
+func (ks *KeySet) Key(kid string) (*Key, bool) {
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+// Sign signs claims with the active signing key and sets the kid header
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	signing := ks.SigningKey()
+	if signing == nil {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(signing.SigningMethod(), claims)
+	token.Header["kid"] = signing.ID
+
+	return token.SignedString(signing.Signer)
+}
+
+// Keyfunc implements Verifier. Any key inside its validity window is
+// accepted, including retired keys that no longer sign.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+	return verificationKey(key, token, ks.now())
+}
+
+// JWKS returns the public keys that have not expired, including keys whose
+// NotBefore is still in the future so verifiers learn them ahead of time
+func (ks *KeySet) JWKS() (JSONWebKeySet, error) {
+	now := ks.now()
+
+	set := JSONWebKeySet{Keys: make([]JWK, 0, len(ks.order))}
+	for _, key := range ks.order {
+		if key.ExpiredAt(now) {
+			continue
+		}
+		jwk, err := key.JWK()
+		if err != nil {
+			return JSONWebKeySet{}, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
+
+// verificationKey returns key's public key after checking the token's alg
+// header matches it, so a key can't be used with a different algorithm, and
+// that the key is inside its validity window
+func verificationKey(key *Key, token *jwt.Token, now time.Time) (interface{}, error) {
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method %q for key %q", token.Method.Alg(), key.ID)
+	}
+	if !key.ValidAt(now.Add(verificationLeeway)) && !key.ValidAt(now.Add(-verificationLeeway)) {
+		return nil, fmt.Errorf("%w: %q", ErrKeyNotValid, key.ID)
+	}
+	return key.Public, nil
+}
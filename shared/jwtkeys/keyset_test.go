@@ -0,0 +1,212 @@
+package jwtkeys
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TestKeySetRotation follows a key set through a rotation: the new key is
+// published before it signs, and the retired key verifies the tokens it
+// signed until its window closes
+func TestKeySetRotation(t *testing.T) {
+	const (
+		publishDelay = time.Hour
+		overlap      = 24 * time.Hour
+	)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+
+	current := generateKey(t, AlgorithmEdDSA)
+	next := generateKey(t, AlgorithmEdDSA)
+	keys := Rotate([]*Key{current}, next, start, publishDelay, overlap)
+	activation := start.Add(publishDelay)
+	retireAt := activation.Add(overlap)
+
+	if keys[0].NotAfter != retireAt || !current.NotAfter.IsZero() {
+		t.Fatalf("retired key window ends %v, want %v without changing the original", keys[0].NotAfter, retireAt)
+	}
+	if keys[1].NotBefore != activation || !keys[1].NotAfter.IsZero() {
+		t.Fatalf("new key window = %v to %v, want from %v", keys[1].NotBefore, keys[1].NotAfter, activation)
+	}
+
+	ks, err := NewKeySet(keys, WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+
+	sign := func() string {
+		tokenString, err := ks.Sign(jwt.MapClaims{"sub": "user-1"})
+		if err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+		return tokenString
+	}
+	published := func() []string {
+		set, err := ks.JWKS()
+		if err != nil {
+			t.Fatalf("JWKS: %v", err)
+		}
+		var kids []string
+		for _, jwk := range set.Keys {
+			kids = append(kids, jwk.Kid)
+		}
+		return kids
+	}
+
+	// Before activation the old key signs and both are published
+	oldToken := sign()
+	if kid := ks.SigningKey().ID; kid != current.ID {
+		t.Fatalf("signing with %s before activation, want %s", kid, current.ID)
+	}
+	if kids := published(); len(kids) != 2 {
+		t.Fatalf("JWKS = %v, want both keys ahead of activation", kids)
+	}
+	early := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{"sub": "user-1"})
+	early.Header["kid"] = next.ID
+	earlyToken, err := early.SignedString(next.Signer)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		at          time.Time
+		wantSigning string
+		wantJWKS    int
+		wantOld     error // verifying the token the old key signed
+		wantEarly   error // verifying a token the new key signed before activation
+	}{
+		{name: "before activation", at: start, wantSigning: current.ID, wantJWKS: 2, wantEarly: ErrKeyNotValid},
+		{name: "within the leeway of activation", at: activation.Add(-verificationLeeway / 2), wantSigning: current.ID, wantJWKS: 2},
+		{name: "activation", at: activation, wantSigning: next.ID, wantJWKS: 2},
+		{name: "during the overlap", at: retireAt.Add(-time.Second), wantSigning: next.ID, wantJWKS: 2},
+		{name: "within the leeway of retirement", at: retireAt.Add(verificationLeeway / 2), wantSigning: next.ID, wantJWKS: 1},
+		{name: "after retirement", at: retireAt.Add(2 * verificationLeeway), wantSigning: next.ID, wantJWKS: 1, wantOld: ErrKeyNotValid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = tt.at
+
+			if kid := ks.SigningKey().ID; kid != tt.wantSigning {
+				t.Fatalf("signing with %s, want %s", kid, tt.wantSigning)
+			}
+			if kids := published(); len(kids) != tt.wantJWKS {
+				t.Fatalf("JWKS = %v, want %d keys", kids, tt.wantJWKS)
+			}
+			if _, err := verify(ks, oldToken); !errors.Is(err, tt.wantOld) {
+				t.Fatalf("verifying the old key's token error = %v, want %v", err, tt.wantOld)
+			}
+			if _, err := verify(ks, earlyToken); !errors.Is(err, tt.wantEarly) {
+				t.Fatalf("verifying the new key's early token error = %v, want %v", err, tt.wantEarly)
+			}
+			if _, err := verify(ks, sign()); err != nil {
+				t.Fatalf("verifying a fresh token: %v", err)
+			}
+		})
+	}
+
+	if pruned := Prune(keys, retireAt); len(pruned) != 1 || pruned[0].ID != next.ID {
+		t.Fatalf("Prune() kept %d keys, want only %s", len(pruned), next.ID)
+	}
+}
+
+func TestKeySetSigningKey(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	newKey := func(notBefore, notAfter time.Duration) *Key {
+		key := generateKey(t, AlgorithmES256)
+		if notBefore != 0 {
+			key.NotBefore = now.Add(notBefore)
+		}
+		if notAfter != 0 {
+			key.NotAfter = now.Add(notAfter)
+		}
+		return key
+	}
+	open := newKey(0, 0)
+	older := newKey(-48*time.Hour, 0)
+	newest := newKey(-time.Hour, 0)
+	expired := newKey(-72*time.Hour, -time.Minute)
+	future := newKey(time.Hour, 0)
+	public, err := NewVerificationKey("", generateKey(t, AlgorithmES256).Public)
+	if err != nil {
+		t.Fatalf("NewVerificationKey: %v", err)
+	}
+	public.NotBefore = now
+
+	tests := []struct {
+		name string
+		keys []*Key
+		want *Key
+	}{
+		{name: "open window", keys: []*Key{open}, want: open},
+		{name: "latest NotBefore wins", keys: []*Key{newest, older, open}, want: newest},
+		{name: "expired keys never sign", keys: []*Key{expired, older}, want: older},
+		{name: "future keys wait", keys: []*Key{older, future}, want: older},
+		{name: "verification-only keys never sign", keys: []*Key{older, public}, want: older},
+		{name: "nothing valid", keys: []*Key{expired, future}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks, err := NewKeySet(tt.keys, WithClock(func() time.Time { return now }))
+			if err != nil {
+				t.Fatalf("NewKeySet: %v", err)
+			}
+			if got := ks.SigningKey(); got != tt.want {
+				t.Fatalf("SigningKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeySetJSON(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	keys := Rotate([]*Key{generateKey(t, AlgorithmRS256)}, generateKey(t, AlgorithmEdDSA), now, time.Hour, 24*time.Hour)
+
+	data, err := MarshalKeySetJSON(keys)
+	if err != nil {
+		t.Fatalf("MarshalKeySetJSON: %v", err)
+	}
+	parsed, err := ParseKeySetJSON(data)
+	if err != nil {
+		t.Fatalf("ParseKeySetJSON: %v", err)
+	}
+
+	if len(parsed) != len(keys) {
+		t.Fatalf("ParseKeySetJSON() = %d keys, want %d", len(parsed), len(keys))
+	}
+	for i, key := range parsed {
+		want := keys[i]
+		if key.ID != want.ID || key.Algorithm != want.Algorithm || !key.CanSign() ||
+			!key.NotBefore.Equal(want.NotBefore) || !key.NotAfter.Equal(want.NotAfter) {
+			t.Fatalf("key %d = %s %s %v-%v, want %s %s %v-%v", i,
+				key.ID, key.Algorithm, key.NotBefore, key.NotAfter, want.ID, want.Algorithm, want.NotBefore, want.NotAfter)
+		}
+	}
+
+	// The validity window is published with the public key
+	jwk, err := keys[1].JWK()
+	if err != nil {
+		t.Fatalf("JWK: %v", err)
+	}
+	fromJWK, err := jwk.Key()
+	if err != nil {
+		t.Fatalf("Key: %v", err)
+	}
+	if !fromJWK.NotBefore.Equal(keys[1].NotBefore) || !fromJWK.NotAfter.IsZero() {
+		t.Fatalf("JWK round trip window = %v-%v, want from %v", fromJWK.NotBefore, fromJWK.NotAfter, keys[1].NotBefore)
+	}
+
+	public, err := NewVerificationKey("", keys[0].Public)
+	if err != nil {
+		t.Fatalf("NewVerificationKey: %v", err)
+	}
+	if _, err := MarshalKeySetJSON([]*Key{public}); err == nil {
+		t.Fatal("MarshalKeySetJSON() accepted a key without its private half")
+	}
+}
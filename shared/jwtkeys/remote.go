@@ -0,0 +1,113 @@
+package jwtkeys
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RemoteKeySet verifies tokens against keys fetched from a JWKS URL. Keys are
+// cached for refreshInterval; an unknown kid triggers an early refresh, at most
+// once per minRefreshInterval, so newly published keys are picked up quickly
+// without letting bogus kids hammer the endpoint.
+type RemoteKeySet struct {
+	url                string
+	client             *http.Client
+	refreshInterval    time.Duration
+	minRefreshInterval time.Duration
+	now                func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]*Key
+	fetchedAt time.Time
+}
+
+// NewRemoteKeySet creates a verifier for the JWKS document at url
+func NewRemoteKeySet(url string, client *http.Client) *RemoteKeySet {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &RemoteKeySet{
+		url:                url,
+		client:             client,
+		refreshInterval:    time.Hour,
+		minRefreshInterval: time.Minute,
+		now:                time.Now,
+		keys:               make(map[string]*Key),
+	}
+}
+
+// Keyfunc implements Verifier
+func (r *RemoteKeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, err := r.key(context.Background(), kid)
+	if err != nil {
+		return nil, err
+	}
+	return verificationKey(key, token, r.now())
+}
+
+func (r *RemoteKeySet) key(ctx context.Context, kid string) (*Key, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	key, ok := r.keys[kid]
+	stale := now.Sub(r.fetchedAt) > r.refreshInterval
+
+	if (!ok || stale) && now.Sub(r.fetchedAt) > r.minRefreshInterval {
+		if err := r.refresh(ctx); err != nil {
+			// Keep verifying with cached keys if the endpoint is down
+			if ok {
+				return key, nil
+			}
+			return nil, err
+		}
+		r.fetchedAt = now
+		key, ok = r.keys[kid]
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+	return key, nil
+}
+
+func (r *RemoteKeySet) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build JWKS request: %w", err)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read JWKS: %w", err)
+	}
+
+	keys, err := ParseJWKS(body)
+	if err != nil {
+		return err
+	}
+
+	r.keys = make(map[string]*Key, len(keys))
+	for _, key := range keys {
+		r.keys[key.ID] = key
+	}
+	return nil
+}
@@ -195,6 +195,13 @@ func LogEventBridge(ctx context.Context, eventType string, source string, err er
 	}
 }
 
+// LogSecurityEvent logs events that may indicate an attack, such as a replayed
+// credential, at warn level so they can be alerted on
+func LogSecurityEvent(ctx context.Context, event string, fields ...zap.Field) {
+	allFields := append(fields, zap.String("security_event", event))
+	WithContext(ctx).Warn("Security event", allFields...)
+}
+
 // Sync flushes any buffered log entries
 func Sync() {
 	if globalLogger != nil {
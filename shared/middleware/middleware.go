@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -12,11 +13,82 @@ import (
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"github.com/multitask-platform/backend/shared/config"
+	"github.com/multitask-platform/backend/shared/jwtkeys"
 	"github.com/multitask-platform/backend/shared/logger"
+	"github.com/multitask-platform/backend/shared/revocation"
 )
 
-// AuthMiddleware handles JWT authentication
-func AuthMiddleware(next func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+// Authenticator verifies bearer tokens using only public keys, so services
+// never hold the key tokens are signed with
+type Authenticator struct {
+	verifier    jwtkeys.Verifier
+	revocations *revocation.Checker
+	failOpen    bool
+	accepts     TokenKind
+	now         func() time.Time
+}
+
+// TokenKind is a set of the kinds of access token a route accepts
+type TokenKind uint8
+
+const (
+	// FirstPartyTokens come from users signing in to the platform's own apps
+	FirstPartyTokens TokenKind = 1 << iota
+	// DelegatedTokens are issued to an OAuth2 client acting for a user
+	DelegatedTokens
+	// ClientTokens are issued to an OAuth2 client acting for itself, with
+	// the client credentials grant. They have no user.
+	ClientTokens
+)
+
+// AuthenticatorOption configures an Authenticator
+type AuthenticatorOption func(*Authenticator)
+
+// WithRevocationChecker rejects tokens whose session, jti or user has been
+// revoked since they were issued. If the check itself fails the request is
+// rejected with 503, since a revoked token can't be told apart from a good one.
+func WithRevocationChecker(checker *revocation.Checker) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.revocations = checker
+	}
+}
+
+// WithRevocationFailOpen lets requests through when the revocation check
+// fails. Revoked sessions and disabled users then keep access for as long as
+// the denylist is unreachable, so only use it where availability matters more.
+func WithRevocationFailOpen() AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.failOpen = true
+	}
+}
+
+// NewAuthenticator creates an Authenticator that resolves keys through
+// verifier. It only accepts first-party tokens; see Accepting.
+func NewAuthenticator(verifier jwtkeys.Verifier, opts ...AuthenticatorOption) *Authenticator {
+	a := &Authenticator{
+		verifier: verifier,
+		accepts:  FirstPartyTokens,
+		now:      time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+// Accepting returns a copy of the Authenticator that lets the given kinds of
+// token through, for routes meant to be called by OAuth2 clients
+func (a *Authenticator) Accepting(kinds TokenKind) *Authenticator {
+	accepting := *a
+	accepting.accepts = kinds
+	return &accepting
+}
+
+// Middleware rejects requests without a valid access token of a kind the
+// Authenticator accepts and adds the token's claims to the context
+func (a *Authenticator) Middleware(next func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 		// Extract token from Authorization header
 		authHeader := request.Headers["Authorization"]
@@ -48,15 +120,11 @@ func AuthMiddleware(next func(ctx context.Context, request events.APIGatewayProx
 
 		tokenString := tokenParts[1]
 
-		// Parse and validate JWT token
-		cfg := config.Get()
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Validate signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(cfg.JWTSecret), nil
-		})
+		// Parse and validate JWT token against the public key named by its kid
+		token, err := jwt.Parse(tokenString, a.verifier.Keyfunc,
+			jwt.WithValidMethods(jwtkeys.ValidMethods),
+			jwt.WithTimeFunc(a.now),
+		)
 
 		if err != nil || !token.Valid {
 			logger.WarnCtx(ctx, "Invalid JWT token", 
@@ -85,11 +153,28 @@ func AuthMiddleware(next func(ctx context.Context, request events.APIGatewayProx
 		}
 
 		// Extract user information
+		tokenType, _ := claims["type"].(string)
 		userID, _ := claims["sub"].(string)
 		email, _ := claims["email"].(string)
 		roles, _ := claims["roles"].([]interface{})
+		sessionID, _ := claims["session_id"].(string)
+		clientID, _ := claims["client_id"].(string)
+		scope, _ := claims["scope"].(string)
+
+		// Refresh and anonymous tokens share the signing key but must not
+		// authenticate API calls
+		kind, ok := tokenKind(tokenType, clientID)
+		if !ok {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusUnauthorized,
+				Headers: map[string]string{
+					"Content-Type": "application/json",
+				},
+				Body: `{"error":"invalid token type"}`,
+			}, nil
+		}
 
-		if userID == "" {
+		if userID == "" && kind != ClientTokens {
 			return events.APIGatewayProxyResponse{
 				StatusCode: http.StatusUnauthorized,
 				Headers: map[string]string{
@@ -99,20 +184,58 @@ func AuthMiddleware(next func(ctx context.Context, request events.APIGatewayProx
 			}, nil
 		}
 
+		if a.accepts&kind == 0 {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusForbidden,
+				Headers: map[string]string{
+					"Content-Type": "application/json",
+				},
+				Body: `{"error":"token not accepted for this endpoint"}`,
+			}, nil
+		}
+
+		revoked, err := a.isRevoked(ctx, claims)
+		if err != nil {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusServiceUnavailable,
+				Headers: map[string]string{
+					"Content-Type": "application/json",
+				},
+				Body: `{"error":"token revocation check unavailable"}`,
+			}, nil
+		}
+		if revoked {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusUnauthorized,
+				Headers: map[string]string{
+					"Content-Type": "application/json",
+				},
+				Body: `{"error":"token revoked"}`,
+			}, nil
+		}
+
 		// Add user context
-		ctx = logger.WithUserID(ctx, userID)
+		if userID != "" {
+			ctx = logger.WithUserID(ctx, userID)
+		}
 		ctx = WithUserClaims(ctx, &UserClaims{
-			UserID: userID,
-			Email:  email,
-			Roles:  convertRoles(roles),
+			UserID:    userID,
+			Email:     email,
+			Roles:     convertRoles(roles),
+			SessionID: sessionID,
+			ClientID:  clientID,
+			Scopes:    strings.Fields(scope),
 		})
 
 		return next(ctx, request)
 	}
 }
 
-// OptionalAuthMiddleware allows both authenticated and anonymous requests
-func OptionalAuthMiddleware(next func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+// OptionalMiddleware authenticates requests that carry an Authorization
+// header and lets anonymous requests through
+func (a *Authenticator) OptionalMiddleware(next func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	authed := a.Middleware(next)
+
 	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 		authHeader := request.Headers["Authorization"]
 		if authHeader == "" {
@@ -121,7 +244,7 @@ func OptionalAuthMiddleware(next func(ctx context.Context, request events.APIGat
 
 		if authHeader != "" {
 			// Try to authenticate
-			return AuthMiddleware(next)(ctx, request)
+			return authed(ctx, request)
 		}
 
 		// Continue without authentication
@@ -129,6 +252,144 @@ func OptionalAuthMiddleware(next func(ctx context.Context, request events.APIGat
 	}
 }
 
+// isRevoked checks the token against the revocation denylist, if configured.
+// It only returns an error when the check failed and fail-open isn't set.
+func (a *Authenticator) isRevoked(ctx context.Context, claims jwt.MapClaims) (bool, error) {
+	if a.revocations == nil {
+		return false, nil
+	}
+
+	token := revocation.Token{}
+	token.UserID, _ = claims["sub"].(string)
+	token.SessionID, _ = claims["session_id"].(string)
+	token.TokenID, _ = claims["jti"].(string)
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		token.IssuedAt = iat.Time
+	}
+
+	revoked, err := a.revocations.IsRevoked(ctx, token)
+	if err != nil {
+		if a.failOpen {
+			logger.WarnCtx(ctx, "Token revocation check failed, allowing request", zap.Error(err))
+			return false, nil
+		}
+		logger.ErrorCtx(ctx, "Token revocation check failed, rejecting request", zap.Error(err))
+		return false, err
+	}
+	if revoked {
+		logger.WarnCtx(ctx, "Revoked token presented",
+			zap.String("user_id", token.UserID),
+			zap.String("session_id", token.SessionID),
+		)
+	}
+	return revoked, nil
+}
+
+// The "type" claims auth-svc puts on access tokens issued for a user and on
+// ones a client gets for itself
+const (
+	accessTokenType       = "access"
+	clientAccessTokenType = "client_access"
+)
+
+// tokenKind tells what kind of access token the claims belong to. ok is
+// false for tokens that aren't access tokens at all.
+func tokenKind(tokenType, clientID string) (kind TokenKind, ok bool) {
+	switch {
+	case tokenType == accessTokenType && clientID == "":
+		return FirstPartyTokens, true
+	case tokenType == accessTokenType:
+		return DelegatedTokens, true
+	case tokenType == clientAccessTokenType && clientID != "":
+		return ClientTokens, true
+	default:
+		return 0, false
+	}
+}
+
+var (
+	defaultAuthenticator     *Authenticator
+	defaultAuthenticatorErr  error
+	defaultAuthenticatorOnce sync.Once
+)
+
+// getDefaultAuthenticator builds an Authenticator from JWT_JWKS_URL,
+// JWT_PUBLIC_KEYS or JWT_PRIVATE_KEY (see jwtkeys.LoadVerifier), checking
+// revocations when DYNAMODB_TABLE_AUTH_REVOCATIONS is set
+func getDefaultAuthenticator() (*Authenticator, error) {
+	defaultAuthenticatorOnce.Do(func() {
+		cfg := config.Get()
+
+		verifier, err := jwtkeys.LoadVerifier(cfg)
+		if err != nil {
+			defaultAuthenticatorErr = err
+			return
+		}
+
+		var opts []AuthenticatorOption
+		checker, err := revocation.LoadChecker(context.Background(), cfg)
+		if err != nil {
+			defaultAuthenticatorErr = err
+			return
+		}
+		if checker != nil {
+			opts = append(opts, WithRevocationChecker(checker))
+		}
+		if cfg.Revocation.FailOpen {
+			opts = append(opts, WithRevocationFailOpen())
+		}
+
+		defaultAuthenticator = NewAuthenticator(verifier, opts...)
+	})
+	return defaultAuthenticator, defaultAuthenticatorErr
+}
+
+// AuthMiddleware handles JWT authentication with the configured verification
+// keys, accepting first-party tokens only. Requests fail with 500 if no keys
+// are configured.
+func AuthMiddleware(next func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	authenticator, err := getDefaultAuthenticator()
+	if err != nil {
+		return authUnavailable(err)
+	}
+	return authenticator.Middleware(next)
+}
+
+// AuthMiddlewareAccepting is AuthMiddleware for routes that also take tokens
+// issued to OAuth2 clients, e.g.
+// AuthMiddlewareAccepting(FirstPartyTokens|DelegatedTokens)
+func AuthMiddlewareAccepting(kinds TokenKind) func(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return func(next func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		authenticator, err := getDefaultAuthenticator()
+		if err != nil {
+			return authUnavailable(err)
+		}
+		return authenticator.Accepting(kinds).Middleware(next)
+	}
+}
+
+// OptionalAuthMiddleware allows both authenticated and anonymous requests
+func OptionalAuthMiddleware(next func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	authenticator, err := getDefaultAuthenticator()
+	if err != nil {
+		return authUnavailable(err)
+	}
+	return authenticator.OptionalMiddleware(next)
+}
+
+func authUnavailable(err error) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		logger.ErrorCtx(ctx, "JWT verification keys not configured", zap.Error(err))
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Headers: map[string]string{
+				"Content-Type": "application/json",
+			},
+			Body: `{"error":"authentication unavailable"}`,
+		}, nil
+	}
+}
+
 // CORSMiddleware adds CORS headers
 func CORSMiddleware(next func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
@@ -211,15 +472,6 @@ func RequestLoggingMiddleware(next func(ctx context.Context, request events.APIG
 	}
 }
 
-// RateLimitMiddleware implements rate limiting
-func RateLimitMiddleware(next func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-		// TODO: Implement rate limiting using Redis or DynamoDB
-		// For now, just pass through
-		return next(ctx, request)
-	}
-}
-
 // ValidationMiddleware validates request payload
 func ValidationMiddleware(validator func(request events.APIGatewayProxyRequest) error) func(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	return func(next func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
@@ -252,9 +504,12 @@ func Chain(middlewares ...func(func(ctx context.Context, request events.APIGatew
 
 // UserClaims represents JWT user claims
 type UserClaims struct {
-	UserID string   `json:"sub"`
-	Email  string   `json:"email"`
-	Roles  []string `json:"roles"`
+	UserID    string   `json:"sub"` // Empty for client tokens
+	Email     string   `json:"email"`
+	Roles     []string `json:"roles"`
+	SessionID string   `json:"session_id,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"` // Set on tokens issued to an OAuth2 client
+	Scopes    []string `json:"scope,omitempty"`
 }
 
 // HasRole checks if user has a specific role
@@ -267,6 +522,44 @@ func (u *UserClaims) HasRole(role string) bool {
 	return false
 }
 
+// HasScope checks if the token grants a scope. Tokens from the platform's own
+// logins aren't scoped and grant everything.
+func (u *UserClaims) HasScope(scope string) bool {
+	if u.ClientID == "" {
+		return true
+	}
+	for _, s := range u.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope rejects tokens that lack any of the scopes. It must run after
+// the auth middleware.
+func RequireScope(scopes ...string) func(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return func(next func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			claims := GetUserClaims(ctx)
+			for _, scope := range scopes {
+				if claims == nil || !claims.HasScope(scope) {
+					return events.APIGatewayProxyResponse{
+						StatusCode: http.StatusForbidden,
+						Headers: map[string]string{
+							"Content-Type":     "application/json",
+							"WWW-Authenticate": fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, strings.Join(scopes, " ")),
+						},
+						Body: `{"error":"insufficient scope"}`,
+					}, nil
+				}
+			}
+
+			return next(ctx, request)
+		}
+	}
+}
+
 // Context keys
 type contextKeyType string
 
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/shared/jwtkeys"
+	"github.com/multitask-platform/backend/shared/logger"
+	"github.com/multitask-platform/backend/shared/revocation"
+)
+
+func TestMain(m *testing.M) {
+	if err := logger.Initialize(zap.NewAtomicLevelAt(zap.FatalLevel), false); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestAuthenticatorTokenKinds(t *testing.T) {
+	key, err := jwtkeys.GenerateSigningKey(jwtkeys.AlgorithmES256)
+	if err != nil {
+		t.Fatalf("GenerateSigningKey: %v", err)
+	}
+	keys, err := jwtkeys.NewKeySet([]*jwtkeys.Key{key})
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+
+	sign := func(claims jwt.MapClaims) string {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		token, err := keys.Sign(claims)
+		if err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+		return token
+	}
+
+	firstParty := sign(jwt.MapClaims{"type": "access", "sub": "user-1"})
+	delegated := sign(jwt.MapClaims{"type": "access", "sub": "user-1", "client_id": "client-1"})
+	client := sign(jwt.MapClaims{"type": "client_access", "client_id": "client-1"})
+
+	authenticator := NewAuthenticator(keys)
+	userRoutes := authenticator.Accepting(FirstPartyTokens | DelegatedTokens)
+	clientRoutes := authenticator.Accepting(ClientTokens)
+
+	tests := []struct {
+		name          string
+		authenticator *Authenticator
+		token         string
+		wantStatus    int
+	}{
+		{name: "first-party token on the default", authenticator: authenticator, token: firstParty, wantStatus: http.StatusOK},
+		{name: "delegated token on the default", authenticator: authenticator, token: delegated, wantStatus: http.StatusForbidden},
+		{name: "client token on the default", authenticator: authenticator, token: client, wantStatus: http.StatusForbidden},
+		{name: "delegated token on user routes", authenticator: userRoutes, token: delegated, wantStatus: http.StatusOK},
+		{name: "client token on user routes", authenticator: userRoutes, token: client, wantStatus: http.StatusForbidden},
+		{name: "client token on client routes", authenticator: clientRoutes, token: client, wantStatus: http.StatusOK},
+		{name: "first-party token on client routes", authenticator: clientRoutes, token: firstParty, wantStatus: http.StatusForbidden},
+		{name: "refresh token", authenticator: userRoutes, token: sign(jwt.MapClaims{"type": "refresh", "sub": "user-1"}), wantStatus: http.StatusUnauthorized},
+		{name: "access token without subject", authenticator: userRoutes, token: sign(jwt.MapClaims{"type": "access"}), wantStatus: http.StatusUnauthorized},
+		{name: "client token without client", authenticator: clientRoutes, token: sign(jwt.MapClaims{"type": "client_access"}), wantStatus: http.StatusUnauthorized},
+		{name: "garbage", authenticator: authenticator, token: "not-a-token", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var claims *UserClaims
+			handler := tt.authenticator.Middleware(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				claims = GetUserClaims(ctx)
+				return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+			})
+
+			response, err := handler(context.Background(), events.APIGatewayProxyRequest{
+				Headers: map[string]string{"Authorization": "Bearer " + tt.token},
+			})
+			if err != nil {
+				t.Fatalf("handler: %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d (%s), want %d", response.StatusCode, response.Body, tt.wantStatus)
+			}
+			if response.StatusCode == http.StatusOK && claims == nil {
+				t.Fatal("no user claims in the context")
+			}
+		})
+	}
+}
+
+// failingStore is a revocation store that can't be reached
+type failingStore struct{}
+
+func (failingStore) Revoke(ctx context.Context, key string, revokedAt, expiresAt time.Time) error {
+	return errors.New("store unavailable")
+}
+
+func (failingStore) RevokedAt(ctx context.Context, key string, now time.Time) (time.Time, error) {
+	return time.Time{}, errors.New("store unavailable")
+}
+
+func TestAuthenticatorRevocation(t *testing.T) {
+	ctx := context.Background()
+	key, err := jwtkeys.GenerateSigningKey(jwtkeys.AlgorithmEdDSA)
+	if err != nil {
+		t.Fatalf("GenerateSigningKey: %v", err)
+	}
+	keys, err := jwtkeys.NewKeySet([]*jwtkeys.Key{key})
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+
+	now := time.Now()
+	sign := func(sessionID string, issuedAt time.Time) string {
+		token, err := keys.Sign(jwt.MapClaims{
+			"type":       "access",
+			"sub":        "user-1",
+			"session_id": sessionID,
+			"iat":        issuedAt.Unix(),
+			"exp":        issuedAt.Add(time.Hour).Unix(),
+		})
+		if err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+		return token
+	}
+
+	checker := revocation.NewChecker(revocation.NewMemoryStore(), time.Minute)
+	if err := checker.Revoke(ctx, revocation.SessionKey("revoked"), time.Hour); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	unreachable := revocation.NewChecker(failingStore{}, time.Minute)
+
+	tests := []struct {
+		name          string
+		authenticator *Authenticator
+		token         string
+		wantStatus    int
+	}{
+		{name: "no checker", authenticator: NewAuthenticator(keys), token: sign("revoked", now.Add(-time.Minute)), wantStatus: http.StatusOK},
+		{name: "live session", authenticator: NewAuthenticator(keys, WithRevocationChecker(checker)), token: sign("live", now.Add(-time.Minute)), wantStatus: http.StatusOK},
+		{name: "revoked session", authenticator: NewAuthenticator(keys, WithRevocationChecker(checker)), token: sign("revoked", now.Add(-time.Minute)), wantStatus: http.StatusUnauthorized},
+		{name: "issued after the revocation", authenticator: NewAuthenticator(keys, WithRevocationChecker(checker)), token: sign("revoked", now.Add(time.Minute)), wantStatus: http.StatusOK},
+		{name: "store error fails closed", authenticator: NewAuthenticator(keys, WithRevocationChecker(unreachable)), token: sign("live", now.Add(-time.Minute)), wantStatus: http.StatusServiceUnavailable},
+		{name: "store error with fail-open", authenticator: NewAuthenticator(keys, WithRevocationChecker(unreachable), WithRevocationFailOpen()), token: sign("live", now.Add(-time.Minute)), wantStatus: http.StatusOK},
+		{name: "fail-open still rejects revoked sessions", authenticator: NewAuthenticator(keys, WithRevocationChecker(checker), WithRevocationFailOpen()), token: sign("revoked", now.Add(-time.Minute)), wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			handler := tt.authenticator.Middleware(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				called = true
+				return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+			})
+
+			response, err := handler(ctx, events.APIGatewayProxyRequest{
+				Headers: map[string]string{"Authorization": "Bearer " + tt.token},
+			})
+			if err != nil {
+				t.Fatalf("handler: %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d (%s), want %d", response.StatusCode, response.Body, tt.wantStatus)
+			}
+			if called != (tt.wantStatus == http.StatusOK) {
+				t.Fatalf("next handler called = %v with status %d", called, response.StatusCode)
+			}
+		})
+	}
+}
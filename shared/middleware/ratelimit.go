@@ -0,0 +1,171 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/shared/config"
+	"github.com/multitask-platform/backend/shared/logger"
+	"github.com/multitask-platform/backend/shared/ratelimit"
+	"github.com/multitask-platform/backend/shared/router"
+)
+
+// RateLimitKeyFunc derives the bucket key for a request
+type RateLimitKeyFunc func(ctx context.Context, request events.APIGatewayProxyRequest) string
+
+// KeyBySourceIP buckets requests by client IP
+func KeyBySourceIP(ctx context.Context, request events.APIGatewayProxyRequest) string {
+	return "ip:" + request.RequestContext.Identity.SourceIP
+}
+
+// KeyByUserID buckets authenticated requests by user ID and falls back to
+// the client IP when no user claims are in the context
+func KeyByUserID(ctx context.Context, request events.APIGatewayProxyRequest) string {
+	if claims := GetUserClaims(ctx); claims != nil && claims.UserID != "" {
+		return "user:" + claims.UserID
+	}
+	return KeyBySourceIP(ctx, request)
+}
+
+// KeyByRoute shares one bucket between all callers of a method and route.
+// Requests that matched no route share a single bucket.
+func KeyByRoute(ctx context.Context, request events.APIGatewayProxyRequest) string {
+	return "route:" + request.HTTPMethod + " " + router.Pattern(ctx)
+}
+
+// RateLimiter enforces token-bucket limits on requests
+type RateLimiter struct {
+	store       ratelimit.Store
+	limit       ratelimit.Limit
+	routeLimits map[string]ratelimit.Limit
+	keyFunc     RateLimitKeyFunc
+	now         func() time.Time
+}
+
+// RateLimiterOption configures a RateLimiter
+type RateLimiterOption func(*RateLimiter)
+
+// WithRouteLimit applies a separate, usually stricter, limit to requests the
+// router resolves to pattern (e.g. "/v1/auth/login" or
+// "/v1/auth/oauth/{provider}/callback"). Whatever spelling of the path a
+// client sends, the limit applies as long as the route handles it.
+func WithRouteLimit(pattern string, limit ratelimit.Limit) RateLimiterOption {
+	return func(l *RateLimiter) {
+		l.routeLimits[router.CleanPath(pattern)] = limit
+	}
+}
+
+// WithRateLimitKey overrides how requests are bucketed (defaults to KeyBySourceIP)
+func WithRateLimitKey(keyFunc RateLimitKeyFunc) RateLimiterOption {
+	return func(l *RateLimiter) {
+		l.keyFunc = keyFunc
+	}
+}
+
+// WithRateLimitClock overrides the time source (defaults to time.Now)
+func WithRateLimitClock(now func() time.Time) RateLimiterOption {
+	return func(l *RateLimiter) {
+		l.now = now
+	}
+}
+
+// NewRateLimiter creates a rate limiter applying limit to every request
+func NewRateLimiter(store ratelimit.Store, limit ratelimit.Limit, opts ...RateLimiterOption) *RateLimiter {
+	l := &RateLimiter{
+		store:       store,
+		limit:       limit,
+		routeLimits: make(map[string]ratelimit.Limit),
+		keyFunc:     KeyBySourceIP,
+		now:         time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// Middleware returns the rate limiting middleware. Allowed responses carry
+// X-RateLimit-Limit and X-RateLimit-Remaining; rejected requests get 429 with
+// Retry-After. If the store fails the request is let through. Route limits
+// and KeyByRoute need the matched route, so install it with Router.Use.
+func (l *RateLimiter) Middleware(next func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		if request.HTTPMethod == http.MethodOptions {
+			return next(ctx, request)
+		}
+
+		limit := l.limit
+		key := l.keyFunc(ctx, request)
+		pattern := router.Pattern(ctx)
+		if routeLimit, ok := l.routeLimits[pattern]; ok {
+			limit = routeLimit
+			key += "|" + pattern
+		}
+
+		result, err := l.store.Take(ctx, key, limit, l.now())
+		if err != nil {
+			logger.WarnCtx(ctx, "Rate limit check failed, allowing request", zap.Error(err))
+			return next(ctx, request)
+		}
+
+		if !result.Allowed {
+			retryAfter := int(result.RetryAfter / time.Second)
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+
+			logger.WarnCtx(ctx, "Rate limit exceeded",
+				zap.String("key", key),
+				zap.String("path", request.Path),
+			)
+
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusTooManyRequests,
+				Headers: map[string]string{
+					"Content-Type":          "application/json",
+					"X-RateLimit-Limit":     strconv.Itoa(result.Limit),
+					"X-RateLimit-Remaining": "0",
+					"Retry-After":           strconv.Itoa(retryAfter),
+				},
+				Body: `{"error":"rate limit exceeded"}`,
+			}, nil
+		}
+
+		response, err := next(ctx, request)
+		if response.Headers == nil {
+			response.Headers = make(map[string]string)
+		}
+		response.Headers["X-RateLimit-Limit"] = strconv.Itoa(result.Limit)
+		response.Headers["X-RateLimit-Remaining"] = strconv.Itoa(result.Remaining)
+
+		return response, err
+	}
+}
+
+var (
+	defaultRateLimiter     *RateLimiter
+	defaultRateLimiterOnce sync.Once
+)
+
+// RateLimitMiddleware implements per-IP rate limiting with the configured
+// RequestsPerMinute and BurstSize, using an in-memory store. Services that need
+// shared state or per-route limits should build their own RateLimiter.
+func RateLimitMiddleware(next func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	defaultRateLimiterOnce.Do(func() {
+		cfg := config.Get()
+		defaultRateLimiter = NewRateLimiter(ratelimit.NewMemoryStore(), ratelimit.Limit{
+			RequestsPerMinute: cfg.RateLimit.RequestsPerMinute,
+			Burst:             cfg.RateLimit.BurstSize,
+		})
+	})
+
+	return defaultRateLimiter.Middleware(next)
+}
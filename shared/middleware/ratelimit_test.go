@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/multitask-platform/backend/shared/ratelimit"
+	"github.com/multitask-platform/backend/shared/router"
+)
+
+func TestRateLimiterRouteLimits(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(ratelimit.NewMemoryStore(),
+		ratelimit.Limit{RequestsPerMinute: 60, Burst: 100},
+		WithRouteLimit("/v1/auth/login", ratelimit.Limit{RequestsPerMinute: 1, Burst: 2}),
+		WithRouteLimit("/v1/auth/oauth/{provider}/callback", ratelimit.Limit{RequestsPerMinute: 1, Burst: 1}),
+		WithRateLimitClock(func() time.Time { return now }),
+	)
+
+	ok := func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+	}
+	r := router.New("/v1/auth")
+	r.Use(limiter.Middleware)
+	r.POST("/login", ok)
+	r.POST("/register", ok)
+	r.POST("/oauth/{provider}/callback", ok)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{name: "login", path: "/v1/auth/login", wantStatus: http.StatusOK},
+		{name: "login with a trailing slash", path: "/v1/auth/login/", wantStatus: http.StatusOK},
+		{name: "login with empty segments", path: "/v1/auth//login", wantStatus: http.StatusTooManyRequests},
+		{name: "login outside the base path", path: "/login", wantStatus: http.StatusNotFound},
+		{name: "login glued to the base path", path: "/v1/authlogin", wantStatus: http.StatusNotFound},
+		{name: "other route keeps its own bucket", path: "/v1/auth/register", wantStatus: http.StatusOK},
+		{name: "first callback", path: "/v1/auth/oauth/google/callback", wantStatus: http.StatusOK},
+		{name: "callback for another provider", path: "/v1/auth/oauth/github/callback", wantStatus: http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Path: tt.path}
+			request.RequestContext.Identity.SourceIP = "192.0.2.1"
+
+			response, err := r.ServeProxy(context.Background(), request)
+			if err != nil {
+				t.Fatalf("ServeProxy: %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d (%s), want %d", response.StatusCode, response.Body, tt.wantStatus)
+			}
+		})
+	}
+
+	// The login bucket is per client
+	request := events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Path: "/v1/auth/login"}
+	request.RequestContext.Identity.SourceIP = "192.0.2.2"
+	response, err := r.ServeProxy(context.Background(), request)
+	if err != nil {
+		t.Fatalf("ServeProxy: %v", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("status for another client = %d, want %d", response.StatusCode, http.StatusOK)
+	}
+}
+
+func TestRateLimiterHeaders(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(ratelimit.NewMemoryStore(),
+		ratelimit.Limit{RequestsPerMinute: 30, Burst: 1},
+		WithRateLimitKey(KeyByRoute),
+		WithRateLimitClock(func() time.Time { return now }),
+	)
+	handler := limiter.Middleware(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+	})
+	request := events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Path: "/v1/auth/health"}
+
+	tests := []struct {
+		wantStatus  int
+		wantHeaders map[string]string
+	}{
+		{wantStatus: http.StatusOK, wantHeaders: map[string]string{"X-RateLimit-Limit": "1", "X-RateLimit-Remaining": "0"}},
+		{wantStatus: http.StatusTooManyRequests, wantHeaders: map[string]string{"X-RateLimit-Remaining": "0", "Retry-After": "2"}},
+	}
+
+	for i, tt := range tests {
+		response, err := handler(context.Background(), request)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		if response.StatusCode != tt.wantStatus {
+			t.Fatalf("request %d: status = %d, want %d", i, response.StatusCode, tt.wantStatus)
+		}
+		for header, want := range tt.wantHeaders {
+			if got := response.Headers[header]; got != want {
+				t.Errorf("request %d: %s = %q, want %q", i, header, got, want)
+			}
+		}
+	}
+
+	// Preflight requests are never limited
+	request.HTTPMethod = http.MethodOptions
+	if response, _ := handler(context.Background(), request); response.StatusCode != http.StatusOK {
+		t.Fatalf("OPTIONS status = %d, want %d", response.StatusCode, http.StatusOK)
+	}
+}
@@ -0,0 +1,284 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+// Permissions are named "resource:action" (e.g. "users:read"). A granted
+// "resource:*" covers every action on the resource and "*" covers everything.
+
+// Role lists what a role may do. A role also has every permission of the
+// roles it inherits, and passes any RequireRole check they would pass.
+type Role struct {
+	Inherits    []string
+	Permissions []string
+}
+
+// DefaultRoles is the platform's role hierarchy: admin ⊇ moderator ⊇ user.
+// The names match the roles auth-svc puts in access tokens. The package-level
+// RequireRole and RequirePermission read it once at startup; services with
+// their own roles build a Policy with NewPolicy.
+var DefaultRoles = map[string]Role{
+	"user": {
+		Permissions: []string{
+			"profile:read", "profile:write",
+			"chat:read", "chat:write",
+		},
+	},
+	"moderator": {
+		Inherits:    []string{"user"},
+		Permissions: []string{"chat:moderate"},
+	},
+	"admin": {
+		Inherits:    []string{"moderator"},
+		Permissions: []string{"*"},
+	},
+}
+
+// Policy answers role and permission checks against a role map with
+// inheritance already resolved
+type Policy struct {
+	includes    map[string]map[string]bool // role -> itself and every role it inherits
+	permissions map[string][]string        // role -> its own and inherited permissions
+	onDenied    DenialHook
+}
+
+// Denial describes a request RequireRole or RequirePermission turned away.
+// The caller fields are empty when the request carried no user claims.
+type Denial struct {
+	Method              string
+	Path                string
+	SourceIP            string
+	UserID              string
+	SessionID           string
+	ClientID            string
+	Roles               []string
+	RequiredRoles       []string
+	RequiredPermissions []string
+}
+
+// DenialHook is told about every request a Policy denies, e.g. to add it to
+// an audit log. It runs before the 403 is sent.
+type DenialHook func(ctx context.Context, denial *Denial)
+
+// NewPolicy resolves a role map. Inheriting an undefined role or inheriting
+// in a cycle is an error.
+func NewPolicy(roles map[string]Role) (*Policy, error) {
+	p := &Policy{
+		includes:    make(map[string]map[string]bool, len(roles)),
+		permissions: make(map[string][]string, len(roles)),
+	}
+
+	for name := range roles {
+		included := make(map[string]bool)
+		if err := resolveRole(roles, name, included, nil); err != nil {
+			return nil, err
+		}
+
+		var permissions []string
+		for role := range included {
+			permissions = append(permissions, roles[role].Permissions...)
+		}
+
+		p.includes[name] = included
+		p.permissions[name] = permissions
+	}
+
+	return p, nil
+}
+
+// WithDenialHook returns a copy of the Policy that calls hook for every
+// request it denies, on top of logging a security event
+func (p *Policy) WithDenialHook(hook DenialHook) *Policy {
+	hooked := *p
+	hooked.onDenied = hook
+	return &hooked
+}
+
+// resolveRole adds name and everything it inherits to included. path holds
+// the roles being resolved above name, to catch cycles.
+func resolveRole(roles map[string]Role, name string, included map[string]bool, path []string) error {
+	for _, role := range path {
+		if role == name {
+			return fmt.Errorf("role %q inherits itself", name)
+		}
+	}
+
+	role, ok := roles[name]
+	if !ok {
+		return fmt.Errorf("role %q inherits undefined role %q", path[len(path)-1], name)
+	}
+
+	included[name] = true
+	for _, parent := range role.Inherits {
+		if err := resolveRole(roles, parent, included, append(path, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HasRole checks if any of the roles is role or inherits it
+func (p *Policy) HasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if p.includes[r][role] {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission checks if any of the roles grants permission
+func (p *Policy) HasPermission(roles []string, permission string) bool {
+	for _, r := range roles {
+		for _, granted := range p.permissions[r] {
+			if permissionCovers(granted, permission) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// permissionCovers reports whether a granted permission, possibly a
+// wildcard, covers the required one
+func permissionCovers(granted, required string) bool {
+	if granted == "*" || granted == required {
+		return true
+	}
+
+	resource, action, ok := strings.Cut(granted, ":")
+	return ok && action == "*" && strings.HasPrefix(required, resource+":")
+}
+
+// RequireRole rejects users that have none of the roles, directly or through
+// inheritance. It must run after the auth middleware.
+func (p *Policy) RequireRole(roles ...string) func(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return func(next func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			claims := GetUserClaims(ctx)
+			if claims != nil {
+				for _, role := range roles {
+					if p.HasRole(claims.Roles, role) {
+						return next(ctx, request)
+					}
+				}
+			}
+
+			return p.accessDenied(ctx, request, claims, &accessDeniedError{
+				Error:         "insufficient role",
+				Code:          errCodeInsufficientPermissions,
+				RequiredRoles: roles,
+			}), nil
+		}
+	}
+}
+
+// RequirePermission rejects users whose roles don't grant every one of the
+// permissions. It must run after the auth middleware.
+func (p *Policy) RequirePermission(permissions ...string) func(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return func(next func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			claims := GetUserClaims(ctx)
+			for _, permission := range permissions {
+				if claims == nil || !p.HasPermission(claims.Roles, permission) {
+					return p.accessDenied(ctx, request, claims, &accessDeniedError{
+						Error:               "insufficient permissions",
+						Code:                errCodeInsufficientPermissions,
+						RequiredPermissions: permissions,
+					}), nil
+				}
+			}
+
+			return next(ctx, request)
+		}
+	}
+}
+
+// errCodeInsufficientPermissions is the platform error code for requests
+// the caller isn't allowed to make
+const errCodeInsufficientPermissions = "RES004"
+
+// accessDeniedError is the body of a 403 from RequireRole or RequirePermission
+type accessDeniedError struct {
+	Error               string   `json:"error"`
+	Code                string   `json:"code"`
+	RequiredRoles       []string `json:"required_roles,omitempty"`
+	RequiredPermissions []string `json:"required_permissions,omitempty"`
+}
+
+// accessDenied records the denial as a security event, passes it to the
+// denial hook and builds the 403
+func (p *Policy) accessDenied(ctx context.Context, request events.APIGatewayProxyRequest, claims *UserClaims, denied *accessDeniedError) events.APIGatewayProxyResponse {
+	denial := &Denial{
+		Method:              request.HTTPMethod,
+		Path:                request.Path,
+		SourceIP:            request.RequestContext.Identity.SourceIP,
+		RequiredRoles:       denied.RequiredRoles,
+		RequiredPermissions: denied.RequiredPermissions,
+	}
+	if claims != nil {
+		denial.UserID = claims.UserID
+		denial.SessionID = claims.SessionID
+		denial.ClientID = claims.ClientID
+		denial.Roles = claims.Roles
+	}
+
+	logger.LogSecurityEvent(ctx, "authorization_denied",
+		zap.String("method", denial.Method),
+		zap.String("path", denial.Path),
+		zap.String("source_ip", denial.SourceIP),
+		zap.Strings("required_roles", denial.RequiredRoles),
+		zap.Strings("required_permissions", denial.RequiredPermissions),
+		zap.String("user_id", denial.UserID),
+		zap.Strings("roles", denial.Roles),
+		zap.String("client_id", denial.ClientID),
+	)
+	if p.onDenied != nil {
+		p.onDenied(ctx, denial)
+	}
+
+	body, _ := json.Marshal(denied)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusForbidden,
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+		Body: string(body),
+	}
+}
+
+var defaultPolicy = mustNewPolicy(DefaultRoles)
+
+func mustNewPolicy(roles map[string]Role) *Policy {
+	p, err := NewPolicy(roles)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// DefaultPolicy returns the policy built from DefaultRoles
+func DefaultPolicy() *Policy {
+	return defaultPolicy
+}
+
+// RequireRole rejects users that have none of the roles under DefaultRoles
+func RequireRole(roles ...string) func(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return defaultPolicy.RequireRole(roles...)
+}
+
+// RequirePermission rejects users whose roles don't grant every one of the
+// permissions under DefaultRoles
+func RequirePermission(permissions ...string) func(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return defaultPolicy.RequirePermission(permissions...)
+}
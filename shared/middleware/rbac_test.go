@@ -0,0 +1,232 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestNewPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		roles   map[string]Role
+		wantErr string // substring of the error, empty for a valid role map
+	}{
+		{
+			name: "diamond",
+			roles: map[string]Role{
+				"base":  {},
+				"left":  {Inherits: []string{"base"}},
+				"right": {Inherits: []string{"base"}},
+				"top":   {Inherits: []string{"left", "right"}},
+			},
+		},
+		{name: "inherits itself", roles: map[string]Role{"a": {Inherits: []string{"a"}}}, wantErr: `role "a" inherits itself`},
+		{
+			name: "cycle",
+			roles: map[string]Role{
+				"a": {Inherits: []string{"b"}},
+				"b": {Inherits: []string{"c"}},
+				"c": {Inherits: []string{"a"}},
+			},
+			wantErr: "inherits itself",
+		},
+		{name: "undefined parent", roles: map[string]Role{"a": {Inherits: []string{"ghost"}}}, wantErr: `role "a" inherits undefined role "ghost"`},
+		{name: "default roles", roles: DefaultRoles},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPolicy(tt.roles)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("NewPolicy() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("NewPolicy() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPolicyHasRole(t *testing.T) {
+	p := DefaultPolicy()
+
+	tests := []struct {
+		roles []string
+		role  string
+		want  bool
+	}{
+		{roles: []string{"admin"}, role: "admin", want: true},
+		{roles: []string{"admin"}, role: "moderator", want: true},
+		{roles: []string{"admin"}, role: "user", want: true},
+		{roles: []string{"moderator"}, role: "user", want: true},
+		{roles: []string{"moderator"}, role: "admin", want: false},
+		{roles: []string{"user"}, role: "moderator", want: false},
+		{roles: []string{"user", "moderator"}, role: "moderator", want: true},
+		{roles: []string{"superuser"}, role: "user", want: false},
+		{roles: nil, role: "user", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := p.HasRole(tt.roles, tt.role); got != tt.want {
+			t.Errorf("HasRole(%v, %q) = %v, want %v", tt.roles, tt.role, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyHasPermission(t *testing.T) {
+	p, err := NewPolicy(map[string]Role{
+		"reader": {Permissions: []string{"posts:read"}},
+		"editor": {Inherits: []string{"reader"}, Permissions: []string{"posts:*", "chat:write"}},
+		"root":   {Permissions: []string{"*"}},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+
+	tests := []struct {
+		policy     *Policy
+		roles      []string
+		permission string
+		want       bool
+	}{
+		{policy: p, roles: []string{"reader"}, permission: "posts:read", want: true},
+		{policy: p, roles: []string{"reader"}, permission: "posts:write", want: false},
+		{policy: p, roles: []string{"editor"}, permission: "posts:read", want: true},
+		{policy: p, roles: []string{"editor"}, permission: "posts:delete", want: true},
+		{policy: p, roles: []string{"editor"}, permission: "postsx:read", want: false},
+		{policy: p, roles: []string{"editor"}, permission: "posts", want: false},
+		{policy: p, roles: []string{"editor"}, permission: "chat:read", want: false},
+		{policy: p, roles: []string{"reader", "editor"}, permission: "chat:write", want: true},
+		{policy: p, roles: []string{"root"}, permission: "anything:at-all", want: true},
+		{policy: p, roles: []string{"ghost"}, permission: "posts:read", want: false},
+
+		// The platform's roles: only admins may use the admin API
+		{policy: DefaultPolicy(), roles: []string{"user"}, permission: "chat:write", want: true},
+		{policy: DefaultPolicy(), roles: []string{"user"}, permission: "chat:moderate", want: false},
+		{policy: DefaultPolicy(), roles: []string{"moderator"}, permission: "chat:write", want: true},
+		{policy: DefaultPolicy(), roles: []string{"moderator"}, permission: "chat:moderate", want: true},
+		{policy: DefaultPolicy(), roles: []string{"moderator"}, permission: "users:read", want: false},
+		{policy: DefaultPolicy(), roles: []string{"admin"}, permission: "users:read", want: true},
+		{policy: DefaultPolicy(), roles: []string{"admin"}, permission: "users:delete", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.policy.HasPermission(tt.roles, tt.permission); got != tt.want {
+			t.Errorf("HasPermission(%v, %q) = %v, want %v", tt.roles, tt.permission, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyMiddleware(t *testing.T) {
+	var denials []*Denial
+	p := DefaultPolicy().WithDenialHook(func(ctx context.Context, denial *Denial) {
+		denials = append(denials, denial)
+	})
+
+	tests := []struct {
+		name       string
+		middleware func(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+		claims     *UserClaims
+		wantStatus int
+		wantBody   accessDeniedError
+	}{
+		{
+			name:       "role through inheritance",
+			middleware: p.RequireRole("moderator"),
+			claims:     &UserClaims{UserID: "user-1", Roles: []string{"admin"}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "any of the roles",
+			middleware: p.RequireRole("admin", "moderator"),
+			claims:     &UserClaims{UserID: "user-1", Roles: []string{"moderator"}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "missing role",
+			middleware: p.RequireRole("admin"),
+			claims:     &UserClaims{UserID: "user-1", SessionID: "session-1", Roles: []string{"moderator"}},
+			wantStatus: http.StatusForbidden,
+			wantBody:   accessDeniedError{Error: "insufficient role", Code: "RES004", RequiredRoles: []string{"admin"}},
+		},
+		{
+			name:       "every permission",
+			middleware: p.RequirePermission("chat:read", "chat:moderate"),
+			claims:     &UserClaims{UserID: "user-1", Roles: []string{"moderator"}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "one permission missing",
+			middleware: p.RequirePermission("chat:read", "users:read"),
+			claims:     &UserClaims{UserID: "user-1", Roles: []string{"moderator"}},
+			wantStatus: http.StatusForbidden,
+			wantBody:   accessDeniedError{Error: "insufficient permissions", Code: "RES004", RequiredPermissions: []string{"chat:read", "users:read"}},
+		},
+		{
+			name:       "no claims",
+			middleware: p.RequirePermission("chat:read"),
+			wantStatus: http.StatusForbidden,
+			wantBody:   accessDeniedError{Error: "insufficient permissions", Code: "RES004", RequiredPermissions: []string{"chat:read"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			denials = nil
+			handler := tt.middleware(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+			})
+
+			ctx := context.Background()
+			if tt.claims != nil {
+				ctx = WithUserClaims(ctx, tt.claims)
+			}
+			request := events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Path: "/v1/auth/admin/users"}
+			request.RequestContext.Identity.SourceIP = "192.0.2.1"
+
+			response, err := handler(ctx, request)
+			if err != nil {
+				t.Fatalf("handler: %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d (%s), want %d", response.StatusCode, response.Body, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if len(denials) != 0 {
+					t.Fatalf("denial hook called for an allowed request: %+v", denials[0])
+				}
+				return
+			}
+
+			var body accessDeniedError
+			if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+				t.Fatalf("Unmarshal(%s): %v", response.Body, err)
+			}
+			if body.Error != tt.wantBody.Error || body.Code != tt.wantBody.Code ||
+				!slices.Equal(body.RequiredRoles, tt.wantBody.RequiredRoles) ||
+				!slices.Equal(body.RequiredPermissions, tt.wantBody.RequiredPermissions) {
+				t.Fatalf("body = %+v, want %+v", body, tt.wantBody)
+			}
+
+			if len(denials) != 1 {
+				t.Fatalf("denial hook called %d times, want once", len(denials))
+			}
+			denial := denials[0]
+			if denial.Method != http.MethodGet || denial.Path != "/v1/auth/admin/users" || denial.SourceIP != "192.0.2.1" {
+				t.Fatalf("denial = %+v, want the request's method, path and IP", denial)
+			}
+			if tt.claims != nil && (denial.UserID != tt.claims.UserID || denial.SessionID != tt.claims.SessionID || !slices.Equal(denial.Roles, tt.claims.Roles)) {
+				t.Fatalf("denial = %+v, want the caller from %+v", denial, tt.claims)
+			}
+		})
+	}
+}
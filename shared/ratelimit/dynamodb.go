@@ -0,0 +1,126 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// maxWriteAttempts bounds optimistic-concurrency retries under contention
+const maxWriteAttempts = 5
+
+// DynamoDBAPI is the subset of the DynamoDB client used by DynamoDBStore
+type DynamoDBAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+// DynamoDBStore keeps token buckets in a DynamoDB table so limits hold across
+// Lambda instances. The table needs a string hash key named "key" and TTL on
+// "expires_at". Each write is conditional on the version it read, so concurrent
+// requests for the same key retry instead of overwriting each other.
+type DynamoDBStore struct {
+	client    DynamoDBAPI
+	tableName string
+}
+
+// NewDynamoDBStore creates a bucket store backed by tableName
+func NewDynamoDBStore(client DynamoDBAPI, tableName string) *DynamoDBStore {
+	return &DynamoDBStore{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	for attempt := 0; attempt < maxWriteAttempts; attempt++ {
+		current, version, err := s.get(ctx, key)
+		if err != nil {
+			return Result{}, err
+		}
+
+		updated, result := take(current, limit, now)
+
+		err = s.put(ctx, key, version, updated, now.Add(timeToFull(limit)))
+		if err == nil {
+			return result, nil
+		}
+
+		var condErr *types.ConditionalCheckFailedException
+		if !errors.As(err, &condErr) {
+			return Result{}, err
+		}
+	}
+
+	return Result{}, fmt.Errorf("rate limit bucket %q is too contended", key)
+}
+
+// get returns the stored bucket and its version; a missing bucket is (nil, 0)
+func (s *DynamoDBStore) get(ctx context.Context, key string) (*bucket, int64, error) {
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"key": &types.AttributeValueMemberS{Value: key},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get rate limit bucket: %w", err)
+	}
+
+	if len(out.Item) == 0 {
+		return nil, 0, nil
+	}
+
+	tokens, _ := strconv.ParseFloat(numberAttr(out.Item, "tokens"), 64)
+	updatedAt, _ := strconv.ParseInt(numberAttr(out.Item, "updated_at"), 10, 64)
+	version, _ := strconv.ParseInt(numberAttr(out.Item, "version"), 10, 64)
+
+	return &bucket{
+		Tokens:    tokens,
+		UpdatedAt: time.UnixMilli(updatedAt),
+	}, version, nil
+}
+
+func (s *DynamoDBStore) put(ctx context.Context, key string, version int64, updated bucket, expiresAt time.Time) error {
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item: map[string]types.AttributeValue{
+			"key":        &types.AttributeValueMemberS{Value: key},
+			"tokens":     &types.AttributeValueMemberN{Value: strconv.FormatFloat(updated.Tokens, 'f', -1, 64)},
+			"updated_at": &types.AttributeValueMemberN{Value: strconv.FormatInt(updated.UpdatedAt.UnixMilli(), 10)},
+			"version":    &types.AttributeValueMemberN{Value: strconv.FormatInt(version+1, 10)},
+			"expires_at": &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#key)"),
+		ExpressionAttributeNames: map[string]string{
+			"#key": "key",
+		},
+	}
+
+	if version > 0 {
+		input.ConditionExpression = aws.String("#version = :version")
+		input.ExpressionAttributeNames = map[string]string{
+			"#version": "version",
+		}
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":version": &types.AttributeValueMemberN{Value: strconv.FormatInt(version, 10)},
+		}
+	}
+
+	_, err := s.client.PutItem(ctx, input)
+	return err
+}
+
+func numberAttr(item map[string]types.AttributeValue, key string) string {
+	if v, ok := item[key].(*types.AttributeValueMemberN); ok {
+		return v.Value
+	}
+	return "0"
+}
@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval controls how often idle buckets are dropped from memory
+const sweepInterval = time.Minute
+
+// MemoryStore keeps token buckets in process memory. Limits are per instance,
+// so it suits the standalone server, tests and single-container deployments.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	bucket
+	idleAfter time.Duration
+}
+
+// NewMemoryStore creates an empty in-memory bucket store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*memoryBucket),
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	var current *bucket
+	if existing, ok := s.buckets[key]; ok {
+		current = &existing.bucket
+	}
+
+	updated, result := take(current, limit, now)
+	s.buckets[key] = &memoryBucket{bucket: updated, idleAfter: timeToFull(limit)}
+
+	return result, nil
+}
+
+// sweep drops buckets that have been idle long enough to be full again
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if now.Sub(b.UpdatedAt) > b.idleAfter {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit describes a token bucket: it refills at RequestsPerMinute and holds
+// at most Burst tokens. A Burst of zero or less defaults to RequestsPerMinute.
+type Limit struct {
+	RequestsPerMinute int
+	Burst             int
+}
+
+// Capacity returns the maximum number of tokens the bucket can hold
+func (l Limit) Capacity() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.RequestsPerMinute
+}
+
+// refillPerSecond returns how many tokens are added to the bucket each second
+func (l Limit) refillPerSecond() float64 {
+	return float64(l.RequestsPerMinute) / 60
+}
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // zero when Allowed
+}
+
+// Store persists token buckets. Implementations must make Take atomic per key.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// bucket is the persisted state of a single token bucket
+type bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// take refills b up to now and tries to remove one token, returning the
+// updated bucket and the result
+func take(b *bucket, limit Limit, now time.Time) (bucket, Result) {
+	capacity := float64(limit.Capacity())
+	rate := limit.refillPerSecond()
+
+	tokens := capacity
+	if b != nil {
+		elapsed := now.Sub(b.UpdatedAt).Seconds()
+		if elapsed < 0 {
+			elapsed = 0
+		}
+		tokens = math.Min(capacity, b.Tokens+elapsed*rate)
+	}
+
+	result := Result{Limit: limit.Capacity()}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else if rate > 0 {
+		wait := (1 - tokens) / rate
+		result.RetryAfter = time.Duration(math.Ceil(wait)) * time.Second
+	} else {
+		result.RetryAfter = time.Minute
+	}
+	result.Remaining = int(math.Floor(tokens))
+
+	return bucket{Tokens: tokens, UpdatedAt: now}, result
+}
+
+// timeToFull returns how long an empty bucket takes to refill completely,
+// after which its state is indistinguishable from a fresh bucket
+func timeToFull(limit Limit) time.Duration {
+	rate := limit.refillPerSecond()
+	if rate <= 0 {
+		return time.Hour
+	}
+	return time.Duration(float64(limit.Capacity())/rate*float64(time.Second)) + time.Second
+}
@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	type take struct {
+		at   time.Duration // after start
+		want Result
+	}
+
+	tests := []struct {
+		name  string
+		limit Limit
+		takes []take
+	}{
+		{
+			name:  "burst then refill",
+			limit: Limit{RequestsPerMinute: 60, Burst: 3},
+			takes: []take{
+				{at: 0, want: Result{Allowed: true, Limit: 3, Remaining: 2}},
+				{at: 0, want: Result{Allowed: true, Limit: 3, Remaining: 1}},
+				{at: 0, want: Result{Allowed: true, Limit: 3, Remaining: 0}},
+				{at: 0, want: Result{Limit: 3, RetryAfter: time.Second}},
+				{at: time.Second, want: Result{Allowed: true, Limit: 3, Remaining: 0}},
+			},
+		},
+		{
+			name:  "refill stops at capacity",
+			limit: Limit{RequestsPerMinute: 60, Burst: 2},
+			takes: []take{
+				{at: 0, want: Result{Allowed: true, Limit: 2, Remaining: 1}},
+				{at: time.Hour, want: Result{Allowed: true, Limit: 2, Remaining: 1}},
+			},
+		},
+		{
+			name:  "retry after rounds up",
+			limit: Limit{RequestsPerMinute: 30, Burst: 1},
+			takes: []take{
+				{at: 0, want: Result{Allowed: true, Limit: 1, Remaining: 0}},
+				{at: 500 * time.Millisecond, want: Result{Limit: 1, RetryAfter: 2 * time.Second}},
+			},
+		},
+		{
+			name:  "burst defaults to the rate",
+			limit: Limit{RequestsPerMinute: 2},
+			takes: []take{
+				{at: 0, want: Result{Allowed: true, Limit: 2, Remaining: 1}},
+				{at: 0, want: Result{Allowed: true, Limit: 2, Remaining: 0}},
+				{at: 0, want: Result{Limit: 2, RetryAfter: 30 * time.Second}},
+			},
+		},
+		{
+			name:  "no refill",
+			limit: Limit{Burst: 1},
+			takes: []take{
+				{at: 0, want: Result{Allowed: true, Limit: 1, Remaining: 0}},
+				{at: time.Hour, want: Result{Limit: 1, RetryAfter: time.Minute}},
+			},
+		},
+		{
+			name:  "clock going backwards adds nothing",
+			limit: Limit{RequestsPerMinute: 60, Burst: 1},
+			takes: []take{
+				{at: time.Minute, want: Result{Allowed: true, Limit: 1, Remaining: 0}},
+				{at: 0, want: Result{Limit: 1, RetryAfter: time.Second}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			for i, take := range tt.takes {
+				got, err := store.Take(context.Background(), "key", tt.limit, start.Add(take.at))
+				if err != nil {
+					t.Fatalf("take %d: %v", i, err)
+				}
+				if got != take.want {
+					t.Fatalf("take %d = %+v, want %+v", i, got, take.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreKeysAreSeparate(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	limit := Limit{RequestsPerMinute: 60, Burst: 1}
+	now := time.Now()
+
+	tests := []struct {
+		key         string
+		wantAllowed bool
+	}{
+		{key: "ip:192.0.2.1", wantAllowed: true},
+		{key: "ip:192.0.2.1", wantAllowed: false},
+		{key: "ip:192.0.2.2", wantAllowed: true},
+	}
+
+	for i, tt := range tests {
+		result, err := store.Take(ctx, tt.key, limit, now)
+		if err != nil {
+			t.Fatalf("take %d: %v", i, err)
+		}
+		if result.Allowed != tt.wantAllowed {
+			t.Fatalf("take %d for %s allowed = %v, want %v", i, tt.key, result.Allowed, tt.wantAllowed)
+		}
+	}
+}
+
+func TestMemoryStoreSweepsFullBuckets(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	limit := Limit{RequestsPerMinute: 60, Burst: 1}
+	start := time.Now()
+
+	if _, err := store.Take(ctx, "idle", limit, start); err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+	if _, err := store.Take(ctx, "other", limit, start.Add(timeToFull(limit)+sweepInterval)); err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+
+	if _, ok := store.buckets["idle"]; ok {
+		t.Fatal("idle bucket was not swept")
+	}
+}
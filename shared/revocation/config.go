@@ -0,0 +1,34 @@
+package revocation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	"github.com/multitask-platform/backend/shared/config"
+)
+
+// LoadChecker returns a Checker backed by the DYNAMODB_TABLE_AUTH_REVOCATIONS
+// table, or nil if the table isn't configured and revocation checks are off
+func LoadChecker(ctx context.Context, cfg *config.Config) (*Checker, error) {
+	if cfg.DynamoDB.AuthRevocations == "" {
+		return nil, nil
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(cfg.Region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	client := dynamodb.NewFromConfig(awsCfg, func(o *dynamodb.Options) {
+		if cfg.DynamoDB.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.DynamoDB.Endpoint)
+		}
+	})
+
+	store := NewDynamoDBStore(client, cfg.DynamoDB.AuthRevocations)
+	return NewChecker(store, cfg.Revocation.CacheTTL), nil
+}
@@ -0,0 +1,81 @@
+package revocation
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoDBAPI is the subset of the DynamoDB client used by DynamoDBStore
+type DynamoDBAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+// DynamoDBStore keeps revocations in a DynamoDB table so every service and
+// Lambda instance sees them. The table needs a string hash key named "key"
+// and TTL on "expires_at".
+type DynamoDBStore struct {
+	client    DynamoDBAPI
+	tableName string
+}
+
+// NewDynamoDBStore creates a revocation store backed by tableName
+func NewDynamoDBStore(client DynamoDBAPI, tableName string) *DynamoDBStore {
+	return &DynamoDBStore{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBStore) Revoke(ctx context.Context, key string, revokedAt, expiresAt time.Time) error {
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item: map[string]types.AttributeValue{
+			"key":        &types.AttributeValueMemberS{Value: key},
+			"revoked_at": &types.AttributeValueMemberN{Value: strconv.FormatInt(revokedAt.Unix(), 10)},
+			"expires_at": &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store revocation: %w", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBStore) RevokedAt(ctx context.Context, key string, now time.Time) (time.Time, error) {
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"key": &types.AttributeValueMemberS{Value: key},
+		},
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get revocation: %w", err)
+	}
+
+	if len(out.Item) == 0 {
+		return time.Time{}, nil
+	}
+
+	// TTL deletion can lag by hours, so check expiry explicitly
+	expiresAt, _ := strconv.ParseInt(numberAttr(out.Item, "expires_at"), 10, 64)
+	if now.Unix() > expiresAt {
+		return time.Time{}, nil
+	}
+
+	revokedAt, _ := strconv.ParseInt(numberAttr(out.Item, "revoked_at"), 10, 64)
+	return time.Unix(revokedAt, 0).UTC(), nil
+}
+
+func numberAttr(item map[string]types.AttributeValue, key string) string {
+	if v, ok := item[key].(*types.AttributeValueMemberN); ok {
+		return v.Value
+	}
+	return "0"
+}
@@ -0,0 +1,72 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval controls how often expired entries are dropped from memory
+const sweepInterval = time.Minute
+
+// MemoryStore keeps revocations in process memory. Revocations are only seen
+// by the instance that made them, so it suits the standalone server, tests
+// and single-container deployments.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+}
+
+type memoryEntry struct {
+	revokedAt time.Time
+	expiresAt time.Time
+}
+
+// NewMemoryStore creates an empty in-memory revocation store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]memoryEntry),
+	}
+}
+
+func (s *MemoryStore) Revoke(ctx context.Context, key string, revokedAt, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(revokedAt)
+
+	// Keep the longer expiry if the key was already revoked
+	if existing, ok := s.entries[key]; ok && existing.expiresAt.After(expiresAt) {
+		expiresAt = existing.expiresAt
+	}
+	s.entries[key] = memoryEntry{revokedAt: revokedAt, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *MemoryStore) RevokedAt(ctx context.Context, key string, now time.Time) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	entry, ok := s.entries[key]
+	if !ok || now.After(entry.expiresAt) {
+		return time.Time{}, nil
+	}
+	return entry.revokedAt, nil
+}
+
+// sweep drops entries that have expired
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, entry := range s.entries {
+		if now.After(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Package revocation keeps a denylist of sessions, tokens and users whose
+// access tokens must stop working before they expire. An entry records when
+// the revocation happened, so it rejects tokens issued up to that point and
+// leaves later ones (e.g. from a fresh login) alone.
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store persists revocations. Entries only need to outlive the tokens they
+// revoke, so stores may drop them once expiresAt has passed.
+type Store interface {
+	Revoke(ctx context.Context, key string, revokedAt, expiresAt time.Time) error
+	// RevokedAt returns when key was revoked, or the zero time if it wasn't
+	RevokedAt(ctx context.Context, key string, now time.Time) (time.Time, error)
+}
+
+// SessionKey is the denylist key for every token of a session
+func SessionKey(sessionID string) string {
+	return "session:" + sessionID
+}
+
+// TokenKey is the denylist key for a single token, by its jti
+func TokenKey(tokenID string) string {
+	return "jti:" + tokenID
+}
+
+// UserKey is the denylist key for every token issued to a user
+func UserKey(userID string) string {
+	return "user:" + userID
+}
+
+// Token is what an access token can be revoked by
+type Token struct {
+	UserID    string
+	SessionID string
+	TokenID   string
+	IssuedAt  time.Time
+}
+
+// keys returns the denylist keys that apply to the token
+func (t Token) keys() []string {
+	keys := make([]string, 0, 3)
+	if t.TokenID != "" {
+		keys = append(keys, TokenKey(t.TokenID))
+	}
+	if t.SessionID != "" {
+		keys = append(keys, SessionKey(t.SessionID))
+	}
+	if t.UserID != "" {
+		keys = append(keys, UserKey(t.UserID))
+	}
+	return keys
+}
+
+// Checker answers whether tokens have been revoked. Store lookups are cached
+// in process for cacheTTL so hot sessions don't hit the store on every
+// request; a revocation made elsewhere therefore takes up to cacheTTL to be
+// seen here, while ones made through this Checker apply immediately.
+type Checker struct {
+	store    Store
+	cacheTTL time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	cache     map[string]cacheEntry
+	lastSweep time.Time
+}
+
+type cacheEntry struct {
+	revokedAt time.Time
+	fetchedAt time.Time
+}
+
+// CheckerOption configures a Checker
+type CheckerOption func(*Checker)
+
+// WithClock overrides the time source (defaults to time.Now)
+func WithClock(now func() time.Time) CheckerOption {
+	return func(c *Checker) {
+		c.now = now
+	}
+}
+
+// NewChecker creates a Checker backed by store. A cacheTTL of zero disables
+// caching.
+func NewChecker(store Store, cacheTTL time.Duration, opts ...CheckerOption) *Checker {
+	c := &Checker{
+		store:    store,
+		cacheTTL: cacheTTL,
+		now:      time.Now,
+		cache:    make(map[string]cacheEntry),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Revoke denylists key for ttl, which should be the lifetime of the longest
+// token it needs to reject
+func (c *Checker) Revoke(ctx context.Context, key string, ttl time.Duration) error {
+	// Tokens carry iat in whole seconds, so truncate to compare like with like
+	now := c.now().UTC().Truncate(time.Second)
+
+	if err := c.store.Revoke(ctx, key, now, now.Add(ttl)); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.cache[key] = cacheEntry{revokedAt: now, fetchedAt: now}
+	c.mu.Unlock()
+
+	return nil
+}
+
+// IsRevoked reports whether any denylist entry covering the token was made at
+// or after the token was issued
+func (c *Checker) IsRevoked(ctx context.Context, token Token) (bool, error) {
+	for _, key := range token.keys() {
+		revokedAt, err := c.revokedAt(ctx, key)
+		if err != nil {
+			return false, err
+		}
+		if !revokedAt.IsZero() && !token.IssuedAt.After(revokedAt) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *Checker) revokedAt(ctx context.Context, key string) (time.Time, error) {
+	now := c.now()
+
+	c.mu.Lock()
+	c.sweep(now)
+	entry, ok := c.cache[key]
+	c.mu.Unlock()
+
+	if ok && now.Sub(entry.fetchedAt) < c.cacheTTL {
+		return entry.revokedAt, nil
+	}
+
+	revokedAt, err := c.store.RevokedAt(ctx, key, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if c.cacheTTL > 0 {
+		c.mu.Lock()
+		c.cache[key] = cacheEntry{revokedAt: revokedAt, fetchedAt: now}
+		c.mu.Unlock()
+	}
+
+	return revokedAt, nil
+}
+
+// sweep drops cache entries too old to be used. Callers must hold c.mu.
+func (c *Checker) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < time.Minute {
+		return
+	}
+	c.lastSweep = now
+
+	for key, entry := range c.cache {
+		if now.Sub(entry.fetchedAt) >= c.cacheTTL {
+			delete(c.cache, key)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// countingStore wraps a Store, counting lookups and failing them on demand
+type countingStore struct {
+	Store
+	lookups int
+	err     error
+}
+
+func (s *countingStore) RevokedAt(ctx context.Context, key string, now time.Time) (time.Time, error) {
+	s.lookups++
+	if s.err != nil {
+		return time.Time{}, s.err
+	}
+	return s.Store.RevokedAt(ctx, key, now)
+}
+
+func TestCheckerIsRevoked(t *testing.T) {
+	ctx := context.Background()
+	revokedAt := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		key   string
+		token Token
+		want  bool
+	}{
+		{name: "session issued before", key: SessionKey("session-1"), token: Token{UserID: "user-1", SessionID: "session-1", IssuedAt: revokedAt.Add(-time.Minute)}, want: true},
+		{name: "session issued in the same second", key: SessionKey("session-1"), token: Token{UserID: "user-1", SessionID: "session-1", IssuedAt: revokedAt}, want: true},
+		{name: "session issued after", key: SessionKey("session-1"), token: Token{UserID: "user-1", SessionID: "session-1", IssuedAt: revokedAt.Add(time.Second)}},
+		{name: "other session", key: SessionKey("session-1"), token: Token{UserID: "user-1", SessionID: "session-2", IssuedAt: revokedAt.Add(-time.Minute)}},
+		{name: "token", key: TokenKey("jti-1"), token: Token{UserID: "user-1", TokenID: "jti-1", IssuedAt: revokedAt.Add(-time.Minute)}, want: true},
+		{name: "other token", key: TokenKey("jti-1"), token: Token{UserID: "user-1", TokenID: "jti-2", IssuedAt: revokedAt.Add(-time.Minute)}},
+		{name: "user covers every session", key: UserKey("user-1"), token: Token{UserID: "user-1", SessionID: "session-2", TokenID: "jti-2", IssuedAt: revokedAt.Add(-time.Minute)}, want: true},
+		{name: "user after a fresh login", key: UserKey("user-1"), token: Token{UserID: "user-1", SessionID: "session-3", IssuedAt: revokedAt.Add(time.Minute)}},
+		{name: "client token without user or session", key: UserKey("user-1"), token: Token{TokenID: "jti-3", IssuedAt: revokedAt.Add(-time.Minute)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := revokedAt
+			checker := NewChecker(NewMemoryStore(), time.Minute, WithClock(func() time.Time { return now }))
+			if err := checker.Revoke(ctx, tt.key, time.Hour); err != nil {
+				t.Fatalf("Revoke: %v", err)
+			}
+
+			now = now.Add(time.Second)
+			got, err := checker.IsRevoked(ctx, tt.token)
+			if err != nil {
+				t.Fatalf("IsRevoked: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("IsRevoked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckerCache(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	token := Token{UserID: "user-1", SessionID: "session-1", IssuedAt: start.Add(-time.Minute)}
+
+	store := &countingStore{Store: NewMemoryStore()}
+	checker := NewChecker(store, 30*time.Second, WithClock(func() time.Time { return now }))
+
+	isRevoked := func() bool {
+		t.Helper()
+		revoked, err := checker.IsRevoked(ctx, token)
+		if err != nil {
+			t.Fatalf("IsRevoked: %v", err)
+		}
+		return revoked
+	}
+
+	if isRevoked() {
+		t.Fatal("token revoked before anything was revoked")
+	}
+	lookups := store.lookups
+
+	// Another instance revokes the session; this one keeps its cached answer
+	// until the entry is cacheTTL old
+	if err := store.Revoke(ctx, SessionKey("session-1"), start, start.Add(time.Hour)); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	now = start.Add(29 * time.Second)
+	if isRevoked() || store.lookups != lookups {
+		t.Fatalf("revoked = true or %d new lookups within the cache TTL, want the cached answer", store.lookups-lookups)
+	}
+	now = start.Add(30 * time.Second)
+	if !isRevoked() {
+		t.Fatal("revocation made elsewhere not seen once the cache expired")
+	}
+
+	// Revocations made through the Checker apply at once
+	other := Token{UserID: "user-2", SessionID: "session-2", IssuedAt: now.Add(-time.Minute)}
+	if revoked, err := checker.IsRevoked(ctx, other); err != nil || revoked {
+		t.Fatalf("IsRevoked() = %v, %v before revoking", revoked, err)
+	}
+	if err := checker.Revoke(ctx, UserKey("user-2"), time.Hour); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if revoked, err := checker.IsRevoked(ctx, other); err != nil || !revoked {
+		t.Fatalf("IsRevoked() = %v, %v right after revoking, want true", revoked, err)
+	}
+
+	// Without a cache every check reaches the store
+	uncached := NewChecker(store, 0, WithClock(func() time.Time { return now }))
+	lookups = store.lookups
+	for i := 0; i < 3; i++ {
+		if _, err := uncached.IsRevoked(ctx, Token{TokenID: "jti-1"}); err != nil {
+			t.Fatalf("IsRevoked: %v", err)
+		}
+	}
+	if store.lookups-lookups != 3 {
+		t.Fatalf("%d lookups without a cache, want 3", store.lookups-lookups)
+	}
+}
+
+func TestCheckerStoreError(t *testing.T) {
+	ctx := context.Background()
+	unavailable := errors.New("store unavailable")
+	store := &countingStore{Store: NewMemoryStore(), err: unavailable}
+	checker := NewChecker(store, time.Minute)
+
+	if _, err := checker.IsRevoked(ctx, Token{UserID: "user-1", IssuedAt: time.Now()}); !errors.Is(err, unavailable) {
+		t.Fatalf("IsRevoked() error = %v, want %v", err, unavailable)
+	}
+
+	// Failures aren't cached
+	store.err = nil
+	if _, err := checker.IsRevoked(ctx, Token{UserID: "user-1", IssuedAt: time.Now()}); err != nil {
+		t.Fatalf("IsRevoked() after recovery: %v", err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+
+	if err := store.Revoke(ctx, "short", start, start.Add(time.Minute)); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if err := store.Revoke(ctx, "long", start, start.Add(time.Hour)); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	// Revoking again moves revokedAt on but keeps the longer expiry
+	if err := store.Revoke(ctx, "long", start.Add(time.Second), start.Add(2*time.Minute)); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		at   time.Time
+		want time.Time
+	}{
+		{name: "unknown", key: "missing", at: start},
+		{name: "before expiry", key: "short", at: start.Add(time.Minute), want: start},
+		{name: "after expiry", key: "short", at: start.Add(time.Minute + time.Second)},
+		{name: "longer expiry kept", key: "long", at: start.Add(30 * time.Minute), want: start.Add(time.Second)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.RevokedAt(ctx, tt.key, tt.at)
+			if err != nil {
+				t.Fatalf("RevokedAt: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Fatalf("RevokedAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeDynamoDB is a single table keyed on "key" that never deletes expired
+// items, like DynamoDB before its TTL sweep catches up
+type fakeDynamoDB struct {
+	items map[string]map[string]types.AttributeValue
+	err   error
+}
+
+func (f *fakeDynamoDB) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	key := params.Key["key"].(*types.AttributeValueMemberS).Value
+	return &dynamodb.GetItemOutput{Item: f.items[key]}, nil
+}
+
+func (f *fakeDynamoDB) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	key := params.Item["key"].(*types.AttributeValueMemberS).Value
+	f.items[key] = params.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestDynamoDBStore(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	client := &fakeDynamoDB{items: make(map[string]map[string]types.AttributeValue)}
+	store := NewDynamoDBStore(client, "auth-revocations")
+
+	if err := store.Revoke(ctx, SessionKey("session-1"), start, start.Add(time.Hour)); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+
+	got, err := store.RevokedAt(ctx, SessionKey("session-1"), start.Add(time.Minute))
+	if err != nil || !got.Equal(start) {
+		t.Fatalf("RevokedAt() = %v, %v; want %v", got, err, start)
+	}
+	if got, err := store.RevokedAt(ctx, SessionKey("session-2"), start); err != nil || !got.IsZero() {
+		t.Fatalf("RevokedAt(unknown) = %v, %v; want the zero time", got, err)
+	}
+
+	// The item is still in the table but has expired
+	if got, err := store.RevokedAt(ctx, SessionKey("session-1"), start.Add(time.Hour+time.Second)); err != nil || !got.IsZero() {
+		t.Fatalf("RevokedAt() after expiry = %v, %v; want the zero time", got, err)
+	}
+
+	unavailable := errors.New("throttled")
+	client.err = unavailable
+	if _, err := store.RevokedAt(ctx, SessionKey("session-1"), start); !errors.Is(err, unavailable) {
+		t.Fatalf("RevokedAt() error = %v, want %v", err, unavailable)
+	}
+	if err := store.Revoke(ctx, SessionKey("session-1"), start, start.Add(time.Hour)); !errors.Is(err, unavailable) {
+		t.Fatalf("Revoke() error = %v, want %v", err, unavailable)
+	}
+}
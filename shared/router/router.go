@@ -0,0 +1,343 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// HandlerFunc is the API Gateway proxy handler shape used by every service
+type HandlerFunc func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// Middleware wraps a handler, e.g. middleware.AuthMiddleware
+type Middleware func(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// Router matches API Gateway proxy requests against method + path patterns.
+// Patterns are slash-separated; a segment written as {name} matches any single
+// non-empty segment and is exposed to the handler through PathParam.
+type Router struct {
+	root        *Group
+	basePath    string
+	routes      []*route
+	middlewares []Middleware
+}
+
+// Group registers routes under a shared path prefix and middleware stack
+type Group struct {
+	router      *Router
+	prefix      string
+	absolute    bool
+	middlewares []Middleware
+}
+
+type route struct {
+	method   string
+	pattern  string
+	absolute bool
+	segments []string
+	handler  HandlerFunc
+}
+
+// New creates a router. basePath (e.g. "/v1/auth") is stripped from incoming
+// request paths before matching, so routes are registered relative to it.
+// Requests outside basePath only match routes registered through Absolute.
+func New(basePath string) *Router {
+	basePath = CleanPath(basePath)
+	if basePath == "/" {
+		basePath = ""
+	}
+
+	r := &Router{basePath: basePath}
+	r.root = &Group{router: r}
+	return r
+}
+
+// Group creates a top-level route group
+func (r *Router) Group(prefix string, middlewares ...Middleware) *Group {
+	return r.root.Group(prefix, middlewares...)
+}
+
+// Absolute creates a group whose routes are matched against the full request
+// path rather than the path under the base path, for the few endpoints that
+// must live outside it such as /.well-known
+func (r *Router) Absolute(prefix string, middlewares ...Middleware) *Group {
+	g := r.root.Group(prefix, middlewares...)
+	g.absolute = true
+	return g
+}
+
+// Use adds middleware that runs for every request once the router has
+// resolved it, including requests answered with 404 or 405. Unlike group
+// middleware it can read the matched route through Pattern.
+func (r *Router) Use(middlewares ...Middleware) {
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// Handle registers handler for method and pattern
+func (r *Router) Handle(method, pattern string, handler HandlerFunc) {
+	r.root.Handle(method, pattern, handler)
+}
+
+// GET registers a GET route
+func (r *Router) GET(pattern string, handler HandlerFunc) {
+	r.root.GET(pattern, handler)
+}
+
+// POST registers a POST route
+func (r *Router) POST(pattern string, handler HandlerFunc) {
+	r.root.POST(pattern, handler)
+}
+
+// PUT registers a PUT route
+func (r *Router) PUT(pattern string, handler HandlerFunc) {
+	r.root.PUT(pattern, handler)
+}
+
+// PATCH registers a PATCH route
+func (r *Router) PATCH(pattern string, handler HandlerFunc) {
+	r.root.PATCH(pattern, handler)
+}
+
+// DELETE registers a DELETE route
+func (r *Router) DELETE(pattern string, handler HandlerFunc) {
+	r.root.DELETE(pattern, handler)
+}
+
+// Group creates a sub-group whose routes share prefix and run behind the
+// given middleware, in addition to any middleware of the parent group
+func (g *Group) Group(prefix string, middlewares ...Middleware) *Group {
+	return &Group{
+		router:      g.router,
+		prefix:      g.prefix + prefix,
+		absolute:    g.absolute,
+		middlewares: append(append([]Middleware(nil), g.middlewares...), middlewares...),
+	}
+}
+
+// Handle registers handler for method and pattern
+func (g *Group) Handle(method, pattern string, handler HandlerFunc) {
+	wrapped := handler
+	for i := len(g.middlewares) - 1; i >= 0; i-- {
+		wrapped = g.middlewares[i](wrapped)
+	}
+
+	full := g.prefix + pattern
+	if !g.absolute {
+		full = g.router.basePath + "/" + full
+	}
+
+	g.router.routes = append(g.router.routes, &route{
+		method:   method,
+		pattern:  CleanPath(full),
+		absolute: g.absolute,
+		segments: splitPath(g.prefix + pattern),
+		handler:  wrapped,
+	})
+}
+
+// GET registers a GET route
+func (g *Group) GET(pattern string, handler HandlerFunc) {
+	g.Handle(http.MethodGet, pattern, handler)
+}
+
+// POST registers a POST route
+func (g *Group) POST(pattern string, handler HandlerFunc) {
+	g.Handle(http.MethodPost, pattern, handler)
+}
+
+// PUT registers a PUT route
+func (g *Group) PUT(pattern string, handler HandlerFunc) {
+	g.Handle(http.MethodPut, pattern, handler)
+}
+
+// PATCH registers a PATCH route
+func (g *Group) PATCH(pattern string, handler HandlerFunc) {
+	g.Handle(http.MethodPatch, pattern, handler)
+}
+
+// DELETE registers a DELETE route
+func (g *Group) DELETE(pattern string, handler HandlerFunc) {
+	g.Handle(http.MethodDelete, pattern, handler)
+}
+
+// ServeProxy dispatches the request to the matching route. Unknown paths,
+// including paths outside the base path that no absolute route covers, get
+// 404; known paths called with an unregistered method get 405 with an Allow
+// header listing the methods that are registered.
+func (r *Router) ServeProxy(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	path := CleanPath(request.Path)
+	absolute := splitPath(path)
+	relative, underBase := r.relativePath(path)
+
+	var (
+		best       *route
+		bestParams map[string]string
+		bestScore  = -1
+		allowed    = make(map[string]bool)
+	)
+
+	for _, rt := range r.routes {
+		segments := absolute
+		if !rt.absolute {
+			if !underBase {
+				continue
+			}
+			segments = relative
+		}
+
+		params, score, ok := rt.match(segments)
+		if !ok {
+			continue
+		}
+		allowed[rt.method] = true
+		if rt.method == request.HTTPMethod && score > bestScore {
+			best, bestParams, bestScore = rt, params, score
+		}
+	}
+
+	var handler HandlerFunc
+	switch {
+	case best != nil:
+		handler = best.handler
+		ctx = context.WithValue(ctx, patternKey, best.pattern)
+		if len(bestParams) > 0 {
+			request.PathParameters = bestParams
+			ctx = context.WithValue(ctx, pathParamsKey, bestParams)
+		}
+	case len(allowed) == 0:
+		handler = errorHandler(http.StatusNotFound, "endpoint not found", nil)
+	default:
+		methods := make([]string, 0, len(allowed))
+		for method := range allowed {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+
+		handler = errorHandler(http.StatusMethodNotAllowed, "method not allowed", map[string]string{
+			"Allow": strings.Join(methods, ", "),
+		})
+	}
+
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		handler = r.middlewares[i](handler)
+	}
+
+	return handler(ctx, request)
+}
+
+// relativePath returns the segments of path below the base path, and false
+// if path is outside it
+func (r *Router) relativePath(path string) ([]string, bool) {
+	if r.basePath == "" {
+		return splitPath(path), true
+	}
+	if path != r.basePath && !strings.HasPrefix(path, r.basePath+"/") {
+		return nil, false
+	}
+	return splitPath(path[len(r.basePath):]), true
+}
+
+// match reports whether the route matches the request segments. score counts
+// literal segments so that /sessions/current beats /sessions/{id}.
+func (rt *route) match(segments []string) (map[string]string, int, bool) {
+	if len(segments) != len(rt.segments) {
+		return nil, 0, false
+	}
+
+	var params map[string]string
+	score := 0
+	for i, seg := range rt.segments {
+		if name, ok := paramName(seg); ok {
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[name] = segments[i]
+			continue
+		}
+		if seg != segments[i] {
+			return nil, 0, false
+		}
+		score++
+	}
+
+	return params, score, true
+}
+
+// Context keys
+type contextKeyType string
+
+const (
+	pathParamsKey contextKeyType = "path_params"
+	patternKey    contextKeyType = "pattern"
+)
+
+// PathParam returns the value of the named path parameter, or "" if the
+// matched route has no such parameter
+func PathParam(ctx context.Context, name string) string {
+	if params, ok := ctx.Value(pathParamsKey).(map[string]string); ok {
+		return params[name]
+	}
+	return ""
+}
+
+// Pattern returns the full pattern of the route the request was resolved to,
+// base path included (e.g. "/v1/auth/sessions/{id}"), or "" if no route
+// matched. It is only set for handlers and middleware the router runs.
+func Pattern(ctx context.Context) string {
+	pattern, _ := ctx.Value(patternKey).(string)
+	return pattern
+}
+
+// CleanPath returns path in the form the router matches it: without empty
+// segments or a trailing slash, so "/v1/auth//login/" becomes "/v1/auth/login"
+func CleanPath(path string) string {
+	return "/" + strings.Join(splitPath(path), "/")
+}
+
+// Helper functions
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+
+	segments := strings.Split(path, "/")
+	result := segments[:0]
+	for _, seg := range segments {
+		if seg != "" {
+			result = append(result, seg)
+		}
+	}
+	return result
+}
+
+func paramName(segment string) (string, bool) {
+	if len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
+
+func errorHandler(statusCode int, message string, headers map[string]string) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return jsonError(statusCode, message, headers), nil
+	}
+}
+
+func jsonError(statusCode int, message string, headers map[string]string) events.APIGatewayProxyResponse {
+	response := events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+		Body: `{"error":"` + message + `"}`,
+	}
+	for key, value := range headers {
+		response.Headers[key] = value
+	}
+	return response
+}
@@ -0,0 +1,178 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// respond answers with the name of the route and the given path parameters
+func respond(name string, params ...string) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		body := name
+		for _, param := range params {
+			body += " " + param + "=" + PathParam(ctx, param)
+		}
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: body}, nil
+	}
+}
+
+// tag adds a header naming the middleware, so tests can see which ran
+func tag(name string) Middleware {
+	return func(next func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			response, err := next(ctx, request)
+			if response.Headers == nil {
+				response.Headers = make(map[string]string)
+			}
+			response.Headers["X-Middleware"] = name + response.Headers["X-Middleware"]
+			return response, err
+		}
+	}
+}
+
+func newTestRouter() *Router {
+	r := New("/v1/auth/")
+	r.POST("/login", respond("login"))
+	r.GET("/health", respond("health"))
+	r.GET("", respond("root"))
+
+	sessions := r.Group("/sessions", tag("a"))
+	sessions.GET("", respond("sessions"))
+	sessions.GET("/current", respond("current"))
+	sessions.DELETE("/{id}", respond("revoke", "id"))
+
+	admin := sessions.Group("/{id}/users", tag("b"))
+	admin.PUT("/{user}", respond("user", "id", "user"))
+
+	r.Absolute("/.well-known").GET("/jwks.json", respond("jwks"))
+	return r
+}
+
+func TestRouterServeProxy(t *testing.T) {
+	r := newTestRouter()
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		wantStatus     int
+		wantBody       string
+		wantMiddleware string
+		wantAllow      string
+	}{
+		{name: "literal", method: http.MethodPost, path: "/v1/auth/login", wantStatus: http.StatusOK, wantBody: "login"},
+		{name: "base path itself", method: http.MethodGet, path: "/v1/auth", wantStatus: http.StatusOK, wantBody: "root"},
+		{name: "empty segments", method: http.MethodPost, path: "/v1/auth//login/", wantStatus: http.StatusOK, wantBody: "login"},
+		{name: "path param", method: http.MethodDelete, path: "/v1/auth/sessions/s-1", wantStatus: http.StatusOK, wantBody: "revoke id=s-1", wantMiddleware: "a"},
+		{name: "literal beats param", method: http.MethodGet, path: "/v1/auth/sessions/current", wantStatus: http.StatusOK, wantBody: "current", wantMiddleware: "a"},
+		{name: "group prefix alone", method: http.MethodGet, path: "/v1/auth/sessions", wantStatus: http.StatusOK, wantBody: "sessions", wantMiddleware: "a"},
+		{name: "nested group", method: http.MethodPut, path: "/v1/auth/sessions/s-1/users/u-1", wantStatus: http.StatusOK, wantBody: "user id=s-1 user=u-1", wantMiddleware: "ab"},
+		{name: "absolute route", method: http.MethodGet, path: "/.well-known/jwks.json", wantStatus: http.StatusOK, wantBody: "jwks"},
+		{name: "absolute route under the base path", method: http.MethodGet, path: "/v1/auth/.well-known/jwks.json", wantStatus: http.StatusNotFound},
+		{name: "unknown path", method: http.MethodGet, path: "/v1/auth/unknown", wantStatus: http.StatusNotFound},
+		{name: "too many segments", method: http.MethodDelete, path: "/v1/auth/sessions/s-1/extra", wantStatus: http.StatusNotFound},
+		{name: "outside the base path", method: http.MethodPost, path: "/login", wantStatus: http.StatusNotFound},
+		{name: "base path without separator", method: http.MethodPost, path: "/v1/authlogin", wantStatus: http.StatusNotFound},
+		{name: "other service", method: http.MethodGet, path: "/v1/profile/health", wantStatus: http.StatusNotFound},
+		{name: "wrong method", method: http.MethodGet, path: "/v1/auth/login", wantStatus: http.StatusMethodNotAllowed, wantAllow: "POST"},
+		{name: "wrong method on a shared path", method: http.MethodPost, path: "/v1/auth/sessions/current", wantStatus: http.StatusMethodNotAllowed, wantAllow: "DELETE, GET"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := r.ServeProxy(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: tt.method, Path: tt.path})
+			if err != nil {
+				t.Fatalf("ServeProxy: %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d (%s), want %d", response.StatusCode, response.Body, tt.wantStatus)
+			}
+			if tt.wantBody != "" && response.Body != tt.wantBody {
+				t.Fatalf("body = %q, want %q", response.Body, tt.wantBody)
+			}
+			if got := response.Headers["X-Middleware"]; got != tt.wantMiddleware {
+				t.Fatalf("middleware = %q, want %q", got, tt.wantMiddleware)
+			}
+			if got := response.Headers["Allow"]; got != tt.wantAllow {
+				t.Fatalf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+		})
+	}
+}
+
+func TestRouterUse(t *testing.T) {
+	r := newTestRouter()
+
+	var pattern string
+	r.Use(func(next func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			pattern = Pattern(ctx)
+			return next(ctx, request)
+		}
+	}, tag("x"))
+
+	tests := []struct {
+		method         string
+		path           string
+		wantPattern    string
+		wantMiddleware string
+	}{
+		{method: http.MethodPost, path: "/v1/auth//login", wantPattern: "/v1/auth/login", wantMiddleware: "x"},
+		{method: http.MethodGet, path: "/v1/auth", wantPattern: "/v1/auth", wantMiddleware: "x"},
+		{method: http.MethodDelete, path: "/v1/auth/sessions/s-1", wantPattern: "/v1/auth/sessions/{id}", wantMiddleware: "xa"},
+		{method: http.MethodPut, path: "/v1/auth/sessions/s-1/users/u-1", wantPattern: "/v1/auth/sessions/{id}/users/{user}", wantMiddleware: "xab"},
+		{method: http.MethodGet, path: "/.well-known/jwks.json", wantPattern: "/.well-known/jwks.json", wantMiddleware: "x"},
+		// Unmatched requests still pass through, without a pattern
+		{method: http.MethodPost, path: "/login", wantPattern: "", wantMiddleware: "x"},
+		{method: http.MethodGet, path: "/v1/auth/login", wantPattern: "", wantMiddleware: "x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			pattern = "unset"
+			response, err := r.ServeProxy(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: tt.method, Path: tt.path})
+			if err != nil {
+				t.Fatalf("ServeProxy: %v", err)
+			}
+			if pattern != tt.wantPattern {
+				t.Fatalf("Pattern() = %q, want %q", pattern, tt.wantPattern)
+			}
+			if got := response.Headers["X-Middleware"]; got != tt.wantMiddleware {
+				t.Fatalf("middleware = %q, want %q", got, tt.wantMiddleware)
+			}
+		})
+	}
+}
+
+func TestRouterWithoutBasePath(t *testing.T) {
+	r := New("")
+	r.GET("/health", respond("health"))
+
+	response, err := r.ServeProxy(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Path: "/health"})
+	if err != nil {
+		t.Fatalf("ServeProxy: %v", err)
+	}
+	if response.StatusCode != http.StatusOK || response.Body != "health" {
+		t.Fatalf("response = %d %q, want 200 health", response.StatusCode, response.Body)
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	tests := map[string]string{
+		"":                  "/",
+		"/":                 "/",
+		"/v1/auth/login":    "/v1/auth/login",
+		"/v1/auth//login/":  "/v1/auth/login",
+		"v1/auth/login":     "/v1/auth/login",
+		"//v1///auth/login": "/v1/auth/login",
+	}
+
+	for path, want := range tests {
+		if got := CleanPath(path); got != want {
+			t.Errorf("CleanPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+// maxBodyBytes mirrors the API Gateway/Lambda synchronous payload limit
+const maxBodyBytes = 6 << 20
+
+// HandlerFunc is the API Gateway proxy handler shape used by every service
+type HandlerFunc func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// Adapter serves a HandlerFunc over net/http by translating each request into
+// an APIGatewayProxyRequest and the proxy response back onto the wire
+type Adapter struct {
+	handler        HandlerFunc
+	stage          string
+	trustedProxies []*net.IPNet
+}
+
+// NewAdapter wraps handler for use with net/http. stage is reported as the
+// API Gateway stage in the request context. X-Forwarded-For is only believed
+// on requests from trustedProxies.
+func NewAdapter(handler HandlerFunc, stage string, trustedProxies []*net.IPNet) *Adapter {
+	return &Adapter{
+		handler:        handler,
+		stage:          stage,
+		trustedProxies: trustedProxies,
+	}
+}
+
+// ParseTrustedProxies parses a list of IP addresses and CIDR ranges
+func ParseTrustedProxies(list []string) ([]*net.IPNet, error) {
+	proxies := make([]*net.IPNet, 0, len(list))
+	for _, item := range list {
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", item, err)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// ServeHTTP implements http.Handler
+func (a *Adapter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	request, err := NewProxyRequest(r, a.stage, a.trustedProxies)
+	if err != nil {
+		logger.WarnCtx(r.Context(), "Failed to read HTTP request", zap.Error(err))
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	response, err := a.handler(r.Context(), request)
+	if err != nil {
+		logger.ErrorCtx(r.Context(), "Handler returned error", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	if err := WriteProxyResponse(w, response); err != nil {
+		logger.WarnCtx(r.Context(), "Failed to write HTTP response", zap.Error(err))
+	}
+}
+
+// NewProxyRequest converts an HTTP request into the API Gateway proxy event
+// shape, taking the source IP from X-Forwarded-For only if the request came
+// through one of trustedProxies
+func NewProxyRequest(r *http.Request, stage string, trustedProxies []*net.IPNet) (events.APIGatewayProxyRequest, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodyBytes))
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+
+	request := events.APIGatewayProxyRequest{
+		Resource:                        r.URL.Path,
+		Path:                            r.URL.Path,
+		HTTPMethod:                      r.Method,
+		Headers:                         make(map[string]string, len(r.Header)),
+		MultiValueHeaders:               make(map[string][]string, len(r.Header)),
+		QueryStringParameters:           make(map[string]string),
+		MultiValueQueryStringParameters: make(map[string][]string),
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID:        uuid.New().String(),
+			Stage:            stage,
+			Path:             r.URL.Path,
+			HTTPMethod:       r.Method,
+			Protocol:         r.Proto,
+			RequestTimeEpoch: time.Now().UnixMilli(),
+			Identity: events.APIGatewayRequestIdentity{
+				SourceIP:  sourceIP(r, trustedProxies),
+				UserAgent: r.UserAgent(),
+			},
+		},
+	}
+
+	for key, values := range r.Header {
+		request.Headers[key] = strings.Join(values, ",")
+		request.MultiValueHeaders[key] = values
+	}
+	if r.Host != "" {
+		request.Headers["Host"] = r.Host
+	}
+
+	for key, values := range r.URL.Query() {
+		request.QueryStringParameters[key] = values[len(values)-1]
+		request.MultiValueQueryStringParameters[key] = values
+	}
+
+	if utf8.Valid(body) {
+		request.Body = string(body)
+	} else {
+		request.Body = base64.StdEncoding.EncodeToString(body)
+		request.IsBase64Encoded = true
+	}
+
+	return request, nil
+}
+
+// WriteProxyResponse writes an API Gateway proxy response to w
+func WriteProxyResponse(w http.ResponseWriter, response events.APIGatewayProxyResponse) error {
+	for key, value := range response.Headers {
+		w.Header().Set(key, value)
+	}
+	for key, values := range response.MultiValueHeaders {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+
+	body := []byte(response.Body)
+	if response.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(response.Body)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return err
+		}
+		body = decoded
+	}
+
+	statusCode := response.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+
+	w.WriteHeader(statusCode)
+	_, err := w.Write(body)
+	return err
+}
+
+// sourceIP is the address the connection came from. When that is a trusted
+// proxy, it is instead the right-most X-Forwarded-For hop that isn't one:
+// hops to the left of it were added by the client and can't be believed.
+func sourceIP(r *http.Request, trustedProxies []*net.IPNet) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	if !isTrustedProxy(remote, trustedProxies) {
+		return remote
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !isTrustedProxy(hop, trustedProxies) {
+			return hop
+		}
+		remote = hop
+	}
+	return remote
+}
+
+// isTrustedProxy reports whether addr is in one of trustedProxies
+func isTrustedProxy(addr string, trustedProxies []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write([]byte(`{"error":"` + message + `"}`))
+}
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+func TestMain(m *testing.M) {
+	if err := logger.Initialize(zap.NewAtomicLevelAt(zap.FatalLevel), false); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestNewProxyRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "http://api.example.com/v1/auth/login?device=web&scope=a&scope=b", strings.NewReader(`{"email":"jane@example.com"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Add("Accept", "text/html")
+	r.Header.Add("Accept", "application/json")
+	r.Header.Set("User-Agent", "test-agent")
+
+	request, err := NewProxyRequest(r, "dev", nil)
+	if err != nil {
+		t.Fatalf("NewProxyRequest: %v", err)
+	}
+
+	if request.HTTPMethod != http.MethodPost || request.Path != "/v1/auth/login" || request.RequestContext.Stage != "dev" {
+		t.Fatalf("request = %s %s on stage %q", request.HTTPMethod, request.Path, request.RequestContext.Stage)
+	}
+	if request.RequestContext.RequestID == "" || request.RequestContext.Identity.UserAgent != "test-agent" {
+		t.Fatalf("request context = %+v, want a request ID and the user agent", request.RequestContext)
+	}
+
+	wantHeaders := map[string]string{
+		"Content-Type": "application/json",
+		"Accept":       "text/html,application/json",
+		"Host":         "api.example.com",
+	}
+	for key, value := range wantHeaders {
+		if got := request.Headers[key]; got != value {
+			t.Errorf("header %s = %q, want %q", key, got, value)
+		}
+	}
+	if got := request.MultiValueHeaders["Accept"]; !slices.Equal(got, []string{"text/html", "application/json"}) {
+		t.Errorf("multi-value Accept = %v", got)
+	}
+
+	// The single-value map keeps the last of repeated parameters, as API
+	// Gateway does
+	if request.QueryStringParameters["device"] != "web" || request.QueryStringParameters["scope"] != "b" {
+		t.Errorf("query = %v", request.QueryStringParameters)
+	}
+	if got := request.MultiValueQueryStringParameters["scope"]; !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("multi-value scope = %v", got)
+	}
+
+	if request.Body != `{"email":"jane@example.com"}` || request.IsBase64Encoded {
+		t.Errorf("body = %q (base64 %v), want the text as is", request.Body, request.IsBase64Encoded)
+	}
+}
+
+func TestNewProxyRequestBody(t *testing.T) {
+	binary := []byte{0xff, 0xfe, 0x00, 0x01}
+
+	tests := []struct {
+		name       string
+		body       []byte
+		wantBody   string
+		wantBase64 bool
+		wantErr    bool
+	}{
+		{name: "empty", body: nil, wantBody: ""},
+		{name: "text", body: []byte("héllo"), wantBody: "héllo"},
+		{name: "binary", body: binary, wantBody: base64.StdEncoding.EncodeToString(binary), wantBase64: true},
+		{name: "over the payload limit", body: bytes.Repeat([]byte("a"), maxBodyBytes+1), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader(tt.body))
+
+			request, err := NewProxyRequest(r, "dev", nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("NewProxyRequest() succeeded")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewProxyRequest: %v", err)
+			}
+			if request.Body != tt.wantBody || request.IsBase64Encoded != tt.wantBase64 {
+				t.Fatalf("body = %q (base64 %v), want %q (base64 %v)", request.Body, request.IsBase64Encoded, tt.wantBody, tt.wantBase64)
+			}
+		})
+	}
+}
+
+func TestSourceIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		wantSourceIP string
+	}{
+		{name: "direct", remoteAddr: "203.0.113.7:1234", wantSourceIP: "203.0.113.7"},
+		{name: "forwarded by an untrusted peer", remoteAddr: "203.0.113.7:1234", forwardedFor: []string{"198.51.100.1"}, wantSourceIP: "203.0.113.7"},
+		{name: "forwarded by a trusted proxy", remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"198.51.100.1"}, wantSourceIP: "198.51.100.1"},
+		{name: "client-supplied hops ignored", remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"1.2.3.4, 198.51.100.1"}, wantSourceIP: "198.51.100.1"},
+		{name: "chain of trusted proxies", remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"198.51.100.1, 192.0.2.1", "10.9.9.9"}, wantSourceIP: "198.51.100.1"},
+		{name: "only trusted hops", remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"10.4.4.4"}, wantSourceIP: "10.4.4.4"},
+		{name: "trusted proxy without the header", remoteAddr: "10.1.2.3:1234", wantSourceIP: "10.1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			request, err := NewProxyRequest(r, "dev", trusted)
+			if err != nil {
+				t.Fatalf("NewProxyRequest: %v", err)
+			}
+			if got := request.RequestContext.Identity.SourceIP; got != tt.wantSourceIP {
+				t.Fatalf("source IP = %q, want %q", got, tt.wantSourceIP)
+			}
+		})
+	}
+
+	if _, err := ParseTrustedProxies([]string{"not-an-ip"}); err == nil {
+		t.Fatal("ParseTrustedProxies() accepted an invalid address")
+	}
+	if _, err := ParseTrustedProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Fatal("ParseTrustedProxies() accepted an invalid range")
+	}
+}
+
+func TestWriteProxyResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		response   events.APIGatewayProxyResponse
+		wantStatus int
+		wantBody   string
+		wantErr    bool
+	}{
+		{name: "status and body", response: events.APIGatewayProxyResponse{StatusCode: http.StatusCreated, Body: `{"id":"1"}`}, wantStatus: http.StatusCreated, wantBody: `{"id":"1"}`},
+		{name: "no status", response: events.APIGatewayProxyResponse{Body: "ok"}, wantStatus: http.StatusOK, wantBody: "ok"},
+		{name: "base64 body", response: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: base64.StdEncoding.EncodeToString([]byte{0xff, 0x00}), IsBase64Encoded: true}, wantStatus: http.StatusOK, wantBody: "\xff\x00"},
+		{name: "invalid base64 body", response: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: "!!!", IsBase64Encoded: true}, wantStatus: http.StatusInternalServerError, wantBody: `{"error":"internal server error"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			err := WriteProxyResponse(w, tt.response)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WriteProxyResponse() error = %v, want error %v", err, tt.wantErr)
+			}
+			if w.Code != tt.wantStatus || w.Body.String() != tt.wantBody {
+				t.Fatalf("response = %d %q, want %d %q", w.Code, w.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+
+	// Both header maps are written, with repeated headers kept apart
+	w := httptest.NewRecorder()
+	err := WriteProxyResponse(w, events.APIGatewayProxyResponse{
+		StatusCode:        http.StatusOK,
+		Headers:           map[string]string{"Content-Type": "application/json"},
+		MultiValueHeaders: map[string][]string{"Set-Cookie": {"a=1", "b=2"}},
+	})
+	if err != nil {
+		t.Fatalf("WriteProxyResponse: %v", err)
+	}
+	if w.Header().Get("Content-Type") != "application/json" || !slices.Equal(w.Header().Values("Set-Cookie"), []string{"a=1", "b=2"}) {
+		t.Fatalf("headers = %v", w.Header())
+	}
+}
+
+func TestAdapter(t *testing.T) {
+	adapter := NewAdapter(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		if request.Path == "/fail" {
+			return events.APIGatewayProxyResponse{}, errors.New("boom")
+		}
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusAccepted,
+			Headers:    map[string]string{"X-Method": request.HTTPMethod},
+			Body:       request.QueryStringParameters["q"] + ":" + request.Body,
+		}, nil
+	}, "dev", nil)
+	server := httptest.NewServer(adapter)
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "round trip", path: "/echo?q=hi", wantStatus: http.StatusAccepted, wantBody: "hi:payload"},
+		{name: "handler error", path: "/fail", wantStatus: http.StatusInternalServerError, wantBody: `{"error":"internal server error"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(server.URL+tt.path, "text/plain", strings.NewReader("payload"))
+			if err != nil {
+				t.Fatalf("Post: %v", err)
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("ReadAll: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus || string(body) != tt.wantBody {
+				t.Fatalf("response = %d %q, want %d %q", resp.StatusCode, body, tt.wantStatus, tt.wantBody)
+			}
+			if resp.StatusCode == http.StatusAccepted && resp.Header.Get("X-Method") != http.MethodPost {
+				t.Fatalf("X-Method = %q, want %s", resp.Header.Get("X-Method"), http.MethodPost)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/multitask-platform/backend/shared/config"
+	"github.com/multitask-platform/backend/shared/logger"
+)
+
+// shutdownTimeout bounds how long in-flight requests get to finish on SIGTERM
+const shutdownTimeout = 15 * time.Second
+
+// ListenAndServe runs handler as a standalone HTTP server on addr until the
+// process receives SIGINT or SIGTERM, then drains in-flight requests
+func ListenAndServe(ctx context.Context, addr string, handler HandlerFunc, cfg *config.Config) error {
+	trustedProxies, err := ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return err
+	}
+	adapter := NewAdapter(handler, cfg.Stage, trustedProxies)
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           adapter,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       cfg.Timeouts.HTTPTimeout,
+		WriteTimeout:      cfg.Timeouts.HTTPTimeout,
+		IdleTimeout:       2 * cfg.Timeouts.HTTPTimeout,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		logger.Info("HTTP server listening", zap.String("addr", addr))
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("Shutting down HTTP server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+
+	logger.Info("HTTP server stopped")
+	return nil
+}